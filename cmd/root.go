@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/worlpaker/gitty/gitty"
@@ -40,11 +41,27 @@ func runRoot(ctx context.Context, f *flags, g gitty.Gitty) func(cmd *cobra.Comma
 		case len(args) < nArgs:
 			return cmd.Help()
 		default:
-			return g.Download(ctx, args[0])
+			return download(ctx, g, args[0])
 		}
 	}
 }
 
+// download downloads the contents from the given URL and reports its start,
+// its completion and the elapsed time.
+func download(ctx context.Context, g gitty.Gitty, url string) error {
+	fmt.Println("Downloading:", url)
+	start := time.Now()
+
+	if err := g.Download(ctx, url); err != nil {
+		return err
+	}
+
+	fmt.Println("Download Completed")
+	fmt.Println(time.Since(start))
+
+	return nil
+}
+
 // Execute executes the root command.
 func Execute(ctx context.Context, version string) error {
 	g := gitty.New()
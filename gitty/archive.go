@@ -0,0 +1,148 @@
+package gitty
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// archive lists the files under the GitHub path from the repository tree and
+// filters and checks them like those listed otherwise, before anything is
+// downloaded. It then downloads the repository tarball with a single request
+// and extracts the selected files as the tarball is streamed, without saving
+// the tarball itself. Files missing from the tarball, such as those excluded
+// by export-ignore attributes, are downloaded one by one instead. It returns
+// the downloaded files.
+func (g *GitHub) archive(ctx context.Context) ([]*github.RepositoryContent, error) {
+	entries, err := g.listTree(ctx, g.Path)
+	if err != nil {
+		return nil, err
+	}
+	files, links, err := g.filterEntries(ctx, entries)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.checkMinFiles(files, links); err != nil {
+		return nil, err
+	}
+
+	link, _, err := g.Client.GetArchiveLink(ctx, g.Owner, g.Repo, github.Tarball, g.Ref, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+
+	resp, err := g.Client.Get(ctx, link.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download: %w", newHTTPError(resp))
+	}
+
+	missing, err := g.extractTarball(ctx, resp.Body, files, links)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	if len(missing) > 0 {
+		if err := g.fetch(ctx, missing); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.materialize(ctx, links, files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// extractTarball extracts the given files under the GitHub path from a
+// gzipped repository tarball and sets the targets of the given symlinks from
+// it. It returns the files missing from the tarball.
+func (g *GitHub) extractTarball(ctx context.Context, r io.Reader, files, links []*github.RepositoryContent) ([]*github.RepositoryContent, error) {
+	extract := make(map[string]bool, len(files))
+	for _, file := range files {
+		extract[file.GetPath()] = true
+	}
+	targets := make(map[string]*github.RepositoryContent, len(links))
+	for _, link := range links {
+		targets[link.GetPath()] = link
+	}
+
+	// The meter is set on a copy of the options, as when fetching the files.
+	o := g.opts
+	if o.progress != nil {
+		o.meter = newMeter(o, estimateSize(files), len(files))
+	}
+
+	err := walkTarball(g.Path, r, func(path string, hdr *tar.Header, content io.Reader) error {
+		switch {
+		case hdr.Typeflag == tar.TypeSymlink && targets[path] != nil:
+			targets[path].Target = github.Ptr(hdr.Linkname)
+		case hdr.Typeflag == tar.TypeReg && extract[path]:
+			delete(extract, path)
+			if err := g.saveBody(ctx, o, path, content); err != nil {
+				return err
+			}
+			o.meter.done(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o.meter != nil {
+		o.meter.finish()
+	}
+
+	var missing []*github.RepositoryContent
+	for _, file := range files {
+		if extract[file.GetPath()] {
+			missing = append(missing, file)
+		}
+	}
+	return missing, nil
+}
+
+// walkTarball calls fn with the repository path, header, and content of each
+// entry under base in a gzipped repository tarball.
+func walkTarball(base string, r io.Reader, fn func(path string, hdr *tar.Header, content io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, path, ok := strings.Cut(hdr.Name, "/")
+		if !ok || !underPath(base, path) {
+			continue
+		}
+		if err := fn(path, hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// underPath reports whether the repository path is base or lies beneath it.
+func underPath(base, path string) bool {
+	return base == "" || path == base || strings.HasPrefix(path, base+"/")
+}
@@ -0,0 +1,463 @@
+package gitty
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tarball creates a gzipped repository tarball of the given files and
+// symlinks to their targets, keyed by repository path, under the top-level
+// directory of an archive. Files starting with a shebang are executable.
+func tarball(t *testing.T, files, links map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header"})
+	require.NoError(t, err)
+	err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "owner-repo-abc1234/", Mode: 0o755})
+	require.NoError(t, err)
+	for path, content := range files {
+		mode := int64(0o644)
+		if strings.HasPrefix(content, "#!") {
+			mode = 0o755
+		}
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     "owner-repo-abc1234/" + path,
+			Mode:     mode,
+			Size:     int64(len(content)),
+		})
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	for path, target := range links {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeSymlink,
+			Name:     "owner-repo-abc1234/" + path,
+			Linkname: target,
+			Mode:     0o777,
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// treeHandler serves the recursive tree of the given files and symlinks to
+// their targets, keyed by repository path. Files starting with a shebang are
+// executable.
+func treeHandler(files, links map[string]string) http.HandlerFunc {
+	// The entries are encoded as they are listed, as go-github encodes tree
+	// entries without their size.
+	type entry struct {
+		Type string `json:"type"`
+		Mode string `json:"mode"`
+		Path string `json:"path"`
+		Size int    `json:"size"`
+	}
+	return func(w http.ResponseWriter, _ *http.Request) {
+		entries := []entry{}
+		for path, content := range files {
+			mode := "100644"
+			if strings.HasPrefix(content, "#!") {
+				mode = "100755"
+			}
+			entries = append(entries, entry{Type: "blob", Mode: mode, Path: path, Size: len(content)})
+		}
+		for path, target := range links {
+			entries = append(entries, entry{Type: "blob", Mode: symlinkMode, Path: path, Size: len(target)})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"sha": "main", "tree": entries})
+	}
+}
+
+// archiveRepository serves the files and symlinks like linksMux, along with
+// the tree and the tarball of the repository at main, and returns a
+// repository that coalesces downloads.
+func archiveRepository(t *testing.T, files, links map[string]string) *GitHub {
+	t.Helper()
+	mux := linksMux(files, links)
+	archiveRoutes(t, mux, files, links)
+	r := serverRepository(t, mux)
+	r.opts.coalesce = true
+	return r
+}
+
+// archiveRoutes registers the tree and the tarball of the files and links
+// on the mux.
+func archiveRoutes(t *testing.T, mux *http.ServeMux, files, links map[string]string) {
+	t.Helper()
+	archive := tarball(t, files, links)
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", treeHandler(files, links))
+	mux.HandleFunc("GET /repos/owner/repo/tarball/main", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+r.Host+"/codeload/main.tar.gz", http.StatusFound)
+	})
+	mux.HandleFunc("GET /codeload/main.tar.gz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(archive)
+	})
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+
+	nFiles := 50
+	files := map[string]string{
+		"other/outside.txt":  "not requested",
+		fakeBase + "x/a.txt": "similar prefix",
+	}
+	for i := range nFiles {
+		files[fmt.Sprintf("%s/sub/file_%d.txt", fakeBase, i)] = fmt.Sprintf("content %d", i)
+	}
+	links := map[string]string{
+		fakeBase + "/sub/link.txt": "file_0.txt",
+	}
+	archive := tarball(t, files, links)
+
+	var requests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", treeHandler(files, links))
+	mux.HandleFunc("GET /repos/owner/repo/tarball/main", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+r.Host+"/codeload/main.tar.gz", http.StatusFound)
+	})
+	mux.HandleFunc("GET /codeload/main.tar.gz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(archive)
+	})
+	counter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		mux.ServeHTTP(w, r)
+	})
+
+	r := serverRepository(t, counter)
+	r.opts.coalesce = true
+	g := fakeNew(r)
+
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	for i := range nFiles {
+		b, err := os.ReadFile(fmt.Sprintf("%s/sub/file_%d.txt", fakeBase, i))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("content %d", i), string(b))
+	}
+	b, err := os.ReadFile(fakeBase + "/sub/link.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content 0", string(b))
+	entries, err := os.ReadDir(fakeBase)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, int32(3), requests.Load(), "want one tree, one archive link, and one archive request")
+}
+
+func TestArchiveModes(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits are not supported on windows")
+	}
+	files := map[string]string{
+		"dir/run.sh":    "#!/bin/sh",
+		"dir/notes.txt": "#!/bin/sh",
+		"dir/plain.sh":  "echo",
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		executable []string
+	}{
+		{name: "preserve modes", executable: []string{"dir/run.sh", "dir/notes.txt"}},
+		{name: "executable extensions", opts: []Option{WithExecutableExtensions([]string{".sh"})}, executable: []string{"dir/run.sh"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r := archiveRepository(t, files, nil)
+			for _, opt := range test.opts {
+				opt(&r.opts)
+			}
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+			base := t.TempDir()
+
+			_, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+			for path := range files {
+				info, err := os.Stat(filepath.Join(base, path))
+				require.NoError(t, err)
+				assert.Equal(t, slices.Contains(test.executable, path), info.Mode()&0o111 != 0, path)
+			}
+		})
+	}
+}
+
+func TestArchiveProgress(t *testing.T) {
+	t.Parallel()
+	r := archiveRepository(t, map[string]string{
+		"dir/a.txt":     strings.Repeat("a", 10),
+		"dir/sub/b.txt": strings.Repeat("b", 2000),
+	}, nil)
+	var events []Progress
+	r.opts.progress = func(p Progress) { events = append(events, p) }
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	require.NoError(t, err)
+
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, Progress{Bytes: 2010, Total: 2010, BytesPerSecond: last.BytesPerSecond, Files: 2, FilesDone: 2}, last)
+}
+
+func TestArchiveMissing(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{"dir/a.txt": "a", "dir/ignored.txt": "export-ignore"}
+	archive := tarball(t, map[string]string{"dir/a.txt": "a"}, nil)
+	var raw atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", treeHandler(files, nil))
+	mux.HandleFunc("GET /repos/owner/repo/tarball/main", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+r.Host+"/codeload/main.tar.gz", http.StatusFound)
+	})
+	mux.HandleFunc("GET /codeload/main.tar.gz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(archive)
+	})
+	mux.HandleFunc("GET /raw/", func(w http.ResponseWriter, r *http.Request) {
+		raw.Add(1)
+		fmt.Fprint(w, files[strings.TrimPrefix(r.URL.Path, "/raw/owner/repo/main/")])
+	})
+	r := serverRepository(t, mux)
+	r.opts.coalesce = true
+	r.opts.rawURL = r.Client.(*service).client.BaseURL.String() + "raw/"
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	base := t.TempDir()
+
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/a.txt", "dir/ignored.txt"}, savedFiles(t, base))
+	// Only the file missing from the archive is downloaded on its own.
+	assert.Equal(t, int32(1), raw.Load())
+}
+
+func TestArchiveSkipEmpty(t *testing.T) {
+	t.Parallel()
+	r := archiveRepository(t, map[string]string{
+		"dir/file.txt": "data",
+		"dir/.gitkeep": "",
+	}, nil)
+	r.opts.skipEmpty = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	base := t.TempDir()
+
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/file.txt"}, savedFiles(t, base))
+	assert.Equal(t, []Warning{{Code: WarnEmptySkipped, Path: "dir/.gitkeep", Message: "Skipping empty file"}}, result.Warnings)
+}
+
+func TestArchiveFilters(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"CODEOWNERS":           "* @org/core\n*.md @docs\n",
+		"LICENSE":              "license",
+		"dir/README.md":        "readme",
+		"dir/main.go":          "main",
+		"dir/sub/util.go":      "util",
+		"dir/sub/deep/data.go": "data",
+	}
+	ignoreFile := filepath.Join(t.TempDir(), ignoreName)
+	require.NoError(t, os.WriteFile(ignoreFile, []byte("sub/\n"), 0o600))
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     []Option
+		expected []string
+	}{
+		{
+			name:     "include",
+			opts:     []Option{WithInclude("*.go")},
+			expected: []string{"dir/main.go", "dir/sub/deep/data.go", "dir/sub/util.go"},
+		},
+		{
+			name:     "exclude",
+			opts:     []Option{WithExclude("*.go")},
+			expected: []string{"dir/README.md"},
+		},
+		{
+			name:     "glob",
+			url:      "https://github.com/owner/repo/tree/main/dir/sub/**/*.go",
+			expected: []string{"sub/deep/data.go", "sub/util.go"},
+		},
+		{
+			name:     "ignore file",
+			opts:     []Option{WithIgnoreFile(ignoreFile)},
+			expected: []string{"dir/README.md", "dir/main.go"},
+		},
+		{
+			name:     "max depth",
+			opts:     []Option{WithMaxDepth(1)},
+			expected: []string{"dir/README.md", "dir/main.go"},
+		},
+		{
+			name:     "strip top level",
+			url:      "https://github.com/owner/repo/tree/main",
+			opts:     []Option{WithStripTopLevel([]string{"CODEOWNERS", "LICENSE"})},
+			expected: []string{"dir/README.md", "dir/main.go", "dir/sub/deep/data.go", "dir/sub/util.go"},
+		},
+		{
+			name:     "code owner",
+			opts:     []Option{WithCodeOwner("@docs")},
+			expected: []string{"dir/README.md"},
+		},
+		{
+			name:     "flatten",
+			opts:     []Option{WithFlatten(true)},
+			expected: []string{"dir/README.md", "dir/data.go", "dir/main.go", "dir/util.go"},
+		},
+		{
+			name: "select",
+			opts: []Option{WithSelect(func([]TreeEntry) ([]string, error) {
+				return []string{"dir/main.go"}, nil
+			})},
+			expected: []string{"dir/main.go"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r := archiveRepository(t, files, nil)
+			for _, opt := range test.opts {
+				opt(&r.opts)
+			}
+			url := test.url
+			if url == "" {
+				url = "https://github.com/owner/repo/tree/main/dir"
+			}
+			require.NoError(t, r.extract(url))
+			base := t.TempDir()
+
+			result, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, savedFiles(t, base))
+			assert.Len(t, result.Manifest.Files, len(test.expected))
+		})
+	}
+}
+
+func TestArchiveError(t *testing.T) {
+	t.Parallel()
+	listed := treeHandler(map[string]string{"file.txt": "data"}, nil)
+	noArchive := http.NewServeMux()
+	noArchive.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", listed)
+	notFound := http.NewServeMux()
+	notFound.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", listed)
+	notFound.HandleFunc("GET /repos/owner/repo/tarball/main", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+r.Host+"/missing.tar.gz", http.StatusFound)
+	})
+	invalid := http.NewServeMux()
+	invalid.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", listed)
+	invalid.HandleFunc("GET /repos/owner/repo/tarball/main", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+r.Host+"/invalid.tar.gz", http.StatusFound)
+	})
+	invalid.HandleFunc("GET /invalid.tar.gz", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "not a tarball")
+	})
+
+	truncated := http.NewServeMux()
+	truncated.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", listed)
+	truncated.HandleFunc("GET /repos/owner/repo/tarball/main", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+r.Host+"/truncated.tar.gz", http.StatusFound)
+	})
+	truncated.HandleFunc("GET /truncated.tar.gz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		fmt.Fprint(w, "partial")
+	})
+	few := archiveRepository(t, map[string]string{"file.txt": "data"}, nil)
+	few.opts.minFiles = 2
+
+	tests := []struct {
+		name     string
+		repo     *GitHub
+		expected string
+	}{
+		{
+			name:     "error truncated archive",
+			repo:     serverRepository(t, truncated),
+			expected: "failed to download: unexpected EOF",
+		},
+		{
+			name:     "error too few files",
+			repo:     few,
+			expected: ErrTooFewFiles.Error(),
+		},
+		{
+			name:     "error tree",
+			repo:     &GitHub{Client: &mockError{}},
+			expected: fmt.Errorf("failed to download: %w", errMockTree).Error(),
+		},
+		{
+			name:     "error archive link",
+			repo:     serverRepository(t, noArchive),
+			expected: "failed to download: unexpected status code: 404 Not Found",
+		},
+		{
+			name:     "error archive status",
+			repo:     serverRepository(t, notFound),
+			expected: "failed to download: 404 Not Found",
+		},
+		{
+			name:     "error invalid archive",
+			repo:     serverRepository(t, invalid),
+			expected: "failed to download: gzip: invalid header",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.repo.Owner, test.repo.Repo = "owner", "repo"
+			test.repo.Ref = &github.RepositoryContentGetOptions{Ref: "main"}
+			test.repo.warnings = &warnings{}
+			_, err := test.repo.archive(context.Background())
+			require.Error(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), test.expected), err.Error())
+		})
+	}
+}
+
+func TestUnderPath(t *testing.T) {
+	t.Parallel()
+	assert.True(t, underPath("", "any/file.txt"))
+	assert.True(t, underPath("dir", "dir"))
+	assert.True(t, underPath("dir", "dir/file.txt"))
+	assert.False(t, underPath("dir", "dirx/file.txt"))
+	assert.False(t, underPath("dir", "other/dir/file.txt"))
+}
@@ -0,0 +1,132 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// attributesName is the name of the file at the repository root that
+// assigns attributes to paths.
+const attributesName = ".gitattributes"
+
+// exportRule represents a line of the attributes file that sets or unsets
+// the export-ignore attribute for the paths matching the pattern.
+type exportRule struct {
+	pattern string
+	ignore  bool
+}
+
+// exportIgnore removes the files marked export-ignore by the attributes file
+// of the repository, as git archive does.
+func (g *GitHub) exportIgnore(ctx context.Context, files []*github.RepositoryContent) ([]*github.RepositoryContent, error) {
+	b, ok, err := g.readFile(ctx, attributesName)
+	if err != nil || !ok {
+		return files, err
+	}
+
+	rules := parseExportRules(b)
+	for i := range rules {
+		rules[i].pattern = globCase(g.opts, rules[i].pattern)
+	}
+	var kept []*github.RepositoryContent
+	for _, file := range files {
+		if !exportIgnored(rules, globCase(g.opts, file.GetPath())) {
+			kept = append(kept, file)
+		}
+	}
+
+	return kept, nil
+}
+
+// readFile returns the content of the file at the repository path at the ref.
+// It reports false if there is no such file.
+func (g *GitHub) readFile(ctx context.Context, name string) ([]byte, bool, error) {
+	file, _, _, err := g.Client.GetContents(ctx, g.Owner, g.Repo, name, g.Ref)
+	if isStatus(err, http.StatusNotFound) || (err == nil && file.GetType() != "file") {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	resp, err := g.Client.Get(ctx, file.GetDownloadURL())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to read %s: %w", name, newHTTPError(resp))
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	return b, true, nil
+}
+
+// parseExportRules returns the export-ignore rules of the attributes file in
+// order. Attributes other than export-ignore are ignored.
+func parseExportRules(b []byte) []exportRule {
+	var rules []exportRule
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "export-ignore":
+				rules = append(rules, exportRule{pattern: fields[0], ignore: true})
+			case "-export-ignore", "!export-ignore":
+				rules = append(rules, exportRule{pattern: fields[0], ignore: false})
+			}
+		}
+	}
+
+	return rules
+}
+
+// exportIgnored reports whether the repository path is marked export-ignore.
+// The last matching rule wins.
+func exportIgnored(rules []exportRule, p string) bool {
+	ignored := false
+	for _, rule := range rules {
+		if matchAttr(rule.pattern, p) {
+			ignored = rule.ignore
+		}
+	}
+	return ignored
+}
+
+// matchAttr reports whether the attributes pattern matches the repository
+// path or one of its parent directories. Patterns without a slash match a
+// name at any depth; other patterns match relative to the repository root.
+func matchAttr(pattern, p string) bool {
+	pattern = strings.TrimPrefix(pattern, "**/")
+	pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "/**"), "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	for dir := p; dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		name := dir
+		if !anchored {
+			name = path.Base(dir)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
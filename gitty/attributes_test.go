@@ -0,0 +1,170 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExportRules(t *testing.T) {
+	t.Parallel()
+	b := []byte(`# Excluded from archives
+*.go text eol=lf
+/testdata export-ignore
+docs/internal/ export-ignore linguist-documentation
+*.md export-ignore
+README.md -export-ignore
+`)
+
+	expected := []exportRule{
+		{pattern: "/testdata", ignore: true},
+		{pattern: "docs/internal/", ignore: true},
+		{pattern: "*.md", ignore: true},
+		{pattern: "README.md", ignore: false},
+	}
+	assert.Equal(t, expected, parseExportRules(b))
+}
+
+func TestMatchAttr(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{pattern: "*.md", path: "docs/guide.md", expected: true},
+		{pattern: "*.md", path: "docs/guide.go", expected: false},
+		{pattern: "testdata", path: "pkg/testdata/file.txt", expected: true},
+		{pattern: "/testdata", path: "testdata/file.txt", expected: true},
+		{pattern: "/testdata", path: "pkg/testdata/file.txt", expected: false},
+		{pattern: "docs/internal/", path: "docs/internal/a/b.txt", expected: true},
+		{pattern: "docs/internal/**", path: "docs/internal/b.txt", expected: true},
+		{pattern: "docs/internal", path: "other/docs/internal/b.txt", expected: false},
+		{pattern: "**/fixtures", path: "a/b/fixtures/c.json", expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.path, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, matchAttr(test.pattern, test.path))
+		})
+	}
+}
+
+func TestExportIgnored(t *testing.T) {
+	t.Parallel()
+	rules := parseExportRules([]byte("*.md export-ignore\nREADME.md -export-ignore\n"))
+	assert.True(t, exportIgnored(rules, "docs/guide.md"))
+	assert.False(t, exportIgnored(rules, "README.md"))
+	assert.False(t, exportIgnored(rules, "main.go"))
+	assert.False(t, exportIgnored(nil, "docs/guide.md"))
+}
+
+func TestDownloadExportIgnore(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		attributesName:                 "/" + fakeBase + "/testdata export-ignore\n*.bak export-ignore\n",
+		fakeBase + "/main.go":          "package main",
+		fakeBase + "/main.go.bak":      "backup",
+		fakeBase + "/testdata/in.json": "{}",
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.exportIgnore = true
+	g := fakeNew(r)
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(fakeBase + "/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main", string(b))
+	for _, path := range []string{"/main.go.bak", "/testdata"} {
+		_, err := os.Stat(fakeBase + path)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+func TestDownloadExportIgnoreCaseInsensitive(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		attributesName: "*.MD export-ignore\n",
+		"README.md":    "readme",
+		"Guide.Md":     "guide",
+		"main.go":      "package main",
+	}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected []string
+	}{
+		{name: "case-sensitive", expected: []string{"Guide.Md", "README.md", "main.go"}},
+		{name: "case-insensitive", enabled: true, expected: []string{"main.go"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			listed := []*github.RepositoryContent{{Path: ptr("Guide.Md")}, {Path: ptr("README.md")}, {Path: ptr("main.go")}}
+			r := serverRepository(t, contentsMux(files))
+			r.Owner, r.Repo = "owner", "repo"
+			r.opts.caseInsensitiveGlobs = test.enabled
+			kept, err := r.exportIgnore(context.Background(), listed)
+			require.NoError(t, err)
+
+			var paths []string
+			for _, file := range kept {
+				paths = append(paths, file.GetPath())
+			}
+			assert.Equal(t, test.expected, paths)
+		})
+	}
+}
+
+func TestExportIgnore(t *testing.T) {
+	t.Parallel()
+	files := []*github.RepositoryContent{{Path: ptr("a.md")}, {Path: ptr("a.go")}}
+
+	t.Run("no attributes file", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(map[string]string{"a.go": "package a"}))
+		r.Owner, r.Repo = "owner", "repo"
+		kept, err := r.exportIgnore(context.Background(), files)
+		require.NoError(t, err)
+		assert.Equal(t, files, kept)
+	})
+
+	t.Run("error get contents", func(t *testing.T) {
+		t.Parallel()
+		r := &GitHub{Client: &mockError{}}
+		_, err := r.exportIgnore(context.Background(), files)
+		require.Error(t, err)
+	})
+
+	t.Run("error download status", func(t *testing.T) {
+		t.Parallel()
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /repos/owner/repo/contents/.gitattributes", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"type":"file","path":".gitattributes","download_url":"http://%s/raw/.gitattributes"}`, r.Host)
+		})
+		mux.HandleFunc("GET /raw/.gitattributes", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		r := serverRepository(t, mux)
+		r.Owner, r.Repo = "owner", "repo"
+		_, err := r.exportIgnore(context.Background(), files)
+		require.ErrorContains(t, err, "500")
+	})
+}
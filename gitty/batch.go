@@ -0,0 +1,70 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DownloadAll downloads the file or directory at each of the given URLs with
+// the given options, like Download, and returns the results in the order of
+// the URLs. The downloads share the client, and with it the token and the
+// connections, and the limit on the files downloaded at once. The result of
+// a URL that failed is nil, and the errors of the failed URLs are joined in
+// the returned error, each prefixed with its URL. With WithStopOnError, only
+// the error of the first URL that failed is returned.
+func DownloadAll(ctx context.Context, urls []string, opts ...Option) ([]*Result, error) {
+	o := newOptions(opts...)
+	client := newClient(o)
+	if o.concurrency > 0 {
+		o.pool = make(chan struct{}, o.concurrency)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*Result, len(urls))
+	errs := make([]error, len(urls))
+	var once sync.Once
+	var first error
+	fail := func(i int, err error) {
+		errs[i] = fmt.Errorf("%s: %w", urls[i], err)
+		if o.stopOnError {
+			once.Do(func() {
+				first = errs[i]
+				cancel()
+			})
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	sem := make(chan struct{}, max(o.batchConcurrency, 1))
+	for i, url := range urls {
+		if err := ctx.Err(); err != nil {
+			fail(i, err)
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// Each URL is downloaded by its own repository, as a download
+			// keeps the state of the URL, but with the same client.
+			g := &Git{repo: repository(client, o)}
+			result, err := g.DownloadResult(ctx, url)
+			if err != nil {
+				fail(i, err)
+				return
+			}
+			results[i] = &result
+		}()
+	}
+	wg.Wait()
+
+	if first != nil {
+		return results, first
+	}
+	return results, errors.Join(errs...)
+}
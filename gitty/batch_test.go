@@ -0,0 +1,143 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reposServer serves the files with contentsMux for each repository of owner
+// but missing, redirects moved to renamed permanently, and records the
+// repositories requested.
+func reposServer(t *testing.T, files map[string]string) (string, *sync.Map) {
+	t.Helper()
+	contents := contentsMux(files)
+	repos := &sync.Map{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/repos/owner/"); ok {
+			repo, rest, _ := strings.Cut(rest, "/")
+			if repo == "missing" {
+				http.NotFound(w, r)
+				return
+			}
+			if repo == "moved" {
+				u := *r.URL
+				u.Path = "/repos/owner/renamed/" + rest
+				http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+				return
+			}
+			repos.Store(repo, true)
+			r = r.Clone(r.Context())
+			r.URL.Path = "/repos/owner/repo/" + rest
+		}
+		contents.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/", repos
+}
+
+func TestDownloadAll(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"docs/guide.md": "guide",
+		"src/main.go":   "package main",
+		"src/util.go":   "package main",
+	}
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		srv, repos := reposServer(t, files)
+		base := t.TempDir()
+
+		results, err := DownloadAll(context.Background(), []string{
+			"https://github.com/owner/first/tree/main/docs",
+			"https://github.com/owner/second/tree/main/src",
+		}, WithToken(""), WithBaseURL(srv), WithOutputDir(base), WithBatchConcurrency(2), WithConcurrency(1))
+		require.NoError(t, err)
+
+		require.Len(t, results, 2)
+		assert.Equal(t, []ManifestEntry{{Path: "docs/guide.md", Size: 5}}, results[0].Manifest.Files)
+		assert.Len(t, results[1].Manifest.Files, 2)
+		assert.Equal(t, 3, results[0].Summary.Written+results[1].Summary.Written)
+		for _, repo := range []string{"first", "second"} {
+			_, ok := repos.Load(repo)
+			assert.True(t, ok, repo)
+		}
+		for path, content := range files {
+			b, err := os.ReadFile(filepath.Join(base, filepath.FromSlash(path)))
+			require.NoError(t, err)
+			assert.Equal(t, content, string(b))
+		}
+	})
+
+	t.Run("moved repo", func(t *testing.T) {
+		t.Parallel()
+		srv, _ := reposServer(t, files)
+
+		results, err := DownloadAll(context.Background(), []string{
+			"https://github.com/owner/moved/tree/main/docs",
+			"https://github.com/owner/second/tree/main/src",
+		}, WithToken(""), WithBaseURL(srv), WithOutputDir(t.TempDir()), WithBatchConcurrency(2))
+		require.NoError(t, err)
+
+		require.Len(t, results, 2)
+		// Only the download of the moved repository reports the move.
+		assert.Equal(t, []Warning{{Code: WarnRepoMoved, Path: "owner/renamed", Message: "Repository moved from owner/moved"}}, results[0].Warnings)
+		assert.Empty(t, results[1].Warnings)
+	})
+
+	t.Run("errors collected", func(t *testing.T) {
+		t.Parallel()
+		srv, _ := reposServer(t, files)
+		base := t.TempDir()
+
+		results, err := DownloadAll(context.Background(), []string{
+			"https://github.com/owner/missing/tree/main/docs",
+			"https://gitlab.com/owner/repo",
+			"https://github.com/owner/second/tree/main/src",
+		}, WithToken(""), WithBaseURL(srv), WithOutputDir(base))
+		require.ErrorContains(t, err, "https://github.com/owner/missing/tree/main/docs: ")
+		require.ErrorIs(t, err, ErrNotValidURL)
+
+		require.Len(t, results, 3)
+		assert.Nil(t, results[0])
+		assert.Nil(t, results[1])
+		require.NotNil(t, results[2])
+		assert.Len(t, results[2].Manifest.Files, 2)
+	})
+
+	t.Run("stop on error", func(t *testing.T) {
+		t.Parallel()
+		srv, _ := reposServer(t, files)
+		base := t.TempDir()
+
+		results, err := DownloadAll(context.Background(), []string{
+			"https://gitlab.com/owner/repo",
+			"https://github.com/owner/second/tree/main/src",
+		}, WithToken(""), WithBaseURL(srv), WithOutputDir(base), WithStopOnError(true))
+		require.ErrorIs(t, err, ErrNotValidURL)
+		assert.NotContains(t, err.Error(), "second")
+
+		assert.Equal(t, []*Result{nil, nil}, results)
+		_, err = os.Stat(filepath.Join(base, "src"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results, err := DownloadAll(ctx, []string{"https://github.com/owner/first/tree/main/docs"}, WithToken(""))
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, []*Result{nil}, results)
+	})
+}
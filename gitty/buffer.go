@@ -0,0 +1,68 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+)
+
+// IndexEntry represents the location of a downloaded file in the buffer the
+// files are written to.
+type IndexEntry struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// bufferWriter appends the contents of the files to a single buffer and
+// indexes their locations. It is safe for concurrent use.
+type bufferWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	index []IndexEntry
+}
+
+// Ensure bufferWriter implements the recorder interface.
+var _ recorder = (*bufferWriter)(nil)
+
+// write reads the body and appends it to the buffer as the file of the path.
+// The body is read first, so the contents of a file are contiguous.
+func (w *bufferWriter) write(path string, body io.Reader) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.index = append(w.index, IndexEntry{Path: path, Offset: int64(w.buf.Len()), Length: int64(len(b))})
+	_, err = w.buf.Write(b)
+	return err
+}
+
+// downloadBuffer downloads the contents from the given URL into a single
+// buffer instead of saving them, and returns the buffer along with the index
+// of the files in it, sorted by path.
+func (g *GitHub) downloadBuffer(ctx context.Context, url string) (*bytes.Buffer, []IndexEntry, error) {
+	if err := g.extract(url); err != nil {
+		return nil, nil, err
+	}
+
+	records := g.opts.records
+	defer func() {
+		g.opts.records = records
+	}()
+	w := &bufferWriter{}
+	g.opts.records = w
+
+	if _, err := g.download(ctx); err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(w.index, func(i, j int) bool {
+		return w.index[i].Path < w.index[j].Path
+	})
+
+	return &w.buf, w.index, nil
+}
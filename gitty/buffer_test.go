@@ -0,0 +1,79 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadToBuffer(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	files := map[string]string{
+		"repo/" + fakeBase + "/a.txt":     "content a",
+		"repo/" + fakeBase + "/empty.txt": "",
+		"repo/" + fakeBase + "/sub/b.bin": "\x00\x01\xff binary",
+		"repo/" + fakeBase + "/sub/c.go":  "package sub",
+	}
+	links := map[string]string{
+		"repo/" + fakeBase + "/link.txt": "a.txt",
+	}
+	g := fakeNew(serverRepository(t, linksMux(files, links)))
+
+	buf, index, err := g.DownloadToBuffer(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+fakeBase)
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		fakeBase + "/a.txt":     "content a",
+		fakeBase + "/empty.txt": "",
+		fakeBase + "/link.txt":  "content a",
+		fakeBase + "/sub/b.bin": "\x00\x01\xff binary",
+		fakeBase + "/sub/c.go":  "package sub",
+	}
+	require.Len(t, index, len(expected))
+	var paths []string
+	for _, entry := range index {
+		paths = append(paths, entry.Path)
+		assert.Equal(t, expected[entry.Path], string(buf.Bytes()[entry.Offset:entry.Offset+entry.Length]), entry.Path)
+	}
+	assert.IsIncreasing(t, paths)
+	_, err = os.Stat(fakeBase)
+	require.ErrorIs(t, err, os.ErrNotExist, "want no files saved")
+
+	_, _, err = g.DownloadToBuffer(context.Background(), "https://gitlab.com/owner/repo")
+	require.ErrorIs(t, err, ErrNotValidURL)
+}
+
+func TestBufferWriter(t *testing.T) {
+	t.Parallel()
+	w := &bufferWriter{}
+	wg := &sync.WaitGroup{}
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, w.write(fmt.Sprintf("file%d.txt", i), strings.NewReader(strings.Repeat(fmt.Sprint(i%10), i))))
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, w.index, 20)
+	for _, entry := range w.index {
+		var i int
+		_, err := fmt.Sscanf(entry.Path, "file%d.txt", &i)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat(fmt.Sprint(i%10), i), w.buf.String()[entry.Offset:entry.Offset+entry.Length])
+	}
+	assert.Equal(t, 190, w.buf.Len())
+
+	err := w.write("error.txt", errReader(0))
+	require.ErrorIs(t, err, errMockReadAll)
+	assert.Len(t, w.index, 20)
+}
@@ -0,0 +1,120 @@
+package gitty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// checkpointState represents the listed files of a download and the files
+// saved so far, as written to the checkpoint file.
+type checkpointState struct {
+	Owner string                      `json:"owner"`
+	Repo  string                      `json:"repo"`
+	Ref   string                      `json:"ref"`
+	Path  string                      `json:"path"`
+	Files []*github.RepositoryContent `json:"files"`
+	Links []*github.RepositoryContent `json:"links"`
+	Done  []string                    `json:"done"`
+}
+
+// checkpoint represents the progress of a download kept in a checkpoint
+// file, so an interrupted download can be resumed without listing again.
+type checkpoint struct {
+	mu    sync.Mutex
+	file  string
+	state checkpointState
+}
+
+// resume returns the files and symlinks listed by an interrupted download of
+// the same contents from the checkpoint file, and marks the files saved
+// before as completed. It reports false if there is nothing to resume.
+func (g *GitHub) resume() (files, links []*github.RepositoryContent, ok bool, err error) {
+	b, err := os.ReadFile(g.opts.checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, nil, false, err
+	}
+	if state.Owner != g.Owner || state.Repo != g.Repo || state.Ref != g.ref() || state.Path != g.Path {
+		return nil, nil, false, nil
+	}
+
+	for _, path := range state.Done {
+		g.completed.add(path)
+	}
+	fmt.Println("Resuming:", len(state.Done), "of", len(state.Files), "files saved")
+
+	return state.Files, state.Links, true, nil
+}
+
+// newCheckpoint creates the checkpoint of the listed files and symlinks and
+// writes it to the checkpoint file.
+func (g *GitHub) newCheckpoint(files, links []*github.RepositoryContent) (*checkpoint, error) {
+	c := &checkpoint{
+		file: g.opts.checkpoint,
+		state: checkpointState{
+			Owner: g.Owner,
+			Repo:  g.Repo,
+			Ref:   g.ref(),
+			Path:  g.Path,
+			Files: files,
+			Links: links,
+		},
+	}
+	for _, file := range files {
+		if g.completed.has(file.GetPath()) {
+			c.state.Done = append(c.state.Done, file.GetPath())
+		}
+	}
+
+	return c, c.write()
+}
+
+// done records the file at the repository path as saved and writes the
+// checkpoint file. A nil checkpoint does nothing.
+func (c *checkpoint) done(path string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state.Done = append(c.state.Done, path)
+	return c.write()
+}
+
+// write writes the checkpoint to a temporary file first and renames it, so an
+// interrupted write never leaves a partial checkpoint file behind.
+func (c *checkpoint) write() error {
+	b, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.file)
+}
+
+// remove removes the checkpoint file once the download is complete. A nil
+// checkpoint does nothing.
+func (c *checkpoint) remove() error {
+	if c == nil {
+		return nil
+	}
+	return os.Remove(c.file)
+}
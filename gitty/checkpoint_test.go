@@ -0,0 +1,109 @@
+package gitty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	nFiles, crashAfter := 5, 2
+	files := make(map[string]string, nFiles)
+	for i := range nFiles {
+		files[fmt.Sprintf("%s/file_%d.txt", fakeBase, i)] = fmt.Sprintf("content %d", i)
+	}
+
+	var crash atomic.Bool
+	var listed, fetched atomic.Int32
+	var resumedPaths sync.Map
+	contents := contentsMux(files)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/raw/") {
+			listed.Add(1)
+			contents.ServeHTTP(w, r)
+			return
+		}
+		// Simulate the process being killed by dropping the connection.
+		if crash.Load() && fetched.Load() >= int32(crashAfter) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		fetched.Add(1)
+		if !crash.Load() {
+			resumedPaths.Store(strings.TrimPrefix(r.URL.Path, "/raw/"), true)
+		}
+		contents.ServeHTTP(w, r)
+	})
+	r := serverRepository(t, handler)
+	r.opts.checkpoint = filepath.Join(t.TempDir(), "checkpoint.json")
+	r.opts.concurrency = 1
+	url := "https://github.com/owner/repo/tree/main/" + fakeBase
+
+	crash.Store(true)
+	err := fakeNew(r).Download(context.Background(), url)
+	require.Error(t, err)
+
+	b, err := os.ReadFile(r.opts.checkpoint)
+	require.NoError(t, err)
+	var state checkpointState
+	require.NoError(t, json.Unmarshal(b, &state))
+	assert.Len(t, state.Files, nFiles)
+	assert.Len(t, state.Done, crashAfter)
+
+	crash.Store(false)
+	listed.Store(0)
+	resumed := &GitHub{Client: r.Client, opts: r.opts}
+	err = fakeNew(resumed).Download(context.Background(), url)
+	require.NoError(t, err)
+
+	assert.Zero(t, listed.Load(), "want no listing when resuming")
+	for path := range files {
+		_, ok := resumedPaths.Load(path)
+		assert.Equal(t, !slices.Contains(state.Done, path), ok, "want only the remaining files fetched: %s", path)
+	}
+	for path, content := range files {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	_, err = os.Stat(r.opts.checkpoint)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCheckpointOtherDownload(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "checkpoint.json")
+	b, err := json.Marshal(checkpointState{Owner: "owner", Repo: "repo", Path: "other", Done: []string{"other/a.txt"}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, b, 0o600))
+
+	r := &GitHub{Owner: "owner", Repo: "repo", Path: "dir", opts: options{checkpoint: file}, completed: &completed{paths: make(map[string]bool)}}
+	_, _, ok, err := r.resume()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, r.completed.has("other/a.txt"))
+
+	require.NoError(t, os.WriteFile(file, []byte("invalid"), 0o600))
+	_, _, _, err = r.resume()
+	require.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// parseChecksums parses a checksum manifest in the format of sha256sum, one
+// SHA-256 digest and path per line, and returns the digests keyed by path.
+func parseChecksums(b []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, path, ok := strings.Cut(line, " ")
+		if !ok || len(sum) != 64 {
+			return nil, fmt.Errorf("invalid checksum on line %d", n)
+		}
+		// A leading asterisk marks a file read in binary mode.
+		path = strings.TrimPrefix(strings.TrimLeft(path, " "), "*")
+		sums[filepath.ToSlash(path)] = strings.ToLower(sum)
+	}
+
+	return sums, scanner.Err()
+}
+
+// verifyChecksums verifies the digests of the downloaded files against the
+// checksum manifest. The paths of the manifest are the paths the files are
+// saved at relative to the directory they are saved under, as reported in
+// Summary.Paths. It returns ErrChecksumMismatch listing the files that differ,
+// were not downloaded, or are missing from the manifest.
+func (g *GitHub) verifyChecksums(files []*github.RepositoryContent) error {
+	b, err := os.ReadFile(g.opts.checksums)
+	if err != nil {
+		return err
+	}
+	sums, err := parseChecksums(b)
+	if err != nil {
+		return err
+	}
+
+	var offenders []string
+	for _, file := range files {
+		p := relPath(g.opts, g.Path, file.GetPath(), false)
+		sum, ok := sums[p]
+		delete(sums, p)
+		switch {
+		case !ok:
+			offenders = append(offenders, p+" (not in manifest)")
+		case sum != g.opts.digests.get(file.GetPath()):
+			offenders = append(offenders, p)
+		}
+	}
+	for p := range sums {
+		offenders = append(offenders, p+" (not downloaded)")
+	}
+	if len(offenders) == 0 {
+		return nil
+	}
+
+	sort.Strings(offenders)
+	return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(offenders, ", "))
+}
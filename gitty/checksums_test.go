@@ -0,0 +1,110 @@
+package gitty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksums(t *testing.T) {
+	t.Parallel()
+	sum := hex.EncodeToString(make([]byte, 32))
+	sums, err := parseChecksums([]byte(sum + "  dir/a.txt\n\n" + sum + " *dir/b.bin\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"dir/a.txt": sum, "dir/b.bin": sum}, sums)
+
+	_, err = parseChecksums([]byte("abc dir/a.txt\n"))
+	require.EqualError(t, err, "invalid checksum on line 1")
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	t.Parallel()
+	sha := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name     string
+		manifest func(base string) string
+		expected string
+	}{
+		{
+			name: "matching manifest",
+			manifest: func(base string) string {
+				return sha("first") + "  " + base + "/a.txt\n" + sha("second") + "  " + base + "/sub/b.txt\n"
+			},
+		},
+		{
+			name: "altered manifest",
+			manifest: func(base string) string {
+				return sha("altered") + "  " + base + "/a.txt\n" + sha("second") + "  " + base + "/sub/b.txt\n" +
+					sha("gone") + "  " + base + "/gone.txt\n"
+			},
+			expected: "%[1]s/a.txt, %[1]s/gone.txt (not downloaded)",
+		},
+		{
+			name: "incomplete manifest",
+			manifest: func(base string) string {
+				return sha("first") + "  " + base + "/a.txt\n"
+			},
+			expected: "%[1]s/sub/b.txt (not in manifest)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			manifest := filepath.Join(t.TempDir(), "checksums.txt")
+			require.NoError(t, os.WriteFile(manifest, []byte(test.manifest(fakeBase)), 0o600))
+
+			r := serverRepository(t, contentsMux(map[string]string{
+				fakeBase + "/a.txt":     "first",
+				fakeBase + "/sub/b.txt": "second",
+			}))
+			r.opts.checksums = manifest
+			err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			if test.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrChecksumMismatch)
+			assert.Contains(t, err.Error(), fmt.Sprintf(test.expected, fakeBase))
+		})
+	}
+}
+
+func TestVerifyChecksumsOutputDir(t *testing.T) {
+	t.Parallel()
+	sum := sha256.Sum256([]byte("first"))
+	manifest := filepath.Join(t.TempDir(), "checksums.txt")
+	require.NoError(t, os.WriteFile(manifest, []byte(hex.EncodeToString(sum[:])+"  dir/a.txt\n"), 0o600))
+
+	r := serverRepository(t, contentsMux(map[string]string{"dir/a.txt": "first"}))
+	r.opts.checksums = manifest
+	r.opts.outputDir = t.TempDir()
+	result, err := fakeNew(r).DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/dir")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/a.txt"}, result.Summary.Paths)
+	assert.Equal(t, "dir/a.txt", result.Manifest.Files[0].Path)
+}
+
+func TestVerifyChecksumsError(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{opts: options{checksums: filepath.Join(t.TempDir(), "missing.txt")}}
+	err := r.verifyChecksums(nil)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,118 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var errChunkLength = errors.New("unexpected chunk length")
+
+// chunk represents the content of a byte range of a file.
+type chunk struct {
+	b   []byte
+	err error
+}
+
+// getChunked retrieves the file of the given size from the URL in byte ranges
+// of the chunk size, in parallel, and saves it like getFileWith. Only as many
+// chunks as downloaded in parallel are held in memory at once. If the server
+// does not support range requests, the file is saved from the first response.
+func (g *GitHub) getChunked(ctx context.Context, o options, url, path string, size int64) error {
+	if url == "" || path == "" {
+		return ErrInvalidPathURL
+	}
+	fmt.Println("Downloading:", path)
+
+	resp, err := g.Client.GetRange(ctx, url, 0, min(o.chunkSize, size))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return saveFileMode(ctx, o, g.Path, path, g.fileMode(path), resp.Body)
+	}
+
+	n := int((size + o.chunkSize - 1) / o.chunkSize)
+	chunks := make([]chan chunk, n)
+	for i := range chunks {
+		chunks[i] = make(chan chunk, 1)
+	}
+	// A slot is taken for each chunk being downloaded or held and given back
+	// once the chunk is saved.
+	slots := make(chan struct{}, max(o.chunkParallelism, 1))
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for i := range n {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			go func() {
+				first := resp
+				if i > 0 {
+					first = nil
+				}
+				chunks[i] <- g.getChunk(ctx, o, url, int64(i)*o.chunkSize, size, first)
+			}()
+		}
+	}()
+
+	return saveFileMode(ctx, o, g.Path, path, g.fileMode(path), &chunkReader{chunks: chunks, slots: slots})
+}
+
+// getChunk retrieves the chunk of the file starting at offset. The response
+// is requested unless it is given.
+func (g *GitHub) getChunk(ctx context.Context, o options, url string, offset, size int64, resp *http.Response) chunk {
+	length := min(o.chunkSize, size-offset)
+	if resp == nil {
+		var err error
+		if resp, err = g.Client.GetRange(ctx, url, offset, length); err != nil {
+			return chunk{err: err}
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return chunk{err: fmt.Errorf("bytes %d-%d: %w", offset, offset+length-1, newHTTPError(resp))}
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, length+1))
+	if err != nil {
+		return chunk{err: err}
+	}
+	if int64(len(b)) != length {
+		return chunk{err: fmt.Errorf("%w: bytes %d-%d", errChunkLength, offset, offset+length-1)}
+	}
+
+	return chunk{b: b}
+}
+
+// chunkReader represents a reader of the chunks of a file in order.
+type chunkReader struct {
+	chunks []chan chunk
+	slots  chan struct{}
+	cur    *bytes.Reader
+	next   int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for r.cur == nil || r.cur.Len() == 0 {
+		if r.next == len(r.chunks) {
+			return 0, io.EOF
+		}
+		c := <-r.chunks[r.next]
+		<-r.slots
+		r.next++
+		if c.err != nil {
+			return 0, c.err
+		}
+		r.cur = bytes.NewReader(c.b)
+	}
+	return r.cur.Read(p)
+}
@@ -0,0 +1,109 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkedDownload(t *testing.T) {
+	t.Parallel()
+	large := strings.Repeat("0123456789", 25) + "tail"
+
+	tests := []struct {
+		name     string
+		ranges   bool
+		expected int32
+	}{
+		{name: "ranges supported", ranges: true, expected: 11},
+		{name: "ranges not supported", expected: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			files := map[string]string{
+				fakeBase + "/large.bin": large,
+				fakeBase + "/small.txt": "small",
+			}
+
+			var requests atomic.Int32
+			contents := contentsMux(files)
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, ok := strings.CutPrefix(r.URL.Path, "/raw/")
+				if !ok || path != fakeBase+"/large.bin" {
+					contents.ServeHTTP(w, r)
+					return
+				}
+				requests.Add(1)
+				if !test.ranges {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, path, time.Time{}, strings.NewReader(files[path]))
+			})
+			r := serverRepository(t, handler)
+			r.opts.chunkSize = 25
+			r.opts.chunkParallelism = 3
+
+			err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			require.NoError(t, err)
+
+			for path, content := range files {
+				b, err := os.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, content, string(b))
+			}
+			assert.Equal(t, test.expected, requests.Load())
+		})
+	}
+}
+
+func TestChunkedDownloadError(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	content := strings.Repeat("x", 100)
+	contents := contentsMux(map[string]string{fakeBase + "/large.bin": content})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/raw/") {
+			contents.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get("Range") != "bytes=0-24" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "large.bin", time.Time{}, strings.NewReader(content))
+	})
+	r := serverRepository(t, handler)
+	r.opts.chunkSize = 25
+	r.opts.chunkParallelism = 2
+
+	err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.ErrorContains(t, err, "503 Service Unavailable")
+	_, err = os.Stat(fakeBase + "/large.bin")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	r = &GitHub{Client: &mockError{}}
+	err = r.getChunked(context.Background(), options{chunkSize: 25}, "url", "path", 100)
+	require.ErrorIs(t, err, errMockGet)
+	err = r.getChunked(context.Background(), options{chunkSize: 25}, "", "path", 100)
+	require.ErrorIs(t, err, ErrInvalidPathURL)
+}
@@ -0,0 +1,101 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// codeOwnersPaths represents the repository paths of the CODEOWNERS file, in
+// the order GitHub looks them up.
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// ErrCodeOwnersNotFound is returned if the files of a code owner are requested
+// and the repository has no CODEOWNERS file.
+var ErrCodeOwnersNotFound = errors.New("CODEOWNERS file not found")
+
+// ownerRule represents a line of the CODEOWNERS file that assigns the owners
+// to the paths matching the pattern.
+type ownerRule struct {
+	pattern string
+	owners  []string
+}
+
+// codeOwners returns the files owned by the code owner according to the
+// CODEOWNERS file of the repository.
+func (g *GitHub) codeOwners(ctx context.Context, files []*github.RepositoryContent) ([]*github.RepositoryContent, error) {
+	var b []byte
+	found := false
+	for _, name := range codeOwnersPaths {
+		var err error
+		if b, found, err = g.readFile(ctx, name); err != nil {
+			return nil, err
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return nil, ErrCodeOwnersNotFound
+	}
+
+	rules := parseOwnerRules(b)
+	for i := range rules {
+		rules[i].pattern = globCase(g.opts, rules[i].pattern)
+	}
+	var kept []*github.RepositoryContent
+	for _, file := range files {
+		if slices.ContainsFunc(owners(rules, globCase(g.opts, file.GetPath())), func(owner string) bool {
+			return sameOwner(owner, g.opts.codeOwner)
+		}) {
+			kept = append(kept, file)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("%w: no files owned by %s", ErrPathNotFound, g.opts.codeOwner)
+	}
+
+	return kept, nil
+}
+
+// parseOwnerRules returns the rules of the CODEOWNERS file in order. A rule
+// without owners unassigns the paths matching its pattern.
+func parseOwnerRules(b []byte) []ownerRule {
+	var rules []ownerRule
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, ownerRule{pattern: fields[0], owners: fields[1:]})
+	}
+
+	return rules
+}
+
+// owners returns the owners of the repository path. The last matching rule
+// wins.
+func owners(rules []ownerRule, p string) []string {
+	var matched []string
+	for _, rule := range rules {
+		if matchAttr(rule.pattern, p) {
+			matched = rule.owners
+		}
+	}
+	return matched
+}
+
+// sameOwner reports whether the owners are the same user, team, or email
+// address, with or without the leading @, matched case-insensitively as on
+// GitHub.
+func sameOwner(a, b string) bool {
+	return strings.EqualFold(strings.TrimPrefix(a, "@"), strings.TrimPrefix(b, "@"))
+}
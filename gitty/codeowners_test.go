@@ -0,0 +1,110 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOwnerRules(t *testing.T) {
+	t.Parallel()
+	b := []byte("# Code owners\n\n*       @org/core\n*.md    @docs-writer # docs\n/build/ @org/ops   ops@example.com\n/vendor/\n")
+	expected := []ownerRule{
+		{pattern: "*", owners: []string{"@org/core"}},
+		{pattern: "*.md", owners: []string{"@docs-writer"}},
+		{pattern: "/build/", owners: []string{"@org/ops", "ops@example.com"}},
+		{pattern: "/vendor/", owners: []string{}},
+	}
+	assert.Equal(t, expected, parseOwnerRules(b))
+}
+
+func TestOwners(t *testing.T) {
+	t.Parallel()
+	rules := parseOwnerRules([]byte("* @org/core\n*.md @docs-writer\n/build/ @org/ops\n/build/vendor/\n"))
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{path: "main.go", expected: []string{"@org/core"}},
+		{path: "docs/README.md", expected: []string{"@docs-writer"}},
+		{path: "build/Makefile", expected: []string{"@org/ops"}},
+		{path: "build/README.md", expected: []string{"@org/ops"}},
+		{path: "build/vendor/lib.go", expected: []string{}},
+		{path: "src/build/main.go", expected: []string{"@org/core"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, owners(rules, test.path))
+		})
+	}
+}
+
+func TestDownloadCodeOwner(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		location string
+		owner    string
+		kept     []string
+	}{
+		{name: "team", location: ".github/CODEOWNERS", owner: "@org/ops", kept: []string{"build/Makefile", "build/README.md"}},
+		{name: "user without at", location: "CODEOWNERS", owner: "Docs-Writer", kept: []string{"README.md", "src/notes.md"}},
+		{name: "docs location", location: "docs/CODEOWNERS", owner: "@org/core", kept: []string{"src/main.go"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			all := []string{"README.md", "build/Makefile", "build/README.md", "src/main.go", "src/notes.md"}
+			files := map[string]string{
+				test.location: "* @org/core\n*.md @docs-writer\n/" + fakeBase + "/build/ @org/ops\n",
+			}
+			for _, path := range all {
+				files[fakeBase+"/"+path] = path
+			}
+			r := serverRepository(t, contentsMux(files))
+			r.opts.codeOwner = test.owner
+			g := fakeNew(r)
+
+			err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			require.NoError(t, err)
+			for _, path := range all {
+				_, err := os.Stat(fakeBase + "/" + path)
+				assert.Equal(t, slices.Contains(test.kept, path), err == nil, path)
+			}
+		})
+	}
+}
+
+func TestDownloadCodeOwnerError(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{"dir/main.go": "package main"}
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.codeOwner = "@org/core"
+	err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/dir")
+	require.ErrorIs(t, err, ErrCodeOwnersNotFound)
+
+	files["CODEOWNERS"] = "* @org/other\n"
+	r = serverRepository(t, contentsMux(files))
+	r.opts.codeOwner = "@org/core"
+	err = fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/dir")
+	require.ErrorIs(t, err, ErrPathNotFound)
+
+	r = &GitHub{Client: &mockError{}, opts: options{codeOwner: "@org/core"}}
+	_, err = r.codeOwners(context.Background(), nil)
+	require.ErrorIs(t, err, errMockContents)
+}
@@ -0,0 +1,57 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// ErrCaseCollision is returned for two files whose local paths differ only in
+// case, which would be saved as the same file on a case-insensitive file
+// system.
+var ErrCaseCollision = errors.New("file paths differ only in case")
+
+// caseCollisions returns the files and symlinks without those whose local
+// paths differ only in case from the local path of another file or symlink,
+// or fails with ErrCaseCollision, unless those are skipped. The first path in
+// order is kept.
+func (g *GitHub) caseCollisions(files, links []*github.RepositoryContent) ([]*github.RepositoryContent, []*github.RepositoryContent, error) {
+	var paths []string
+	for _, list := range [][]*github.RepositoryContent{files, links} {
+		for _, file := range list {
+			paths = append(paths, file.GetPath())
+		}
+	}
+	slices.Sort(paths)
+
+	seen := make(map[string]string, len(paths))
+	collided := make(map[string]bool)
+	for _, path := range paths {
+		p, err := localPath(g.opts, g.Path, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := strings.ToLower(p)
+		other, ok := seen[key]
+		if !ok {
+			seen[key] = path
+			continue
+		}
+		if !g.opts.skipCaseCollisions {
+			return nil, nil, fmt.Errorf("%w: %s and %s", ErrCaseCollision, other, path)
+		}
+		g.warnings.add(WarnCaseCollisionSkipped, path, "Skipping file colliding in case with "+other)
+		collided[path] = true
+	}
+	if len(collided) == 0 {
+		return files, links, nil
+	}
+
+	skipped := func(file *github.RepositoryContent) bool {
+		return collided[file.GetPath()]
+	}
+	return slices.DeleteFunc(files, skipped), slices.DeleteFunc(links, skipped), nil
+}
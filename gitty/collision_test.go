@@ -0,0 +1,68 @@
+package gitty
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadCaseCollision(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/README.md":   "upper",
+		"dir/Readme.md":   "mixed",
+		"dir/sub/main.go": "main",
+	}
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(files))
+		r.opts.caseInsensitiveFS = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		_, err := r.downloadTo(context.Background(), base)
+		require.ErrorIs(t, err, ErrCaseCollision)
+		require.ErrorContains(t, err, "dir/README.md and dir/Readme.md")
+		assert.Empty(t, savedFiles(t, base))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(files))
+		r.opts.caseInsensitiveFS = true
+		r.opts.skipCaseCollisions = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		result, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dir/README.md", "dir/sub/main.go"}, savedFiles(t, base))
+		assert.Equal(t, []Warning{{Code: WarnCaseCollisionSkipped, Path: "dir/Readme.md", Message: "Skipping file colliding in case with dir/README.md"}}, result.Warnings)
+		assert.Equal(t, 1, result.Summary.Skipped)
+	})
+
+	t.Run("case-sensitive", func(t *testing.T) {
+		t.Parallel()
+		if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+			t.Skip("the file system may be case-insensitive")
+		}
+		r := serverRepository(t, contentsMux(files))
+		r.opts.caseInsensitiveFS = false
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		_, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dir/README.md", "dir/Readme.md", "dir/sub/main.go"}, savedFiles(t, base))
+	})
+}
+
+func TestCaseInsensitiveFSDefault(t *testing.T) {
+	t.Parallel()
+	expected := runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	assert.Equal(t, expected, newOptions().caseInsensitiveFS)
+}
@@ -0,0 +1,44 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+var ErrNoCommit = errors.New("no commit found for path")
+
+// CommitInfo represents the metadata of the last commit that changed a file.
+type CommitInfo struct {
+	SHA    string    `json:"sha"`
+	Author string    `json:"author"`
+	Date   time.Time `json:"date"`
+}
+
+// lastCommit returns the metadata of the last commit at the ref that changed
+// the file at the given path.
+func (g *GitHub) lastCommit(ctx context.Context, owner, repo, ref, path string) (CommitInfo, error) {
+	opts := &github.CommitsListOptions{
+		SHA:         ref,
+		Path:        path,
+		ListOptions: github.ListOptions{PerPage: 1},
+	}
+	commits, _, err := g.Client.ListCommits(ctx, owner, repo, opts)
+	if err != nil {
+		return CommitInfo{}, fmt.Errorf("failed to fetch commit: %w", g.notFound(ctx, owner, repo, ref, err))
+	}
+	if len(commits) == 0 {
+		return CommitInfo{}, fmt.Errorf("%w: %s", ErrNoCommit, path)
+	}
+
+	c := commits[0]
+	author := c.GetCommit().GetAuthor()
+	return CommitInfo{
+		SHA:    c.GetSHA(),
+		Author: author.GetName(),
+		Date:   author.GetDate().Time,
+	}, nil
+}
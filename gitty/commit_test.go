@@ -0,0 +1,58 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commitsMux serves the file dir/file.txt and its last commit.
+func commitsMux() *http.ServeMux {
+	contents := contentsMux(map[string]string{"dir/file.txt": "content"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/commits", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("path") != "dir/file.txt" || q.Get("per_page") != "1" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		fmt.Fprint(w, `[{"sha":"a1b2c3d","commit":{"author":{"name":"Jane Doe","email":"jane@example.com","date":"2024-05-01T10:30:00Z"}}}]`)
+	})
+	mux.Handle("/", contents)
+	return mux
+}
+
+func TestFetchFileWithCommit(t *testing.T) {
+	t.Parallel()
+	g := fakeNew(serverRepository(t, commitsMux()))
+
+	b, commit, err := g.FetchFileWithCommit(context.Background(), "owner", "repo", "", "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+	expected := CommitInfo{
+		SHA:    "a1b2c3d",
+		Author: "Jane Doe",
+		Date:   time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+	}
+	assert.Equal(t, expected, commit)
+
+	_, _, err = g.FetchFileWithCommit(context.Background(), "owner", "repo", "", "dir/missing.txt")
+	require.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestLastCommit(t *testing.T) {
+	t.Parallel()
+	r := serverRepository(t, commitsMux())
+
+	_, err := r.lastCommit(context.Background(), "owner", "repo", "main", "dir/other.txt")
+	require.ErrorIs(t, err, ErrNoCommit)
+
+	r = &GitHub{Client: &mockError{}}
+	_, err = r.lastCommit(context.Background(), "owner", "repo", "main", "dir/file.txt")
+	require.ErrorIs(t, err, errMockListCommits)
+}
@@ -0,0 +1,48 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// gzipExt represents the extension appended to the local path of compressed files.
+const gzipExt = ".gz"
+
+// compressedExtensions represents the extensions of files that are already
+// compressed and gain nothing from being compressed again.
+var compressedExtensions = []string{
+	".gz", ".tgz", ".bz2", ".xz", ".zst", ".lz4", ".zip", ".7z", ".rar",
+	".jar", ".png", ".jpg", ".jpeg", ".gif", ".webp", ".mp3", ".mp4", ".pdf",
+}
+
+// gzipped reports whether the file at the local path p was saved gzipped, as
+// only the file with gzipExt appended exists.
+func gzipped(p string) bool {
+	if _, err := os.Lstat(p); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	_, err := os.Stat(p + gzipExt)
+	return err == nil
+}
+
+// gzipText returns the body of the file at the repository path and reports
+// whether it is saved gzipped. Only text files are compressed, judged by the
+// absence of a NUL byte in the leading bytes, and files with a compressed
+// extension are left as they are.
+func gzipText(o options, path string, body io.Reader) (io.Reader, bool) {
+	if !o.gzip || slices.Contains(compressedExtensions, strings.ToLower(filepath.Ext(path))) {
+		return body, false
+	}
+
+	r := bufio.NewReaderSize(body, binaryPeek)
+	// A failed peek is returned again by the first read of the body.
+	peek, _ := r.Peek(binaryPeek)
+	return r, !bytes.Contains(peek, []byte{0})
+}
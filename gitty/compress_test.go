@@ -0,0 +1,95 @@
+package gitty
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveFileGzip(t *testing.T) {
+	t.Parallel()
+	text := strings.Repeat(gofakeit.LoremIpsumSentence(10)+"\n", 100)
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		gzip    bool
+		saved   string
+	}{
+		{name: "text", file: "README.md", content: text, gzip: true, saved: "README.md.gz"},
+		{name: "empty", file: "empty.txt", content: "", gzip: true, saved: "empty.txt.gz"},
+		{name: "binary", file: "app.bin", content: "\x7fELF\x00\x01" + text, gzip: true, saved: "app.bin"},
+		{name: "compressed", file: "docs.tar.gz", content: text, gzip: true, saved: "docs.tar.gz"},
+		{name: "disabled", file: "README.md", content: text, saved: "README.md"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+
+			err := saveFileMode(context.Background(), options{gzip: test.gzip}, fakeBase, fakeBase+"/"+test.file, 0o600, strings.NewReader(test.content))
+			require.NoError(t, err)
+
+			f, err := os.Open(fakeBase + "/" + test.saved)
+			require.NoError(t, err)
+			defer f.Close()
+			var r io.Reader = f
+			if strings.HasSuffix(test.saved, gzipExt) && !strings.HasSuffix(test.file, gzipExt) {
+				zr, err := gzip.NewReader(f)
+				require.NoError(t, err)
+				r = zr
+			}
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, test.content, string(b))
+		})
+	}
+}
+
+func TestSaveFileGzipBufferSize(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	content := strings.Repeat(gofakeit.LoremIpsumSentence(10), 100)
+
+	err := saveFileMode(context.Background(), options{gzip: true, writeBufferSize: 7}, fakeBase, fakeBase+"/file.txt", 0o600, strings.NewReader(content))
+	require.NoError(t, err)
+
+	f, err := os.Open(fakeBase + "/file.txt.gz")
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(b))
+}
+
+func TestSaveFileGzipError(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+
+	err := saveFileMode(context.Background(), options{gzip: true}, fakeBase, fakeBase+"/file.txt", 0o600, errReader(0))
+	require.Error(t, err)
+	assert.NoFileExists(t, fakeBase+"/file.txt.gz")
+}
@@ -0,0 +1,57 @@
+package gitty
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// deadlineKey represents the context key of the deadline of a download.
+type deadlineKey struct{}
+
+// deadline represents the time limit of a download that is extended by the
+// time spent waiting, such as for the reset of a rate limit or before a
+// retry, so waiting does not count against the download.
+type deadline struct {
+	mu    sync.Mutex
+	end   time.Time
+	timer *time.Timer
+}
+
+// withDeadline returns a copy of ctx that is canceled with the cause
+// context.DeadlineExceeded once d has passed, not counting the time the
+// deadline is extended by. The returned cancel function releases the
+// resources of the deadline.
+func withDeadline(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	dl := &deadline{end: time.Now().Add(d)}
+	dl.timer = time.AfterFunc(d, func() {
+		dl.mu.Lock()
+		left := time.Until(dl.end)
+		if left > 0 {
+			// The deadline was extended in the meantime.
+			dl.timer.Reset(left)
+		}
+		dl.mu.Unlock()
+		if left <= 0 {
+			cancel(context.DeadlineExceeded)
+		}
+	})
+
+	return context.WithValue(ctx, deadlineKey{}, dl), func() {
+		dl.timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// extendDeadline extends the deadline of the download of ctx, if any, by d.
+func extendDeadline(ctx context.Context, d time.Duration) {
+	dl, ok := ctx.Value(deadlineKey{}).(*deadline)
+	if !ok || d <= 0 {
+		return
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.end = dl.end.Add(d)
+}
@@ -0,0 +1,38 @@
+package gitty
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDeadline(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := withDeadline(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	extendDeadline(ctx, 100*time.Millisecond)
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, ctx.Err())
+
+	<-ctx.Done()
+	require.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+	assert.Equal(t, ErrTookTooLong, ctxErr(ctx))
+}
+
+func TestWithDeadlineCancel(t *testing.T) {
+	t.Parallel()
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := withDeadline(parent, time.Minute)
+	defer cancel()
+
+	cancelParent()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, ctxErr(ctx))
+
+	// Without a deadline, extending is a no-op.
+	extendDeadline(context.Background(), time.Second)
+}
@@ -0,0 +1,77 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// duplicate represents a file with the same blob SHA as a file downloaded
+// before, which is saved as a hardlink of it instead of being downloaded.
+type duplicate struct {
+	src *github.RepositoryContent
+	dst *github.RepositoryContent
+}
+
+// splitDuplicates returns the first file of each blob SHA, in order, and the
+// files with the same blob SHA as one of them. Files without a SHA are never
+// duplicates.
+func splitDuplicates(files []*github.RepositoryContent) ([]*github.RepositoryContent, []duplicate) {
+	first := make(map[string]*github.RepositoryContent)
+	var unique []*github.RepositoryContent
+	var dups []duplicate
+	for _, file := range files {
+		sha := file.GetSHA()
+		if src, ok := first[sha]; ok && sha != "" {
+			dups = append(dups, duplicate{src: src, dst: file})
+			continue
+		}
+		first[sha] = file
+		unique = append(unique, file)
+	}
+	return unique, dups
+}
+
+// linkFile saves the downloaded file src at the repository path of dst as a
+// hardlink of src. If hardlinks are not supported, such as across devices or
+// on filesystems without them, src is copied instead.
+func (g *GitHub) linkFile(ctx context.Context, src, dst *github.RepositoryContent) error {
+	from, err := localPath(g.opts, g.Path, src.GetPath())
+	if err != nil {
+		return err
+	}
+	to, err := localPath(g.opts, g.Path, dst.GetPath())
+	if err != nil {
+		return err
+	}
+	if keep, err := keepExisting(g.opts, dst.GetPath(), to); err != nil || keep {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+		return err
+	}
+	link := g.opts.link
+	if link == nil {
+		link = os.Link
+	}
+	// The link is created under a temporary name first, so an existing file
+	// is replaced at once, as when saving.
+	tmp := filepath.Join(filepath.Dir(to), tempPrefix+g.opts.runID+"-"+filepath.Base(to))
+	if err := link(from, tmp); err != nil {
+		fmt.Println("Copying:", to)
+		return g.copyFile(ctx, src, dst.GetPath())
+	}
+	defer os.Remove(tmp)
+
+	fmt.Println("Linking:", to)
+	if err := os.Rename(tmp, to); err != nil {
+		return err
+	}
+
+	g.opts.written.add(relPath(g.opts, g.Path, dst.GetPath(), false), 0)
+	return nil
+}
@@ -0,0 +1,127 @@
+package gitty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDuplicates(t *testing.T) {
+	t.Parallel()
+	file := func(path, sha string) *github.RepositoryContent {
+		return &github.RepositoryContent{Path: ptr(path), SHA: ptr(sha)}
+	}
+	a, b, c, d, e := file("a", "1"), file("b", "2"), file("c", "1"), file("d", ""), file("e", "")
+
+	unique, dups := splitDuplicates([]*github.RepositoryContent{a, b, c, d, e})
+	assert.Equal(t, []*github.RepositoryContent{a, b, d, e}, unique)
+	assert.Equal(t, []duplicate{{src: a, dst: c}}, dups)
+}
+
+func TestDownloadHardlinkDuplicates(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/a.txt":     "same",
+		"dir/b.txt":     "other",
+		"dir/sub/c.txt": "same",
+		"dir/sub/d.txt": "same",
+	}
+	shas := map[string]string{"same": "1111111", "other": "2222222"}
+	errLink := errors.New("hardlinks unsupported")
+	tests := []struct {
+		name   string
+		link   func(oldname, newname string) error
+		linked bool
+	}{
+		{name: "hardlink", linked: true},
+		{name: "copy fallback", link: func(string, string) error { return errLink }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			base := t.TempDir()
+			contents := contentsMux(files)
+			var mu sync.Mutex
+			downloaded := map[string]int{}
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+				// The listed files carry the blob SHAs of their contents.
+				rec := &jsonRecorder{header: http.Header{}}
+				contents.ServeHTTP(rec, r)
+				var entries []*github.RepositoryContent
+				if err := json.Unmarshal(rec.body, &entries); err != nil {
+					w.WriteHeader(rec.code)
+					_, _ = w.Write(rec.body)
+					return
+				}
+				for _, entry := range entries {
+					if content, ok := files[entry.GetPath()]; ok {
+						entry.SHA = ptr(shas[content])
+					}
+				}
+				_ = json.NewEncoder(w).Encode(entries)
+			})
+			mux.HandleFunc("GET /raw/", func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				downloaded[strings.TrimPrefix(r.URL.Path, "/raw/")]++
+				mu.Unlock()
+				contents.ServeHTTP(w, r)
+			})
+			r := serverRepository(t, mux)
+			r.opts.hardlinkDuplicates = true
+			r.opts.link = test.link
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+			result, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+
+			for path, content := range files {
+				b, err := os.ReadFile(filepath.Join(base, path))
+				require.NoError(t, err)
+				assert.Equal(t, content, string(b), path)
+			}
+			mu.Lock()
+			assert.Equal(t, map[string]int{"dir/a.txt": 1, "dir/b.txt": 1}, downloaded)
+			mu.Unlock()
+			assert.Equal(t, 4, result.Summary.Written)
+
+			a, err := os.Stat(filepath.Join(base, "dir/a.txt"))
+			require.NoError(t, err)
+			for _, path := range []string{"dir/sub/c.txt", "dir/sub/d.txt"} {
+				dup, err := os.Stat(filepath.Join(base, path))
+				require.NoError(t, err)
+				assert.Equal(t, test.linked, os.SameFile(a, dup), path)
+			}
+			entries, err := os.ReadDir(filepath.Join(base, "dir/sub"))
+			require.NoError(t, err)
+			assert.Len(t, entries, 2, "want no temporary files left")
+		})
+	}
+}
+
+// jsonRecorder records the response of a handler to rewrite it.
+type jsonRecorder struct {
+	header http.Header
+	code   int
+	body   []byte
+}
+
+func (r *jsonRecorder) Header() http.Header { return r.header }
+
+func (r *jsonRecorder) WriteHeader(code int) { r.code = code }
+
+func (r *jsonRecorder) Write(b []byte) (int, error) {
+	r.body = append(r.body, b...)
+	return len(b), nil
+}
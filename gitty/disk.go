@@ -0,0 +1,55 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-github/v70/github"
+)
+
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// estimateSize returns the total size in bytes of the given files.
+func estimateSize(files []*github.RepositoryContent) uint64 {
+	var size uint64
+	for _, file := range files {
+		if s := file.GetSize(); s > 0 {
+			size += uint64(s)
+		}
+	}
+	return size
+}
+
+// checkSpace reports whether dir has enough free disk space for the files
+// while keeping the configured minimum free space.
+func checkSpace(o options, dir string, files []*github.RepositoryContent) error {
+	free, err := o.freeSpace(existingDir(dir))
+	if err != nil {
+		return fmt.Errorf("failed to check disk space: %w", err)
+	}
+
+	need := estimateSize(files) + o.minFreeSpace
+	if need > free {
+		return fmt.Errorf("%w: need %d bytes, %d bytes available", ErrInsufficientSpace, need, free)
+	}
+
+	return nil
+}
+
+// existingDir returns dir, or its nearest ancestor that exists if the
+// download has yet to create it. An empty dir refers to the current directory.
+func existingDir(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,14 @@
+//go:build darwin || linux
+
+package gitty
+
+import "syscall"
+
+// diskFree returns the available disk space in bytes of the directory.
+func diskFree(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil //nolint:unconvert // Types differ across platforms.
+}
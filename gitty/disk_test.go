@@ -0,0 +1,109 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errMockFreeSpace = errors.New("mock free space error")
+
+func TestEstimateSize(t *testing.T) {
+	t.Parallel()
+	files := []*github.RepositoryContent{
+		{Size: ptr(10)},
+		{Size: ptr(20)},
+		{},
+	}
+	assert.Equal(t, uint64(30), estimateSize(files))
+}
+
+func TestCheckSpace(t *testing.T) {
+	t.Parallel()
+	files := []*github.RepositoryContent{
+		{Size: ptr(100)},
+		{Size: ptr(50)},
+	}
+	freeSpace := func(n uint64, err error) func(string) (uint64, error) {
+		return func(_ string) (uint64, error) {
+			return n, err
+		}
+	}
+
+	tests := []struct {
+		name     string
+		opts     options
+		expected error
+	}{
+		{
+			name:     "enough space",
+			opts:     options{freeSpace: freeSpace(150, nil)},
+			expected: nil,
+		},
+		{
+			name:     "not enough space",
+			opts:     options{freeSpace: freeSpace(149, nil)},
+			expected: fmt.Errorf("%w: need %d bytes, %d bytes available", ErrInsufficientSpace, 150, 149),
+		},
+		{
+			name:     "not enough space with minimum free space",
+			opts:     options{freeSpace: freeSpace(200, nil), minFreeSpace: 100},
+			expected: fmt.Errorf("%w: need %d bytes, %d bytes available", ErrInsufficientSpace, 250, 200),
+		},
+		{
+			name:     "error free space",
+			opts:     options{freeSpace: freeSpace(0, errMockFreeSpace)},
+			expected: fmt.Errorf("failed to check disk space: %w", errMockFreeSpace),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			err := checkSpace(test.opts, ".", files)
+			assert.Equal(t, test.expected, err)
+		})
+	}
+}
+
+func TestDownloadCheckSpace(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	r := serverRepository(t, contentsMux(map[string]string{"dir/file.txt": "data"}))
+	var checked string
+	r.opts.checkSpace = true
+	r.opts.freeSpace = func(dir string) (uint64, error) {
+		checked = dir
+		return 0, nil
+	}
+	r.opts.outputDir = filepath.Join(tmp, "out", "nested")
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.download(context.Background())
+	require.ErrorIs(t, err, ErrInsufficientSpace)
+	// The space is checked on the disk of the output directory, which is yet
+	// to be created.
+	assert.Equal(t, tmp, checked)
+}
+
+func TestExistingDir(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	assert.Equal(t, tmp, existingDir(tmp))
+	assert.Equal(t, tmp, existingDir(filepath.Join(tmp, "a", "b")))
+	assert.Equal(t, ".", existingDir(""))
+	assert.Equal(t, ".", existingDir("missing"))
+}
+
+func TestDiskFree(t *testing.T) {
+	t.Parallel()
+	free, err := diskFree(".")
+	require.NoError(t, err)
+	assert.Positive(t, free)
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !darwin && !linux
+
+package gitty
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// diskFree returns an error for unsupported os.
+func diskFree(_ string) (uint64, error) {
+	return 0, fmt.Errorf("%v is currently unsupported", runtime.GOOS)
+}
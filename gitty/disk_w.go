@@ -0,0 +1,24 @@
+//go:build windows
+
+package gitty
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// diskFree returns the available disk space in bytes of the directory.
+func diskFree(dir string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var free uint64
+	proc := syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+	r, _, e := proc.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if r == 0 {
+		return 0, e
+	}
+	return free, nil
+}
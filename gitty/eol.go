@@ -0,0 +1,76 @@
+package gitty
+
+import (
+	"io"
+	"slices"
+	"sync"
+)
+
+// binaryPeek represents the number of leading bytes searched for a NUL byte
+// to tell binary files from text files, as git does.
+const binaryPeek = 8000
+
+// lineEndings collects the repository paths of saved text files that mix
+// CRLF and LF line endings. It is safe for concurrent use.
+type lineEndings struct {
+	mu    sync.Mutex
+	mixed []string
+}
+
+// scan returns a reader of the body that records the file at the repository
+// path if it is a text file with mixed line endings once the body is read to
+// the end. A nil lineEndings returns the body unchanged.
+func (l *lineEndings) scan(path string, body io.Reader) io.Reader {
+	if l == nil {
+		return body
+	}
+	return &eolReader{l: l, path: path, r: body}
+}
+
+// all returns the recorded repository paths in order.
+func (l *lineEndings) all() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Sorted(slices.Values(l.mixed))
+}
+
+// eolReader represents a reader that detects the line endings of the content
+// it reads, without modifying it.
+type eolReader struct {
+	l      *lineEndings
+	path   string
+	r      io.Reader
+	n      int
+	cr     bool
+	crlf   bool
+	lf     bool
+	binary bool
+	done   bool
+}
+
+func (r *eolReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for _, b := range p[:n] {
+		if b == 0 && r.n < binaryPeek {
+			r.binary = true
+		}
+		if b == '\n' {
+			if r.cr {
+				r.crlf = true
+			} else {
+				r.lf = true
+			}
+		}
+		r.cr = b == '\r'
+		r.n++
+	}
+	if err == io.EOF && !r.done {
+		r.done = true
+		if !r.binary && r.crlf && r.lf {
+			r.l.mu.Lock()
+			r.l.mixed = append(r.l.mixed, r.path)
+			r.l.mu.Unlock()
+		}
+	}
+	return n, err
+}
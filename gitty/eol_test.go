@@ -0,0 +1,73 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineEndings(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		content string
+		mixed   bool
+	}{
+		{name: "lf", content: "a\nb\nc\n"},
+		{name: "crlf", content: "a\r\nb\r\nc\r\n"},
+		{name: "mixed", content: "a\r\nb\nc\r\n", mixed: true},
+		{name: "no newline", content: "abc"},
+		{name: "binary", content: "a\r\nb\n\x00c"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			l := &lineEndings{}
+			// Read one byte at a time, so a CRLF spans reads.
+			b, err := io.ReadAll(l.scan("file.txt", iotest.OneByteReader(strings.NewReader(test.content))))
+			require.NoError(t, err)
+			assert.Equal(t, test.content, string(b))
+			if test.mixed {
+				assert.Equal(t, []string{"file.txt"}, l.all())
+			} else {
+				assert.Empty(t, l.all())
+			}
+		})
+	}
+
+	var nilEndings *lineEndings
+	r := strings.NewReader("data")
+	assert.Equal(t, io.Reader(r), nilEndings.scan("file.txt", r))
+}
+
+func TestDownloadMixedLineEndings(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/clean.txt": "a\nb\n",
+		fakeBase + "/mixed.txt": "a\r\nb\n",
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.detectLineEndings = true
+	result, err := fakeNew(r).DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Warning{{Code: WarnMixedLineEndings, Path: fakeBase + "/mixed.txt", Message: "Mixed line endings"}}, result.Warnings)
+	b, err := os.ReadFile(fakeBase + "/mixed.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a\r\nb\n", string(b))
+}
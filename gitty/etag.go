@@ -0,0 +1,86 @@
+package gitty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// etagExt represents the extension of the files ETags are cached in.
+const etagExt = ".etag"
+
+// etagFile returns the path of the file the ETag of the file of the
+// repository path at the ref is cached in, beneath the cache directory.
+func (g *GitHub) etagFile(o options, path string) string {
+	sum := sha256.Sum256([]byte(webHost(o) + "/" + g.Owner + "/" + g.Repo + "@" + g.ref() + ":" + path))
+	return filepath.Join(o.cacheDir, hex.EncodeToString(sum[:])+etagExt)
+}
+
+// cachedETag returns the ETag cached for the file of the repository path, if
+// the file is still saved at the local path p.
+func (g *GitHub) cachedETag(o options, path, p string) (string, error) {
+	if info, err := os.Stat(p); err != nil || !info.Mode().IsRegular() {
+		return "", nil
+	}
+	b, err := os.ReadFile(g.etagFile(o, path))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// cacheETag caches the ETag of the file of the repository path, if any.
+func (g *GitHub) cacheETag(o options, path, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	if err := os.MkdirAll(o.cacheDir, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(g.etagFile(o, path), []byte(etag), 0o600)
+}
+
+// getFileConditional retrieves a file like getFileWith, with the ETag cached
+// for it when it was saved before. If the content still has the ETag, the
+// saved file is kept as it is and reported with WarnUnchangedSkipped.
+func (g *GitHub) getFileConditional(ctx context.Context, o options, url, path string) error {
+	p, err := localPath(o, g.Path, path)
+	if err != nil {
+		return err
+	}
+	etag, err := g.cachedETag(o, path, p)
+	if err != nil {
+		return fmt.Errorf("failed to read ETag cache: %w", err)
+	}
+
+	resp, err := g.Client.GetIfNoneMatch(ctx, url, etag)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		g.warnings.add(WarnUnchangedSkipped, path, "Skipping unchanged file")
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(resp)
+	}
+
+	if err := g.saveBody(ctx, o, path, resp.Body); err != nil {
+		return err
+	}
+	if err := g.cacheETag(o, path, resp.Header.Get("ETag")); err != nil {
+		return fmt.Errorf("failed to write ETag cache: %w", err)
+	}
+	return nil
+}
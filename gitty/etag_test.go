@@ -0,0 +1,111 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagMux serves the file of contentsMux with an ETag and responds with 304
+// (Not Modified) to requests with the ETag. It counts the requests with the
+// ETag.
+func etagMux(etag string) (http.Handler, *atomic.Int32) {
+	var conditional atomic.Int32
+	contents := contentsMux(map[string]string{"dir/file.txt": "content"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /raw/dir/file.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	return mux, &conditional
+}
+
+func TestDownloadCacheDir(t *testing.T) {
+	t.Parallel()
+	mux, conditional := etagMux(`"v1"`)
+	cache := t.TempDir()
+	base := t.TempDir()
+	download := func() Result {
+		r := serverRepository(t, mux)
+		r.opts.cacheDir = cache
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		result, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+		return result
+	}
+
+	result := download()
+	assert.Empty(t, result.Warnings)
+	assert.Zero(t, conditional.Load())
+	p := filepath.Join(base, "dir", "file.txt")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(p, past, past))
+
+	result = download()
+	assert.Equal(t, int32(1), conditional.Load())
+	assert.Equal(t, []Warning{{Code: WarnUnchangedSkipped, Path: "dir/file.txt", Message: "Skipping unchanged file"}}, result.Warnings)
+	assert.Equal(t, 1, result.Summary.Skipped)
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(past), "file rewritten")
+	b, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+
+	// A removed file is downloaded again without the ETag.
+	require.NoError(t, os.Remove(p))
+	download()
+	assert.Equal(t, int32(1), conditional.Load())
+	b, err = os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+}
+
+func TestDownloadCacheDirChanged(t *testing.T) {
+	t.Parallel()
+	cache := t.TempDir()
+	base := t.TempDir()
+	for _, etag := range []string{`"v1"`, `"v2"`} {
+		mux, conditional := etagMux(etag)
+		r := serverRepository(t, mux)
+		r.opts.cacheDir = cache
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		result, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+		assert.Empty(t, result.Warnings)
+		assert.Zero(t, conditional.Load())
+	}
+
+	r := &GitHub{Owner: "owner", Repo: "repo", Ref: &github.RepositoryContentGetOptions{Ref: "main"}}
+	b, err := os.ReadFile(r.etagFile(options{cacheDir: cache}, "dir/file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, `"v2"`, string(b))
+}
+
+func TestDownloadCacheDirError(t *testing.T) {
+	t.Parallel()
+	mux, _ := etagMux(`"v1"`)
+	// The cache directory is a file, so the ETag cannot be cached.
+	cache := filepath.Join(t.TempDir(), "cache")
+	require.NoError(t, os.WriteFile(cache, nil, 0o600))
+	r := serverRepository(t, mux)
+	r.opts.cacheDir = cache
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	require.ErrorContains(t, err, "failed to write ETag cache")
+}
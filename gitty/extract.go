@@ -0,0 +1,196 @@
+package gitty
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// archiveExtensions represents the extensions of the downloaded archives that
+// are extracted, longest first.
+var archiveExtensions = []string{".tar.gz", ".tgz", ".zip"}
+
+// ErrNotValidArchivePath is returned for an archive entry outside of the
+// directory the archive is extracted into.
+var ErrNotValidArchivePath = errors.New("archive path must be relative to the extracted directory")
+
+// archiveDir returns the directory the archive at the path is extracted
+// into, the path without its archive extension, such as docs for docs.tar.gz.
+// It reports false if the path is not an archive.
+func archiveDir(p string) (string, bool) {
+	lower := strings.ToLower(p)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) && len(p) > len(ext) {
+			return p[:len(p)-len(ext)], true
+		}
+	}
+	return "", false
+}
+
+// extractArchives extracts the downloaded archives into the directories
+// beside them, named after the archives without their extensions. The
+// archives are removed unless they are kept.
+func (g *GitHub) extractArchives(files []*github.RepositoryContent) error {
+	for _, file := range files {
+		p, err := localPath(g.opts, g.Path, file.GetPath())
+		if err != nil {
+			return err
+		}
+		dir, ok := archiveDir(p)
+		if !ok {
+			continue
+		}
+
+		fmt.Println("Extracting:", p)
+		err = extractArchive(p, dir)
+		// An archive saved under another name, such as renamed by a recipe, is
+		// not extracted.
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.GetPath(), err)
+		}
+		if g.opts.keepArchives {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractArchive extracts the zip or gzipped tar archive at the path into dir.
+func extractArchive(p, dir string) error {
+	if strings.HasSuffix(strings.ToLower(p), ".zip") {
+		return extractZip(p, dir)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return extractTar(f, dir)
+}
+
+// extractZip extracts the regular files and directories of the zip archive
+// at the path into dir.
+func extractZip(p, dir string) error {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		target, err := archivePath(dir, entry.Name)
+		if err != nil {
+			return err
+		}
+		mode := entry.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+
+		r, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = writeEntry(target, mode, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractTar extracts the regular files and directories of the gzipped tar
+// archive into dir.
+func extractTar(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := archivePath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeEntry(target, hdr.FileInfo().Mode(), tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// archivePath returns the local path of the archive entry name beneath dir.
+// Entries outside of dir, such as ../file or /file, are rejected, so an
+// archive cannot write files elsewhere.
+func archivePath(dir, name string) (string, error) {
+	p := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("%w: %s", ErrNotValidArchivePath, name)
+	}
+	return filepath.Join(dir, p), nil
+}
+
+// writeEntry saves the content of the archive entry at the path and keeps
+// the executable bit of mode.
+func writeEntry(p string, mode os.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0o600)
+	if mode&0o111 != 0 {
+		perm = 0o700
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
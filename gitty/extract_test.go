@@ -0,0 +1,132 @@
+package gitty
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipArchive returns a zip archive of the given files, keyed by entry name.
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveDir(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{path: "dir/docs.zip", expected: "dir/docs", ok: true},
+		{path: "dir/src.tar.gz", expected: "dir/src", ok: true},
+		{path: "dir/SRC.TGZ", expected: "dir/SRC", ok: true},
+		{path: "dir/file.gz", ok: false},
+		{path: "dir/file.txt", ok: false},
+		{path: ".zip", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+			dir, ok := archiveDir(test.path)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.expected, dir)
+		})
+	}
+}
+
+func TestDownloadExtractArchives(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		keep bool
+	}{
+		{name: "in place", keep: false},
+		{name: "alongside", keep: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			files := map[string]string{
+				fakeBase + "/README.md":  "readme",
+				fakeBase + "/docs.zip":   string(zipArchive(t, map[string]string{"guide/intro.md": "intro"})),
+				fakeBase + "/src.tar.gz": string(tarball(t, map[string]string{"main.go": "package main"}, nil)),
+			}
+			r := serverRepository(t, contentsMux(files))
+			r.opts.extractArchives = true
+			r.opts.keepArchives = test.keep
+
+			err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			require.NoError(t, err)
+
+			b, err := os.ReadFile(filepath.Join(fakeBase, "docs", "guide", "intro.md"))
+			require.NoError(t, err)
+			assert.Equal(t, "intro", string(b))
+			// The tarball of the archive helper is prefixed with its top-level directory.
+			b, err = os.ReadFile(filepath.Join(fakeBase, "src", "owner-repo-abc1234", "main.go"))
+			require.NoError(t, err)
+			assert.Equal(t, "package main", string(b))
+
+			for _, name := range []string{"docs.zip", "src.tar.gz"} {
+				_, err = os.Stat(filepath.Join(fakeBase, name))
+				if test.keep {
+					require.NoError(t, err)
+				} else {
+					require.ErrorIs(t, err, os.ErrNotExist)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchiveOutside(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{name: "parent", entry: "../evil.txt"},
+		{name: "nested parent", entry: "dir/../../evil.txt"},
+		{name: "absolute", entry: "/evil.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			tmp := t.TempDir()
+			p := filepath.Join(tmp, "archive.zip")
+			err := os.WriteFile(p, zipArchive(t, map[string]string{test.entry: "evil"}), 0o600)
+			require.NoError(t, err)
+
+			err = extractArchive(p, filepath.Join(tmp, "archive"))
+			require.ErrorIs(t, err, ErrNotValidArchivePath)
+			_, err = os.Stat(filepath.Join(tmp, "evil.txt"))
+			require.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+}
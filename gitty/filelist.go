@@ -0,0 +1,39 @@
+package gitty
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// writeFileList writes the paths of the downloaded files as saved, without the
+// base directory chosen by the router, to w. The paths are sorted and use
+// forward slashes on all platforms, one per line, so the lists of different
+// downloads can be diffed.
+func writeFileList(w io.Writer, o options, base string, files []*github.RepositoryContent) error {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		flat, err := o.flat.path(base, file.GetPath())
+		if err != nil {
+			return err
+		}
+		p, err := exactPath(base, flat)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(p))
+	}
+	sort.Strings(paths)
+
+	bw := bufio.NewWriter(w)
+	for _, p := range paths {
+		if _, err := bw.WriteString(p + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
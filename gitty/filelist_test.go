@@ -0,0 +1,68 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileList(t *testing.T) {
+	t.Parallel()
+	files := []*github.RepositoryContent{
+		{Path: ptr("dir/sub/b.txt")},
+		{Path: ptr("dir/a.txt")},
+		{Path: ptr("dir/B.txt")},
+		{Path: ptr("dir/sub/a/z.txt")},
+	}
+	expected := "dir/B.txt\ndir/a.txt\ndir/sub/a/z.txt\ndir/sub/b.txt\n"
+
+	var buf bytes.Buffer
+	err := writeFileList(&buf, options{}, "dir", files)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+
+	// The list is the same for files listed in another order.
+	buf.Reset()
+	err = writeFileList(&buf, options{}, "dir", []*github.RepositoryContent{files[3], files[1], files[0], files[2]})
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+
+	// Paths joined with the separator of the platform use forward slashes.
+	buf.Reset()
+	err = writeFileList(&buf, options{}, filepath.Join("repo", "dir"), []*github.RepositoryContent{{Path: ptr("repo/dir/sub/b.txt")}})
+	require.NoError(t, err)
+	assert.Equal(t, "dir/sub/b.txt\n", buf.String())
+
+	err = writeFileList(errWriter{}, options{}, "dir", files)
+	require.Error(t, err)
+}
+
+func TestDownloadFileList(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	dir := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	files := map[string]string{
+		dir + "/z.txt":         "z",
+		dir + "/a/b/c.txt":     "c",
+		dir + "/a.txt":         "a",
+		dir + "/docs/guide.md": "guide",
+	}
+
+	var buf bytes.Buffer
+	r := serverRepository(t, contentsMux(files))
+	r.opts.fileList = &buf
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/"+dir))
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	// The list does not depend on the output directory.
+	expected := dir + "/a.txt\n" + dir + "/a/b/c.txt\n" + dir + "/docs/guide.md\n" + dir + "/z.txt\n"
+	assert.Equal(t, expected, buf.String())
+}
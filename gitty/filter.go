@@ -0,0 +1,147 @@
+package gitty
+
+import (
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// filterGlobs keeps the files matching an include pattern, if any are set,
+// and neither matching an exclude pattern nor ignored by the ignore file.
+// Exclude patterns win over include patterns. If the URL path is a glob
+// pattern, only the files it matches are kept.
+func (g *GitHub) filterGlobs(files []*github.RepositoryContent) []*github.RepositoryContent {
+	var kept []*github.RepositoryContent
+	for _, file := range files {
+		if g.included(file.GetPath()) && !g.excluded(file.GetPath()) && !g.ignored(file.GetPath(), false) && g.globbed(file.GetPath(), false) {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// included reports whether the repository path matches an include pattern,
+// or whether no include patterns are set.
+func (g *GitHub) included(p string) bool {
+	return len(g.opts.include) == 0 || g.matchGlobs(g.opts.include, p)
+}
+
+// excluded reports whether the repository path matches an exclude pattern.
+func (g *GitHub) excluded(p string) bool {
+	return g.matchGlobs(g.opts.exclude, p)
+}
+
+// matchGlobs reports whether a pattern matches the repository path relative
+// to the requested directory, as matchAttr does.
+func (g *GitHub) matchGlobs(patterns []string, p string) bool {
+	rel := strings.TrimPrefix(strings.TrimPrefix(p, g.Path), "/")
+	rel = globCase(g.opts, rel)
+	for _, pattern := range patterns {
+		if matchAttr(globCase(g.opts, pattern), rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterDepth keeps the files within the maximum depth beneath the GitHub
+// path.
+func (g *GitHub) filterDepth(files []*github.RepositoryContent) []*github.RepositoryContent {
+	var kept []*github.RepositoryContent
+	for _, file := range files {
+		if g.withinDepth(file.GetPath(), false) {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// withinDepth reports whether the repository path, a directory if dir is set,
+// lies within the maximum depth beneath the GitHub path, or whether no
+// maximum depth is set. The files directly in the GitHub path are at depth 1,
+// and a directory lies within the depth if the files directly in it do.
+func (g *GitHub) withinDepth(p string, dir bool) bool {
+	if !g.opts.depthLimit {
+		return true
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(p, g.Path), "/")
+	depth := strings.Count(rel, "/") + 1
+	if dir {
+		depth++
+	}
+	return depth <= max(g.opts.maxDepth, 1)
+}
+
+// globChars represents the characters that make a segment of the path of a
+// URL a glob pattern.
+const globChars = "*?["
+
+// splitGlob returns the longest prefix of literal directories of the path of
+// a URL, before the first segment containing a glob character, and reports
+// whether the path is a glob pattern.
+func splitGlob(p string) (string, bool) {
+	strs := strings.Split(p, "/")
+	for i, s := range strs {
+		if strings.ContainsAny(s, globChars) {
+			return strings.Join(strs[:i], "/"), true
+		}
+	}
+	return p, false
+}
+
+// globbed reports whether the repository path, a directory if dir is set,
+// matches the glob pattern of the URL path, or whether the URL path is not
+// a glob pattern. A directory matches if the files beneath it may match.
+func (g *GitHub) globbed(p string, dir bool) bool {
+	if g.glob == "" {
+		return true
+	}
+	pattern := strings.Split(globCase(g.opts, g.glob), "/")
+	strs := strings.Split(globCase(g.opts, p), "/")
+	if dir {
+		return matchGlobDir(pattern, strs)
+	}
+	return matchGlob(pattern, strs)
+}
+
+// matchGlob reports whether the segments of the pattern match the segments
+// of the whole path. A ** segment matches any number of directories,
+// including none, and other segments match as by path.Match.
+func matchGlob(pattern, strs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := range len(strs) + 1 {
+				if matchGlob(pattern[1:], strs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(strs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], strs[0]); !ok {
+			return false
+		}
+		pattern, strs = pattern[1:], strs[1:]
+	}
+	return len(strs) == 0
+}
+
+// matchGlobDir reports whether the segments of the pattern may match the
+// paths beneath the directory of the given segments.
+func matchGlobDir(pattern, dir []string) bool {
+	for i, s := range dir {
+		if i >= len(pattern) {
+			return false
+		}
+		if pattern[i] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[i], s); !ok {
+			return false
+		}
+	}
+	return len(dir) < len(pattern)
+}
@@ -0,0 +1,267 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// savedFiles returns the slash separated paths of the files under base.
+func savedFiles(t *testing.T, base string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.WalkDir(base, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(base, p)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	require.NoError(t, err)
+	sort.Strings(paths)
+	return paths
+}
+
+func TestFilterGlobs(t *testing.T) {
+	t.Parallel()
+	paths := []string{
+		"dir/main.go",
+		"dir/main_test.go",
+		"dir/README.md",
+		"dir/docs/guide.md",
+		"dir/internal/util.go",
+		"dir/testdata/fixture.go",
+	}
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		insens   bool
+		expected []string
+	}{
+		{name: "none", expected: paths},
+		{
+			name:     "include",
+			include:  []string{"*.go"},
+			expected: []string{"dir/main.go", "dir/main_test.go", "dir/internal/util.go", "dir/testdata/fixture.go"},
+		},
+		{
+			name:     "exclude",
+			exclude:  []string{"testdata/"},
+			expected: []string{"dir/main.go", "dir/main_test.go", "dir/README.md", "dir/docs/guide.md", "dir/internal/util.go"},
+		},
+		{
+			name:     "exclude wins",
+			include:  []string{"*.go", "docs"},
+			exclude:  []string{"testdata", "*_test.go"},
+			expected: []string{"dir/main.go", "dir/docs/guide.md", "dir/internal/util.go"},
+		},
+		{
+			name:     "relative path",
+			include:  []string{"internal/*.go", "/*.md"},
+			expected: []string{"dir/README.md", "dir/internal/util.go"},
+		},
+		{
+			name:     "case insensitive",
+			include:  []string{"readme.MD"},
+			insens:   true,
+			expected: []string{"dir/README.md"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			files := make([]*github.RepositoryContent, 0, len(paths))
+			for _, p := range paths {
+				files = append(files, &github.RepositoryContent{Path: github.Ptr(p)})
+			}
+			g := &GitHub{Path: "dir", opts: options{include: test.include, exclude: test.exclude, caseInsensitiveGlobs: test.insens}}
+
+			var got []string
+			for _, file := range g.filterGlobs(files) {
+				got = append(got, file.GetPath())
+			}
+			assert.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestDownloadIncludeExclude(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	contents := contentsMux(map[string]string{
+		"dir/main.go":             "main",
+		"dir/main_test.go":        "test",
+		"dir/README.md":           "readme",
+		"dir/sub/util.go":         "util",
+		"dir/testdata/fixture.go": "fixture",
+	})
+	var walked atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/dir/testdata", func(w http.ResponseWriter, r *http.Request) {
+		walked.Add(1)
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	r := serverRepository(t, mux)
+	r.opts.include = []string{"*.go"}
+	r.opts.exclude = []string{"testdata", "*_test.go"}
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dir/main.go", "dir/sub/util.go"}, savedFiles(t, base))
+	assert.Zero(t, walked.Load(), "excluded directory walked")
+}
+
+func TestDownloadMaxDepth(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/main.go":          "main",
+		"dir/sub/util.go":      "util",
+		"dir/sub/deep/data.go": "data",
+	}
+	tests := []struct {
+		name     string
+		depth    int
+		tree     bool
+		expected []string
+		walked   int32
+	}{
+		{name: "depth 0", depth: 0, expected: []string{"dir/main.go"}},
+		{name: "depth 1", depth: 1, expected: []string{"dir/main.go"}},
+		{name: "depth 2", depth: 2, expected: []string{"dir/main.go", "dir/sub/util.go"}},
+		{name: "depth 3", depth: 3, expected: []string{"dir/main.go", "dir/sub/deep/data.go", "dir/sub/util.go"}, walked: 1},
+		{name: "tree depth 2", depth: 2, tree: true, expected: []string{"dir/main.go", "dir/sub/util.go"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			base := t.TempDir()
+			var walked, requests atomic.Int32
+			var r *GitHub
+			if test.tree {
+				r = treeRepository(t, treeMux(files, nil, &requests))
+			} else {
+				contents := contentsMux(files)
+				mux := http.NewServeMux()
+				mux.HandleFunc("GET /repos/owner/repo/contents/dir/sub/deep", func(w http.ResponseWriter, r *http.Request) {
+					walked.Add(1)
+					contents.ServeHTTP(w, r)
+				})
+				mux.Handle("/", contents)
+				r = serverRepository(t, mux)
+			}
+			r.opts.depthLimit = true
+			r.opts.maxDepth = test.depth
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+			_, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, savedFiles(t, base))
+			assert.Equal(t, test.walked, walked.Load(), "directory beyond the depth walked")
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+		dir      bool
+	}{
+		{pattern: "src/*.go", path: "src/main.go", expected: true},
+		{pattern: "src/*.go", path: "src/pkg/util.go", expected: false},
+		{pattern: "src/**/*.go", path: "src/main.go", expected: true},
+		{pattern: "src/**/*.go", path: "src/pkg/deep/data.go", expected: true},
+		{pattern: "src/**/*.go", path: "src/README.md", expected: false},
+		{pattern: "src/**", path: "src/pkg/util.go", expected: true},
+		{pattern: "*/*.md", path: "docs/guide.md", expected: true},
+		{pattern: "src/*.go", path: "src", expected: true, dir: true},
+		{pattern: "src/*.go", path: "src/pkg", expected: false, dir: true},
+		{pattern: "src/**/*.go", path: "src/pkg/deep", expected: true, dir: true},
+		{pattern: "src/**/*.go", path: "docs", expected: false, dir: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.path, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{glob: test.pattern}
+			assert.Equal(t, test.expected, g.globbed(test.path, test.dir))
+		})
+	}
+
+	prefix, ok := splitGlob("src/**/*.go")
+	assert.True(t, ok)
+	assert.Equal(t, "src", prefix)
+	prefix, ok = splitGlob("src/pkg")
+	assert.False(t, ok)
+	assert.Equal(t, "src/pkg", prefix)
+	assert.True(t, (&GitHub{}).globbed("any/path", false))
+}
+
+func TestDownloadURLGlob(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"docs/guide.md":        "guide",
+		"src/README.md":        "readme",
+		"src/main.go":          "main",
+		"src/pkg/util.go":      "util",
+		"src/pkg/deep/data.go": "data",
+	}
+	tests := []struct {
+		name     string
+		path     string
+		tree     bool
+		expected []string
+		walked   int32
+	}{
+		{name: "star", path: "src/*.go", expected: []string{"src/main.go"}},
+		{name: "double star", path: "src/**/*.go", expected: []string{"src/main.go", "src/pkg/deep/data.go", "src/pkg/util.go"}, walked: 1},
+		{name: "root star", path: "*/*.md", expected: []string{"docs/guide.md", "src/README.md"}},
+		{name: "tree double star", path: "src/**/*.go", tree: true, expected: []string{"src/main.go", "src/pkg/deep/data.go", "src/pkg/util.go"}},
+		{name: "literal", path: "src/pkg", expected: []string{"pkg/deep/data.go", "pkg/util.go"}, walked: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			base := t.TempDir()
+			var walked, requests atomic.Int32
+			var r *GitHub
+			if test.tree {
+				r = treeRepository(t, treeMux(files, nil, &requests))
+			} else {
+				contents := contentsMux(files)
+				mux := http.NewServeMux()
+				mux.HandleFunc("GET /repos/owner/repo/contents/src/pkg/deep", func(w http.ResponseWriter, r *http.Request) {
+					walked.Add(1)
+					contents.ServeHTTP(w, r)
+				})
+				mux.Handle("/", contents)
+				r = serverRepository(t, mux)
+			}
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/"+test.path))
+
+			_, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, savedFiles(t, base))
+			assert.Equal(t, test.walked, walked.Load(), "directory outside the pattern walked")
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// CollisionMode represents the policy for files of the same name in different
+// directories, which would be saved at the same path when flattened.
+type CollisionMode int
+
+const (
+	// CollisionError aborts the download with ErrFlattenCollision at the
+	// first file whose name is taken. It is the default.
+	CollisionError CollisionMode = iota
+	// CollisionSuffix saves the file under its name with a numeric suffix
+	// before the extension, such as README-1.md.
+	CollisionSuffix
+)
+
+// ErrFlattenCollision is returned for a file whose name is already taken by
+// another file when flattened, if the collision mode is CollisionError.
+var ErrFlattenCollision = errors.New("file name already taken when flattened")
+
+// flattener assigns the flat names of the files of a flattened download,
+// keyed by repository path. It is safe for concurrent use.
+type flattener struct {
+	mu    sync.Mutex
+	mode  CollisionMode
+	names map[string]string
+	taken map[string]bool
+}
+
+// assign assigns the names of the files and symlinks in the order of their
+// repository paths, so the suffixes do not depend on the order the files are
+// saved in.
+func (f *flattener) assign(lists ...[]*github.RepositoryContent) error {
+	if f == nil {
+		return nil
+	}
+	var paths []string
+	for _, files := range lists {
+		for _, file := range files {
+			paths = append(paths, file.GetPath())
+		}
+	}
+	slices.Sort(paths)
+	for _, p := range paths {
+		if _, err := f.name(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name returns the flat name of the file of the repository path, assigned
+// once per path.
+func (f *flattener) name(p string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if name, ok := f.names[p]; ok {
+		return name, nil
+	}
+
+	name := path.Base(p)
+	if f.taken[name] {
+		if f.mode != CollisionSuffix {
+			return "", fmt.Errorf("%w: %s", ErrFlattenCollision, p)
+		}
+		ext := path.Ext(name)
+		stem := strings.TrimSuffix(name, ext)
+		for i := 1; f.taken[name]; i++ {
+			name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+		}
+	}
+	f.taken[name] = true
+	f.names[p] = name
+	return name, nil
+}
+
+// path returns the repository path of the file as saved beneath base when
+// flattened, if f is not nil. A single file requested as base is unchanged.
+func (f *flattener) path(base, p string) (string, error) {
+	if f == nil || p == base {
+		return p, nil
+	}
+	name, err := f.name(p)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(base, name), nil
+}
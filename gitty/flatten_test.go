@@ -0,0 +1,107 @@
+package gitty
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlattenerPath(t *testing.T) {
+	t.Parallel()
+	f := &flattener{mode: CollisionSuffix, names: make(map[string]string), taken: make(map[string]bool)}
+	require.NoError(t, f.assign([]*github.RepositoryContent{
+		{Path: ptr("dir/b/README.md")},
+		{Path: ptr("dir/a/README.md")},
+		{Path: ptr("dir/README-1.md")},
+	}, []*github.RepositoryContent{{Path: ptr("dir/c/README.md")}}))
+
+	tests := map[string]string{
+		"dir/README-1.md": "dir/README-1.md",
+		"dir/a/README.md": "dir/README.md",
+		"dir/b/README.md": "dir/README-2.md",
+		"dir/c/README.md": "dir/README-3.md",
+		"dir":             "dir",
+	}
+	for p, expected := range tests {
+		got, err := f.path("dir", p)
+		require.NoError(t, err)
+		assert.Equal(t, expected, got, p)
+	}
+
+	var nilFlattener *flattener
+	got, err := nilFlattener.path("dir", "dir/a/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, "dir/a/README.md", got)
+}
+
+func TestDownloadFlatten(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/main.go":          "main",
+		"dir/sub/util.go":      "util",
+		"dir/sub/deep/data.md": "data",
+	}
+	r := serverRepository(t, contentsMux(files))
+	r.opts.flatten = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	base := t.TempDir()
+
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dir/data.md", "dir/main.go", "dir/util.go"}, savedFiles(t, base))
+	b, err := os.ReadFile(filepath.Join(base, "dir", "util.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "util", string(b))
+	assert.Equal(t, 3, result.Summary.Written)
+}
+
+func TestDownloadFlattenCollision(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/README.md":     "root",
+		"dir/a/README.md":   "a",
+		"dir/b/README.md":   "b",
+		"dir/b/LICENSE.txt": "license",
+	}
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(files))
+		r.opts.flatten = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		_, err := r.downloadTo(context.Background(), base)
+		require.ErrorIs(t, err, ErrFlattenCollision)
+		assert.Empty(t, savedFiles(t, base))
+	})
+
+	t.Run("suffix", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(files))
+		r.opts.flatten = true
+		r.opts.collision = CollisionSuffix
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		_, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+
+		for name, content := range map[string]string{
+			"README.md":   "root",
+			"README-1.md": "a",
+			"README-2.md": "b",
+			"LICENSE.txt": "license",
+		} {
+			b, err := os.ReadFile(filepath.Join(base, "dir", name))
+			require.NoError(t, err)
+			assert.Equal(t, content, string(b), name)
+		}
+	})
+}
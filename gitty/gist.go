@@ -0,0 +1,104 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// gistHost represents the host of the GitHub Gist URLs.
+const gistHost = "gist.github.com"
+
+// ErrNotValidGist is returned for a GitHub Gist URL without a gist ID.
+var ErrNotValidGist = errors.New("gist url must be gist.github.com/id or gist.github.com/user/id")
+
+// gist represents a GitHub Gist and its revision, if any.
+type gist struct {
+	id  string
+	sha string
+}
+
+// gistPath returns the path of a GitHub Gist URL after the host and reports
+// whether the URL is a gist URL.
+func gistPath(url string) (string, bool) {
+	for _, pref := range []string{"https://" + gistHost + "/", gistHost + "/"} {
+		if path, ok := strings.CutPrefix(url, pref); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// parseGist parses the path of a GitHub Gist URL, id or user/id, optionally
+// followed by the SHA of a revision of the gist.
+func parseGist(path string) (gist, error) {
+	path, _, _ = strings.Cut(path, "#")
+	strs := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	var sha string
+	if n := len(strs); n > 1 && isFullCommitSHA(strs[n-1]) {
+		sha = strs[n-1]
+		strs = strs[:n-1]
+	}
+	if len(strs) > 2 || strs[len(strs)-1] == "" || strs[0] == "" {
+		return gist{}, ErrNotValidGist
+	}
+
+	return gist{id: strs[len(strs)-1], sha: sha}, nil
+}
+
+// extractGist sets the gist of the path of a GitHub Gist URL, at its revision,
+// if any, to be downloaded in place of a repository path. Gists are not
+// repositories, so they are rejected if the allowed repositories are set.
+func (g *GitHub) extractGist(path string) error {
+	gist, err := parseGist(path)
+	if err != nil {
+		return err
+	}
+	if g.opts.allowedRepos != nil {
+		return fmt.Errorf("%w: gist %s", ErrRepoNotAllowed, gist.id)
+	}
+
+	g.Owner = ""
+	g.Repo = ""
+	g.Ref = nil
+	if gist.sha != "" {
+		g.Ref = &github.RepositoryContentGetOptions{Ref: gist.sha}
+	}
+	g.Path = ""
+	g.file = false
+	g.gist = &gist
+
+	return nil
+}
+
+// listGist returns the files of the gist at its revision, if any, named after
+// their file names.
+func (g *GitHub) listGist(ctx context.Context) ([]*github.RepositoryContent, error) {
+	var gist *github.Gist
+	var err error
+	if g.gist.sha == "" {
+		gist, _, err = g.Client.GetGist(ctx, g.gist.id)
+	} else {
+		gist, _, err = g.Client.GetGistRevision(ctx, g.gist.id, g.gist.sha)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gist: %w", err)
+	}
+
+	files := make([]*github.RepositoryContent, 0, len(gist.Files))
+	for _, file := range gist.Files {
+		files = append(files, &github.RepositoryContent{
+			Type:        github.Ptr("file"),
+			Name:        file.Filename,
+			Path:        file.Filename,
+			Size:        file.Size,
+			DownloadURL: file.RawURL,
+		})
+	}
+	sortTree(files)
+
+	return files, nil
+}
@@ -0,0 +1,136 @@
+package gitty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGist(t *testing.T) {
+	t.Parallel()
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	tests := []struct {
+		name     string
+		url      string
+		expected gist
+		err      error
+	}{
+		{name: "id", url: "https://gist.github.com/aa5a315d61ae9438b18d", expected: gist{id: "aa5a315d61ae9438b18d"}},
+		{name: "user and id", url: "https://gist.github.com/owner/aa5a315d61ae9438b18d", expected: gist{id: "aa5a315d61ae9438b18d"}},
+		{name: "without https", url: "gist.github.com/owner/aa5a315d61ae9438b18d/", expected: gist{id: "aa5a315d61ae9438b18d"}},
+		{name: "revision", url: "https://gist.github.com/aa5a315d61ae9438b18d/" + sha, expected: gist{id: "aa5a315d61ae9438b18d", sha: sha}},
+		{name: "user and revision", url: "https://gist.github.com/owner/aa5a315d61ae9438b18d/" + sha, expected: gist{id: "aa5a315d61ae9438b18d", sha: sha}},
+		{name: "file anchor", url: "https://gist.github.com/owner/aa5a315d61ae9438b18d#file-main-go", expected: gist{id: "aa5a315d61ae9438b18d"}},
+		{name: "without id", url: "https://gist.github.com/", err: ErrNotValidGist},
+		{name: "too many segments", url: "https://gist.github.com/owner/aa5a315d61ae9438b18d/raw/main.go", err: ErrNotValidGist},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			path, ok := gistPath(test.url)
+			require.True(t, ok)
+			got, err := parseGist(path)
+			require.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.expected, got)
+		})
+	}
+
+	_, ok := gistPath("https://github.com/owner/repo")
+	assert.False(t, ok)
+}
+
+// gistMux serves the gist id with the given files, keyed by file name, at
+// its latest revision and at the revision sha with the contents suffixed by
+// the SHA.
+func gistMux(id, sha string, files map[string]string) *http.ServeMux {
+	mux := http.NewServeMux()
+	serve := func(w http.ResponseWriter, r *http.Request, rev string) {
+		g := &github.Gist{ID: ptr(id), Files: make(map[github.GistFilename]github.GistFile)}
+		for name, content := range files {
+			g.Files[github.GistFilename(name)] = github.GistFile{
+				Filename: ptr(name),
+				Size:     ptr(len(content + rev)),
+				RawURL:   ptr("http://" + r.Host + "/raw/" + rev + "/" + name),
+			}
+		}
+		_ = json.NewEncoder(w).Encode(g)
+	}
+	mux.HandleFunc("GET /gists/"+id, func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, "latest")
+	})
+	mux.HandleFunc("GET /gists/"+id+"/"+sha, func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, sha)
+	})
+	mux.HandleFunc("GET /raw/{rev}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.PathValue("name")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content + r.PathValue("rev")))
+	})
+	return mux
+}
+
+func TestDownloadGist(t *testing.T) {
+	t.Parallel()
+	id := "aa5a315d61ae9438b18d"
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	files := map[string]string{"main.go": "package main", "README.md": "readme"}
+	tests := []struct {
+		name string
+		url  string
+		rev  string
+	}{
+		{name: "latest", url: "https://gist.github.com/owner/" + id, rev: "latest"},
+		{name: "revision", url: "https://gist.github.com/" + id + "/" + sha, rev: sha},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r := serverRepository(t, gistMux(id, sha, files))
+			require.NoError(t, r.extract(test.url))
+			base := t.TempDir()
+
+			result, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+
+			var paths []string
+			for _, file := range result.Manifest.Files {
+				paths = append(paths, file.Path)
+			}
+			assert.Equal(t, []string{"README.md", "main.go"}, paths)
+			for name, content := range files {
+				b, err := os.ReadFile(filepath.Join(base, name))
+				require.NoError(t, err)
+				assert.Equal(t, content+test.rev, string(b))
+			}
+		})
+	}
+}
+
+func TestDownloadGistError(t *testing.T) {
+	t.Parallel()
+	g := &GitHub{Client: &mockError{}}
+	require.NoError(t, g.extract("https://gist.github.com/aa5a315d61ae9438b18d"))
+	_, err := g.download(context.Background())
+	require.ErrorIs(t, err, errMockGist)
+
+	require.NoError(t, g.extract("https://gist.github.com/aa5a315d61ae9438b18d/a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"))
+	_, err = g.download(context.Background())
+	require.ErrorIs(t, err, errMockGist)
+
+	require.ErrorIs(t, g.extract("https://gist.github.com/"), ErrNotValidGist)
+
+	g.opts.allowedRepos = []string{"owner/repo"}
+	require.ErrorIs(t, g.extract("https://gist.github.com/aa5a315d61ae9438b18d"), ErrRepoNotAllowed)
+}
@@ -2,10 +2,16 @@ package gitty
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/v70/github"
-	"github.com/worlpaker/gitty/gitty/token"
 )
 
 // GitHub represents a GitHub repository with specific attributes.
@@ -15,37 +21,192 @@ type GitHub struct {
 	Repo   string
 	Ref    *github.RepositoryContentGetOptions
 	Path   string
+	opts   options
+	trees  *treeCache
+	// file reports whether the path was given as a file, by a blob URL.
+	file bool
+	// warnings collects the warnings of the current download.
+	warnings *warnings
+	// completed records the files saved by the current download.
+	completed *completed
+	// checkpoint keeps the progress of the current download, if enabled.
+	checkpoint *checkpoint
+	// pull is the files changed by the pull request being downloaded, if any.
+	pull []*github.CommitFile
+	// gist is the gist being downloaded, if any.
+	gist *gist
+	// glob is the glob pattern of the URL path, if any, matched against the
+	// repository paths beneath the path.
+	glob string
+	// modes records the file modes of the files of the current download
+	// listed from the repository tree, keyed by repository path.
+	modes map[string]os.FileMode
 }
 
 // service represents a GitHub client that interacts with the GitHub API.
 type service struct {
 	client *github.Client
+	// storage sends the requests to other hosts, such as the storage of Git
+	// LFS objects, without the credentials of the client, if set.
+	storage *http.Client
 }
 
+// defaultTimeout represents the time limit of a request, including reading
+// the response body, of the default HTTP client.
+const defaultTimeout = 10 * time.Minute
+
 // newClient creates a new authenticated GitHub client using a provided access token, if any.
-func newClient() *github.Client {
-	c := github.NewClient(nil)
-	if token.Get() == "" {
+// If several tokens are provided, requests are authenticated with them in turn.
+// If an HTTP client is provided, requests are sent with a copy of it and its
+// transport, if any.
+func newClient(o options) *github.Client {
+	hc := &http.Client{Timeout: defaultTimeout}
+	if o.httpClient != nil {
+		c := *o.httpClient
+		hc = &c
+	}
+	if _, ok := rawTransport(o).(*http.Transport); ok && o.insecureSkipTLSVerify {
+		fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled.")
+	}
+	base := baseTransport(o)
+	if o.cacheProxy != "" {
+		base = &cacheTransport{
+			base:   base,
+			prefix: o.cacheProxy,
+		}
+	}
+	if o.intercept != nil {
+		base = &interceptTransport{
+			base:      base,
+			intercept: o.intercept,
+		}
+	}
+	if o.userAgent != "" || len(o.headers) > 0 {
+		base = &headerTransport{
+			base:      base,
+			userAgent: o.userAgent,
+			headers:   o.headers,
+		}
+	}
+	if len(o.tokens) > 0 {
+		base = newTokenTransport(base, o.tokens)
+	}
+	base = &rateLimitTransport{
+		base: base,
+		wait: o.waitOnRateLimit,
+	}
+	if o.requestsPerSecond > 0 {
+		base = &throttleTransport{
+			base:     base,
+			throttle: newThrottle(o.requestsPerSecond),
+		}
+	}
+
+	hc.Transport = &retryTransport{
+		base:         base,
+		retries:      o.retries,
+		backoff:      o.backoff,
+		abuseBackoff: o.abuseBackoff,
+	}
+	if hc.CheckRedirect == nil && o.moves != nil {
+		hc.CheckRedirect = o.moves.redirect(o.followMoved)
+	}
+
+	c := github.NewClient(hc)
+	if o.userAgent != "" {
+		c.UserAgent = o.userAgent
+	}
+	if o.baseURL != "" {
+		u, err := url.Parse(strings.TrimSuffix(o.baseURL, "/") + "/")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: invalid base URL, using the default:", err)
+		} else {
+			c.BaseURL = u
+		}
+	}
+	if o.token == "" || len(o.tokens) > 0 {
 		return c
 	}
 
-	return c.WithAuthToken(token.Get())
+	return c.WithAuthToken(o.token)
+}
+
+// rawTransport returns the transport of the HTTP client of the options, if
+// any, or the transport of the options.
+func rawTransport(o options) http.RoundTripper {
+	if o.httpClient != nil && o.httpClient.Transport != nil {
+		return o.httpClient.Transport
+	}
+	return o.transport
+}
+
+// baseTransport returns the transport requests are sent on, with the TLS and
+// HTTP/2 settings of the options applied to a copy of it.
+func baseTransport(o options) http.RoundTripper {
+	base := rawTransport(o)
+	if t, ok := base.(*http.Transport); ok && o.insecureSkipTLSVerify {
+		t = t.Clone()
+		t.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true, //nolint:gosec // Explicitly requested for self-hosted testing.
+		}
+		base = t
+	}
+	if t, ok := base.(*http.Transport); ok && o.forceHTTP1 {
+		t = t.Clone()
+		// A non-nil empty map disables the HTTP/2 upgrade of TLS connections.
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		t.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		base = t
+	}
+	return base
+}
+
+// newStorageClient creates the HTTP client of requests to hosts other than
+// GitHub, such as the storage of Git LFS objects. It sends the requests on the
+// transport of the options but without the credentials or headers of the
+// GitHub client, so they are not leaked to other hosts.
+func newStorageClient(o options) *http.Client {
+	hc := &http.Client{Timeout: defaultTimeout}
+	if o.httpClient != nil {
+		hc.Timeout = o.httpClient.Timeout
+	}
+	hc.Transport = baseTransport(o)
+	return hc
 }
 
 // Client defines methods for interacting with [go-github] API.
 //
 // [go-github]: https://github.com/google/go-github
 type Client interface {
-	Get(url string) (resp *http.Response, err error)
+	Get(ctx context.Context, url string) (resp *http.Response, err error)
 	GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (fileContent *github.RepositoryContent, directoryContent []*github.RepositoryContent, resp *github.Response, err error)
 	RateLimit(ctx context.Context) (*github.RateLimits, *github.Response, error)
 	GetUser(ctx context.Context, user string) (*github.User, *github.Response, error)
+	GetArchiveLink(ctx context.Context, owner, repo string, archiveformat github.ArchiveFormat, opts *github.RepositoryContentGetOptions, maxRedirects int) (*url.URL, *github.Response, error)
+	GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error)
+	GetLatestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, *github.Response, error)
+	GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*github.Tree, *github.Response, error)
+	ListCommits(ctx context.Context, owner, repo string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error)
+	GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error)
+	ListPullRequestFiles(ctx context.Context, owner, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error)
+	GetRange(ctx context.Context, url string, offset, length int64) (resp *http.Response, err error)
+	GetIfNoneMatch(ctx context.Context, url, etag string) (resp *http.Response, err error)
+	ListBranches(ctx context.Context, owner, repo string, opts *github.BranchListOptions) ([]*github.Branch, *github.Response, error)
+	GetGist(ctx context.Context, id string) (*github.Gist, *github.Response, error)
+	GetGistRevision(ctx context.Context, id, sha string) (*github.Gist, *github.Response, error)
+	PostLFSBatch(ctx context.Context, url string, body io.Reader) (resp *http.Response, err error)
+	GetWithHeader(ctx context.Context, url string, header map[string]string) (resp *http.Response, err error)
 }
 
 // Ensure service implements the Client interface.
 var _ Client = (*service)(nil)
 
-// Get issues a GET to the specified URL. If the response is one of the
+// Get issues a GET to the specified URL, canceled when ctx is done. If the response is one of the
 // following redirect codes, Get follows the redirect after calling the
 // [Client.CheckRedirect] function:
 //
@@ -63,8 +224,84 @@ var _ Client = (*service)(nil)
 //
 // When err is nil, resp always contains a non-nil resp.Body.
 // Caller should close resp.Body when done reading from it.
-func (s *service) Get(url string) (resp *http.Response, err error) {
-	return s.client.Client().Get(url)
+func (s *service) Get(ctx context.Context, url string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Client().Do(req)
+}
+
+// GetRange issues a GET to the specified URL for length bytes starting at
+// offset, canceled when ctx is done. A server that supports range requests
+// responds with 206 (Partial Content) and only the requested bytes, other servers respond with 200 (OK)
+// and the whole content.
+//
+// When err is nil, resp always contains a non-nil resp.Body.
+// Caller should close resp.Body when done reading from it.
+func (s *service) GetRange(ctx context.Context, url string, offset, length int64) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	return s.client.Client().Do(req)
+}
+
+// GetIfNoneMatch issues a GET to the specified URL with the ETag of the
+// content saved before, canceled when ctx is done. A server responds with 304
+// (Not Modified) and no content if the content still has the ETag, and with
+// 200 (OK) and the whole content otherwise. Without an ETag, it issues a GET
+// like Get.
+//
+// When err is nil, resp always contains a non-nil resp.Body.
+// Caller should close resp.Body when done reading from it.
+func (s *service) GetIfNoneMatch(ctx context.Context, url, etag string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	return s.client.Client().Do(req)
+}
+
+// PostLFSBatch issues a POST of the Git LFS batch API request body to the
+// specified URL, canceled when ctx is done.
+//
+// Git LFS API docs: https://github.com/git-lfs/git-lfs/blob/main/docs/api/batch.md
+//
+// When err is nil, resp always contains a non-nil resp.Body.
+// Caller should close resp.Body when done reading from it.
+func (s *service) PostLFSBatch(ctx context.Context, url string, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	return s.client.Client().Do(req)
+}
+
+// GetWithHeader issues a GET to the specified URL with only the given headers,
+// canceled when ctx is done. It is sent without the credentials of the
+// client, as the URL may be of another host.
+//
+// When err is nil, resp always contains a non-nil resp.Body.
+// Caller should close resp.Body when done reading from it.
+func (s *service) GetWithHeader(ctx context.Context, url string, header map[string]string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	if s.storage == nil {
+		return http.DefaultClient.Do(req)
+	}
+	return s.storage.Do(req)
 }
 
 // GetContents can return either the metadata and content of a single file
@@ -105,3 +342,107 @@ func (s *service) RateLimit(ctx context.Context) (*github.RateLimits, *github.Re
 func (s *service) GetUser(ctx context.Context, user string) (*github.User, *github.Response, error) {
 	return s.client.Users.Get(ctx, user)
 }
+
+// GetArchiveLink returns an URL to download a tarball or zipball archive for a
+// repository. The archiveFormat can be specified by either the github.Tarball
+// or github.Zipball constant.
+//
+// GitHub API docs: https://docs.github.com/rest/repos/contents#download-a-repository-archive-tar
+// GitHub API docs: https://docs.github.com/rest/repos/contents#download-a-repository-archive-zip
+//
+//meta:operation GET /repos/{owner}/{repo}/tarball/{ref}
+//meta:operation GET /repos/{owner}/{repo}/zipball/{ref}
+func (s *service) GetArchiveLink(ctx context.Context, owner, repo string, archiveformat github.ArchiveFormat, opts *github.RepositoryContentGetOptions, maxRedirects int) (*url.URL, *github.Response, error) {
+	return s.client.Repositories.GetArchiveLink(ctx, owner, repo, archiveformat, opts, maxRedirects)
+}
+
+// GetCommitSHA1 gets the SHA-1 of a commit reference. If a last-known SHA1 is
+// supplied and no new commits have occurred, a 304 Unmodified response is returned.
+//
+// GitHub API docs: https://docs.github.com/rest/commits/commits#get-a-commit
+//
+//meta:operation GET /repos/{owner}/{repo}/commits/{ref}
+func (s *service) GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error) {
+	return s.client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, lastSHA)
+}
+
+// GetLatestRelease fetches the latest published release for the repository.
+//
+// GitHub API docs: https://docs.github.com/rest/releases/releases#get-the-latest-release
+//
+//meta:operation GET /repos/{owner}/{repo}/releases/latest
+func (s *service) GetLatestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, *github.Response, error) {
+	return s.client.Repositories.GetLatestRelease(ctx, owner, repo)
+}
+
+// GetRepository fetches a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/repos/repos#get-a-repository
+//
+//meta:operation GET /repos/{owner}/{repo}
+func (s *service) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	return s.client.Repositories.Get(ctx, owner, repo)
+}
+
+// GetTree fetches the Tree object for a given sha hash from a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/git/trees#get-a-tree
+//
+//meta:operation GET /repos/{owner}/{repo}/git/trees/{tree_sha}
+func (s *service) GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*github.Tree, *github.Response, error) {
+	return s.client.Git.GetTree(ctx, owner, repo, sha, recursive)
+}
+
+// ListCommits lists the commits of a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/commits/commits#list-commits
+//
+//meta:operation GET /repos/{owner}/{repo}/commits
+func (s *service) ListCommits(ctx context.Context, owner, repo string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return s.client.Repositories.ListCommits(ctx, owner, repo, opts)
+}
+
+// GetPullRequest fetches a single pull request.
+//
+// GitHub API docs: https://docs.github.com/rest/pulls/pulls#get-a-pull-request
+//
+//meta:operation GET /repos/{owner}/{repo}/pulls/{pull_number}
+func (s *service) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	return s.client.PullRequests.Get(ctx, owner, repo, number)
+}
+
+// ListBranches lists the branches of a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/branches/branches#list-branches
+//
+//meta:operation GET /repos/{owner}/{repo}/branches
+func (s *service) ListBranches(ctx context.Context, owner, repo string, opts *github.BranchListOptions) ([]*github.Branch, *github.Response, error) {
+	return s.client.Repositories.ListBranches(ctx, owner, repo, opts)
+}
+
+// ListPullRequestFiles lists the files in a pull request.
+//
+// GitHub API docs: https://docs.github.com/rest/pulls/pulls#list-pull-requests-files
+//
+//meta:operation GET /repos/{owner}/{repo}/pulls/{pull_number}/files
+func (s *service) ListPullRequestFiles(ctx context.Context, owner, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	return s.client.PullRequests.ListFiles(ctx, owner, repo, number, opts)
+}
+
+// GetGist fetches a gist.
+//
+// GitHub API docs: https://docs.github.com/rest/gists/gists#get-a-gist
+//
+//meta:operation GET /gists/{gist_id}
+func (s *service) GetGist(ctx context.Context, id string) (*github.Gist, *github.Response, error) {
+	return s.client.Gists.Get(ctx, id)
+}
+
+// GetGistRevision fetches a specific revision of a gist.
+//
+// GitHub API docs: https://docs.github.com/rest/gists/gists#get-a-gist-revision
+//
+//meta:operation GET /gists/{gist_id}/{sha}
+func (s *service) GetGistRevision(ctx context.Context, id, sha string) (*github.Gist, *github.Response, error) {
+	return s.client.Gists.GetRevision(ctx, id, sha)
+}
@@ -3,10 +3,18 @@ package gitty
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/google/go-github/v70/github"
@@ -43,8 +51,10 @@ func TestNewClient(t *testing.T) {
 				err := os.Unsetenv(tokenKey)
 				require.NoError(t, err)
 			}
-			client := newClient()
+			client := newClient(newOptions(WithUserAgent("")))
 			assert.Equal(t, test.expected.UserAgent, client.UserAgent)
+			client = newClient(newOptions())
+			assert.Equal(t, defaultUserAgent(debug.ReadBuildInfo), client.UserAgent)
 		})
 	}
 }
@@ -75,7 +85,7 @@ func TestGet(t *testing.T) {
 	t.Parallel()
 	s := setup()
 
-	resp, err := s.Get("https://test.com")
+	resp, err := s.Get(context.Background(), "https://test.com")
 	require.NoError(t, err)
 	defer resp.Body.Close()
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
@@ -110,3 +120,368 @@ func TestGetUser(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestGetArchiveLink(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	opts := &github.RepositoryContentGetOptions{
+		Ref: "main",
+	}
+	_, resp, err := s.GetArchiveLink(context.Background(), "owner", "repo", github.Tarball, opts, 1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetCommitSHA1(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	sha, resp, err := s.GetCommitSHA1(context.Background(), "owner", "repo", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, string(mockGetBody), sha)
+}
+
+func TestNewClientTransport(t *testing.T) {
+	t.Parallel()
+	c := newClient(newOptions(WithRetries(2), WithRequestsPerSecond(5)))
+	rt, ok := c.Client().Transport.(*retryTransport)
+	require.True(t, ok)
+	assert.Equal(t, 2, rt.retries)
+	_, ok = rt.base.(*throttleTransport)
+	assert.True(t, ok)
+
+	c = newClient(newOptions())
+	rt, ok = c.Client().Transport.(*retryTransport)
+	require.True(t, ok)
+	rl, ok := rt.base.(*rateLimitTransport)
+	require.True(t, ok)
+	assert.False(t, rl.wait)
+	ht, ok := rl.base.(*headerTransport)
+	require.True(t, ok)
+	assert.Equal(t, http.DefaultTransport, ht.base)
+
+	c = newClient(options{transport: http.DefaultTransport})
+	rt, ok = c.Client().Transport.(*retryTransport)
+	require.True(t, ok)
+	rl, ok = rt.base.(*rateLimitTransport)
+	require.True(t, ok)
+	assert.Equal(t, http.DefaultTransport, rl.base)
+}
+
+func TestGetLatestRelease(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetLatestRelease(context.Background(), "owner", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetRepository(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetRepository(context.Background(), "owner", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewClientInsecureSkipTLSVerify(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	srv := httptest.NewTLSServer(contentsMux(map[string]string{fakeBase + "/file.txt": "secure data"}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		insecure bool
+	}{
+		{
+			name: "self-signed certificate rejected by default",
+		},
+		{
+			name:     "self-signed certificate accepted when verification is skipped",
+			opts:     []Option{WithInsecureSkipTLSVerify(true)},
+			insecure: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			o := newOptions(test.opts...)
+			c := newClient(o)
+			u, err := url.Parse(srv.URL + "/")
+			require.NoError(t, err)
+			c.BaseURL = u
+
+			r := repository(c, o)
+			r.Owner, r.Repo, r.Path = "owner", "repo", fakeBase
+			_, err = r.download(context.Background())
+			if !test.insecure {
+				var certErr *tls.CertificateVerificationError
+				require.ErrorAs(t, err, &certErr)
+				return
+			}
+			require.NoError(t, err)
+			b, err := os.ReadFile(fakeBase + "/file.txt")
+			require.NoError(t, err)
+			assert.Equal(t, "secure data", string(b))
+		})
+	}
+}
+
+func TestNewClientForceHTTP1(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"name":%q}`, r.Proto)
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{"h2", "http/1.1"}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{name: "http2 negotiated by default", expected: "HTTP/2.0"},
+		{name: "http1 forced", opts: []Option{WithForceHTTP1(true)}, expected: "HTTP/1.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c := newClient(newOptions(append(test.opts, WithHTTPClient(srv.Client()))...))
+			u, err := url.Parse(srv.URL + "/")
+			require.NoError(t, err)
+			c.BaseURL = u
+
+			repo, _, err := c.Repositories.Get(context.Background(), "owner", "repo")
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, repo.GetName())
+		})
+	}
+}
+
+func TestGetTree(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetTree(context.Background(), "owner", "repo", "main", true)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestListCommits(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	t.Cleanup(srv.Close)
+	c := github.NewClient(nil)
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+	s := &service{client: c}
+
+	_, resp, err := s.ListCommits(context.Background(), "owner", "repo", &github.CommitsListOptions{Path: "path"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetRange(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	t.Cleanup(srv.Close)
+	s := setup()
+	s.client = github.NewClient(nil)
+
+	resp, err := s.GetRange(context.Background(), srv.URL, 2, 3)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(b))
+
+	_, err = s.GetRange(context.Background(), "://invalid", 0, 1)
+	require.Error(t, err)
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, "content")
+	}))
+	t.Cleanup(srv.Close)
+	s := setup()
+	s.client = github.NewClient(nil)
+
+	resp, err := s.GetIfNoneMatch(context.Background(), srv.URL, `"v1"`)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, err = s.GetIfNoneMatch(context.Background(), srv.URL, "")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = s.GetIfNoneMatch(context.Background(), "://invalid", "")
+	require.Error(t, err)
+}
+
+func TestPostLFSBatch(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		fmt.Fprint(w, r.Method, " ", r.Header.Get("Accept"), " ", r.Header.Get("Content-Type"), " ", string(b))
+	}))
+	t.Cleanup(srv.Close)
+	s := setup()
+	s.client = github.NewClient(nil)
+
+	resp, err := s.PostLFSBatch(context.Background(), srv.URL, strings.NewReader("{}"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "POST "+lfsMediaType+" "+lfsMediaType+" {}", string(b))
+
+	_, err = s.PostLFSBatch(context.Background(), "://invalid", nil)
+	require.Error(t, err)
+}
+
+func TestGetWithHeader(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("X-Key"))
+	}))
+	t.Cleanup(srv.Close)
+	s := setup()
+	s.client = github.NewClient(nil)
+
+	resp, err := s.GetWithHeader(context.Background(), srv.URL, map[string]string{"X-Key": "value"})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(b))
+
+	_, err = s.GetWithHeader(context.Background(), "://invalid", nil)
+	require.Error(t, err)
+}
+
+func TestNewClientDefaults(t *testing.T) {
+	t.Parallel()
+	c := newClient(options{transport: http.DefaultTransport, userAgent: "team-agent", baseURL: "https://ghe.example.com/api/v3"})
+	assert.Equal(t, "team-agent", c.UserAgent)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", c.BaseURL.String())
+
+	c = newClient(options{transport: http.DefaultTransport, baseURL: "://invalid"})
+	assert.Equal(t, github.NewClient(nil).BaseURL, c.BaseURL)
+}
+
+// proxyTransport represents an http.RoundTripper that sets a header on each
+// request, as a proxy would.
+type proxyTransport struct {
+	base http.RoundTripper
+}
+
+func (t proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Proxy", "corporate")
+	return t.base.RoundTrip(req)
+}
+
+func TestNewClientHTTPClient(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("X-Proxy"))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := newClient(options{transport: http.DefaultTransport})
+	assert.Equal(t, defaultTimeout, c.Client().Timeout)
+
+	rt := proxyTransport{base: http.DefaultTransport}
+	redirect := func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	custom := &http.Client{Transport: rt, Timeout: 5 * time.Second, CheckRedirect: redirect}
+	c = newClient(options{transport: http.DefaultTransport, httpClient: custom, moves: newMoves()})
+	assert.Equal(t, 5*time.Second, c.Client().Timeout)
+	assert.NotNil(t, c.Client().CheckRedirect)
+	// The provided client is not modified.
+	assert.Equal(t, rt, custom.Transport)
+
+	resp, err := c.Client().Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "corporate", string(b))
+
+	// A client without a transport is sent on the default transport.
+	c = newClient(options{transport: http.DefaultTransport, httpClient: &http.Client{}})
+	assert.Zero(t, c.Client().Timeout)
+	resp, err = c.Client().Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestNewClientToken(t *testing.T) {
+	// Stdout is captured, so the test is not run in parallel.
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	secret := "ghp_" + gofakeit.LetterN(36)
+	contents := contentsMux(map[string]string{
+		fakeBase + "/a.txt":     "a",
+		fakeBase + "/sub/b.txt": "b",
+	})
+	var requests, authorized atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") == "Bearer "+secret {
+			authorized.Add(1)
+		}
+		contents.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	o := options{transport: http.DefaultTransport, token: secret}
+	c := newClient(o)
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err = fakeNew(repository(c, o)).Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	w.Close()
+	os.Stdout = old
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), secret)
+	// The contents of the directory and its subdirectory are listed, the tree
+	// is fetched for the file modes, and both raw files are downloaded, all
+	// with the token.
+	assert.Equal(t, int32(5), requests.Load())
+	assert.Equal(t, requests.Load(), authorized.Load())
+}
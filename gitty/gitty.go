@@ -1,14 +1,25 @@
+// Package gitty downloads files and directories from GitHub repositories
+// without cloning them.
+//
+// Download downloads the contents at a GitHub URL in a single call, configured
+// with options such as WithInclude or WithRouter. NewGit returns a Git with the
+// same options for the other operations, such as fetching a single file or
+// streaming an archive.
 package gitty
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"time"
+	"io"
 )
 
+// pagesBranch represents the branch GitHub Pages sites are published from.
+const pagesBranch = "gh-pages"
+
 // Git represents repository attributes.
 type Git struct {
-	repo Repository
+	repo *GitHub
 }
 
 // Gitty defines methods for interacting with cmd.
@@ -21,15 +32,36 @@ type Gitty interface {
 // Ensure Git implements the Gitty interface.
 var _ Gitty = (*Git)(nil)
 
-// New creates a new Gitty.
-func New() Gitty {
-	client := newClient()
-	r := repository(client)
+// New creates a new Gitty configured with the given options.
+func New(opts ...Option) Gitty {
+	return NewGit(opts...)
+}
+
+// NewGit creates a new Git configured with the given options. Unlike New, it
+// returns the Git itself, for the operations beyond those of Gitty.
+func NewGit(opts ...Option) *Git {
+	o := newOptions(opts...)
+	client := newClient(o)
+	r := repository(client, o)
 	return &Git{
 		repo: r,
 	}
 }
 
+// Download downloads the file or directory at the given URL with the given
+// options and returns the manifest of the downloaded files, the warnings, and
+// the summary. It is the entry point for a single download, equivalent to
+// NewGit(opts...).DownloadResult(ctx, url). Use NewGit for the other
+// operations or to reuse the client across downloads.
+func Download(ctx context.Context, url string, opts ...Option) (*Result, error) {
+	result, err := NewGit(opts...).DownloadResult(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 // Status reports the status of the client.
 func (g *Git) Status(ctx context.Context) error {
 	return g.repo.status(ctx)
@@ -43,19 +75,147 @@ func (g *Git) Auth(ctx context.Context) error {
 // Download downloads the contents from the given URL. It extracts the URL,
 // collects the contents, and downloads files concurrently.
 func (g *Git) Download(ctx context.Context, url string) error {
+	_, err := g.DownloadManifest(ctx, url)
+	return err
+}
+
+// DownloadManifest downloads the contents from the given URL like Download
+// and returns the manifest of the downloaded files. The files are listed in
+// tree order, so the manifest is stable across runs.
+func (g *Git) DownloadManifest(ctx context.Context, url string) (Manifest, error) {
+	result, err := g.DownloadResult(ctx, url)
+	return result.Manifest, err
+}
+
+// DownloadResult downloads the contents from the given URL like Download and
+// returns the manifest of the downloaded files along with the warnings about
+// non-fatal conditions, such as skipped files, and the summary of the written
+// and skipped files.
+func (g *Git) DownloadResult(ctx context.Context, url string) (Result, error) {
+	if err := g.repo.extract(url); err != nil {
+		return Result{}, err
+	}
+
+	return g.repo.download(ctx)
+}
+
+// FetchFileAtCommit returns the content of the file at the given path as it
+// was at the specified commit SHA.
+func (g *Git) FetchFileAtCommit(ctx context.Context, owner, repo, sha, path string) ([]byte, error) {
+	if !isCommitSHA(sha) {
+		return nil, ErrNotValidSHA
+	}
+
+	return g.repo.fetchFile(ctx, owner, repo, sha, path)
+}
+
+// FetchWithType returns the content of the file at the given URL along with
+// its content type, as returned by the server or detected from the content.
+func (g *Git) FetchWithType(ctx context.Context, url string) ([]byte, string, error) {
+	if err := g.repo.extract(url); err != nil {
+		return nil, "", err
+	}
+
+	return g.repo.fetchWithType(ctx)
+}
+
+// FetchFileWithCommit returns the content of the file at the given path and
+// ref along with the metadata of the last commit that changed it. An empty
+// ref refers to the default branch.
+func (g *Git) FetchFileWithCommit(ctx context.Context, owner, repo, ref, path string) ([]byte, CommitInfo, error) {
+	b, err := g.repo.fetchFile(ctx, owner, repo, ref, path)
+	if err != nil {
+		return nil, CommitInfo{}, err
+	}
+
+	commit, err := g.repo.lastCommit(ctx, owner, repo, ref, path)
+	if err != nil {
+		return nil, CommitInfo{}, err
+	}
+
+	return b, commit, nil
+}
+
+// FetchPages returns the content of the file at the given path of the
+// gh-pages branch, where GitHub Pages sites and build artifacts, such as a
+// dist directory, are commonly published.
+func (g *Git) FetchPages(ctx context.Context, owner, repo, path string) ([]byte, error) {
+	return g.repo.fetchFile(ctx, owner, repo, pagesBranch, path)
+}
+
+// FetchDirAtBranchTip downloads the directory dir of the branch into the base
+// directory. The tip of the branch is resolved first and the contents are
+// downloaded at its commit SHA, so they are consistent even if the branch
+// moves during the download. An empty dir refers to the repository root.
+func (g *Git) FetchDirAtBranchTip(ctx context.Context, owner, repo, branch, dir, base string) (Result, error) {
+	sha, err := g.repo.tip(ctx, owner, repo, branch)
+	if err != nil {
+		return Result{}, err
+	}
+
+	url := g.repo.treeURL(owner, repo, sha, dir)
+	if err := g.repo.extract(url); err != nil {
+		return Result{}, err
+	}
 	fmt.Println("Downloading:", url)
-	start := time.Now()
 
+	return g.repo.downloadTo(ctx, base)
+}
+
+// DownloadAction downloads the files of the action referenced as in the uses
+// key of workflow files, such as actions/checkout@v4 or owner/repo/path@sha.
+// A tag or branch is resolved to its commit SHA first, so the files are
+// downloaded at the exact commit the reference pins, for review.
+func (g *Git) DownloadAction(ctx context.Context, uses string) (Result, error) {
+	owner, repo, path, ref, err := parseAction(uses)
+	if err != nil {
+		return Result{}, err
+	}
+
+	sha, err := g.repo.tip(ctx, owner, repo, ref)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return g.DownloadResult(ctx, g.repo.treeURL(owner, repo, sha, path))
+}
+
+// DownloadPRFiles downloads the files changed by the pull request of the
+// repository into the base directory, as they are at the head commit of the
+// pull request, for review. Renamed files are saved at their new paths and
+// removed files are skipped with a warning.
+func (g *Git) DownloadPRFiles(ctx context.Context, owner, repo string, number int, base string) (Result, error) {
+	return g.repo.downloadPull(ctx, owner, repo, number, base)
+}
+
+// DownloadToBuffer downloads the contents from the given URL into a single
+// buffer instead of saving them, for search indexing without thousands of
+// small files. The index locates the content of each file in the buffer at
+// the path the file would be saved at.
+func (g *Git) DownloadToBuffer(ctx context.Context, url string) (*bytes.Buffer, []IndexEntry, error) {
+	return g.repo.downloadBuffer(ctx, url)
+}
+
+// GetFile copies the content of the file at the given URL to w as it is
+// downloaded, such as to stdout for scripting, without writing to disk. It
+// returns ErrNotFile if the URL points at a directory.
+func (g *Git) GetFile(ctx context.Context, url string, w io.Writer) error {
 	if err := g.repo.extract(url); err != nil {
 		return err
 	}
 
-	if err := g.repo.download(ctx); err != nil {
+	return g.repo.streamFile(ctx, w)
+}
+
+// GetArchive copies the archive of the whole repository at the ref of the
+// given URL to w in the given format, ArchiveTarGz or ArchiveZip, as a single
+// snapshot instead of separate files. A bare repository URL refers to the
+// default branch. It returns ErrNotRepoRoot if the URL points at a path
+// within the repository.
+func (g *Git) GetArchive(ctx context.Context, url string, format ArchiveFormat, w io.Writer) error {
+	if err := g.repo.extract(url); err != nil {
 		return err
 	}
 
-	fmt.Println("Download Completed")
-	fmt.Println(time.Since(start))
-
-	return nil
+	return g.repo.streamArchive(ctx, format, w)
 }
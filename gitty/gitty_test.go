@@ -1,9 +1,14 @@
 package gitty
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 
 	"github.com/brianvoe/gofakeit/v7"
@@ -11,7 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func fakeNew(fakeRepo Repository) Gitty {
+func fakeNew(fakeRepo *GitHub) *Git {
 	return &Git{repo: fakeRepo}
 }
 
@@ -21,11 +26,49 @@ func TestNewRepo(t *testing.T) {
 	assert.NotNil(t, r)
 }
 
+func TestNewGit(t *testing.T) {
+	t.Parallel()
+	g := NewGit(WithToken(""))
+	require.NotNil(t, g)
+	assert.NotNil(t, g.repo)
+}
+
+func TestDownloadFunc(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/main.go":     "package main",
+		"dir/sub/util.go": "package sub",
+	}
+	srv := httptest.NewServer(contentsMux(files))
+	t.Cleanup(srv.Close)
+	base := t.TempDir()
+
+	result, err := Download(context.Background(), "https://github.com/owner/repo/tree/main/dir",
+		WithToken(""),
+		WithBaseURL(srv.URL+"/"),
+		WithRouter(func(string) string { return base }),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "main", result.Manifest.Ref)
+	assert.Len(t, result.Manifest.Files, 2)
+	assert.Equal(t, 2, result.Summary.Written)
+	for path, content := range files {
+		b, err := os.ReadFile(filepath.Join(base, filepath.FromSlash(path)))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+
+	result, err = Download(context.Background(), "https://gitlab.com/owner/repo")
+	require.ErrorIs(t, err, ErrNotValidURL)
+	assert.Nil(t, result)
+}
+
 func TestStatus(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name     string
-		repo     Repository
+		repo     *GitHub
 		expected error
 	}{
 		{
@@ -66,7 +109,7 @@ func TestDownload(t *testing.T) {
 
 	tests := []struct {
 		name     string
-		repo     Repository
+		repo     *GitHub
 		ctx      context.Context
 		url      string
 		expected error
@@ -115,7 +158,7 @@ func TestAuth(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
 		name     string
-		repo     Repository
+		repo     *GitHub
 		expected error
 	}{
 		{
@@ -139,3 +182,192 @@ func TestAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchFileAtCommit(t *testing.T) {
+	t.Parallel()
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	g := fakeNew(serverRepository(t, commitMux(sha)))
+
+	b, err := g.FetchFileAtCommit(context.Background(), "owner", "repo", sha, "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content at "+sha, string(b))
+
+	_, err = g.FetchFileAtCommit(context.Background(), "owner", "repo", "main", "dir/file.txt")
+	assert.Equal(t, ErrNotValidSHA, err)
+}
+
+func TestFetchPages(t *testing.T) {
+	t.Parallel()
+	g := fakeNew(serverRepository(t, commitMux("gh-pages")))
+
+	b, err := g.FetchPages(context.Background(), "owner", "repo", "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "content at gh-pages", string(b))
+
+	// The mock serves no commits, so the branch is reported as missing.
+	_, err = g.FetchPages(context.Background(), "owner", "repo", "missing.txt")
+	require.ErrorIs(t, err, ErrRefNotFound)
+}
+
+func TestFetchDirAtBranchTip(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	var resolved atomic.Int32
+	mux := commitMux(sha)
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ref") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+		// The branch moves after it was resolved once.
+		if resolved.Add(1) > 1 {
+			fmt.Fprint(w, "ffffffffffffffffffffffffffffffffffffffff")
+			return
+		}
+		fmt.Fprint(w, sha)
+	})
+	g := fakeNew(serverRepository(t, mux))
+
+	result, err := g.FetchDirAtBranchTip(context.Background(), "owner", "repo", "main", "dir", base)
+	require.NoError(t, err)
+	assert.Equal(t, sha, result.Manifest.Ref)
+	assert.Equal(t, int32(1), resolved.Load())
+	b, err := os.ReadFile(filepath.Join(base, "dir", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "content at "+sha, string(b))
+
+	_, err = g.FetchDirAtBranchTip(context.Background(), "owner", "repo", "missing", "dir", base)
+	require.ErrorIs(t, err, ErrRefNotFound)
+
+	r := &GitHub{Client: &mockError{}}
+	_, err = r.tip(context.Background(), "owner", "repo", "main")
+	require.ErrorIs(t, err, errMockCommitSHA1)
+}
+
+func TestDownloadAction(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	contents := contentsMux(map[string]string{fakeBase + "/action.yml": "name: action"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ref") != "v4" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, sha)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != sha {
+			http.Error(w, "want pinned sha", http.StatusBadRequest)
+			return
+		}
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	g := fakeNew(serverRepository(t, mux))
+
+	result, err := g.DownloadAction(context.Background(), "owner/repo/"+fakeBase+"@v4")
+	require.NoError(t, err)
+	assert.Equal(t, sha, result.Manifest.Ref)
+	b, err := os.ReadFile(fakeBase + "/action.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: action", string(b))
+
+	_, err = g.DownloadAction(context.Background(), "owner/repo@missing")
+	require.ErrorIs(t, err, ErrRefNotFound)
+	_, err = g.DownloadAction(context.Background(), "owner/repo")
+	require.ErrorIs(t, err, ErrNotValidAction)
+}
+
+func TestFetchWithType(t *testing.T) {
+	t.Parallel()
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"
+	files := map[string]string{
+		"docs/readme.md": "# Title",
+		"docs/data.json": `{"key":"value"}`,
+		"img/logo.png":   png,
+		"docs/notes":     "plain notes",
+	}
+	types := map[string]string{
+		"docs/readme.md": "text/markdown; charset=utf-8",
+		"docs/data.json": "application/json",
+	}
+	contents := contentsMux(files)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /raw/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		// A nil value prevents the server from sniffing the content type.
+		w.Header()["Content-Type"] = nil
+		if contentType, ok := types[r.PathValue("path")]; ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+		fmt.Fprint(w, files[r.PathValue("path")])
+	})
+	mux.Handle("/", contents)
+	g := fakeNew(serverRepository(t, mux))
+
+	tests := []struct {
+		name         string
+		url          string
+		expected     string
+		expectedType string
+	}{
+		{name: "server content type", url: "docs/readme.md", expected: "# Title", expectedType: "text/markdown; charset=utf-8"},
+		{name: "server json type", url: "docs/data.json", expected: `{"key":"value"}`, expectedType: "application/json"},
+		{name: "detected image type", url: "img/logo.png", expected: png, expectedType: "image/png"},
+		{name: "detected text type", url: "docs/notes", expected: "plain notes", expectedType: "text/plain; charset=utf-8"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, contentType, err := g.FetchWithType(context.Background(), "https://github.com/owner/repo/blob/main/"+test.url)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+			assert.Equal(t, test.expectedType, contentType)
+		})
+	}
+
+	t.Run("error not file", func(t *testing.T) {
+		_, _, err := g.FetchWithType(context.Background(), "https://github.com/owner/repo/tree/main/docs")
+		assert.Equal(t, ErrNotFile, err)
+	})
+
+	t.Run("error invalid url", func(t *testing.T) {
+		_, _, err := g.FetchWithType(context.Background(), "https://example.com/owner/repo")
+		assert.Equal(t, ErrNotValidURL, err)
+	})
+}
+
+func TestGetFileWriter(t *testing.T) {
+	t.Parallel()
+	contents := contentsMux(map[string]string{
+		"docs/readme.md":  "# Title",
+		"docs/missing.md": "missing",
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /raw/docs/missing.md", http.NotFound)
+	mux.Handle("/", contents)
+	g := fakeNew(serverRepository(t, mux))
+
+	var buf bytes.Buffer
+	err := g.GetFile(context.Background(), "https://github.com/owner/repo/blob/main/docs/readme.md", &buf)
+	require.NoError(t, err)
+	assert.Equal(t, "# Title", buf.String())
+
+	err = g.GetFile(context.Background(), "https://github.com/owner/repo/tree/main/docs", &buf)
+	require.ErrorIs(t, err, ErrNotFile)
+
+	err = g.GetFile(context.Background(), "https://github.com/owner/repo/blob/main/docs/missing.md", &buf)
+	require.ErrorContains(t, err, "404")
+
+	err = fakeNew(fakeRepository(&mockError{})).GetFile(context.Background(), "https://github.com/owner/repo/blob/main/docs/readme.md", &buf)
+	require.ErrorIs(t, err, errMockContents)
+
+	err = g.GetFile(context.Background(), "https://example.com/owner/repo", &buf)
+	assert.Equal(t, ErrNotValidURL, err)
+}
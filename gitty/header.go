@@ -0,0 +1,57 @@
+package gitty
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// modulePath represents the path of the gitty module.
+const modulePath = "github.com/worlpaker/gitty"
+
+// headerTransport represents an http.RoundTripper that sets the User-Agent
+// and the extra headers on each request.
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   http.Header
+}
+
+// Ensure headerTransport implements the http.RoundTripper interface.
+var _ http.RoundTripper = (*headerTransport)(nil)
+
+// RoundTrip sets the headers on a copy of the request and executes a single
+// HTTP transaction with it. The extra headers replace those of the request.
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	for key, values := range t.headers {
+		req.Header[key] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+// defaultUserAgent returns the default User-Agent header, gitty/version with
+// the version of the gitty module in the build information of the binary, or
+// gitty if the version is not known, such as in a development build.
+func defaultUserAgent(readBuildInfo func() (*debug.BuildInfo, bool)) string {
+	info, ok := readBuildInfo()
+	if !ok {
+		return "gitty"
+	}
+	version := info.Main.Version
+	if info.Main.Path != modulePath {
+		version = ""
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				break
+			}
+		}
+	}
+	if version == "" || version == "(devel)" {
+		return "gitty"
+	}
+	return "gitty/" + version
+}
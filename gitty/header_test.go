@@ -0,0 +1,125 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultUserAgent(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		info     *debug.BuildInfo
+		ok       bool
+		expected string
+	}{
+		{
+			name:     "main module",
+			info:     &debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v0.1.5"}},
+			ok:       true,
+			expected: "gitty/v0.1.5",
+		},
+		{
+			name: "dependency",
+			info: &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/app", Version: "v1.0.0"},
+				Deps: []*debug.Module{{Path: "example.com/other", Version: "v2.0.0"}, {Path: modulePath, Version: "v0.1.4"}},
+			},
+			ok:       true,
+			expected: "gitty/v0.1.4",
+		},
+		{
+			name:     "not a dependency",
+			info:     &debug.BuildInfo{Main: debug.Module{Path: "example.com/app", Version: "v1.0.0"}},
+			ok:       true,
+			expected: "gitty",
+		},
+		{
+			name:     "development build",
+			info:     &debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}},
+			ok:       true,
+			expected: "gitty",
+		},
+		{
+			name:     "no build info",
+			expected: "gitty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ua := defaultUserAgent(func() (*debug.BuildInfo, bool) { return test.info, test.ok })
+			assert.Equal(t, test.expected, ua)
+		})
+	}
+}
+
+func TestDownloadHeaders(t *testing.T) {
+	t.Parallel()
+	contents := contentsMux(map[string]string{"dir/a.txt": "a", "dir/b.txt": "b"})
+	var mu sync.Mutex
+	seen := map[string]http.Header{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		contents.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := Download(context.Background(), "https://github.com/owner/repo/tree/main/dir",
+		WithToken(""),
+		WithBaseURL(srv.URL+"/"),
+		WithOutputDir(t.TempDir()),
+		WithUserAgent("team-agent/1.0"),
+		WithHeader("X-Proxy-Auth", "secret"),
+		WithHeader("X-Trace", "a"),
+		WithHeader("X-Trace", "b"),
+	)
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Both the API requests and the downloads of the files carry the headers.
+	for _, path := range []string{"/repos/owner/repo/contents/dir", "/raw/dir/a.txt", "/raw/dir/b.txt"} {
+		header, ok := seen[path]
+		require.True(t, ok, path)
+		assert.Equal(t, "team-agent/1.0", header.Get("User-Agent"), path)
+		assert.Equal(t, "secret", header.Get("X-Proxy-Auth"), path)
+		assert.Equal(t, []string{"a", "b"}, header.Values("X-Trace"), path)
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User-Agent", r.Header.Get("User-Agent"))
+		w.Header().Set("X-Got", r.Header.Get("X-Custom"))
+	}))
+	t.Cleanup(srv.Close)
+	c := &http.Client{Transport: &headerTransport{
+		base:    http.DefaultTransport,
+		headers: http.Header{"X-Custom": {"replaced"}},
+	}}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Custom", "original")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "replaced", resp.Header.Get("X-Got"))
+	// Without a User-Agent, the one of the request is kept.
+	assert.Equal(t, "Go-http-client/1.1", resp.Header.Get("X-User-Agent"))
+	// The request is not modified.
+	assert.Equal(t, "original", req.Header.Get("X-Custom"))
+}
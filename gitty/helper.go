@@ -1,76 +1,462 @@
 package gitty
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/google/go-github/v70/github"
 )
 
 const (
-	hPrefix = "https://github.com/"
-	prefix  = "github.com/"
+	// defaultHost represents the host of GitHub URLs unless another is set.
+	defaultHost = "github.com"
+	// rawHost represents the host of the raw file URLs of defaultHost.
+	rawHost = "raw.githubusercontent.com"
+	// latestRef represents the ref of the latest release shorthand.
+	latestRef = "@latest"
+	// defaultRef represents the ref of a bare repository URL, resolved to the
+	// default branch of the repository.
+	defaultRef = "@default"
+	// tempPrefix is the name prefix of temporary files written while saving.
+	tempPrefix = ".gitty-"
+	// treeKind and blobKind represent the segments of directory and file URLs
+	// that precede the ref.
+	treeKind = "tree"
+	blobKind = "blob"
 )
 
 var (
 	ErrNotValidURL    = errors.New("url must starts with https://github.com/ or github.com/")
-	ErrNotValidFormat = errors.New("url format must be https://github.com/owner/repo/tree/branch/directory")
+	ErrNotValidFormat = errors.New("url format must be https://github.com/owner/repo/tree/ref/directory or https://github.com/owner/repo/blob/ref/file")
+	ErrNotValidSHA    = errors.New("sha must be a hexadecimal commit hash of 7 to 40 characters")
+	ErrNotValidAction = errors.New("action must be in the format owner/repo@ref or owner/repo/path@ref")
 )
 
+// ErrPathEscape is returned for a repository path that would be saved outside
+// of the base directory, such as a crafted path with .. segments.
+var ErrPathEscape = errors.New("path must not lead outside of the base directory")
+
 // getGitHubRepo parses and extracts the repository path from a GitHub URL.
 func getGitHubRepo(url string) (string, error) {
-	prefixes := []string{hPrefix, prefix}
+	return getHostRepo(defaultHost, url)
+}
+
+// getHostRepo parses and extracts the repository path from a URL of the
+// GitHub host, such as the host of a GitHub Enterprise Server. The SSH clone
+// URLs git@host:owner/repo.git and ssh://git@host/owner/repo.git are accepted
+// as the bare repository form. For github.com, the raw file URLs of
+// raw.githubusercontent.com are accepted as the blob form. The segments of
+// web and raw URLs are percent-decoded, as GitHub encodes them. Leading,
+// trailing, and doubled slashes of the path are dropped before it is parsed.
+func getHostRepo(host, url string) (string, error) {
+	if host == defaultHost {
+		for _, pref := range []string{"https://" + rawHost + "/", rawHost + "/"} {
+			if path, ok := strings.CutPrefix(url, pref); ok {
+				return validate(unescape(raw(clean(path))))
+			}
+		}
+	}
+	prefixes := []string{"https://" + host + "/", host + "/"}
 	for _, pref := range prefixes {
 		if path, ok := strings.CutPrefix(url, pref); ok {
-			return validate(path)
+			return validate(unescape(bare(latest(clean(path)))))
 		}
 	}
+	sshPrefixes := []string{"git@" + host + ":", "ssh://git@" + host + "/"}
+	for _, pref := range sshPrefixes {
+		if path, ok := strings.CutPrefix(url, pref); ok {
+			return validate(bare(strings.TrimSuffix(clean(path), ".git")))
+		}
+	}
+	if host != defaultHost {
+		return "", fmt.Errorf("%w: want a URL of %s", ErrNotValidURL, host)
+	}
 	return "", ErrNotValidURL
 }
 
+// webHost returns the host of the GitHub URLs.
+func webHost(o options) string {
+	if o.host == "" {
+		return defaultHost
+	}
+	return o.host
+}
+
+// parseAction parses the reference of an action as used in workflow files,
+// owner/repo@ref where the repository may be followed by the path of the
+// action, such as actions/checkout@v4.
+func parseAction(uses string) (owner, repo, path, ref string, err error) {
+	s, ref, ok := strings.Cut(uses, "@")
+	if !ok || ref == "" || strings.Contains(ref, "@") {
+		return "", "", "", "", ErrNotValidAction
+	}
+	owner, rest, _ := strings.Cut(s, "/")
+	repo, path, _ = strings.Cut(rest, "/")
+	if owner == "" || repo == "" {
+		return "", "", "", "", ErrNotValidAction
+	}
+
+	return owner, repo, strings.TrimSuffix(path, "/"), ref, nil
+}
+
+// latest expands the latest release shorthand owner/repo@latest, optionally
+// followed by a path, into the tree format with latestRef as the ref.
+// Other paths are returned unchanged.
+func latest(s string) string {
+	owner, rest, _ := strings.Cut(s, "/")
+	repo, path, _ := strings.Cut(rest, "/")
+	repo, ok := strings.CutSuffix(repo, latestRef)
+	if !ok || owner == "" || repo == "" {
+		return s
+	}
+	return strings.Join([]string{owner, repo, treeKind, latestRef, path}, "/")
+}
+
+// unescape percent-decodes each segment of the path, such as my%20folder to
+// my folder. A segment that is not validly encoded or would be decoded to a
+// slash, such as a branch with %2F, is kept as is, so it is not split.
+func unescape(s string) string {
+	strs := strings.Split(s, "/")
+	for i, seg := range strs {
+		if decoded, err := url.PathUnescape(seg); err == nil && !strings.Contains(decoded, "/") {
+			strs[i] = decoded
+		}
+	}
+	return strings.Join(strs, "/")
+}
+
+// raw expands the path of a raw file URL, owner/repo/ref/path, into the blob
+// format, as the ref follows the repository without a tree or blob segment.
+// The full ref form refs/heads/branch or refs/tags/tag is shortened to the
+// branch or tag. Other paths are returned unchanged.
+func raw(s string) string {
+	strs := strings.SplitN(s, "/", 3)
+	if len(strs) < 3 {
+		return s
+	}
+	rest := strs[2]
+	for _, pref := range []string{"refs/heads/", "refs/tags/"} {
+		if r, ok := strings.CutPrefix(rest, pref); ok {
+			rest = r
+			break
+		}
+	}
+	return strings.Join([]string{strs[0], strs[1], blobKind, rest}, "/")
+}
+
+// bare expands the bare repository form owner/repo into the tree format with
+// defaultRef as the ref, so the whole repository is downloaded from its
+// default branch. Other paths are returned unchanged.
+func bare(s string) string {
+	owner, repo, ok := strings.Cut(strings.TrimSuffix(s, "/"), "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return s
+	}
+	return strings.Join([]string{owner, repo, treeKind, defaultRef, ""}, "/")
+}
+
 // validate checks if the URL has a valid format.
 func validate(s string) (string, error) {
-	// Valid format example is: https://github.com/owner/repo/tree/branch/directory
-	// After the domain, the expected format is: owner/repo/tree/branch/directory
-	if strings.Count(s, "/") < 4 {
+	// Valid format example is: https://github.com/owner/repo/tree/ref/directory
+	// After the domain, the expected format is: owner/repo/tree/ref/directory
+	// A single file may be given as owner/repo/blob/ref/directory/file, as
+	// linked to by GitHub.
+	// The ref is a branch, a tag, or a full or abbreviated commit SHA, as
+	// GitHub puts all of them in the same segment. It is not interpreted and
+	// is passed to the API unchanged.
+	// Leading, trailing, and doubled slashes are dropped first, so the path
+	// keeps no trailing slash and the root of a tree is owner/repo/tree/ref/.
+	strs := strings.SplitN(clean(s), "/", 5)
+	if len(strs) < 4 {
+		return "", ErrNotValidFormat
+	}
+	if len(strs) == 4 {
+		strs = append(strs, "")
+	}
+	switch strs[2] {
+	case treeKind:
+		return strings.Join(strs, "/"), nil
+	case blobKind:
+		if strs[4] == "" {
+			return "", ErrNotValidFormat
+		}
+		return strings.Join(strs, "/"), nil
+	default:
 		return "", ErrNotValidFormat
 	}
-	return s, nil
 }
 
-// saveFile saves the content of the file at the specified path.
-func saveFile(base, path string, body io.Reader) error {
-	p, err := exactPath(base, path)
+// clean drops the leading, trailing, and doubled slashes of the path, as left
+// by copying or joining URLs, such as /owner/repo//tree/main/dir/.
+func clean(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return r == '/'
+	}), "/")
+}
+
+// isCommitSHA reports whether s looks like a full or abbreviated commit SHA.
+func isCommitSHA(s string) bool {
+	if len(s) < 7 || len(s) > 40 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFullCommitSHA reports whether s looks like a full commit SHA, which
+// unlike branches, tags, and abbreviated SHAs always refers to the same
+// commit.
+func isFullCommitSHA(s string) bool {
+	return len(s) == 40 && isCommitSHA(s)
+}
+
+// saveFileMode saves the content of the file at the specified path and keeps
+// the executable bit of mode, if the file may be executable. Copying stops
+// with the error of ctx once ctx is done, and the file is not saved.
+func saveFileMode(ctx context.Context, o options, base, path string, mode os.FileMode, body io.Reader) error {
+	p, err := localPath(o, base, path)
 	if err != nil {
 		return err
 	}
+	body = &ctxReader{ctx: ctx, r: body}
+	body = o.digests.hash(path, body)
+	body = o.meter.count(path, body)
+	body = o.total.tee(body)
+	body = o.lineEndings.scan(path, body)
+	body = o.blobs.verify(path, body)
+	if o.records != nil {
+		return o.records.write(filepath.ToSlash(p), body)
+	}
+	counted := &countReader{r: body}
+	body, gz := gzipText(o, path, counted)
+	if gz {
+		p += gzipExt
+	}
+	if keep, err := keepExisting(o, path, p); err != nil || keep {
+		return err
+	}
 	fmt.Println("Saving:", p)
 
 	if errMkdir := os.MkdirAll(filepath.Dir(p), os.ModePerm); errMkdir != nil {
 		return errMkdir
 	}
 
-	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	// The content is written to a temporary file prefixed with the run ID first,
+	// so concurrent downloads into the same base never share a temporary file.
+	f, err := os.CreateTemp(filepath.Dir(p), tempPrefix+o.runID+"-*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if executable(o, path, mode) {
+		if err := f.Chmod(0o700); err != nil {
+			f.Close()
+			return err
+		}
+	}
 
-	if _, err := io.Copy(f, body); err != nil {
+	if gz {
+		err = writeGzip(o, f, body)
+	} else {
+		err = writeFile(o, f, body)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
 		return err
 	}
 
+	o.written.add(relPath(o, base, path, gz), counted.n)
 	return nil
 }
 
-// exactPath removes unnecessary directories from the given path.
+// relPath returns the path the file of the repository path is saved at,
+// relative to the directory returned by the router, if any, as a slash
+// separated path.
+func relPath(o options, base, path string, gz bool) string {
+	flat, err := o.flat.path(base, path)
+	if err != nil {
+		return path
+	}
+	if o.windowsNames {
+		if base, flat, err = windowsPaths(o, base, flat); err != nil {
+			return path
+		}
+	}
+	p, err := exactPath(base, flat)
+	if err != nil {
+		return path
+	}
+	if gz {
+		p += gzipExt
+	}
+	return filepath.ToSlash(p)
+}
+
+// ctxReader represents a reader that fails with the error of ctx once ctx is
+// done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// writeFile copies the body into the file, buffered if a write buffer size is set.
+func writeFile(o options, f io.Writer, body io.Reader) error {
+	if o.writeBufferSize <= 0 {
+		_, err := io.Copy(f, body)
+		return err
+	}
+
+	w := bufio.NewWriterSize(f, o.writeBufferSize)
+	if _, err := io.Copy(w, body); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// writeGzip copies the gzipped body into the file.
+func writeGzip(o options, f io.Writer, body io.Reader) error {
+	zw := gzip.NewWriter(f)
+	if err := writeFile(o, zw, body); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// executable reports whether the file of the repository path with the given
+// mode is saved as executable. Only files with one of the executable
+// extensions keep the executable bit, if these are set.
+func executable(o options, path string, mode os.FileMode) bool {
+	if mode&0o111 == 0 {
+		return false
+	}
+	if o.executableExtensions == nil {
+		return true
+	}
+
+	return slices.Contains(o.executableExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// skipEmpty returns the files that are not empty, judged by their listed size.
+func skipEmpty(w *warnings, files []*github.RepositoryContent) []*github.RepositoryContent {
+	var kept []*github.RepositoryContent
+	for _, file := range files {
+		if file.GetSize() == 0 {
+			w.add(WarnEmptySkipped, file.GetPath(), "Skipping empty file")
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+// stripTopLevel returns the files that are neither one of the names at the
+// repository root nor beneath one of them. Files of the same names in nested
+// directories are kept.
+func stripTopLevel(names []string, files []*github.RepositoryContent) []*github.RepositoryContent {
+	var kept []*github.RepositoryContent
+	for _, file := range files {
+		top, _, _ := strings.Cut(file.GetPath(), "/")
+		if slices.Contains(names, top) {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+// newRunID returns a random identifier of a single download.
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// localPath returns the local path of the repository path, placed under the
+// base directory chosen by the router, if any.
+func localPath(o options, base, path string) (string, error) {
+	flat, err := o.flat.path(base, path)
+	if err != nil {
+		return "", err
+	}
+	if o.windowsNames {
+		if base, flat, err = windowsPaths(o, base, flat); err != nil {
+			return "", err
+		}
+	}
+	p, err := exactPath(base, flat)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir(o, path), p), nil
+}
+
+// baseDir returns the directory the file of the repository path is saved
+// under: the base directory returned by the router, if any, beneath the
+// output directory, if any.
+func baseDir(o options, path string) string {
+	if o.router == nil {
+		return o.outputDir
+	}
+	return filepath.Join(o.outputDir, o.router(path))
+}
+
+// exactPath removes unnecessary directories from the given path. It returns
+// ErrPathEscape if the path is absolute or the local path would lead outside
+// of the directory the files are saved in.
 func exactPath(base, path string) (string, error) {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("%w: %s", ErrPathEscape, path)
+	}
 	relPath, err := filepath.Rel(base, path)
 	if err != nil {
 		return "", err
 	}
 
-	return filepath.Join(filepath.Base(base), relPath), nil
+	p := filepath.Join(filepath.Base(base), relPath)
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("%w: %s", ErrPathEscape, path)
+	}
+	return p, nil
+}
+
+// globCase returns s as matched against glob patterns: lowercased if globs
+// are matched case-insensitively, otherwise unchanged. Both the pattern and
+// the path are passed through it, so saved paths keep their original case.
+func globCase(o options, s string) string {
+	if o.caseInsensitiveGlobs {
+		return strings.ToLower(s)
+	}
+	return s
 }
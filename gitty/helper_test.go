@@ -2,6 +2,7 @@ package gitty
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -44,6 +45,90 @@ func TestGetGitHubRepo(t *testing.T) {
 			expected:    "owner/repo/tree/branch/directory1/directory2/file.txt",
 			expectedErr: nil,
 		},
+		{
+			name:        "valid blob url",
+			url:         "https://github.com/owner/repo/blob/branch/directory/file.go",
+			expected:    "owner/repo/blob/branch/directory/file.go",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid latest release url",
+			url:         "https://github.com/owner/repo@latest",
+			expected:    "owner/repo/tree/@latest/",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid latest release url with path",
+			url:         "github.com/owner/repo@latest/directory",
+			expected:    "owner/repo/tree/@latest/directory",
+			expectedErr: nil,
+		},
+		{
+			name:        "encoded space",
+			url:         "https://github.com/owner/repo/tree/main/my%20folder/file.txt",
+			expected:    "owner/repo/tree/main/my folder/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "encoded plus",
+			url:         "https://github.com/owner/repo/blob/main/c%2B%2B/main.cpp",
+			expected:    "owner/repo/blob/main/c++/main.cpp",
+			expectedErr: nil,
+		},
+		{
+			name:        "encoded slash kept",
+			url:         "https://github.com/owner/repo/tree/feature%2Fx/dir",
+			expected:    "owner/repo/tree/feature%2Fx/dir",
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid encoding kept",
+			url:         "https://github.com/owner/repo/tree/main/100%/file.txt",
+			expected:    "owner/repo/tree/main/100%/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "not encoded",
+			url:         "https://github.com/owner/repo/tree/main/my+folder/file.txt",
+			expected:    "owner/repo/tree/main/my+folder/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "encoded raw url",
+			url:         "https://raw.githubusercontent.com/owner/repo/main/my%20folder/file.txt",
+			expected:    "owner/repo/blob/main/my folder/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid raw url",
+			url:         "https://raw.githubusercontent.com/owner/repo/branch/directory1/directory2/file.txt",
+			expected:    "owner/repo/blob/branch/directory1/directory2/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid raw url without https",
+			url:         "raw.githubusercontent.com/owner/repo/branch/file.txt",
+			expected:    "owner/repo/blob/branch/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid raw url with full ref",
+			url:         "https://raw.githubusercontent.com/owner/repo/refs/heads/branch/directory/file.txt",
+			expected:    "owner/repo/blob/branch/directory/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid raw url without path",
+			url:         "https://raw.githubusercontent.com/owner/repo/branch",
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
+		{
+			name:        "invalid raw url without ref",
+			url:         "https://raw.githubusercontent.com/owner/repo",
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
 		{
 			name:        "invalid https url",
 			url:         "https://gitlab.com/owner/repo/tree/branch/directory",
@@ -57,14 +142,74 @@ func TestGetGitHubRepo(t *testing.T) {
 			expectedErr: ErrNotValidURL,
 		},
 		{
-			name:        "invalid https url format",
+			name:        "valid bare https url",
 			url:         "https://github.com/owner/repo",
+			expected:    "owner/repo/tree/@default/",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid bare url with trailing slash",
+			url:         "github.com/owner/repo/",
+			expected:    "owner/repo/tree/@default/",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid ssh clone url",
+			url:         "git@github.com:owner/repo.git",
+			expected:    "owner/repo/tree/@default/",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid ssh clone url without suffix",
+			url:         "git@github.com:owner/repo",
+			expected:    "owner/repo/tree/@default/",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid ssh scheme url",
+			url:         "ssh://git@github.com/owner/repo.git",
+			expected:    "owner/repo/tree/@default/",
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid ssh clone url",
+			url:         "git@github.com:owner",
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
+		{
+			name:        "invalid ssh host",
+			url:         "git@gitlab.com:owner/repo.git",
+			expected:    "",
+			expectedErr: ErrNotValidURL,
+		},
+		{
+			name:        "valid url with leading and trailing slashes",
+			url:         "https://github.com//owner/repo/tree/branch/directory/",
+			expected:    "owner/repo/tree/branch/directory",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid bare url with doubled slashes",
+			url:         "github.com/owner//repo//",
+			expected:    "owner/repo/tree/@default/",
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid url format with slashes",
+			url:         "https://github.com//owner//",
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
+		{
+			name:        "invalid https url format",
+			url:         "https://github.com/owner",
 			expected:    "",
 			expectedErr: ErrNotValidFormat,
 		},
 		{
 			name:        "invalid url format",
-			url:         "github.com/owner/repo",
+			url:         "github.com/owner/repo/tree",
 			expected:    "",
 			expectedErr: ErrNotValidFormat,
 		},
@@ -80,6 +225,48 @@ func TestGetGitHubRepo(t *testing.T) {
 	}
 }
 
+func TestGetHostRepo(t *testing.T) {
+	t.Parallel()
+	host := "github.mycorp.com"
+	repo, err := getHostRepo(host, "https://github.mycorp.com/owner/repo/tree/main/dir")
+	require.NoError(t, err)
+	assert.Equal(t, "owner/repo/tree/main/dir", repo)
+
+	repo, err = getHostRepo(host, "github.mycorp.com/owner/repo")
+	require.NoError(t, err)
+	assert.Equal(t, "owner/repo/tree/@default/", repo)
+
+	repo, err = getHostRepo(host, "git@github.mycorp.com:owner/repo.git")
+	require.NoError(t, err)
+	assert.Equal(t, "owner/repo/tree/@default/", repo)
+
+	_, err = getHostRepo(host, "https://github.com/owner/repo/tree/main/dir")
+	require.ErrorIs(t, err, ErrNotValidURL)
+	assert.ErrorContains(t, err, host)
+}
+
+func TestLatest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "repository root", input: "owner/repo@latest", expected: "owner/repo/tree/@latest/"},
+		{name: "repository path", input: "owner/repo@latest/dir/file.txt", expected: "owner/repo/tree/@latest/dir/file.txt"},
+		{name: "tree url", input: "owner/repo/tree/branch/dir", expected: "owner/repo/tree/branch/dir"},
+		{name: "missing repository", input: "owner/@latest", expected: "owner/@latest"},
+		{name: "missing owner", input: "/repo@latest", expected: "/repo@latest"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, latest(test.input))
+		})
+	}
+}
+
 func TestValidate(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -106,6 +293,72 @@ func TestValidate(t *testing.T) {
 			expected:    "owner/repo/tree/branch/directory1/directory2/file.txt",
 			expectedErr: nil,
 		},
+		{
+			name:        "valid blob format",
+			input:       "owner/repo/blob/branch/directory/file.go",
+			expected:    "owner/repo/blob/branch/directory/file.go",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid blob format with trailing slash",
+			input:       "owner/repo/blob/branch/file.go/",
+			expected:    "owner/repo/blob/branch/file.go",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid format with trailing slash",
+			input:       "owner/repo/tree/branch/directory/",
+			expected:    "owner/repo/tree/branch/directory",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid format with leading slash",
+			input:       "/owner/repo/tree/branch/directory",
+			expected:    "owner/repo/tree/branch/directory",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid format with doubled slashes",
+			input:       "owner//repo/tree/branch/directory1//directory2",
+			expected:    "owner/repo/tree/branch/directory1/directory2",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid format without path",
+			input:       "owner/repo/tree/branch",
+			expected:    "owner/repo/tree/branch/",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid tag ref",
+			input:       "owner/repo/tree/v1.2.3/directory",
+			expected:    "owner/repo/tree/v1.2.3/directory",
+			expectedErr: nil,
+		},
+		{
+			name:        "valid commit sha ref",
+			input:       "owner/repo/blob/a1b2c3d/directory/file.go",
+			expected:    "owner/repo/blob/a1b2c3d/directory/file.go",
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid format without ref",
+			input:       "owner/repo/tree//",
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
+		{
+			name:        "invalid blob format without path",
+			input:       "owner/repo/blob/branch/",
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
+		{
+			name:        "invalid kind",
+			input:       "owner/repo/commits/branch/directory",
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
 		{
 			name:        "invalid format 1",
 			input:       "owner/repo/directory",
@@ -130,6 +383,37 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestIsCommitSHA(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{name: "full sha", input: "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678", expected: true},
+		{name: "short sha", input: "a1b2c3d", expected: true},
+		{name: "uppercase sha", input: "A1B2C3D", expected: true},
+		{name: "too short", input: "a1b2c3", expected: false},
+		{name: "too long", input: strings.Repeat("a", 41), expected: false},
+		{name: "branch name", input: "master", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, isCommitSHA(test.input))
+		})
+	}
+}
+
+func TestIsFullCommitSHA(t *testing.T) {
+	t.Parallel()
+	assert.True(t, isFullCommitSHA("a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"))
+	assert.False(t, isFullCommitSHA("a1b2c3d"))
+	assert.False(t, isFullCommitSHA("main"))
+	assert.False(t, isFullCommitSHA("g1b2c3d4e5f60718293a4b5c6d7e8f9012345678"))
+}
+
 type errReader int
 
 var errMockReadAll = errors.New("mock readall body error")
@@ -176,13 +460,6 @@ func TestSaveFile(t *testing.T) {
 			body:     bytes.NewBufferString("test data"),
 			expected: nil,
 		},
-		{
-			name:     "error open file",
-			base:     "tmp",
-			path:     ".",
-			body:     nil,
-			expected: &os.PathError{Op: "open", Path: ".", Err: syscall.EISDIR},
-		},
 		{
 			name:     "error reading body",
 			base:     "tmp_err_reading_body",
@@ -197,6 +474,20 @@ func TestSaveFile(t *testing.T) {
 			body:     bytes.NewBufferString("test data"),
 			expected: fmt.Errorf("Rel: can't make %s relative to %s", "path/to/dir/file2.txt", "/nonexistent/base"),
 		},
+		{
+			name:     "path escaping base",
+			base:     fakeBase,
+			path:     fakeBase + "/../../escaped.txt",
+			body:     bytes.NewBufferString("test data"),
+			expected: fmt.Errorf("%w: %s", ErrPathEscape, fakeBase+"/../../escaped.txt"),
+		},
+		{
+			name:     "absolute path",
+			base:     fakeBase,
+			path:     "/tmp/escaped.txt",
+			body:     bytes.NewBufferString("test data"),
+			expected: fmt.Errorf("%w: %s", ErrPathEscape, "/tmp/escaped.txt"),
+		},
 		{
 			name:     "error creating directory",
 			base:     strings.Repeat("a", 256),
@@ -209,10 +500,148 @@ func TestSaveFile(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			err := saveFile(test.base, test.path, test.body)
+			err := saveFileMode(context.Background(), options{}, test.base, test.path, 0o600, test.body)
 			assert.Equal(t, test.expected, err)
 		})
 	}
+
+	t.Run("error rename file", func(t *testing.T) {
+		t.Parallel()
+		err := saveFileMode(context.Background(), options{}, "tmp", ".", 0o600, bytes.NewBufferString("test data"))
+		var linkErr *os.LinkError
+		require.ErrorAs(t, err, &linkErr)
+		assert.Equal(t, ".", linkErr.New)
+		_, err = os.Stat(linkErr.Old)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+	t.Run("error reading body partway", func(t *testing.T) {
+		t.Parallel()
+		out := t.TempDir()
+		body := io.MultiReader(strings.NewReader("partial data"), errReader(0))
+		err := saveFileMode(context.Background(), options{outputDir: out}, "dir", "dir/file.txt", 0o600, body)
+		require.ErrorIs(t, err, errMockReadAll)
+
+		dir := filepath.Join(out, "dir")
+		_, err = os.Stat(filepath.Join(dir, "file.txt"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+	t.Run("error ctx cancel", func(t *testing.T) {
+		t.Parallel()
+		fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+		t.Cleanup(func() {
+			err := os.RemoveAll(fakeBase)
+			require.NoError(t, err)
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := saveFileMode(ctx, options{}, fakeBase, fakeBase+"/file.txt", 0o600, bytes.NewBufferString("test data"))
+		require.ErrorIs(t, err, context.Canceled)
+		_, err = os.Stat(fakeBase + "/file.txt")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestNewRunID(t *testing.T) {
+	t.Parallel()
+	id := newRunID()
+	assert.Len(t, id, 16)
+	assert.NotEqual(t, id, newRunID())
+}
+
+func TestSaveFileBufferSize(t *testing.T) {
+	t.Parallel()
+	content := strings.Repeat(gofakeit.LoremIpsumSentence(10), 100)
+
+	for _, size := range []int{0, 1, 7, 4096, 1 << 20} {
+		t.Run(fmt.Sprintf("buffer size %d", size), func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			fakePath := fmt.Sprintf("%s/%s_%d.txt", fakeBase, gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+
+			err := saveFileMode(context.Background(), options{writeBufferSize: size}, fakeBase, fakePath, 0o600, strings.NewReader(content))
+			require.NoError(t, err)
+
+			b, err := os.ReadFile(fakePath)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(b))
+		})
+	}
+
+	t.Run("error reading body", func(t *testing.T) {
+		t.Parallel()
+		t.Cleanup(func() {
+			err := os.RemoveAll("tmp_err_reading_body_buffered")
+			require.NoError(t, err)
+		})
+		err := saveFileMode(context.Background(), options{writeBufferSize: 16}, "tmp_err_reading_body_buffered", "tmp_err_reading_body_buffered/file.txt", 0o600, errReader(0))
+		assert.Equal(t, errMockReadAll, err)
+	})
+}
+
+func TestExecutable(t *testing.T) {
+	t.Parallel()
+	restricted := options{executableExtensions: []string{".sh"}}
+	tests := []struct {
+		name     string
+		opts     options
+		path     string
+		mode     os.FileMode
+		expected bool
+	}{
+		{name: "executable", path: "run.txt", mode: 0o755, expected: true},
+		{name: "not executable", path: "run.sh", mode: 0o644, expected: false},
+		{name: "executable extension", opts: restricted, path: "dir/run.sh", mode: 0o755, expected: true},
+		{name: "executable extension case", opts: restricted, path: "dir/RUN.SH", mode: 0o755, expected: true},
+		{name: "other extension", opts: restricted, path: "dir/run.txt", mode: 0o755, expected: false},
+		{name: "not executable extension", opts: restricted, path: "dir/run.sh", mode: 0o644, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, executable(test.opts, test.path, test.mode))
+		})
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	t.Parallel()
+	router := func(path string) string {
+		if strings.HasSuffix(path, ".md") {
+			return "docs"
+		}
+		return "code"
+	}
+
+	p, err := localPath(options{}, "repo/dir", "repo/dir/a.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("dir", "a.go"), p)
+
+	p, err = localPath(options{router: router}, "repo/dir", "repo/dir/a.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("code", "dir", "a.go"), p)
+
+	p, err = localPath(options{router: router}, "repo/dir", "repo/dir/sub/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("docs", "dir", "sub", "README.md"), p)
+
+	p, err = localPath(options{outputDir: "out"}, "repo/dir", "repo/dir/a.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("out", "dir", "a.go"), p)
+
+	p, err = localPath(options{outputDir: "out", router: router}, "repo/dir", "repo/dir/a.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("out", "code", "dir", "a.go"), p)
+
+	_, err = localPath(options{router: router}, "/nonexistent/base", "path/to/file.txt")
+	require.Error(t, err)
 }
 
 func TestExactPath(t *testing.T) {
@@ -252,6 +681,34 @@ func TestExactPath(t *testing.T) {
 			expected:    "",
 			expectedErr: fmt.Errorf("Rel: can't make %s relative to %s", "path/to/dir/file.txt", "/nonexistent/base"),
 		},
+		{
+			name:        "parent segments",
+			base:        "path/to/dir",
+			path:        "path/to/dir/../../../../etc/passwd",
+			expected:    "",
+			expectedErr: fmt.Errorf("%w: %s", ErrPathEscape, "path/to/dir/../../../../etc/passwd"),
+		},
+		{
+			name:        "parent segments without base",
+			base:        "",
+			path:        "../etc/passwd",
+			expected:    "",
+			expectedErr: fmt.Errorf("%w: %s", ErrPathEscape, "../etc/passwd"),
+		},
+		{
+			name:        "parent segments within base",
+			base:        "path/to/dir",
+			path:        "path/to/dir/sub/../file.txt",
+			expected:    "dir/file.txt",
+			expectedErr: nil,
+		},
+		{
+			name:        "absolute path",
+			base:        "path/to/dir",
+			path:        "/etc/passwd",
+			expected:    "",
+			expectedErr: fmt.Errorf("%w: %s", ErrPathEscape, "/etc/passwd"),
+		},
 	}
 
 	for _, test := range tests {
@@ -263,3 +720,54 @@ func TestExactPath(t *testing.T) {
 		})
 	}
 }
+
+func TestGlobCase(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "Docs/README.md", globCase(options{}, "Docs/README.md"))
+	assert.Equal(t, "docs/readme.md", globCase(options{caseInsensitiveGlobs: true}, "Docs/README.md"))
+}
+
+func TestParseAction(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		uses  string
+		owner string
+		repo  string
+		path  string
+		ref   string
+		err   error
+	}{
+		{uses: "actions/checkout@v4", owner: "actions", repo: "checkout", ref: "v4"},
+		{uses: "owner/repo/path/to/action@a1b2c3d", owner: "owner", repo: "repo", path: "path/to/action", ref: "a1b2c3d"},
+		{uses: "actions/checkout", err: ErrNotValidAction},
+		{uses: "actions/checkout@", err: ErrNotValidAction},
+		{uses: "checkout@v4", err: ErrNotValidAction},
+		{uses: "actions/checkout@v4@v5", err: ErrNotValidAction},
+	}
+
+	for _, test := range tests {
+		t.Run(test.uses, func(t *testing.T) {
+			t.Parallel()
+			owner, repo, path, ref, err := parseAction(test.uses)
+			require.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.owner, owner)
+			assert.Equal(t, test.repo, repo)
+			assert.Equal(t, test.path, path)
+			assert.Equal(t, test.ref, ref)
+		})
+	}
+}
+
+func TestDownloadEncodedPath(t *testing.T) {
+	t.Parallel()
+	r := serverRepository(t, contentsMux(map[string]string{"my folder/c++ file.txt": "data"}))
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/my%20folder"))
+	assert.Equal(t, "my folder", r.Path)
+	base := t.TempDir()
+
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join(base, "my folder", "c++ file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(b))
+}
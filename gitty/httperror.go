@@ -0,0 +1,46 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// httpErrorBodyLimit represents the number of bytes of a response body kept
+// in an HTTPError at most.
+const httpErrorBodyLimit = 512
+
+// ErrRepoNotFound is returned for a repository that does not exist or is not
+// visible with the token in use.
+var ErrRepoNotFound = errors.New("repository not found")
+
+// HTTPError is returned for a response to a raw download, such as a file or
+// archive, with a status other than 200 OK.
+type HTTPError struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// URL is the URL of the request.
+	URL string
+	// Body is the start of the response body, at most 512 bytes.
+	Body string
+}
+
+// Error returns the message of the error along with the status and URL.
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+}
+
+// newHTTPError returns the HTTPError of resp, with the start of its body.
+func newHTTPError(resp *http.Response) *HTTPError {
+	e := &HTTPError{StatusCode: resp.StatusCode}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.URL = resp.Request.URL.String()
+	}
+	if resp.Body != nil {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, httpErrorBodyLimit))
+		e.Body = strings.TrimSpace(string(b))
+	}
+	return e
+}
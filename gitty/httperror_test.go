@@ -0,0 +1,53 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPError(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/raw/file.txt", nil)
+	resp := &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Request:    req,
+		Body:       io.NopCloser(strings.NewReader(strings.Repeat("x", httpErrorBodyLimit+10))),
+	}
+
+	err := newHTTPError(resp)
+	assert.Equal(t, http.StatusBadGateway, err.StatusCode)
+	assert.Equal(t, "https://example.com/raw/file.txt", err.URL)
+	assert.Len(t, err.Body, httpErrorBodyLimit)
+	assert.Equal(t, "502 Bad Gateway: https://example.com/raw/file.txt", err.Error())
+	assert.True(t, isStatus(fmt.Errorf("failed: %w", err), http.StatusBadGateway))
+	assert.False(t, isStatus(err, http.StatusNotFound))
+
+	assert.Equal(t, &HTTPError{StatusCode: http.StatusNotFound}, newHTTPError(&http.Response{StatusCode: http.StatusNotFound}))
+}
+
+func TestDownloadHTTPError(t *testing.T) {
+	t.Parallel()
+	contents := contentsMux(map[string]string{"dir/file.txt": "content"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /raw/dir/file.txt", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+	mux.Handle("/", contents)
+	r := serverRepository(t, mux)
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusGone, httpErr.StatusCode)
+	assert.True(t, strings.HasSuffix(httpErr.URL, "/raw/dir/file.txt"))
+	assert.Equal(t, "gone", httpErr.Body)
+}
@@ -0,0 +1,128 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreName represents the name of the ignore file read from the directory
+// the files are saved in, unless another path is set.
+const ignoreName = ".gittyignore"
+
+// ignorePattern represents a pattern of an ignore file.
+type ignorePattern struct {
+	pattern string
+	// negate re-includes the paths matching the pattern.
+	negate bool
+	// dir matches directories only.
+	dir bool
+	// anchored matches the path relative to the requested directory instead
+	// of a name at any depth.
+	anchored bool
+}
+
+// match reports whether the pattern matches the path, a directory if dir is
+// set.
+func (p ignorePattern) match(rel string, dir bool) bool {
+	if p.dir && !dir {
+		return false
+	}
+	name := rel
+	if !p.anchored {
+		name = path.Base(rel)
+	}
+	ok, _ := path.Match(p.pattern, name)
+	return ok
+}
+
+// parseIgnore parses the patterns of an ignore file in the format of
+// .gitignore. Blank lines and lines starting with # are skipped, a leading !
+// negates the pattern, and a trailing slash matches directories only.
+// Patterns with a slash other than a trailing one match relative to the
+// requested directory, other patterns match a name at any depth.
+func parseIgnore(b []byte) []ignorePattern {
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p ignorePattern
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			p.negate = true
+			line = rest
+		}
+		// A leading backslash escapes a leading # or !.
+		line = strings.TrimPrefix(line, `\`)
+		line = strings.TrimPrefix(line, "**/")
+		if rest, ok := strings.CutSuffix(line, "/**"); ok {
+			p.dir = true
+			line = rest
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			p.dir = true
+			line = rest
+		}
+		p.anchored = strings.Contains(line, "/")
+		p.pattern = strings.TrimPrefix(line, "/")
+		if p.pattern != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// readIgnore reads the patterns of the ignore file, at the set path or named
+// ignoreName in the directory the files of the repository path are saved in,
+// if it exists.
+func readIgnore(o options, path string) ([]ignorePattern, error) {
+	name := o.ignoreFile
+	if name == "" {
+		name = filepath.Join(baseDir(o, path), ignoreName)
+	}
+
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseIgnore(b), nil
+}
+
+// ignored reports whether the repository path, a directory if dir is set, is
+// ignored by the patterns of the ignore file, relative to the requested
+// directory. As with .gitignore, the last matching pattern decides, and the
+// paths within an ignored directory cannot be re-included.
+func (g *GitHub) ignored(p string, dir bool) bool {
+	if len(g.opts.ignore) == 0 {
+		return false
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(p, g.Path), "/")
+	rel = globCase(g.opts, rel)
+
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		sub := strings.Join(segments[:i+1], "/")
+		isDir := dir || i < len(segments)-1
+		ignored := false
+		for _, pattern := range g.opts.ignore {
+			pattern.pattern = globCase(g.opts, pattern.pattern)
+			if pattern.match(sub, isDir) {
+				ignored = !pattern.negate
+			}
+		}
+		if ignored {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIgnore(t *testing.T) {
+	t.Parallel()
+	b := []byte("# comment\n\n*.log  \n!keep.log\nbuild/\n/docs/*.md\n**/vendor\ncache/**\n\\#hash\n")
+	expected := []ignorePattern{
+		{pattern: "*.log"},
+		{pattern: "keep.log", negate: true},
+		{pattern: "build", dir: true},
+		{pattern: "docs/*.md", anchored: true},
+		{pattern: "vendor"},
+		{pattern: "cache", dir: true},
+		{pattern: "#hash"},
+	}
+	assert.Equal(t, expected, parseIgnore(b))
+}
+
+func TestIgnored(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		patterns string
+		path     string
+		dir      bool
+		expected bool
+	}{
+		{name: "name", patterns: "*.log", path: "dir/sub/debug.log", expected: true},
+		{name: "no match", patterns: "*.log", path: "dir/main.go", expected: false},
+		{name: "negation", patterns: "*.log\n!keep.log", path: "dir/keep.log", expected: false},
+		{name: "negation order", patterns: "!keep.log\n*.log", path: "dir/keep.log", expected: true},
+		{name: "dir pattern", patterns: "build/", path: "dir/build", dir: true, expected: true},
+		{name: "dir pattern file", patterns: "build/", path: "dir/build", expected: false},
+		{name: "within dir", patterns: "build/", path: "dir/build/out.txt", expected: true},
+		{name: "within ignored dir", patterns: "build/\n!build/keep.txt", path: "dir/build/keep.txt", expected: true},
+		{name: "anchored", patterns: "/sub/*.md", path: "dir/sub/README.md", expected: true},
+		{name: "anchored nested", patterns: "/sub/*.md", path: "dir/a/sub/README.md", expected: false},
+		{name: "no patterns", path: "dir/main.go", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Path: "dir", opts: newOptions()}
+			g.opts.ignore = parseIgnore([]byte(test.patterns))
+			assert.Equal(t, test.expected, g.ignored(test.path, test.dir))
+		})
+	}
+}
+
+func TestReadIgnore(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	o := newOptions()
+	var routed []string
+	o.router = func(path string) string {
+		routed = append(routed, path)
+		return base
+	}
+
+	patterns, err := readIgnore(o, "dir")
+	require.NoError(t, err)
+	assert.Nil(t, patterns)
+	// The router is given the path of the download.
+	assert.Equal(t, []string{"dir"}, routed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(base, ignoreName), []byte("*.log\n"), 0o600))
+	patterns, err = readIgnore(o, "dir")
+	require.NoError(t, err)
+	assert.Equal(t, []ignorePattern{{pattern: "*.log"}}, patterns)
+
+	o.ignoreFile = base
+	_, err = readIgnore(o, "dir")
+	require.Error(t, err)
+}
+
+func TestDownloadIgnoreFile(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	contents := contentsMux(map[string]string{
+		"dir/main.go":           "main",
+		"dir/debug.log":         "debug",
+		"dir/keep.log":          "keep",
+		"dir/build/out.txt":     "out",
+		"dir/sub/README.md":     "readme",
+		"dir/sub/util.go":       "util",
+		"dir/vendor/lib/lib.go": "lib",
+	})
+	var walked atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/dir/build", func(w http.ResponseWriter, r *http.Request) {
+		walked.Add(1)
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	ignore := filepath.Join(base, ignoreName)
+	require.NoError(t, os.WriteFile(ignore, []byte("*.log\n!keep.log\nbuild/\n/sub/*.md\n"), 0o600))
+	r := serverRepository(t, mux)
+	r.opts.exclude = []string{"vendor"}
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{ignoreName, "dir/keep.log", "dir/main.go", "dir/sub/util.go"}, savedFiles(t, base))
+	assert.Zero(t, walked.Load(), "ignored directory walked")
+}
+
+func TestDownloadIgnoreFileError(t *testing.T) {
+	t.Parallel()
+	r := serverRepository(t, contentsMux(map[string]string{"dir/main.go": "main"}))
+	r.opts.ignoreFile = t.TempDir()
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	require.ErrorContains(t, err, "failed to read ignore file")
+}
@@ -0,0 +1,53 @@
+package gitty
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// indexName represents the file name of a directory index.
+const indexName = "index.json"
+
+// indexEntry represents a file listed in a directory index.
+type indexEntry struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+	SHA  string `json:"sha"`
+}
+
+// writeIndexes writes an index file into each downloaded directory listing
+// the files of the directory with their sizes and SHAs.
+func writeIndexes(o options, base string, files []*github.RepositoryContent) error {
+	dirs := make(map[string][]indexEntry)
+	for _, file := range files {
+		p, err := localPath(o, base, file.GetPath())
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(p)
+		dirs[dir] = append(dirs[dir], indexEntry{
+			Name: filepath.Base(p),
+			Size: file.GetSize(),
+			SHA:  file.GetSHA(),
+		})
+	}
+
+	for dir, entries := range dirs {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name < entries[j].Name
+		})
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, indexName), b, 0o600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package gitty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readIndex reads the index file of the directory.
+func readIndex(t *testing.T, dir string) []indexEntry {
+	t.Helper()
+	b, err := os.ReadFile(dir + "/" + indexName)
+	require.NoError(t, err)
+	var entries []indexEntry
+	err = json.Unmarshal(b, &entries)
+	require.NoError(t, err)
+	return entries
+}
+
+func TestWriteIndexes(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	for _, dir := range []string{fakeBase, fakeBase + "/sub"} {
+		err := os.MkdirAll(dir, os.ModePerm)
+		require.NoError(t, err)
+	}
+
+	files := []*github.RepositoryContent{
+		{Path: ptr("repo/" + fakeBase + "/b.txt"), Size: ptr(2), SHA: ptr("bbb")},
+		{Path: ptr("repo/" + fakeBase + "/a.txt"), Size: ptr(1), SHA: ptr("aaa")},
+		{Path: ptr("repo/" + fakeBase + "/sub/c.txt"), Size: ptr(3), SHA: ptr("ccc")},
+	}
+	err := writeIndexes(options{}, "repo/"+fakeBase, files)
+	require.NoError(t, err)
+
+	assert.Equal(t, []indexEntry{
+		{Name: "a.txt", Size: 1, SHA: "aaa"},
+		{Name: "b.txt", Size: 2, SHA: "bbb"},
+	}, readIndex(t, fakeBase))
+	assert.Equal(t, []indexEntry{
+		{Name: "c.txt", Size: 3, SHA: "ccc"},
+	}, readIndex(t, fakeBase+"/sub"))
+}
+
+func TestWriteIndexesError(t *testing.T) {
+	t.Parallel()
+	files := []*github.RepositoryContent{{Path: ptr("path/to/dir/file.txt")}}
+	err := writeIndexes(options{}, "/nonexistent/base", files)
+	require.Error(t, err)
+
+	files = []*github.RepositoryContent{{Path: ptr("missing_dir/file.txt")}}
+	err = writeIndexes(options{}, "missing_dir", files)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDownloadIndex(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+		err = os.RemoveAll(fakeBase + ".txt")
+		require.NoError(t, err)
+	})
+
+	r := serverRepository(t, contentsMux(map[string]string{
+		fakeBase + "/a.txt":                    "a",
+		fakeBase + "/sub/" + fakeBase + ".txt": "bb",
+	}))
+	r.opts.index = true
+	g := fakeNew(r)
+
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+	assert.Equal(t, []indexEntry{{Name: "a.txt", Size: 1}}, readIndex(t, fakeBase))
+	assert.Equal(t, []indexEntry{{Name: fakeBase + ".txt", Size: 2}}, readIndex(t, fakeBase+"/sub"))
+
+	// A single file download does not write an index.
+	err = g.Download(context.Background(), "https://github.com/owner/repo/blob/main/"+fakeBase+"/sub/"+fakeBase+".txt")
+	require.NoError(t, err)
+	_, err = os.Stat(indexName)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,23 @@
+package gitty
+
+import "net/http"
+
+// interceptTransport represents an http.RoundTripper that passes each
+// request to an interceptor before sending it.
+type interceptTransport struct {
+	base      http.RoundTripper
+	intercept func(*http.Request) error
+}
+
+// Ensure interceptTransport implements the http.RoundTripper interface.
+var _ http.RoundTripper = (*interceptTransport)(nil)
+
+// RoundTrip passes a copy of the request to the interceptor and executes a
+// single HTTP transaction with it, unless the interceptor returns an error.
+func (t *interceptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := t.intercept(req); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
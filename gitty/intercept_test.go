@@ -0,0 +1,80 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestInterceptor(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/a.txt":      "a",
+		fakeBase + "/secret.txt": "secret",
+	}
+
+	contents := contentsMux(files)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signature") != "signed" {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		contents.ServeHTTP(w, r)
+	})
+	srv := serverRepository(t, handler)
+
+	errBlocked := errors.New("blocked")
+	var mu sync.Mutex
+	var seen []string
+	o := newOptions(WithRequestInterceptor(func(req *http.Request) error {
+		mu.Lock()
+		seen = append(seen, req.URL.Path)
+		mu.Unlock()
+		req.Header.Set("X-Signature", "signed")
+		if req.URL.Path == "/raw/"+fakeBase+"/secret.txt" {
+			return errBlocked
+		}
+		return nil
+	}))
+	c := newClient(o)
+	c.BaseURL = srv.Client.(*service).client.BaseURL
+	r := repository(c, o)
+	r.opts.concurrency = 1
+
+	err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.ErrorIs(t, err, errBlocked)
+	var urlErr *url.Error
+	require.ErrorAs(t, err, &urlErr)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, seen, "/repos/owner/repo/contents/"+fakeBase)
+	assert.Contains(t, seen, "/raw/"+fakeBase+"/secret.txt")
+	_, err = os.Stat(fakeBase + "/secret.txt")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewClientInterceptor(t *testing.T) {
+	t.Parallel()
+	c := newClient(options{transport: http.DefaultTransport, intercept: func(*http.Request) error { return nil }})
+	rt, ok := c.Client().Transport.(*retryTransport)
+	require.True(t, ok)
+	rl, ok := rt.base.(*rateLimitTransport)
+	require.True(t, ok)
+	_, ok = rl.base.(*interceptTransport)
+	assert.True(t, ok)
+}
@@ -0,0 +1,222 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// lfsVersion represents the first line of a Git LFS pointer file.
+	lfsVersion = "version https://git-lfs.github.com/spec/v1\n"
+	// lfsPointerMax represents the maximum size of a Git LFS pointer file.
+	lfsPointerMax = 1024
+	// lfsMediaType represents the media type of the Git LFS batch API.
+	lfsMediaType = "application/vnd.git-lfs+json"
+	// defaultLFSURL represents the base URL of the Git LFS batch API.
+	defaultLFSURL = "https://github.com/"
+)
+
+var (
+	// ErrLFSObject is returned for a Git LFS object that cannot be downloaded.
+	ErrLFSObject = errors.New("failed to download Git LFS object")
+	// ErrLFSMismatch is returned for a Git LFS object whose content does not
+	// match the SHA-256 digest or size of its pointer.
+	ErrLFSMismatch = errors.New("object does not match its Git LFS pointer")
+)
+
+// lfsPointer represents the object a Git LFS pointer file refers to.
+type lfsPointer struct {
+	oid  string
+	size int64
+}
+
+// parseLFSPointer parses the content of a Git LFS pointer file and reports
+// whether it is one.
+func parseLFSPointer(b []byte) (lfsPointer, bool) {
+	s, ok := strings.CutPrefix(string(b), lfsVersion)
+	if !ok || len(b) > lfsPointerMax {
+		return lfsPointer{}, false
+	}
+
+	var p lfsPointer
+	sized := false
+	for _, line := range strings.Split(s, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			oid, ok := strings.CutPrefix(value, "sha256:")
+			if _, err := hex.DecodeString(oid); !ok || err != nil || len(oid) != sha256.Size*2 {
+				return lfsPointer{}, false
+			}
+			p.oid = oid
+		case "size":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 {
+				return lfsPointer{}, false
+			}
+			p.size, sized = n, true
+		}
+	}
+	return p, p.oid != "" && sized
+}
+
+// peekLFSPointer reports whether the body starts with a Git LFS pointer file
+// and returns the pointer, without consuming the body. The reader must
+// buffer more than lfsPointerMax bytes.
+func peekLFSPointer(r *bufio.Reader) (lfsPointer, bool) {
+	if b, _ := r.Peek(len(lfsVersion)); string(b) != lfsVersion {
+		return lfsPointer{}, false
+	}
+	b, _ := r.Peek(lfsPointerMax + 1)
+	return parseLFSPointer(b)
+}
+
+// saveBody saves the body downloaded for the file at the repository path.
+// A Git LFS pointer is replaced by the content of its object if LFS is
+// enabled, and is saved as is with a warning otherwise.
+func (g *GitHub) saveBody(ctx context.Context, o options, path string, body io.Reader) error {
+	r := bufio.NewReaderSize(body, lfsPointerMax+1)
+	pointer, ok := peekLFSPointer(r)
+	if ok && o.lfs {
+		return g.getLFSObject(ctx, o, pointer, path)
+	}
+	if ok {
+		g.warnings.add(WarnLFSPointer, path, "Saving Git LFS pointer")
+	}
+
+	return saveFileMode(ctx, o, g.Path, path, g.fileMode(path), r)
+}
+
+// getLFSObject downloads the object of the Git LFS pointer through the batch
+// API of the repository and saves it as the file at the repository path.
+func (g *GitHub) getLFSObject(ctx context.Context, o options, pointer lfsPointer, path string) error {
+	href, header, err := g.lfsDownload(ctx, o, pointer)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrLFSObject, path, err)
+	}
+	resp, err := g.Client.GetWithHeader(ctx, href, header)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrLFSObject, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: %w", ErrLFSObject, path, newHTTPError(resp))
+	}
+
+	// The blob SHA listed for the file is the SHA of the pointer, so the
+	// object is verified against the digest of the pointer instead.
+	o.blobs = nil
+	body := &lfsReader{r: resp.Body, pointer: pointer, h: sha256.New()}
+	return saveFileMode(ctx, o, g.Path, path, g.fileMode(path), body)
+}
+
+// lfsBatchRequest represents a request of the Git LFS batch API.
+type lfsBatchRequest struct {
+	Operation string      `json:"operation"`
+	Transfers []string    `json:"transfers"`
+	Objects   []lfsObject `json:"objects"`
+}
+
+// lfsBatchResponse represents a response of the Git LFS batch API.
+type lfsBatchResponse struct {
+	Objects []lfsObject `json:"objects"`
+}
+
+// lfsObject represents an object of the Git LFS batch API, along with the
+// actions or the error of the object in a response.
+type lfsObject struct {
+	OID     string      `json:"oid"`
+	Size    int64       `json:"size"`
+	Actions *lfsActions `json:"actions,omitempty"`
+	Error   *lfsError   `json:"error,omitempty"`
+}
+
+// lfsActions represents the actions available for an object.
+type lfsActions struct {
+	Download *lfsAction `json:"download"`
+}
+
+// lfsAction represents the request that transfers an object.
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+// lfsError represents the error of an object.
+type lfsError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lfsDownload returns the URL and headers of the request that downloads the
+// object of the Git LFS pointer, as given by the batch API.
+func (g *GitHub) lfsDownload(ctx context.Context, o options, pointer lfsPointer) (string, map[string]string, error) {
+	body, err := json.Marshal(lfsBatchRequest{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   []lfsObject{{OID: pointer.oid, Size: pointer.size}},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	url := o.lfsURL + g.Owner + "/" + g.Repo + ".git/info/lfs/objects/batch"
+	resp, err := g.Client.PostLFSBatch(ctx, url, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, newHTTPError(resp)
+	}
+	var batch lfsBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return "", nil, err
+	}
+	for _, obj := range batch.Objects {
+		if obj.OID != pointer.oid {
+			continue
+		}
+		if obj.Error != nil {
+			return "", nil, fmt.Errorf("%d %s", obj.Error.Code, obj.Error.Message)
+		}
+		if obj.Actions != nil && obj.Actions.Download != nil {
+			return obj.Actions.Download.Href, obj.Actions.Download.Header, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no download action for %s", pointer.oid)
+}
+
+// lfsReader represents a reader of the content of a Git LFS object that
+// fails with ErrLFSMismatch at the end of the content if it does not match
+// the SHA-256 digest or size of its pointer.
+type lfsReader struct {
+	r       io.Reader
+	pointer lfsPointer
+	h       hash.Hash
+	n       int64
+}
+
+// Read reads from the content and verifies it once it is read.
+func (r *lfsReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+	if errors.Is(err, io.EOF) && (r.n != r.pointer.size || hex.EncodeToString(r.h.Sum(nil)) != r.pointer.oid) {
+		return n, fmt.Errorf("%w: %s", ErrLFSMismatch, r.pointer.oid)
+	}
+	return n, err
+}
@@ -0,0 +1,253 @@
+package gitty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lfsFile returns the Git LFS pointer file of the content and its oid.
+func lfsFile(content string) (pointer, oid string) {
+	sum := sha256.Sum256([]byte(content))
+	oid = hex.EncodeToString(sum[:])
+	return fmt.Sprintf("%soid sha256:%s\nsize %d\n", lfsVersion, oid, len(content)), oid
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	t.Parallel()
+	pointer, oid := lfsFile("large content")
+	tests := []struct {
+		name     string
+		content  string
+		expected lfsPointer
+		ok       bool
+	}{
+		{name: "pointer", content: pointer, expected: lfsPointer{oid: oid, size: int64(len("large content"))}, ok: true},
+		{name: "pointer with extension", content: lfsVersion + "ext-0-foo sha256:" + oid + "\noid sha256:" + oid + "\nsize 1\n", expected: lfsPointer{oid: oid, size: 1}, ok: true},
+		{name: "regular file", content: "package main\n"},
+		{name: "without oid", content: lfsVersion + "size 1\n"},
+		{name: "without size", content: lfsVersion + "oid sha256:" + oid + "\n"},
+		{name: "invalid oid", content: lfsVersion + "oid sha256:xyz\nsize 1\n"},
+		{name: "invalid size", content: lfsVersion + "oid sha256:" + oid + "\nsize -1\n"},
+		{name: "too large", content: pointer + strings.Repeat("x", lfsPointerMax)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := parseLFSPointer([]byte(test.content))
+			assert.Equal(t, test.ok, ok)
+			if ok {
+				assert.Equal(t, test.expected, got)
+			}
+		})
+	}
+}
+
+// lfsMux serves the files like contentsMux, along with the Git LFS batch API
+// of the repository and the downloads of the objects of the given contents,
+// which require the header given by the batch API.
+func lfsMux(files map[string]string, objects map[string]string) *http.ServeMux {
+	mux := contentsMux(files)
+	mux.HandleFunc("POST /owner/repo.git/info/lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != lfsMediaType {
+			http.Error(w, "want lfs media type", http.StatusNotAcceptable)
+			return
+		}
+		var req lfsBatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Operation != "download" {
+			http.Error(w, "want download batch", http.StatusBadRequest)
+			return
+		}
+		var resp lfsBatchResponse
+		for _, obj := range req.Objects {
+			if _, ok := objects[obj.OID]; !ok {
+				resp.Objects = append(resp.Objects, lfsObject{OID: obj.OID, Size: obj.Size, Error: &lfsError{Code: http.StatusNotFound, Message: "Object does not exist"}})
+				continue
+			}
+			resp.Objects = append(resp.Objects, lfsObject{OID: obj.OID, Size: obj.Size, Actions: &lfsActions{Download: &lfsAction{
+				Href:   "http://" + r.Host + "/lfs/" + obj.OID,
+				Header: map[string]string{"Authorization": "RemoteAuth token"},
+			}}})
+		}
+		w.Header().Set("Content-Type", lfsMediaType)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("GET /lfs/{oid}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "RemoteAuth token" {
+			http.Error(w, "want action header", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, objects[r.PathValue("oid")])
+	})
+	return mux
+}
+
+func TestDownloadLFS(t *testing.T) {
+	t.Parallel()
+	content := "large binary content"
+	pointer, oid := lfsFile(content)
+	other, _ := lfsFile("missing content")
+	warning := []Warning{{Code: WarnLFSPointer, Path: "dir/image.bin", Message: "Saving Git LFS pointer"}}
+	tests := []struct {
+		name     string
+		file     string
+		objects  map[string]string
+		lfs      bool
+		coalesce bool
+		saved    string
+		warnings []Warning
+		expected error
+	}{
+		{name: "object", file: pointer, objects: map[string]string{oid: content}, lfs: true, saved: content},
+		{name: "disabled", file: pointer, objects: map[string]string{oid: content}, saved: pointer, warnings: warning},
+		{name: "archive object", file: pointer, objects: map[string]string{oid: content}, lfs: true, coalesce: true, saved: content},
+		{name: "archive disabled", file: pointer, objects: map[string]string{oid: content}, coalesce: true, saved: pointer, warnings: warning},
+		{name: "mismatch", file: pointer, objects: map[string]string{oid: "tampered content"}, lfs: true, expected: ErrLFSMismatch},
+		{name: "missing object", file: other, objects: map[string]string{oid: content}, lfs: true, expected: ErrLFSObject},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			files := map[string]string{"dir/image.bin": test.file, "dir/README.md": "readme"}
+			mux := lfsMux(files, test.objects)
+			if test.coalesce {
+				archiveRoutes(t, mux, files, nil)
+			}
+			r := serverRepository(t, mux)
+			r.opts.lfs = test.lfs
+			r.opts.coalesce = test.coalesce
+			r.opts.lfsURL = r.Client.(*service).client.BaseURL.String()
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+			base := t.TempDir()
+
+			result, err := r.downloadTo(context.Background(), base)
+			require.ErrorIs(t, err, test.expected)
+
+			b, err := os.ReadFile(filepath.Join(base, "dir", "image.bin"))
+			if test.expected != nil {
+				require.ErrorIs(t, err, os.ErrNotExist)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.saved, string(b))
+			assert.Equal(t, test.warnings, result.Warnings)
+		})
+	}
+}
+
+func TestDownloadLFSToken(t *testing.T) {
+	t.Parallel()
+	content := "large binary content"
+	pointer, oid := lfsFile(content)
+	secret := "ghp_secret"
+
+	// The objects are kept on another host, which must not see the token.
+	var storageAuth []string
+	var mu sync.Mutex
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		storageAuth = append(storageAuth, r.Header.Values("Authorization")...)
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "RemoteAuth token" {
+			http.Error(w, "want action header", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	t.Cleanup(storage.Close)
+
+	mux := contentsMux(map[string]string{"dir/image.bin": pointer})
+	mux.HandleFunc("POST /owner/repo.git/info/lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+secret {
+			http.Error(w, "want token", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(lfsBatchResponse{Objects: []lfsObject{{OID: oid, Size: int64(len(content)), Actions: &lfsActions{Download: &lfsAction{
+			Href:   storage.URL + "/" + oid,
+			Header: map[string]string{"Authorization": "RemoteAuth token"},
+		}}}}})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	base := t.TempDir()
+
+	_, err := Download(context.Background(), "https://github.com/owner/repo/tree/main/dir",
+		WithToken(secret),
+		WithBaseURL(srv.URL+"/"),
+		WithLFS(true),
+		WithOutputDir(base),
+		func(o *options) { o.lfsURL = srv.URL + "/" },
+	)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(filepath.Join(base, "dir", "image.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(b))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"RemoteAuth token"}, storageAuth)
+}
+
+func TestLFSDownloadError(t *testing.T) {
+	t.Parallel()
+	_, oid := lfsFile("content")
+	pointer := lfsPointer{oid: oid, size: int64(len("content"))}
+
+	g := &GitHub{Client: &mockError{}, Owner: "owner", Repo: "repo"}
+	_, _, err := g.lfsDownload(context.Background(), options{}, pointer)
+	require.ErrorIs(t, err, errMockLFS)
+	err = g.getLFSObject(context.Background(), options{}, pointer, "file.bin")
+	require.ErrorIs(t, err, ErrLFSObject)
+
+	g.Client = &mockSuccess{}
+	_, _, err = g.lfsDownload(context.Background(), options{}, pointer)
+	require.ErrorContains(t, err, "no download action")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /owner/repo.git/info/lfs/objects/batch", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "{")
+	})
+	mux.HandleFunc("POST /owner/other.git/info/lfs/objects/batch", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	for repo, href := range map[string]string{"missing": "/missing", "invalid": "://invalid"} {
+		mux.HandleFunc("POST /owner/"+repo+".git/info/lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+			u := href
+			if !strings.HasPrefix(u, ":") {
+				u = "http://" + r.Host + u
+			}
+			_ = json.NewEncoder(w).Encode(lfsBatchResponse{Objects: []lfsObject{{OID: oid, Actions: &lfsActions{Download: &lfsAction{Href: u}}}}})
+		})
+	}
+	r := serverRepository(t, mux)
+	o := options{lfsURL: r.Client.(*service).client.BaseURL.String()}
+	r.Owner = "owner"
+	for _, repo := range []string{"missing", "invalid"} {
+		r.Repo = repo
+		err = r.getLFSObject(context.Background(), o, pointer, "file.bin")
+		require.ErrorIs(t, err, ErrLFSObject, repo)
+	}
+
+	r.Owner, r.Repo = "owner", "repo"
+	_, _, err = r.lfsDownload(context.Background(), o, pointer)
+	require.Error(t, err)
+	r.Repo = "other"
+	_, _, err = r.lfsDownload(context.Background(), o, pointer)
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusForbidden, httpErr.StatusCode)
+}
@@ -0,0 +1,30 @@
+package gitty
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler represents a slog.Handler that discards all records.
+type discardHandler struct{}
+
+// Ensure discardHandler implements the slog.Handler interface.
+var _ slog.Handler = discardHandler{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// discardLogger represents the logger used if none is set, which discards all
+// records.
+var discardLogger = slog.New(discardHandler{})
+
+// logger returns the logger of the options, or one that discards all records
+// if none is set.
+func logger(o options) *slog.Logger {
+	if o.logger == nil {
+		return discardLogger
+	}
+	return o.logger
+}
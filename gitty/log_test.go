@@ -0,0 +1,112 @@
+package gitty
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordHandler represents a slog.Handler that records the messages of the
+// log events along with their attributes.
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordHandler) WithGroup(string) slog.Handler { return h }
+
+// events returns the attributes of the recorded events, keyed by message.
+func (h *recordHandler) events() map[string][]map[string]any {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := make(map[string][]map[string]any)
+	for _, r := range h.records {
+		attrs := make(map[string]any)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value.Any()
+			return true
+		})
+		events[r.Message] = append(events[r.Message], attrs)
+	}
+	return events
+}
+
+func TestDownloadLogger(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{"dir/a.txt": "content a", "dir/b.txt": "content b"}
+	links := map[string]string{"dir/escape.txt": "../../etc/passwd"}
+	var requests atomic.Int32
+	tree := treeMux(files, links, &requests)
+	var flaky atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /raw/owner/repo/main/dir/b.txt", func(w http.ResponseWriter, r *http.Request) {
+		// A body shorter than its length fails with io.ErrUnexpectedEOF.
+		if !flaky.Swap(true) {
+			w.Header().Set("Content-Length", "100")
+			_, _ = w.Write([]byte("partial"))
+			return
+		}
+		tree.ServeHTTP(w, r)
+	})
+	mux.Handle("/", tree)
+	r := treeRepository(t, mux)
+	h := &recordHandler{}
+	r.opts.logger = slog.New(h)
+	r.opts.fileRetries = 1
+	r.opts.backoff = time.Millisecond
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	require.NoError(t, err)
+
+	events := h.events()
+	require.Len(t, events["fetched tree"], 1)
+	assert.Equal(t, int64(5), events["fetched tree"][0]["entries"], "want a submodule, a directory, the files, and the symlink")
+	assert.ElementsMatch(t, []any{"dir/a.txt", "dir/b.txt", "dir/b.txt"}, paths(events["downloading file"]))
+	assert.ElementsMatch(t, []any{"dir/a.txt", "dir/b.txt"}, paths(events["downloaded file"]))
+	assert.Equal(t, int64(len("content a")), events["downloaded file"][0]["size"])
+	assert.Equal(t, []any{"dir/b.txt"}, paths(events["retrying file later"]))
+	assert.Equal(t, []any{"dir/escape.txt"}, paths(events["Skipping symlink"]))
+	assert.Equal(t, WarnSymlinkSkipped, events["Skipping symlink"][0]["code"])
+	require.Len(t, events["download finished"], 1)
+	assert.Equal(t, int64(2), events["download finished"][0]["written"])
+	assert.Equal(t, int64(1), events["download finished"][0]["skipped"])
+}
+
+// paths returns the path attributes of the events.
+func paths(events []map[string]any) []any {
+	var paths []any
+	for _, event := range events {
+		paths = append(paths, event["path"])
+	}
+	return paths
+}
+
+func TestLoggerDiscard(t *testing.T) {
+	t.Parallel()
+	l := logger(options{})
+	assert.False(t, l.Enabled(context.Background(), slog.LevelError))
+	l.With("key", "value").WithGroup("group").Error("discarded")
+
+	l = slog.New(slog.NewTextHandler(io.Discard, nil))
+	assert.Same(t, l, logger(options{logger: l}))
+}
@@ -0,0 +1,99 @@
+package gitty
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// ManifestEntry represents a downloaded file recorded in a manifest.
+type ManifestEntry struct {
+	// Path is the path of the file in the repository, not the path it is
+	// saved at, which is reported in Summary.Paths.
+	Path string `json:"path"`
+	Size int    `json:"size"`
+	SHA  string `json:"sha"`
+}
+
+// Manifest represents a record of the files of a download.
+type Manifest struct {
+	Ref string `json:"ref"`
+	// Commit is the SHA of the commit the ref resolved to, if it was
+	// resolved.
+	Commit string          `json:"commit,omitempty"`
+	Files  []ManifestEntry `json:"files"`
+}
+
+// newManifest creates the manifest of the files downloaded at the ref and its
+// commit, if resolved. The files are recorded in the given order.
+func newManifest(ref, commit string, files []*github.RepositoryContent) Manifest {
+	m := Manifest{Ref: ref, Commit: commit, Files: make([]ManifestEntry, 0, len(files))}
+	for _, file := range files {
+		m.Files = append(m.Files, ManifestEntry{
+			Path: file.GetPath(),
+			Size: file.GetSize(),
+			SHA:  file.GetSHA(),
+		})
+	}
+	return m
+}
+
+// writeManifest writes the manifest to the file at path as JSON.
+func writeManifest(path string, m Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
+
+// sortTree sorts the files in tree order: depth first, with the entries of
+// each directory ordered by name, as the contents API lists them.
+func sortTree(files []*github.RepositoryContent) {
+	slices.SortStableFunc(files, func(a, b *github.RepositoryContent) int {
+		return slices.Compare(strings.Split(a.GetPath(), "/"), strings.Split(b.GetPath(), "/"))
+	})
+}
+
+// ManifestDiff represents the changes between two manifests. Paths are sorted.
+type ManifestDiff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// DiffManifests compares a previous manifest with the next one and reports
+// the files that were added, removed, or modified. A file is modified if its
+// SHA or size has changed.
+func DiffManifests(prev, next Manifest) ManifestDiff {
+	old := make(map[string]ManifestEntry, len(prev.Files))
+	for _, f := range prev.Files {
+		old[f.Path] = f
+	}
+
+	var d ManifestDiff
+	for _, f := range next.Files {
+		o, ok := old[f.Path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, f.Path)
+		case o.SHA != f.SHA || o.Size != f.Size:
+			d.Modified = append(d.Modified, f.Path)
+		}
+		delete(old, f.Path)
+	}
+	for p := range old {
+		d.Removed = append(d.Removed, p)
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Modified)
+
+	return d
+}
@@ -0,0 +1,214 @@
+package gitty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffManifests(t *testing.T) {
+	t.Parallel()
+	prev := Manifest{
+		Ref: "v1.0.0",
+		Files: []ManifestEntry{
+			{Path: "dir/same.txt", Size: 4, SHA: "aaa"},
+			{Path: "dir/changed_sha.txt", Size: 4, SHA: "bbb"},
+			{Path: "dir/changed_size.txt", Size: 4, SHA: ""},
+			{Path: "dir/removed_b.txt", Size: 1, SHA: "ccc"},
+			{Path: "dir/removed_a.txt", Size: 1, SHA: "ddd"},
+		},
+	}
+	next := Manifest{
+		Ref: "v1.1.0",
+		Files: []ManifestEntry{
+			{Path: "dir/same.txt", Size: 4, SHA: "aaa"},
+			{Path: "dir/changed_sha.txt", Size: 4, SHA: "eee"},
+			{Path: "dir/changed_size.txt", Size: 5, SHA: ""},
+			{Path: "dir/added_b.txt", Size: 1, SHA: "fff"},
+			{Path: "dir/added_a.txt", Size: 1, SHA: "ggg"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		prev     Manifest
+		next     Manifest
+		expected ManifestDiff
+	}{
+		{
+			name: "added removed and modified",
+			prev: prev,
+			next: next,
+			expected: ManifestDiff{
+				Added:    []string{"dir/added_a.txt", "dir/added_b.txt"},
+				Removed:  []string{"dir/removed_a.txt", "dir/removed_b.txt"},
+				Modified: []string{"dir/changed_sha.txt", "dir/changed_size.txt"},
+			},
+		},
+		{
+			name:     "identical manifests",
+			prev:     prev,
+			next:     prev,
+			expected: ManifestDiff{},
+		},
+		{
+			name: "empty previous manifest",
+			prev: Manifest{},
+			next: Manifest{Files: []ManifestEntry{{Path: "file.txt"}}},
+			expected: ManifestDiff{
+				Added: []string{"file.txt"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, DiffManifests(test.prev, test.next))
+		})
+	}
+}
+
+func TestNewManifest(t *testing.T) {
+	t.Parallel()
+	files := []*github.RepositoryContent{
+		{Path: ptr("dir/b.txt"), Size: ptr(2), SHA: ptr("sha-b")},
+		{Path: ptr("dir/a.txt"), Size: ptr(1), SHA: ptr("sha-a")},
+	}
+
+	expected := Manifest{
+		Ref: "main",
+		Files: []ManifestEntry{
+			{Path: "dir/b.txt", Size: 2, SHA: "sha-b"},
+			{Path: "dir/a.txt", Size: 1, SHA: "sha-a"},
+		},
+	}
+	assert.Equal(t, expected, newManifest("main", "", files))
+}
+
+func TestSortTree(t *testing.T) {
+	t.Parallel()
+	paths := []string{"dir/z.txt", "dir/a.txt", "dir/a/b.txt", "dir/a-b/c.txt", "dir/a.md", "dir/sub/x/y.txt"}
+	files := make([]*github.RepositoryContent, 0, len(paths))
+	for _, p := range paths {
+		files = append(files, &github.RepositoryContent{Path: ptr(p)})
+	}
+
+	sortTree(files)
+	actual := make([]string, 0, len(files))
+	for _, f := range files {
+		actual = append(actual, f.GetPath())
+	}
+	expected := []string{"dir/a/b.txt", "dir/a-b/c.txt", "dir/a.md", "dir/a.txt", "dir/sub/x/y.txt", "dir/z.txt"}
+	assert.Equal(t, expected, actual)
+}
+
+func TestDownloadManifestOrder(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{}
+	for i := range 5 {
+		for j := range 5 {
+			files[fmt.Sprintf("%s/dir_%d/file_%d.txt", fakeBase, i, j)] = fmt.Sprint(i, j)
+		}
+		files[fmt.Sprintf("%s/file_%d.txt", fakeBase, i)] = fmt.Sprint(i)
+	}
+	contents := contentsMux(files)
+	// Random delays let both listings and downloads complete out of order.
+	delayed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(rand.IntN(5)) * time.Millisecond)
+		contents.ServeHTTP(w, r)
+	})
+
+	var expected []string
+	for range 3 {
+		g := fakeNew(serverRepository(t, delayed))
+		m, err := g.DownloadManifest(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+		require.NoError(t, err)
+		require.Len(t, m.Files, len(files))
+		assert.Equal(t, "main", m.Ref)
+
+		var paths []string
+		for _, f := range m.Files {
+			paths = append(paths, f.Path)
+		}
+		if expected == nil {
+			expected = paths
+			continue
+		}
+		assert.Equal(t, expected, paths)
+	}
+	assert.Equal(t, fakeBase+"/dir_0/file_0.txt", expected[0])
+	assert.Equal(t, fakeBase+"/file_4.txt", expected[len(expected)-1])
+}
+
+func TestWriteManifest(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/a.txt":     "first",
+		"dir/sub/b.txt": "second",
+	}
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	var requests atomic.Int32
+	mux := treeMux(files, nil, &requests)
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ref") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, sha)
+	})
+	r := treeRepository(t, mux)
+	r.opts.manifest = filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	result, err := r.downloadTo(context.Background(), t.TempDir())
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(r.opts.manifest)
+	require.NoError(t, err)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(b, &m))
+	assert.Equal(t, Manifest{
+		Ref:    "main",
+		Commit: sha,
+		Files: []ManifestEntry{
+			{Path: "dir/a.txt", Size: len("first"), SHA: "sha-dir/a.txt"},
+			{Path: "dir/sub/b.txt", Size: len("second"), SHA: "sha-dir/sub/b.txt"},
+		},
+	}, m)
+	assert.Equal(t, result.Manifest, m)
+	assert.Equal(t, sha, result.ResolvedCommit)
+}
+
+func TestWriteManifestError(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	mux := treeMux(map[string]string{"dir/a.txt": "first"}, nil, &requests)
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "0123456789abcdef0123456789abcdef01234567")
+	})
+	r := treeRepository(t, mux)
+	r.opts.manifest = filepath.Join(t.TempDir(), "missing", "manifest.json")
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.ErrorContains(t, err, "failed to write manifest")
+}
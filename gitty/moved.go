@@ -0,0 +1,94 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxRedirects represents the number of redirects a request follows at most,
+// as with the default HTTP client.
+const maxRedirects = 10
+
+// ErrRepoMoved is returned for a repository that was renamed or transferred,
+// if moved repositories are not followed.
+var ErrRepoMoved = errors.New("repository moved")
+
+// moves records the repositories whose API requests were permanently
+// redirected, as the new location keyed by the former owner/repo. It is safe
+// for concurrent use.
+type moves struct {
+	mu    sync.Mutex
+	moved map[string]string
+}
+
+// newMoves creates an empty record of moved repositories.
+func newMoves() *moves {
+	return &moves{
+		moved: make(map[string]string),
+	}
+}
+
+// redirect returns the redirect policy of a client that records moved
+// repositories, detected by a permanent redirect of an API request of a
+// repository, and follows them only if follow is set.
+func (m *moves) redirect(follow bool) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if req.Response == nil || req.Response.StatusCode != http.StatusMovedPermanently {
+			return nil
+		}
+		from, ok := repoOf(via[len(via)-1].URL)
+		if !ok {
+			return nil
+		}
+		// GitHub may redirect to the repository by ID, which has no name.
+		to, ok := repoOf(req.URL)
+		if !ok {
+			to = req.URL.String()
+		}
+		if !follow {
+			return fmt.Errorf("%w: %s to %s", ErrRepoMoved, from, to)
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.moved[from] = to
+		return nil
+	}
+}
+
+// take returns the new location of the given owner/repo if it was recorded
+// as moved, and removes it from the record. Downloads that share the record
+// take only their own repository.
+func (m *moves) take(repo string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	to, ok := m.moved[repo]
+	delete(m.moved, repo)
+	return to, ok
+}
+
+// repoOf returns the owner/repo of the API URL of a repository, such as
+// /repos/owner/repo/contents/path.
+func repoOf(u *url.URL) (string, bool) {
+	_, rest, ok := strings.Cut(u.Path, "/repos/")
+	if !ok {
+		return "", false
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+
+	return parts[0] + "/" + parts[1], true
+}
@@ -0,0 +1,145 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// movedMux serves the given files of owner/repo under newowner/newrepo and
+// redirects the API requests of owner/repo there permanently.
+func movedMux(files map[string]string) *http.ServeMux {
+	contents := contentsMux(files)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/{rest...}", func(w http.ResponseWriter, r *http.Request) {
+		u := *r.URL
+		u.Path = "/repos/newowner/newrepo/" + r.PathValue("rest")
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("GET /repos/newowner/newrepo/{rest...}", func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = "/repos/owner/repo/" + r.PathValue("rest")
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	return mux
+}
+
+func TestDownloadMovedRepo(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		follow bool
+	}{
+		{name: "follow", follow: true},
+		{name: "reject", follow: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			srv := httptest.NewServer(movedMux(map[string]string{fakeBase + "/file.txt": "data"}))
+			t.Cleanup(srv.Close)
+
+			o := options{transport: http.DefaultTransport, followMoved: test.follow, moves: newMoves()}
+			c := newClient(o)
+			u, err := url.Parse(srv.URL + "/")
+			require.NoError(t, err)
+			c.BaseURL = u
+			g := fakeNew(repository(c, o))
+
+			result, err := g.DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			if !test.follow {
+				require.ErrorIs(t, err, ErrRepoMoved)
+				assert.Contains(t, err.Error(), "owner/repo to newowner/newrepo")
+				assert.NoFileExists(t, fakeBase+"/file.txt")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []Warning{{Code: WarnRepoMoved, Path: "newowner/newrepo", Message: "Repository moved from owner/repo"}}, result.Warnings)
+			b, err := os.ReadFile(fakeBase + "/file.txt")
+			require.NoError(t, err)
+			assert.Equal(t, "data", string(b))
+
+			// The record is cleared once reported.
+			_, ok := o.moves.take("owner/repo")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestMovesRedirect(t *testing.T) {
+	t.Parallel()
+	redirect := func(to string, code int) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, to, nil)
+		req.Response = &http.Response{StatusCode: code}
+		return req
+	}
+	via := func(from string) []*http.Request {
+		return []*http.Request{httptest.NewRequest(http.MethodGet, from, nil)}
+	}
+
+	m := newMoves()
+	follow := m.redirect(true)
+	require.NoError(t, follow(redirect("https://api.github.com/repositories/42/contents/a", http.StatusMovedPermanently), via("https://api.github.com/repos/owner/repo/contents/a")))
+	require.NoError(t, follow(redirect("https://api.github.com/repos/o/r/contents/a", http.StatusFound), via("https://api.github.com/repos/other/repo/contents/a")))
+	require.NoError(t, follow(redirect("https://example.com/new", http.StatusMovedPermanently), via("https://example.com/old")))
+	_, ok := m.take("other/repo")
+	assert.False(t, ok)
+	to, ok := m.take("owner/repo")
+	require.True(t, ok)
+	assert.Equal(t, "https://api.github.com/repositories/42/contents/a", to)
+	_, ok = m.take("owner/repo")
+	assert.False(t, ok)
+
+	err := follow(redirect("https://api.github.com/repos/o/r", http.StatusFound), make([]*http.Request, maxRedirects))
+	require.EqualError(t, err, fmt.Sprintf("stopped after %d redirects", maxRedirects))
+
+	err = m.redirect(false)(redirect("https://api.github.com/repos/new/name/git/trees/main", http.StatusMovedPermanently), via("https://api.github.com/api/v3/repos/old/name/git/trees/main"))
+	require.ErrorIs(t, err, ErrRepoMoved)
+	assert.True(t, strings.HasSuffix(err.Error(), "old/name to new/name"))
+
+	var nilMoves *moves
+	_, ok = nilMoves.take("owner/repo")
+	assert.False(t, ok)
+}
+
+func TestRepoOf(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url  string
+		repo string
+		ok   bool
+	}{
+		{url: "https://api.github.com/repos/owner/repo", repo: "owner/repo", ok: true},
+		{url: "https://api.github.com/repos/owner/repo/contents/a/b", repo: "owner/repo", ok: true},
+		{url: "https://ghe.example.com/api/v3/repos/owner/repo/git/trees/main", repo: "owner/repo", ok: true},
+		{url: "https://api.github.com/repos/owner", ok: false},
+		{url: "https://api.github.com/repositories/42/contents/a", ok: false},
+		{url: "https://raw.githubusercontent.com/owner/repo/main/a", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			t.Parallel()
+			u, err := url.Parse(test.url)
+			require.NoError(t, err)
+			repo, ok := repoOf(u)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.repo, repo)
+		})
+	}
+}
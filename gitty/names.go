@@ -0,0 +1,135 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// windowsInvalidChars represents the characters not allowed in Windows file
+// names, besides the control characters. The backslash separates paths on
+// Windows, so it is not allowed within a name of a repository path.
+const windowsInvalidChars = `<>:"|?*\`
+
+// windowsReservedNames represents the device names Windows does not allow as
+// file names, with or without an extension.
+var windowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// ErrInvalidFileName is wrapped by InvalidNameError.
+var ErrInvalidFileName = errors.New("file name is not allowed on windows")
+
+// InvalidNameError is returned for a file whose local path has a name that
+// cannot be saved on Windows, unless names are sanitized. It wraps
+// ErrInvalidFileName.
+type InvalidNameError struct {
+	// Path is the repository path of the file.
+	Path string
+	// Name is the name in the path that is not allowed.
+	Name string
+}
+
+// Error returns the message of the error along with the path and name.
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("%s: %s: %q", ErrInvalidFileName, e.Path, e.Name)
+}
+
+// Unwrap returns ErrInvalidFileName.
+func (e *InvalidNameError) Unwrap() error {
+	return ErrInvalidFileName
+}
+
+// validWindowsName reports whether Windows allows the file name: it has no
+// invalid or control characters, does not end with a dot or space, and is not
+// a reserved device name.
+func validWindowsName(name string) bool {
+	if strings.ContainsAny(name, windowsInvalidChars) || strings.ContainsFunc(name, isControl) {
+		return false
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return name == "." || name == ".."
+	}
+	stem, _, _ := strings.Cut(name, ".")
+	return !slices.Contains(windowsReservedNames, strings.ToUpper(strings.TrimRight(stem, " ")))
+}
+
+// sanitizeWindowsName returns the file name with the invalid and control
+// characters and a trailing dot or space replaced by underscores, prefixed by
+// an underscore if it is a reserved device name.
+func sanitizeWindowsName(name string) string {
+	if validWindowsName(name) {
+		return name
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(windowsInvalidChars, r) || isControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if trimmed := strings.TrimRight(name, ". "); trimmed != name {
+		name = trimmed + strings.Repeat("_", len(name)-len(trimmed))
+	}
+	if !validWindowsName(name) {
+		name = "_" + name
+	}
+	return name
+}
+
+// isControl reports whether r is a control character.
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
+// windowsPaths returns the repository paths of the base directory and the
+// file with each name sanitized, if enabled, or an InvalidNameError for the
+// first name Windows does not allow. Both are sanitized alike, so the file
+// stays beneath the base directory.
+func windowsPaths(o options, base, path string) (string, string, error) {
+	base, err := windowsPath(o, base)
+	if err != nil {
+		return "", "", err
+	}
+	path, err = windowsPath(o, path)
+	if err != nil {
+		return "", "", err
+	}
+	return base, path, nil
+}
+
+// windowsPath returns the repository path with each name sanitized, if
+// enabled, or an InvalidNameError for the first name Windows does not allow.
+func windowsPath(o options, path string) (string, error) {
+	if path == "" {
+		return path, nil
+	}
+	names := strings.Split(path, "/")
+	for i, name := range names {
+		if validWindowsName(name) {
+			continue
+		}
+		if !o.sanitizeNames {
+			return "", &InvalidNameError{Path: path, Name: name}
+		}
+		names[i] = sanitizeWindowsName(name)
+	}
+	return strings.Join(names, "/"), nil
+}
+
+// checkNames returns an InvalidNameError for the first file or symlink whose
+// local path has a name Windows does not allow, unless names are sanitized.
+func (g *GitHub) checkNames(lists ...[]*github.RepositoryContent) error {
+	for _, files := range lists {
+		for _, file := range files {
+			if _, err := localPath(g.opts, g.Path, file.GetPath()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package gitty
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidWindowsName(t *testing.T) {
+	t.Parallel()
+	tests := map[string]bool{
+		"main.go":      true,
+		".gitignore":   true,
+		"..":           true,
+		"console.log":  true,
+		"COM10":        true,
+		"a:b.txt":      false,
+		"what?.md":     false,
+		"star*.go":     false,
+		`back\slash`:   false,
+		"tab\tname":    false,
+		"trailing.":    false,
+		"trailing ":    false,
+		"CON":          false,
+		"con.txt":      false,
+		"Nul.tar.gz":   false,
+		"LPT1":         false,
+		"AUX .md":      false,
+		"<angle>.html": false,
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, validWindowsName(name), name)
+	}
+}
+
+func TestSanitizeWindowsName(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"main.go":   "main.go",
+		"a:b.txt":   "a_b.txt",
+		"what?*.md": "what__.md",
+		"trailing.": "trailing_",
+		"dots. .":   "dots___",
+		"CON":       "_CON",
+		"con.txt":   "_con.txt",
+		"tab\tname": "tab_name",
+	}
+	for name, expected := range tests {
+		got := sanitizeWindowsName(name)
+		assert.Equal(t, expected, got, name)
+		assert.True(t, validWindowsName(got), got)
+	}
+}
+
+func TestDownloadWindowsNames(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/main.go":      "main",
+		"dir/a:b/file.txt": "file",
+		"dir/CON.txt":      "con",
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(files))
+		r.opts.windowsNames = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		_, err := r.downloadTo(context.Background(), base)
+		require.ErrorIs(t, err, ErrInvalidFileName)
+		var nameErr *InvalidNameError
+		require.ErrorAs(t, err, &nameErr)
+		assert.Contains(t, []string{"a:b", "CON.txt"}, nameErr.Name)
+		assert.Empty(t, savedFiles(t, base), "files saved before the invalid name")
+	})
+
+	t.Run("sanitize", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(files))
+		r.opts.windowsNames = true
+		r.opts.sanitizeNames = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		result, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dir/_CON.txt", "dir/a_b/file.txt", "dir/main.go"}, savedFiles(t, base))
+		assert.Equal(t, 3, result.Summary.Written)
+	})
+
+	t.Run("base", func(t *testing.T) {
+		t.Parallel()
+		r := serverRepository(t, contentsMux(map[string]string{"a:b/file.txt": "file"}))
+		r.opts.windowsNames = true
+		r.opts.sanitizeNames = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/a:b"))
+		base := t.TempDir()
+
+		_, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a_b/file.txt"}, savedFiles(t, base))
+	})
+}
+
+func TestWindowsNamesDefault(t *testing.T) {
+	t.Parallel()
+	o := newOptions()
+	if runtime.GOOS != "windows" {
+		assert.False(t, o.windowsNames, "names checked on %s", runtime.GOOS)
+		return
+	}
+	assert.True(t, o.windowsNames)
+
+	r := serverRepository(t, contentsMux(map[string]string{"dir/a:b.txt": "file"}))
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	require.ErrorIs(t, err, ErrInvalidFileName)
+}
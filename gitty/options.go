@@ -0,0 +1,1179 @@
+package gitty
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/worlpaker/gitty/gitty/token"
+)
+
+// Option configures optional behavior of Gitty.
+type Option func(*options)
+
+// options represents the optional settings of a download.
+type options struct {
+	// freeSpace reports the available disk space in bytes of a directory.
+	freeSpace func(dir string) (uint64, error)
+	// minFreeSpace is the number of bytes that must remain free after download.
+	minFreeSpace uint64
+	checkSpace   bool
+	// transport is the base transport of the HTTP client.
+	transport http.RoundTripper
+	// httpClient is the HTTP client requests are sent with, if set.
+	httpClient *http.Client
+	// retries is the number of times a failed request is retried.
+	retries int
+	// backoff is the delay before the first retry.
+	backoff time.Duration
+	// abuseBackoff is the delay before the first retry of a request rejected
+	// by the abuse detection.
+	abuseBackoff time.Duration
+	// insecureSkipTLSVerify disables TLS certificate verification.
+	insecureSkipTLSVerify bool
+	// requestsPerSecond limits the rate of requests, if positive.
+	requestsPerSecond float64
+	// coalesce downloads the contents from a single archive.
+	coalesce bool
+	// writeBufferSize is the buffer size used when saving files.
+	writeBufferSize int
+	// index writes an index file into each downloaded directory.
+	index bool
+	// dependencies returns the repository paths referenced by a downloaded file.
+	dependencies func(path string, content []byte) ([]string, error)
+	// router returns the base directory a file of the repository path is saved under.
+	router func(repoPath string) (base string)
+	// executableExtensions are the lowercase file extensions allowed to keep
+	// the executable bit, if set.
+	executableExtensions []string
+	// exportIgnore skips the paths marked export-ignore in .gitattributes.
+	exportIgnore bool
+	// treeCache lists the contents from a cached recursive repository tree.
+	treeCache bool
+	// rawURL is the base URL of raw file downloads of the cached trees.
+	rawURL string
+	// skipEmpty skips files with a size of zero.
+	skipEmpty bool
+	// operationRetries is the number of times a download is repeated after a
+	// transient error.
+	operationRetries int
+	// records writes the files, such as JSON records, instead of saving
+	// them, if set.
+	records recorder
+	// concurrency is the maximum number of files downloaded at once, if positive.
+	concurrency int
+	// batchConcurrency is the maximum number of URLs downloaded at once by
+	// DownloadAll, if greater than one.
+	batchConcurrency int
+	// stopOnError stops DownloadAll at the first URL that fails.
+	stopOnError bool
+	// pool limits the files downloaded at once across the downloads sharing
+	// it, in place of the concurrency, if set.
+	pool chan struct{}
+	// prefixMatch downloads all paths starting with the GitHub path.
+	prefixMatch bool
+	// minRateLimit is the number of unauthenticated requests that must remain
+	// before a download, if positive.
+	minRateLimit int
+	// provenance is the file the provenance record is written to, if set.
+	provenance string
+	// manifest is the file the manifest of the downloaded files is written
+	// to, if set.
+	manifest string
+	// checksums is the checksum manifest the downloaded files are verified
+	// against, if set.
+	checksums string
+	// digests collects the digests of the saved files for the provenance
+	// record and the checksum verification.
+	digests *digests
+	// allowedRepos are the owner/repo names downloads are restricted to, if set.
+	allowedRepos []string
+	// followDirLinks downloads the targets of directory symlinks at the symlink path.
+	followDirLinks bool
+	// progress is called with the progress of the files being downloaded, if set.
+	progress func(Progress)
+	// progressInterval is the minimum time between progress reports.
+	progressInterval time.Duration
+	// now returns the current time, if set, and is replaced in tests.
+	now func() time.Time
+	// meter measures the throughput of the files being downloaded.
+	meter *meter
+	// caseInsensitiveGlobs matches glob patterns case-insensitively.
+	caseInsensitiveGlobs bool
+	// total is written the downloaded content of all files, if set.
+	total *totalWriter
+	// checkpoint is the file the progress of a download is kept in, if set.
+	checkpoint string
+	// chunkSize is the size in bytes of the byte ranges larger files are
+	// downloaded in, if positive.
+	chunkSize int64
+	// chunkParallelism is the number of byte ranges of a file downloaded at once.
+	chunkParallelism int
+	// selection returns the repository paths to download of the listed entries, if set.
+	selection func(files []TreeEntry) ([]string, error)
+	// detectLineEndings warns about text files with mixed line endings.
+	detectLineEndings bool
+	// lineEndings collects the files with mixed line endings.
+	lineEndings *lineEndings
+	// token is the GitHub token requests are authenticated with, if set.
+	token string
+	// tokens is the GitHub tokens requests are authenticated with in turn, if set.
+	tokens []string
+	// userAgent is the User-Agent header of requests, if set.
+	userAgent string
+	// headers are the extra headers of requests.
+	headers http.Header
+	// baseURL is the base URL of the GitHub API, if set.
+	baseURL string
+	// intercept is called with each request before it is sent, if set.
+	intercept func(*http.Request) error
+	// gzip saves text files gzipped.
+	gzip bool
+	// treeDir is the directory downloads are saved beneath, in a directory
+	// named after the tree SHA, if set.
+	treeDir string
+	// resolveCommit reports the SHA of the downloaded commit in the result.
+	resolveCommit bool
+	// followMoved follows the new location of renamed or transferred
+	// repositories.
+	followMoved bool
+	// moves records the repositories that were renamed or transferred.
+	moves *moves
+	// stripTopLevel is the names of the files and directories at the
+	// repository root that are not downloaded.
+	stripTopLevel []string
+	// recipe applies the .gitty.yaml recipe of the downloaded directory.
+	recipe bool
+	// recipeVars overrides the variables of the recipe.
+	recipeVars map[string]string
+	// codeOwner is the user or team whose files are downloaded, by the
+	// CODEOWNERS file, if set.
+	codeOwner string
+	// fileList is written the sorted paths of the downloaded files, if set.
+	fileList io.Writer
+	// minFiles is the number of files below which the download is aborted.
+	minFiles int
+	// extractArchives extracts the downloaded .tar.gz, .tgz and .zip archives.
+	extractArchives bool
+	// keepArchives keeps the extracted archives beside their directories.
+	keepArchives bool
+	// cacheProxy is the URL prefix of the caching proxy requests are sent
+	// through, if set.
+	cacheProxy string
+	// waitOnRateLimit waits for the reset of an exhausted rate limit instead
+	// of failing with a RateLimitError.
+	waitOnRateLimit bool
+	// xattrProvenance tags the downloaded files with extended attributes
+	// recording their source URL and commit SHA.
+	xattrProvenance bool
+	// include is the glob patterns of the files to download, if set.
+	include []string
+	// exclude is the glob patterns of the files not to download.
+	exclude []string
+	// forceHTTP1 disables HTTP/2, so requests are sent over HTTP/1.1.
+	forceHTTP1 bool
+	// dryRun lists the files without downloading them.
+	dryRun bool
+	// fileRetries is the number of times a file failed with a transient error
+	// is retried after the other files are downloaded.
+	fileRetries int
+	// fileTimeout is the time limit of the download of each file, if set.
+	fileTimeout time.Duration
+	// overwrite is the policy for files that already exist.
+	overwrite OverwriteMode
+	// existing collects the files skipped because they already exist, if
+	// the overwrite mode is OverwriteSkip.
+	existing *existing
+	// immutableRef rejects refs other than full commit SHAs.
+	immutableRef bool
+	// written collects the paths and sizes of the written files.
+	written *written
+	// repoConfig applies the default options set by the config file of the
+	// repository.
+	repoConfig bool
+	// hardlinkDuplicates saves the files with the same blob SHA as hardlinks
+	// of the first one.
+	hardlinkDuplicates bool
+	// link creates the hardlinks of duplicate files, os.Link if nil.
+	link func(oldname, newname string) error
+	// followLinks saves symlinks as regular files with the content of their
+	// targets instead of as symlinks.
+	followLinks bool
+	// symlink creates the symlinks, os.Symlink if nil.
+	symlink func(oldname, newname string) error
+	// host is the host of the GitHub URLs, github.com if empty.
+	host string
+	// verify verifies the content of the downloaded files against their
+	// blob SHAs.
+	verify bool
+	// blobs collects the files verified against their blob SHAs.
+	blobs *blobs
+	// logger receives the structured log events of downloads, if set.
+	logger *slog.Logger
+	// stripArchivePrefix strips the top-level directory of the entries of
+	// streamed repository archives.
+	stripArchivePrefix bool
+	// ignoreFile is the path of the ignore file, .gittyignore in the
+	// directory the files are saved in if empty.
+	ignoreFile string
+	// ignore is the patterns of the ignore file of the current download.
+	ignore []ignorePattern
+	// maxTotalBytes is the maximum total size of the contents, if positive.
+	maxTotalBytes uint64
+	// maxFileBytes is the maximum size of a single file, if positive.
+	maxFileBytes uint64
+	// skipOversized skips files larger than maxFileBytes instead of failing.
+	skipOversized bool
+	// flatten saves all files directly beneath the base directory.
+	flatten bool
+	// collision is the policy for files of the same name when flattened.
+	collision CollisionMode
+	// flat assigns the names of the files of the current flattened download.
+	flat *flattener
+	// resume skips the files already saved with their listed size.
+	resume bool
+	// cacheDir is the directory the ETags of downloaded files are cached in,
+	// if set.
+	cacheDir string
+	// maxDepth is the maximum depth of the files beneath the GitHub path, at
+	// least 1, if depthLimit is set.
+	maxDepth   int
+	depthLimit bool
+	// windowsNames rejects or sanitizes the file names Windows does not
+	// allow. It is set on Windows.
+	windowsNames bool
+	// sanitizeNames replaces the characters of file names Windows does not
+	// allow instead of failing.
+	sanitizeNames bool
+	// caseInsensitiveFS detects the files whose local paths differ only in
+	// case. It is set on macOS and Windows.
+	caseInsensitiveFS bool
+	// skipCaseCollisions skips the files colliding in case instead of
+	// failing.
+	skipCaseCollisions bool
+	// lfs downloads the objects of Git LFS pointer files in their place.
+	lfs bool
+	// lfsURL is the base URL of the Git LFS batch API of the repositories.
+	lfsURL string
+	// outputDir is the directory the files are saved under, in place of the
+	// current directory.
+	outputDir string
+	// runID identifies the current download in temporary file names.
+	runID string
+}
+
+const (
+	// concurrencyPerCPU represents the number of files downloaded at once per CPU
+	// by default. Downloads mostly wait on the network, so it exceeds one.
+	concurrencyPerCPU = 4
+	// maxConcurrency represents the maximum default number of files downloaded at once.
+	maxConcurrency = 64
+)
+
+// defaultConcurrency returns the default number of files downloaded at once
+// derived from the number of CPUs reported by numCPU, up to maxConcurrency.
+func defaultConcurrency(numCPU func() int) int {
+	return min(max(numCPU(), 1)*concurrencyPerCPU, maxConcurrency)
+}
+
+const (
+	// envConcurrency represents the environment variable of the default
+	// number of files downloaded at once.
+	envConcurrency = "GITTY_CONCURRENCY"
+	// envUserAgent represents the environment variable of the default
+	// User-Agent header.
+	envUserAgent = "GITTY_USER_AGENT"
+	// envBaseURL represents the environment variable of the default base URL
+	// of the GitHub API.
+	envBaseURL = "GITTY_BASE_URL"
+)
+
+// newOptions creates options with default values, overridden by the
+// environment variables, and applies the given options.
+func newOptions(opts ...Option) options {
+	o := options{
+		freeSpace:    diskFree,
+		transport:    http.DefaultTransport,
+		backoff:      defaultBackoff,
+		abuseBackoff: defaultAbuseBackoff,
+		rawURL:       defaultRawURL,
+		lfsURL:       defaultLFSURL,
+		concurrency:  defaultConcurrency(runtime.NumCPU),
+		userAgent:    defaultUserAgent(debug.ReadBuildInfo),
+		token:        token.Get(),
+		followMoved:  true,
+		moves:        newMoves(),
+		windowsNames: runtime.GOOS == "windows",
+		// The default file systems of macOS and Windows are case-insensitive.
+		caseInsensitiveFS: runtime.GOOS == "darwin" || runtime.GOOS == "windows",
+	}
+	envOptions(&o, os.Getenv)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// envOptions sets the defaults given by the environment variables looked up
+// with getenv. Invalid values are ignored.
+func envOptions(o *options, getenv func(key string) string) {
+	if n, err := strconv.Atoi(getenv(envConcurrency)); err == nil {
+		o.concurrency = n
+	}
+	if ua := getenv(envUserAgent); ua != "" {
+		o.userAgent = ua
+	}
+	if u := getenv(envBaseURL); u != "" {
+		o.baseURL = u
+	}
+}
+
+// WithMinFreeSpace enables a disk space check before downloading. The download
+// is aborted with ErrInsufficientSpace if less than n bytes would remain free
+// after saving the estimated size of the contents.
+func WithMinFreeSpace(n uint64) Option {
+	return func(o *options) {
+		o.checkSpace = true
+		o.minFreeSpace = n
+	}
+}
+
+// WithDependencies sets a hook that is called with the repository path and
+// content of each downloaded file. The returned repository paths, files or
+// directories, are downloaded as well and passed to the hook in turn, so
+// references are followed transitively. Each path is downloaded only once.
+func WithDependencies(fn func(path string, content []byte) ([]string, error)) Option {
+	return func(o *options) {
+		o.dependencies = fn
+	}
+}
+
+// WithRetries sets the number of times a request is retried with exponential
+// backoff and jitter when it fails with a transient error, such as a DNS
+// resolution error or a reset connection, or with a server error or 429 (Too
+// Many Requests) response. Client errors, such as 401 (Unauthorized) and 404
+// (Not Found), are not retried. Requests rejected by the abuse detection of
+// GitHub are retried up to n times as well, with a longer backoff starting at
+// 30 seconds. Downloads that fail while reading the response are repeated by
+// WithRetryOperation instead.
+func WithRetries(n int) Option {
+	return func(o *options) {
+		o.retries = n
+	}
+}
+
+// WithCoalesce downloads the contents with a single repository archive
+// instead of one request per file and directory, which greatly reduces the
+// number of requests for directories of many small files. The contents are
+// listed from the repository tree beforehand, so the filters, the size limits
+// and the disk space check apply as without it, and only the selected files
+// are extracted as the archive is streamed. Files missing from the archive,
+// such as those marked export-ignore, are downloaded one by one. The
+// executable bits of the files are kept.
+func WithCoalesce(enabled bool) Option {
+	return func(o *options) {
+		o.coalesce = enabled
+	}
+}
+
+// WithWriteBufferSize sets the size in bytes of the buffer used when writing
+// downloaded files to disk. By default, files are written unbuffered.
+func WithWriteBufferSize(n int) Option {
+	return func(o *options) {
+		o.writeBufferSize = n
+	}
+}
+
+// WithIndex writes an index.json file into each downloaded directory that
+// lists the files of the directory with their sizes and SHAs.
+func WithIndex(enabled bool) Option {
+	return func(o *options) {
+		o.index = enabled
+	}
+}
+
+// WithRequestsPerSecond limits all outgoing requests to at most n requests
+// per second, evenly spaced, to stay under the rate limits of GitHub.
+func WithRequestsPerSecond(n float64) Option {
+	return func(o *options) {
+		o.requestsPerSecond = n
+	}
+}
+
+// WithInsecureSkipTLSVerify disables TLS certificate verification. It is
+// intended only for testing against self-hosted instances with self-signed
+// certificates, and a warning is printed when it is enabled. Never use it
+// with untrusted networks.
+func WithInsecureSkipTLSVerify(enabled bool) Option {
+	return func(o *options) {
+		o.insecureSkipTLSVerify = enabled
+	}
+}
+
+// WithRouter saves each file under the base directory returned by router for
+// the repository path of the file, instead of the current directory. This
+// allows splitting the contents, e.g. docs to one root and code to another.
+func WithRouter(router func(repoPath string) (base string)) Option {
+	return func(o *options) {
+		o.router = router
+	}
+}
+
+// WithExecutableExtensions allows only files with one of the given extensions,
+// such as ".sh", to keep the executable bit of the repository. Other files are
+// always saved non-executable. Extensions are matched case-insensitively and
+// the leading dot is optional.
+func WithExecutableExtensions(exts []string) Option {
+	return func(o *options) {
+		o.executableExtensions = make([]string, 0, len(exts))
+		for _, ext := range exts {
+			ext = strings.ToLower(ext)
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			o.executableExtensions = append(o.executableExtensions, ext)
+		}
+	}
+}
+
+// WithExportIgnore skips the paths marked export-ignore in the .gitattributes
+// file at the repository root, so the downloaded contents match those of git
+// archive.
+func WithExportIgnore(enabled bool) Option {
+	return func(o *options) {
+		o.exportIgnore = enabled
+	}
+}
+
+// WithTreeCache lists the contents from the recursive tree of the repository,
+// which is fetched with a single request and cached by owner, repository and
+// ref. Subsequent downloads from the same repository and ref, such as of other
+// subdirectories, reuse the cached tree.
+func WithTreeCache(enabled bool) Option {
+	return func(o *options) {
+		o.treeCache = enabled
+	}
+}
+
+// WithSkipEmptyFiles skips zero-byte files, which are often placeholders such
+// as .gitkeep. Their size is known from the listing, so no request is made
+// for them.
+func WithSkipEmptyFiles(enabled bool) Option {
+	return func(o *options) {
+		o.skipEmpty = enabled
+	}
+}
+
+// WithRetryOperation repeats the whole download up to n times if it fails with
+// a transient network error, such as a reset connection, with exponential
+// backoff. Files saved by a failed attempt are not downloaded again.
+func WithRetryOperation(n int) Option {
+	return func(o *options) {
+		o.operationRetries = n
+	}
+}
+
+// WithNDJSON writes the downloaded files to w as newline-delimited JSON
+// records instead of saving them. Each record holds the path the file would
+// be saved at and its base64-encoded content. As no files are saved, index
+// files are not written and dependencies are not followed.
+func WithNDJSON(w io.Writer) Option {
+	return func(o *options) {
+		o.records = &recordWriter{enc: json.NewEncoder(w)}
+	}
+}
+
+// WithConcurrency sets the maximum number of files downloaded at once. By
+// default, it is read from the GITTY_CONCURRENCY environment variable, if set,
+// or derived from the number of CPUs, up to 64. A value of zero or less
+// removes the limit.
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithBatchConcurrency sets the maximum number of URLs DownloadAll downloads
+// at once. The limit on the files downloaded at once set by WithConcurrency
+// applies to all of them together. By default, the URLs are downloaded one
+// after another.
+func WithBatchConcurrency(n int) Option {
+	return func(o *options) {
+		o.batchConcurrency = n
+	}
+}
+
+// WithStopOnError stops DownloadAll at the first URL that fails and cancels
+// the downloads in progress. By default, the errors of the URLs are collected
+// and the other URLs are downloaded regardless.
+func WithStopOnError(enabled bool) Option {
+	return func(o *options) {
+		o.stopOnError = enabled
+	}
+}
+
+// WithPrefixMatch treats the path of the URL as a prefix rather than an exact
+// directory, so all files whose path starts with it are downloaded. For
+// example, config downloads both config/ and config.yaml. The files are listed
+// from the recursive tree of the repository.
+func WithPrefixMatch(enabled bool) Option {
+	return func(o *options) {
+		o.prefixMatch = enabled
+	}
+}
+
+// WithMinRateLimit checks the rate limit before each download, which does not
+// count against it. If the client is not authenticated and fewer than n
+// requests remain, the download fails early with ErrRateLimitedSuggestToken
+// instead of running out of requests midway. Authenticated clients are not
+// affected.
+func WithMinRateLimit(n int) Option {
+	return func(o *options) {
+		o.minRateLimit = n
+	}
+}
+
+// WithProvenance writes a provenance record of each download to the file at
+// path as JSON. It records the source URL, the commit SHA the ref resolved to,
+// the time of the download and the SHA-256 digest of each downloaded file.
+// Resolving the commit costs one request.
+func WithProvenance(path string) Option {
+	return func(o *options) {
+		o.provenance = path
+	}
+}
+
+// WithAllowedRepos restricts all downloads and fetches to the given
+// repositories, named as owner/repo. Other repositories are rejected with
+// ErrRepoNotAllowed when the URL is parsed, before any request is made.
+func WithAllowedRepos(repos []string) Option {
+	return func(o *options) {
+		o.allowedRepos = append([]string{}, repos...)
+	}
+}
+
+// WithFollowDirSymlinks downloads the contents of a directory symlink target
+// within the repository at the path of the symlink, as if the directory was
+// copied there. The filters and limits of the options apply to the contents
+// at that path. Symlinks within the target are followed in turn, and a
+// symlink leading back into a followed directory is skipped as a cycle.
+func WithFollowDirSymlinks(enabled bool) Option {
+	return func(o *options) {
+		o.followDirLinks = enabled
+	}
+}
+
+// WithProgress calls fn with the throughput and the estimated time remaining
+// while files are downloaded, at most once per interval, once more when each
+// file is downloaded, and once more when all files are downloaded. Each report
+// also carries the file downloaded last with its bytes so far, and the number
+// of files downloaded of all files. The remaining time is estimated from the
+// sizes of the listed files. With WithCoalesce, the files are reported as they
+// are extracted from the archive. fn is called from the download goroutines
+// one at a time and must not block.
+func WithProgress(interval time.Duration, fn func(Progress)) Option {
+	return func(o *options) {
+		o.progressInterval = interval
+		o.progress = fn
+	}
+}
+
+// WithCaseInsensitiveGlobs matches glob patterns, such as the export-ignore
+// rules of .gitattributes, case-insensitively, so *.MD matches readme.md.
+// The files are still saved with the case of the repository.
+func WithCaseInsensitiveGlobs(enabled bool) Option {
+	return func(o *options) {
+		o.caseInsensitiveGlobs = enabled
+	}
+}
+
+// WithCheckpoint keeps the listed files and the files saved so far in the
+// checkpoint file at path while downloading. If the download is interrupted,
+// for example when the process is killed, the next download of the same
+// contents resumes from the checkpoint: it neither lists the contents again
+// nor downloads the saved files. The checkpoint file is removed once the
+// download is complete. It has no effect with WithCoalesce.
+func WithCheckpoint(path string) Option {
+	return func(o *options) {
+		o.checkpoint = path
+	}
+}
+
+// WithVerifyChecksums verifies the downloaded files against the checksum
+// manifest at path, such as a checksums.txt written by sha256sum. Each line
+// holds the SHA-256 digest of a file and the path it is saved at, relative to
+// the directory it is saved under as in Summary.Paths. If a file differs, is
+// missing or is not listed, the download fails with ErrChecksumMismatch
+// listing the offending files.
+func WithVerifyChecksums(path string) Option {
+	return func(o *options) {
+		o.checksums = path
+	}
+}
+
+// WithChunkedDownload downloads each file larger than size bytes in byte
+// ranges of size bytes, n of them at once, and reassembles them in order,
+// which speeds up the download of very large files. If the server does not
+// support range requests, the file is downloaded at once instead.
+func WithChunkedDownload(size int64, n int) Option {
+	return func(o *options) {
+		o.chunkSize = size
+		o.chunkParallelism = n
+	}
+}
+
+// WithSelect calls fn with the listed files and symlinks before they are
+// downloaded, such as to let the user pick them in an interactive picker.
+// Only the entries whose repository paths are returned are downloaded. If fn
+// returns an error, for example because the user canceled, the download is
+// canceled with it. With WithCoalesce, fn is called with the files listed
+// from the repository tree, and only the selected files are extracted from
+// the archive.
+func WithSelect(fn func(files []TreeEntry) ([]string, error)) Option {
+	return func(o *options) {
+		o.selection = fn
+	}
+}
+
+// WithDetectMixedLineEndings scans the downloaded text files for mixed CRLF
+// and LF line endings and reports each such file as a warning in the Result.
+// The content is saved unmodified. Files with a NUL byte in their first 8000
+// bytes are binary and not reported.
+func WithDetectMixedLineEndings(enabled bool) Option {
+	return func(o *options) {
+		o.detectLineEndings = enabled
+	}
+}
+
+// WithToken authenticates requests with the GitHub token instead of the token
+// of the GH_TOKEN environment variable. The token is sent as a bearer token
+// with every API request and raw download, which raises the rate limit and
+// gives access to the private repositories of the token.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithTokens authenticates requests with the GitHub tokens in turn instead of
+// a single token, which multiplies the rate limit. A token whose rate limit is
+// exhausted is skipped until its rate limit resets.
+func WithTokens(tokens []string) Option {
+	return func(o *options) {
+		o.tokens = tokens
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the API and content requests.
+// By default, it is read from the GITTY_USER_AGENT environment variable, if
+// set, or gitty/version with the version of gitty, if known.
+func WithUserAgent(ua string) Option {
+	return func(o *options) {
+		o.userAgent = ua
+	}
+}
+
+// WithHeader adds the header to the API and content requests, such as a
+// header required by a proxy, in place of any value the request has for it.
+// Calling it again with the same key adds another value.
+func WithHeader(key, value string) Option {
+	return func(o *options) {
+		if o.headers == nil {
+			o.headers = http.Header{}
+		}
+		o.headers.Add(key, value)
+	}
+}
+
+// WithBaseURL sets the base URL of the GitHub API, such as the API URL of a
+// GitHub Enterprise Server. By default, it is read from the GITTY_BASE_URL
+// environment variable, if set.
+func WithBaseURL(u string) Option {
+	return func(o *options) {
+		o.baseURL = u
+	}
+}
+
+// WithRequestInterceptor calls fn with each outgoing request just before it
+// is sent, including retries, so it can modify the request, for example to
+// sign it or add headers. If fn returns an error, the request is not sent and
+// fails with the error.
+func WithRequestInterceptor(fn func(*http.Request) error) Option {
+	return func(o *options) {
+		o.intercept = fn
+	}
+}
+
+// WithGzip saves text files gzipped, with the .gz extension appended to their
+// names. Binary files and files that are already compressed are saved as they
+// are.
+func WithGzip(enabled bool) Option {
+	return func(o *options) {
+		o.gzip = enabled
+	}
+}
+
+// WithTreeDir saves downloads beneath the directory in a directory named after
+// the SHA of the repository tree at the ref, as dir/<tree-sha>/..., so
+// downloading the same tree again reuses its directory and different versions
+// are kept side by side.
+func WithTreeDir(dir string) Option {
+	return func(o *options) {
+		o.treeDir = dir
+	}
+}
+
+// WithManifest writes the manifest of each successful download to the file
+// at path as JSON, as reported in the result. It records the ref, the commit
+// SHA the ref resolved to, and the repository path, size and Git blob SHA of
+// each downloaded file. The paths the files are saved at are reported in
+// Summary.Paths instead. Resolving the commit costs one request.
+func WithManifest(path string) Option {
+	return func(o *options) {
+		o.manifest = path
+	}
+}
+
+// WithResolveCommit reports the SHA of the downloaded commit in the result,
+// whether a branch, tag, or commit SHA was given.
+func WithResolveCommit(enabled bool) Option {
+	return func(o *options) {
+		o.resolveCommit = enabled
+	}
+}
+
+// WithTotalProgress writes the downloaded content of all files to w as it is
+// saved, so w sees the running total of downloaded bytes, such as the writer
+// of a progress bar. Writes are serialized and their errors are ignored.
+func WithTotalProgress(w io.Writer) Option {
+	return func(o *options) {
+		o.total = &totalWriter{w: w}
+	}
+}
+
+// WithFollowMovedRepos sets whether the new location of a renamed or
+// transferred repository is followed, which GitHub redirects to permanently.
+// A followed repository is reported with a WarnRepoMoved warning, otherwise
+// the download fails with ErrRepoMoved. Moved repositories are followed by
+// default.
+func WithFollowMovedRepos(follow bool) Option {
+	return func(o *options) {
+		o.followMoved = follow
+	}
+}
+
+// WithStripTopLevel skips the files and directories of the names at the
+// repository root, such as .github or LICENSE. Files and directories of the
+// same names in nested directories are still downloaded.
+func WithStripTopLevel(names []string) Option {
+	return func(o *options) {
+		o.stripTopLevel = names
+	}
+}
+
+// WithRecipe applies the .gitty.yaml recipe of the downloaded directory after
+// the download, if there is one, and removes it. A recipe deletes and renames
+// files and directories and substitutes {{name}} in the contents of text files
+// with the values of its variables, so a repository can be used as a
+// template. The variables of vars override the variables of the recipe.
+func WithRecipe(vars map[string]string) Option {
+	return func(o *options) {
+		o.recipe = true
+		o.recipeVars = vars
+	}
+}
+
+// WithCodeOwner downloads only the files owned by the user or team, such as
+// @org/team, according to the CODEOWNERS file of the repository. As on
+// GitHub, the last matching pattern of the CODEOWNERS file decides the owners
+// of a file.
+func WithCodeOwner(owner string) Option {
+	return func(o *options) {
+		o.codeOwner = owner
+	}
+}
+
+// WithFileList writes the paths of the downloaded files to w once the download
+// completes, sorted and with forward slashes, one per line, such as dir/a.txt
+// for the directory dir. The list is the same on all platforms and for any
+// output directory, so it can be committed and diffed across runs to detect
+// changes.
+func WithFileList(w io.Writer) Option {
+	return func(o *options) {
+		o.fileList = w
+	}
+}
+
+// WithHTTPClient sends requests with a copy of the HTTP client, such as a
+// client with a proxy, custom TLS settings, or a timeout. Requests are still
+// retried and throttled as configured, on top of the transport of the client.
+// By default, a client with a timeout of 10 minutes per request is used.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
+
+// WithMinFiles aborts the download with ErrTooFewFiles if fewer than n files
+// would be downloaded, checked against the listing before any file is saved.
+// It catches refs or paths that resolve to a nearly empty directory.
+func WithMinFiles(n int) Option {
+	return func(o *options) {
+		o.minFiles = n
+	}
+}
+
+// WithAutoExtractArchives extracts the downloaded .tar.gz, .tgz and .zip
+// archives into the directories named after them without their extensions,
+// such as docs for docs.zip, and removes the archives. Archive entries outside
+// of the directory are rejected with ErrNotValidArchivePath.
+func WithAutoExtractArchives(enabled bool) Option {
+	return func(o *options) {
+		o.extractArchives = enabled
+	}
+}
+
+// WithKeepArchives keeps the archives extracted by WithAutoExtractArchives
+// beside their directories, instead of extracting them in their place.
+func WithKeepArchives(enabled bool) Option {
+	return func(o *options) {
+		o.keepArchives = enabled
+	}
+}
+
+// WithCacheProxy sends GET requests through the caching proxy at the URL
+// prefix, such as https://cache.example.com/, for reproducible downloads in
+// CI. The URL of each request is appended to the prefix without its scheme,
+// as in https://cache.example.com/api.github.com/repos/owner/repo/contents/dir.
+// If the proxy fails or responds with 404 (Not Found) or a server error, the
+// original URL is requested instead. The token is not sent to the proxy.
+func WithCacheProxy(prefix string) Option {
+	return func(o *options) {
+		o.cacheProxy = prefix
+	}
+}
+
+// WithWaitOnRateLimit waits until the rate limit resets and continues, when
+// a request is rejected because the rate limit is exhausted, as reported by
+// the X-RateLimit-Remaining and X-RateLimit-Reset headers. The time waited
+// does not count against the time limit of the download. By default, the
+// download fails with a RateLimitError, which reports the reset time.
+func WithWaitOnRateLimit(enabled bool) Option {
+	return func(o *options) {
+		o.waitOnRateLimit = enabled
+	}
+}
+
+// WithXattrProvenance tags each downloaded file with the extended attributes
+// user.gitty.url and user.gitty.commit, recording the URL of the file at the
+// commit and the commit SHA the ref resolved to, so the provenance of a file
+// can be traced from the file alone. Unless WithResolveCommit is set,
+// resolving the commit costs one request. Extended attributes are supported on
+// Linux; on other systems and on filesystems without them, nothing is written.
+func WithXattrProvenance(enabled bool) Option {
+	return func(o *options) {
+		o.xattrProvenance = enabled
+	}
+}
+
+// WithInclude downloads only the files matching one of the glob patterns, such
+// as *.go. The patterns are matched against the paths relative to the
+// requested directory, in the syntax of path.Match. Patterns without a slash
+// match a name at any depth, and patterns matching a directory match all of
+// its files.
+func WithInclude(patterns ...string) Option {
+	return func(o *options) {
+		o.include = append(o.include, patterns...)
+	}
+}
+
+// WithExclude skips the files matching one of the glob patterns, such as
+// testdata, as matched by WithInclude. Directories matching a pattern are not
+// walked. Exclude patterns win over include patterns.
+func WithExclude(patterns ...string) Option {
+	return func(o *options) {
+		o.exclude = append(o.exclude, patterns...)
+	}
+}
+
+// WithForceHTTP1 sends requests over HTTP/1.1 instead of negotiating HTTP/2,
+// to work around proxies that misbehave with HTTP/2. It applies to the
+// default transport and to an *http.Transport set by WithHTTPClient.
+func WithForceHTTP1(enabled bool) Option {
+	return func(o *options) {
+		o.forceHTTP1 = enabled
+	}
+}
+
+// WithDryRun lists the files that would be downloaded, along with their sizes,
+// without downloading or saving them. Only the listing of the contents is
+// requested. The listed files are reported in the manifest of the result, as
+// returned by DownloadResult.
+func WithDryRun(enabled bool) Option {
+	return func(o *options) {
+		o.dryRun = enabled
+	}
+}
+
+// WithRetryFailedFiles retries the files that fail with a transient network
+// error, such as a reset connection, up to n times in a final sequential pass
+// with exponential backoff, once the other files are downloaded, instead of
+// failing the whole download. The download fails if a file still fails.
+func WithRetryFailedFiles(n int) Option {
+	return func(o *options) {
+		o.fileRetries = n
+	}
+}
+
+// WithPerFileTimeout limits the download of each file to d, apart from the
+// time limit of the whole download, so a single stalled file does not hold
+// up the others. A file that takes longer fails with ErrFileTimeout, which is
+// retried like a transient network error if failed files are retried.
+func WithPerFileTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.fileTimeout = d
+	}
+}
+
+// WithOverwrite sets the policy for downloaded files that would replace files
+// that already exist, to protect local edits. OverwriteSkip leaves them
+// untouched and reports them as warnings, and OverwriteError aborts the
+// download with an ExistsError naming the existing file. By default, existing
+// files are overwritten, as with OverwriteAlways.
+func WithOverwrite(mode OverwriteMode) Option {
+	return func(o *options) {
+		o.overwrite = mode
+	}
+}
+
+// WithImmutableRef rejects downloads at a branch, a tag, or an abbreviated
+// commit SHA with ErrNotImmutableRef, before any request is sent, so a locked
+// pipeline cannot download contents that may change between runs. Only full
+// commit SHAs are accepted. By default, mutable refs are allowed.
+func WithImmutableRef(enabled bool) Option {
+	return func(o *options) {
+		o.immutableRef = enabled
+	}
+}
+
+// WithRepoConfig reads the .gitty/config.yaml file at the root of the
+// repository, if there is one, and applies the default options its authors
+// set, to guide how the contents are consumed. The file may set the defaults
+// of WithInclude and WithExclude as include and exclude, and of
+// WithExecutableExtensions as executable_extensions. Options set locally
+// override the defaults of the repository. Reading the file costs one or two
+// requests.
+func WithRepoConfig(enabled bool) Option {
+	return func(o *options) {
+		o.repoConfig = enabled
+	}
+}
+
+// WithHardlinkDuplicates downloads only the first of the files with the same
+// blob SHA and saves the others as hardlinks of it, to save space in
+// repositories with many identical files. Where hardlinks are not supported,
+// the file is copied instead. Files are not linked when saved gzipped or
+// written as records.
+func WithHardlinkDuplicates(enabled bool) Option {
+	return func(o *options) {
+		o.hardlinkDuplicates = enabled
+	}
+}
+
+// WithFollowSymlinks saves each symlink as a regular file with the content of
+// its target, which must be one of the downloaded files, instead of as a
+// symlink. Symlinks are always followed when written as records.
+func WithFollowSymlinks(enabled bool) Option {
+	return func(o *options) {
+		o.followLinks = enabled
+	}
+}
+
+// WithHost sets the host of a GitHub Enterprise Server, such as
+// github.mycorp.com, whose URLs are downloaded in place of github.com URLs.
+// The API and raw downloads are served from https://<host>/api/v3/ and
+// https://<host>/raw/, as by the Enterprise convention. The API URL may be
+// changed with a later WithBaseURL.
+func WithHost(host string) Option {
+	return func(o *options) {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "https://"), "/")
+		o.host = host
+		o.baseURL = "https://" + host + "/api/v3/"
+		o.rawURL = "https://" + host + "/raw/"
+		o.lfsURL = "https://" + host + "/"
+	}
+}
+
+// WithVerify verifies the content of each downloaded file against the Git
+// blob SHA listed for it, to guard against truncated or corrupted downloads.
+// A file that does not match is not saved, and the download fails with a
+// BlobMismatchError.
+func WithVerify(enabled bool) Option {
+	return func(o *options) {
+		o.verify = enabled
+	}
+}
+
+// WithLogger emits structured log events of downloads to logger, such as the
+// fetched trees, the start and end of each file download, retries, warnings
+// about skipped files, and the final summary. Per-file events are logged at
+// the debug level. By default, nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithStripArchivePrefix strips the top-level directory, named after the
+// owner, repository and commit, such as owner-repo-abc1234/, from the entries
+// of archives written by GetArchive. By default, the archive is written as
+// GitHub serves it.
+func WithStripArchivePrefix(enabled bool) Option {
+	return func(o *options) {
+		o.stripArchivePrefix = enabled
+	}
+}
+
+// WithIgnoreFile sets the path of the ignore file whose patterns, in the
+// format of .gitignore, exclude files from downloads in addition to
+// WithExclude. By default, .gittyignore is read from the directory the files
+// are saved in, if it exists. Patterns match relative to the requested
+// directory, and a leading ! re-includes the matching paths.
+func WithIgnoreFile(name string) Option {
+	return func(o *options) {
+		o.ignoreFile = name
+	}
+}
+
+// WithMaxTotalBytes aborts the download with ErrSizeLimitExceeded before any
+// content is downloaded if the listed sizes of the files add up to more than
+// n bytes. Zero removes the limit.
+func WithMaxTotalBytes(n uint64) Option {
+	return func(o *options) {
+		o.maxTotalBytes = n
+	}
+}
+
+// WithMaxFileBytes aborts the download with ErrSizeLimitExceeded before any
+// content is downloaded if the listed size of a file is more than n bytes.
+// With WithSkipOversizedFiles, such files are skipped instead. Zero removes
+// the limit.
+func WithMaxFileBytes(n uint64) Option {
+	return func(o *options) {
+		o.maxFileBytes = n
+	}
+}
+
+// WithSkipOversizedFiles skips the files larger than the size set by
+// WithMaxFileBytes, with a warning, instead of failing the download.
+func WithSkipOversizedFiles(enabled bool) Option {
+	return func(o *options) {
+		o.skipOversized = enabled
+	}
+}
+
+// WithFlatten saves all downloaded files directly in the directory of the
+// requested path, by their names, without recreating the directories beneath
+// it. Files of the same name in different directories fail the download with
+// ErrFlattenCollision, unless WithFlattenCollisions allows numeric suffixes.
+func WithFlatten(enabled bool) Option {
+	return func(o *options) {
+		o.flatten = enabled
+	}
+}
+
+// WithFlattenCollisions sets the policy for files of the same name in
+// different directories when flattened. CollisionSuffix saves them with a
+// numeric suffix, assigned in the order of their repository paths. By
+// default, the download fails, as with CollisionError.
+func WithFlattenCollisions(mode CollisionMode) Option {
+	return func(o *options) {
+		o.collision = mode
+	}
+}
+
+// WithResume skips downloading the files that already exist where they are
+// saved with their listed size, so a download that failed halfway can be run
+// again without fetching the saved files another time. With WithVerify, their
+// content must also hash to their blob SHAs. The skipped files are reported
+// with WarnPresentSkipped.
+func WithResume(enabled bool) Option {
+	return func(o *options) {
+		o.resume = enabled
+	}
+}
+
+// WithCacheDir caches the ETags of the downloaded files in dir, keyed by
+// repository, ref and path, and sends them with the requests of later
+// downloads of the same files. A file whose content still has its ETag is
+// not downloaded again, and the saved file is kept as it is, which saves
+// bandwidth when the same contents are downloaded repeatedly. The kept files
+// are reported with WarnUnchangedSkipped.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithMaxDepth limits the download to the files at most n levels beneath the
+// requested directory. Depth 1, as well as 0, downloads only the files
+// directly in the directory, and the directories beyond the depth are not
+// walked. By default, the whole subtree is downloaded.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.depthLimit = true
+		o.maxDepth = n
+	}
+}
+
+// WithSanitizeNames replaces the characters of file names that Windows does
+// not allow, such as : and ?, with underscores, and prefixes reserved device
+// names, such as CON, with an underscore. By default, downloads on Windows
+// fail with an InvalidNameError naming the file before any file is saved.
+// File names are not changed on other platforms.
+func WithSanitizeNames(enabled bool) Option {
+	return func(o *options) {
+		o.sanitizeNames = enabled
+	}
+}
+
+// WithSkipCaseCollisions skips the files whose local paths differ only in
+// case from those of other files, such as Readme.md next to README.md, with a
+// warning, and keeps the first in order of their repository paths. Such
+// files would replace each other on case-insensitive file systems, so by
+// default, downloads on macOS and Windows fail with ErrCaseCollision before
+// any file is saved.
+func WithSkipCaseCollisions(enabled bool) Option {
+	return func(o *options) {
+		o.skipCaseCollisions = enabled
+	}
+}
+
+// WithOutputDir saves the files under dir instead of the current directory,
+// e.g. the contents of tree/main/examples beneath dir/examples. The base
+// directories returned by the router, if any, are created beneath dir. It
+// has no effect on downloads with their own base directory, such as those
+// to a tree directory.
+func WithOutputDir(dir string) Option {
+	return func(o *options) {
+		o.outputDir = dir
+	}
+}
+
+// WithLFS downloads the content of the files stored with Git LFS in place of
+// their pointer files, through the Git LFS batch API of the repository. The
+// content is verified against the SHA-256 digest and size of the pointer.
+// Without it, a pointer file is saved as is and reported with a
+// WarnLFSPointer warning, so the pointer is not mistaken for the content.
+func WithLFS(enabled bool) Option {
+	return func(o *options) {
+		o.lfs = enabled
+	}
+}
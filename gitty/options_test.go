@@ -0,0 +1,560 @@
+package gitty
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOptions(t *testing.T) {
+	t.Parallel()
+	o := newOptions()
+	assert.NotNil(t, o.freeSpace)
+	assert.False(t, o.checkSpace)
+}
+
+func TestWithMinFreeSpace(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithMinFreeSpace(1024))
+	assert.True(t, o.checkSpace)
+	assert.Equal(t, uint64(1024), o.minFreeSpace)
+}
+
+func TestWithDependencies(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithDependencies(func(_ string, _ []byte) ([]string, error) {
+		return []string{"dep"}, nil
+	}))
+	deps, err := o.dependencies("path", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dep"}, deps)
+}
+
+func TestWithRetries(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRetries(3))
+	assert.Equal(t, 3, o.retries)
+	assert.Equal(t, defaultBackoff, o.backoff)
+}
+
+func TestWithWriteBufferSize(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithWriteBufferSize(8192))
+	assert.Equal(t, 8192, o.writeBufferSize)
+}
+
+func TestWithIndex(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithIndex(true))
+	assert.True(t, o.index)
+}
+
+func TestWithRequestsPerSecond(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRequestsPerSecond(2.5))
+	assert.InDelta(t, 2.5, o.requestsPerSecond, 0)
+}
+
+func TestWithInsecureSkipTLSVerify(t *testing.T) {
+	t.Parallel()
+	assert.False(t, newOptions().insecureSkipTLSVerify)
+	o := newOptions(WithInsecureSkipTLSVerify(true))
+	assert.True(t, o.insecureSkipTLSVerify)
+}
+
+func TestWithRouter(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRouter(func(string) string { return "docs" }))
+	require.NotNil(t, o.router)
+	assert.Equal(t, "docs", o.router("README.md"))
+}
+
+func TestWithExecutableExtensions(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newOptions().executableExtensions)
+	o := newOptions(WithExecutableExtensions([]string{".sh", "PY"}))
+	assert.Equal(t, []string{".sh", ".py"}, o.executableExtensions)
+}
+
+func TestWithExportIgnore(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithExportIgnore(true))
+	assert.True(t, o.exportIgnore)
+}
+
+func TestWithTreeCache(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, defaultRawURL, newOptions().rawURL)
+	o := newOptions(WithTreeCache(true))
+	assert.True(t, o.treeCache)
+}
+
+func TestWithSkipEmptyFiles(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithSkipEmptyFiles(true))
+	assert.True(t, o.skipEmpty)
+}
+
+func TestWithRetryOperation(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRetryOperation(3))
+	assert.Equal(t, 3, o.operationRetries)
+}
+
+func TestWithNDJSON(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newOptions().records)
+	o := newOptions(WithNDJSON(io.Discard))
+	assert.NotNil(t, o.records)
+}
+
+func TestDefaultConcurrency(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		cpus     int
+		expected int
+	}{
+		{cpus: 0, expected: concurrencyPerCPU},
+		{cpus: 1, expected: concurrencyPerCPU},
+		{cpus: 4, expected: 4 * concurrencyPerCPU},
+		{cpus: 8, expected: 8 * concurrencyPerCPU},
+		{cpus: 64, expected: maxConcurrency},
+		{cpus: 256, expected: maxConcurrency},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d cpus", test.cpus), func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, defaultConcurrency(func() int { return test.cpus }))
+		})
+	}
+}
+
+func TestWithConcurrency(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, defaultConcurrency(runtime.NumCPU), newOptions().concurrency)
+	o := newOptions(WithConcurrency(3))
+	assert.Equal(t, 3, o.concurrency)
+}
+
+func TestWithBatchConcurrency(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithBatchConcurrency(4))
+	assert.Equal(t, 4, o.batchConcurrency)
+}
+
+func TestWithStopOnError(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithStopOnError(true))
+	assert.True(t, o.stopOnError)
+}
+
+func TestWithPrefixMatch(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithPrefixMatch(true))
+	assert.True(t, o.prefixMatch)
+}
+
+func TestWithMinRateLimit(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithMinRateLimit(10))
+	assert.Equal(t, 10, o.minRateLimit)
+}
+
+func TestWithProvenance(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithProvenance("provenance.json"))
+	assert.Equal(t, "provenance.json", o.provenance)
+}
+
+func TestWithPerFileTimeout(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithPerFileTimeout(time.Second))
+	assert.Equal(t, time.Second, o.fileTimeout)
+}
+
+func TestWithManifest(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithManifest("manifest.json"))
+	assert.Equal(t, "manifest.json", o.manifest)
+}
+
+func TestWithAllowedRepos(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithAllowedRepos([]string{"owner/repo"}))
+	assert.Equal(t, []string{"owner/repo"}, o.allowedRepos)
+}
+
+func TestWithFollowDirSymlinks(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithFollowDirSymlinks(true))
+	assert.True(t, o.followDirLinks)
+}
+
+func TestWithProgress(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithProgress(time.Second, func(Progress) {}))
+	assert.Equal(t, time.Second, o.progressInterval)
+	assert.NotNil(t, o.progress)
+}
+
+func TestWithCaseInsensitiveGlobs(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithCaseInsensitiveGlobs(true))
+	assert.True(t, o.caseInsensitiveGlobs)
+}
+
+func TestWithCheckpoint(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithCheckpoint("checkpoint.json"))
+	assert.Equal(t, "checkpoint.json", o.checkpoint)
+}
+
+func TestWithVerifyChecksums(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithVerifyChecksums("checksums.txt"))
+	assert.Equal(t, "checksums.txt", o.checksums)
+}
+
+func TestWithChunkedDownload(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithChunkedDownload(1<<20, 4))
+	assert.Equal(t, int64(1<<20), o.chunkSize)
+	assert.Equal(t, 4, o.chunkParallelism)
+}
+
+func TestWithSelect(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithSelect(func([]TreeEntry) ([]string, error) { return nil, nil }))
+	assert.NotNil(t, o.selection)
+}
+
+func TestWithDetectMixedLineEndings(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithDetectMixedLineEndings(true))
+	assert.True(t, o.detectLineEndings)
+}
+
+func TestEnvOptions(t *testing.T) {
+	t.Parallel()
+	env := map[string]string{
+		envConcurrency: "7",
+		envUserAgent:   "team-agent",
+		envBaseURL:     "https://ghe.example.com/api/v3/",
+	}
+	o := options{concurrency: 3}
+	envOptions(&o, func(key string) string { return env[key] })
+	assert.Equal(t, 7, o.concurrency)
+	assert.Equal(t, "team-agent", o.userAgent)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", o.baseURL)
+
+	o = options{concurrency: 3}
+	envOptions(&o, func(key string) string {
+		if key == envConcurrency {
+			return "many"
+		}
+		return ""
+	})
+	assert.Equal(t, options{concurrency: 3}, o)
+}
+
+func TestNewOptionsEnv(t *testing.T) {
+	t.Setenv("GH_TOKEN", "env-token")
+	t.Setenv(envConcurrency, "7")
+	t.Setenv(envUserAgent, "team-agent")
+	t.Setenv(envBaseURL, "https://ghe.example.com/api/v3/")
+
+	o := newOptions()
+	assert.Equal(t, "env-token", o.token)
+	assert.Equal(t, 7, o.concurrency)
+	assert.Equal(t, "team-agent", o.userAgent)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", o.baseURL)
+
+	o = newOptions(WithToken("token"), WithConcurrency(2), WithUserAgent("agent"), WithBaseURL("https://api.example.com/"))
+	assert.Equal(t, "token", o.token)
+	assert.Equal(t, 2, o.concurrency)
+	assert.Equal(t, "agent", o.userAgent)
+	assert.Equal(t, "https://api.example.com/", o.baseURL)
+}
+
+func TestWithHeader(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newOptions().headers)
+	o := newOptions(WithHeader("X-Proxy-Auth", "secret"), WithHeader("x-trace", "a"), WithHeader("X-Trace", "b"))
+	assert.Equal(t, http.Header{"X-Proxy-Auth": {"secret"}, "X-Trace": {"a", "b"}}, o.headers)
+}
+
+func TestWithTokens(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithTokens([]string{"a", "b"}))
+	assert.Equal(t, []string{"a", "b"}, o.tokens)
+}
+
+func TestWithRequestInterceptor(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRequestInterceptor(func(*http.Request) error { return nil }))
+	assert.NotNil(t, o.intercept)
+}
+
+func TestWithGzip(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithGzip(true))
+	assert.True(t, o.gzip)
+}
+
+func TestWithTreeDir(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithTreeDir("cache"))
+	assert.Equal(t, "cache", o.treeDir)
+}
+
+func TestWithResolveCommit(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithResolveCommit(true))
+	assert.True(t, o.resolveCommit)
+}
+
+func TestWithTotalProgress(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithTotalProgress(io.Discard))
+	require.NotNil(t, o.total)
+	assert.Equal(t, io.Discard, o.total.w)
+}
+
+func TestWithFollowMovedRepos(t *testing.T) {
+	t.Parallel()
+	o := newOptions()
+	assert.True(t, o.followMoved)
+	assert.NotNil(t, o.moves)
+
+	o = newOptions(WithFollowMovedRepos(false))
+	assert.False(t, o.followMoved)
+}
+
+func TestWithStripTopLevel(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithStripTopLevel([]string{".github", "LICENSE"}))
+	assert.Equal(t, []string{".github", "LICENSE"}, o.stripTopLevel)
+}
+
+func TestWithRecipe(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRecipe(map[string]string{"name": "app"}))
+	assert.True(t, o.recipe)
+	assert.Equal(t, map[string]string{"name": "app"}, o.recipeVars)
+}
+
+func TestWithCodeOwner(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithCodeOwner("@org/team"))
+	assert.Equal(t, "@org/team", o.codeOwner)
+}
+
+func TestWithFileList(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	o := newOptions(WithFileList(&buf))
+	assert.Equal(t, &buf, o.fileList)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	t.Parallel()
+	c := &http.Client{Timeout: time.Second}
+	o := newOptions(WithHTTPClient(c))
+	assert.Same(t, c, o.httpClient)
+}
+
+func TestWithMinFiles(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithMinFiles(3))
+	assert.Equal(t, 3, o.minFiles)
+}
+
+func TestWithAutoExtractArchives(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithAutoExtractArchives(true), WithKeepArchives(true))
+	assert.True(t, o.extractArchives)
+	assert.True(t, o.keepArchives)
+}
+
+func TestWithCacheProxy(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithCacheProxy("https://cache.example.com/"))
+	assert.Equal(t, "https://cache.example.com/", o.cacheProxy)
+}
+
+func TestWithWaitOnRateLimit(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithWaitOnRateLimit(true))
+	assert.True(t, o.waitOnRateLimit)
+}
+
+func TestWithXattrProvenance(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithXattrProvenance(true))
+	assert.True(t, o.xattrProvenance)
+}
+
+func TestWithInclude(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithInclude("*.go"), WithInclude("docs"), WithExclude("testdata", "*_test.go"))
+	assert.Equal(t, []string{"*.go", "docs"}, o.include)
+	assert.Equal(t, []string{"testdata", "*_test.go"}, o.exclude)
+}
+
+func TestWithForceHTTP1(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithForceHTTP1(true))
+	assert.True(t, o.forceHTTP1)
+}
+
+func TestWithDryRun(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithDryRun(true))
+	assert.True(t, o.dryRun)
+}
+
+func TestWithRetryFailedFiles(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRetryFailedFiles(3))
+	assert.Equal(t, 3, o.fileRetries)
+}
+
+func TestWithOverwrite(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, OverwriteAlways, newOptions().overwrite)
+	o := newOptions(WithOverwrite(OverwriteSkip))
+	assert.Equal(t, OverwriteSkip, o.overwrite)
+}
+
+func TestWithImmutableRef(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithImmutableRef(true))
+	assert.True(t, o.immutableRef)
+}
+
+func TestWithRepoConfig(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithRepoConfig(true))
+	assert.True(t, o.repoConfig)
+}
+
+func TestWithHardlinkDuplicates(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithHardlinkDuplicates(true))
+	assert.True(t, o.hardlinkDuplicates)
+}
+
+func TestWithFollowSymlinks(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithFollowSymlinks(true))
+	assert.True(t, o.followLinks)
+}
+
+func TestWithHost(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithHost("https://github.mycorp.com/"))
+	assert.Equal(t, "github.mycorp.com", o.host)
+	assert.Equal(t, "https://github.mycorp.com/raw/", o.rawURL)
+	assert.Equal(t, "https://github.mycorp.com/api/v3/", newClient(o).BaseURL.String())
+
+	r := repository(newClient(o), o)
+	require.NoError(t, r.extract("https://github.mycorp.com/owner/repo/tree/main/dir"))
+	assert.Equal(t, "owner", r.Owner)
+	assert.Equal(t, "dir", r.Path)
+	assert.Equal(t, "https://github.mycorp.com/owner/repo/tree/sha/dir", r.treeURL("owner", "repo", "sha", "dir"))
+	require.ErrorIs(t, r.extract("https://github.com/owner/repo/tree/main/dir"), ErrNotValidURL)
+}
+
+func TestWithVerify(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithVerify(true))
+	assert.True(t, o.verify)
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	o := newOptions(WithLogger(l))
+	assert.Same(t, l, o.logger)
+}
+
+func TestWithStripArchivePrefix(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithStripArchivePrefix(true))
+	assert.True(t, o.stripArchivePrefix)
+}
+
+func TestWithIgnoreFile(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithIgnoreFile("custom.ignore"))
+	assert.Equal(t, "custom.ignore", o.ignoreFile)
+}
+
+func TestWithSizeLimits(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithMaxTotalBytes(100), WithMaxFileBytes(10), WithSkipOversizedFiles(true))
+	assert.Equal(t, uint64(100), o.maxTotalBytes)
+	assert.Equal(t, uint64(10), o.maxFileBytes)
+	assert.True(t, o.skipOversized)
+}
+
+func TestWithFlatten(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithFlatten(true), WithFlattenCollisions(CollisionSuffix))
+	assert.True(t, o.flatten)
+	assert.Equal(t, CollisionSuffix, o.collision)
+}
+
+func TestWithResume(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithResume(true))
+	assert.True(t, o.resume)
+}
+
+func TestWithCacheDir(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithCacheDir("cache"))
+	assert.Equal(t, "cache", o.cacheDir)
+}
+
+func TestWithMaxDepth(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithMaxDepth(2))
+	assert.True(t, o.depthLimit)
+	assert.Equal(t, 2, o.maxDepth)
+}
+
+func TestWithSanitizeNames(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithSanitizeNames(true))
+	assert.True(t, o.sanitizeNames)
+}
+
+func TestWithSkipCaseCollisions(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithSkipCaseCollisions(true))
+	assert.True(t, o.skipCaseCollisions)
+}
+
+func TestWithOutputDir(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithOutputDir("out"))
+	assert.Equal(t, "out", o.outputDir)
+}
+
+func TestWithLFS(t *testing.T) {
+	t.Parallel()
+	o := newOptions(WithLFS(true))
+	assert.True(t, o.lfs)
+	assert.Equal(t, defaultLFSURL, o.lfsURL)
+	assert.Equal(t, "https://github.mycorp.com/", newOptions(WithHost("github.mycorp.com")).lfsURL)
+}
@@ -0,0 +1,92 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+)
+
+// OverwriteMode represents the policy for files that already exist where the
+// downloaded files are saved.
+type OverwriteMode int
+
+const (
+	// OverwriteAlways replaces existing files. It is the default.
+	OverwriteAlways OverwriteMode = iota
+	// OverwriteSkip leaves existing files untouched and reports them with
+	// WarnExistingSkipped.
+	OverwriteSkip
+	// OverwriteError aborts the download with an ExistsError at the first
+	// existing file.
+	OverwriteError
+)
+
+// ErrFileExists is wrapped by ExistsError.
+var ErrFileExists = errors.New("file already exists")
+
+// ExistsError is returned for a downloaded file that would replace an existing
+// file, if the overwrite mode is OverwriteError. It wraps ErrFileExists.
+type ExistsError struct {
+	// Path is the path of the existing file.
+	Path string
+}
+
+// Error returns the message of the error along with the path.
+func (e *ExistsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrFileExists, e.Path)
+}
+
+// Unwrap returns ErrFileExists.
+func (e *ExistsError) Unwrap() error {
+	return ErrFileExists
+}
+
+// existing collects the repository paths of the files skipped because they
+// already exist. It is safe for concurrent use.
+type existing struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// add records the repository path, if e is not nil.
+func (e *existing) add(path string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paths = append(e.paths, path)
+}
+
+// all returns the recorded repository paths in order.
+func (e *existing) all() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Sorted(slices.Values(e.paths))
+}
+
+// keepExisting reports whether the file of the repository path must not be
+// saved at the local path p because a file already exists there, as decided
+// by the overwrite mode. It returns an ExistsError if the mode is
+// OverwriteError.
+func keepExisting(o options, path, p string) (bool, error) {
+	if o.overwrite == OverwriteAlways {
+		return false, nil
+	}
+	_, err := os.Lstat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if o.overwrite == OverwriteError {
+		return false, &ExistsError{Path: p}
+	}
+
+	o.existing.add(path)
+	return true, nil
+}
@@ -0,0 +1,86 @@
+package gitty
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadOverwrite(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		mode     OverwriteMode
+		expected string
+		warnings []Warning
+		err      bool
+	}{
+		{name: "overwrite", mode: OverwriteAlways, expected: "remote a"},
+		{
+			name:     "skip",
+			mode:     OverwriteSkip,
+			expected: "local edit",
+			warnings: []Warning{{Code: WarnExistingSkipped, Path: "dir/a.txt", Message: "Skipping existing file"}},
+		},
+		{name: "error", mode: OverwriteError, expected: "local edit", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			base := t.TempDir()
+			existing := filepath.Join(base, "dir", "a.txt")
+			require.NoError(t, os.MkdirAll(filepath.Dir(existing), 0o700))
+			require.NoError(t, os.WriteFile(existing, []byte("local edit"), 0o600))
+
+			r := serverRepository(t, contentsMux(map[string]string{"dir/a.txt": "remote a", "dir/b.txt": "remote b"}))
+			r.opts.overwrite = test.mode
+			r.opts.concurrency = 1
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+			result, err := r.downloadTo(context.Background(), base)
+			b, errRead := os.ReadFile(existing)
+			require.NoError(t, errRead)
+			assert.Equal(t, test.expected, string(b))
+			if test.err {
+				var existsErr *ExistsError
+				require.ErrorAs(t, err, &existsErr)
+				assert.Equal(t, existing, existsErr.Path)
+				require.ErrorIs(t, err, ErrFileExists)
+				assert.Contains(t, err.Error(), existing)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.warnings, result.Warnings)
+			b, err = os.ReadFile(filepath.Join(base, "dir", "b.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "remote b", string(b))
+		})
+	}
+}
+
+func TestKeepExisting(t *testing.T) {
+	t.Parallel()
+	p := filepath.Join(t.TempDir(), "file.txt")
+	o := options{overwrite: OverwriteSkip, existing: &existing{}}
+
+	keep, err := keepExisting(o, "file.txt", p)
+	require.NoError(t, err)
+	assert.False(t, keep)
+	assert.Empty(t, o.existing.all())
+
+	require.NoError(t, os.WriteFile(p, nil, 0o600))
+	keep, err = keepExisting(o, "file.txt", p)
+	require.NoError(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, []string{"file.txt"}, o.existing.all())
+
+	keep, err = keepExisting(options{}, "file.txt", p)
+	require.NoError(t, err)
+	assert.False(t, keep)
+}
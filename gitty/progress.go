@@ -0,0 +1,189 @@
+package gitty
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress represents the state of the files being downloaded.
+type Progress struct {
+	// Bytes is the number of bytes downloaded so far.
+	Bytes uint64
+	// Total is the estimated number of bytes to download.
+	Total uint64
+	// BytesPerSecond is the average throughput since the download started.
+	BytesPerSecond float64
+	// ETA is the estimated time until the remaining bytes are downloaded, or
+	// zero if the throughput is not known yet.
+	ETA time.Duration
+	// Path is the repository path of the file downloaded last, or empty in
+	// the final report.
+	Path string
+	// FileBytes is the number of bytes of the file at Path downloaded so far.
+	FileBytes uint64
+	// Files is the number of files to download.
+	Files int
+	// FilesDone is the number of files downloaded so far.
+	FilesDone int
+}
+
+// meter represents the throughput measurement of the files being downloaded.
+type meter struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	interval  time.Duration
+	report    func(Progress)
+	total     uint64
+	bytes     uint64
+	files     int
+	filesDone int
+	fileBytes map[string]uint64
+	start     time.Time
+	last      time.Time
+}
+
+// newMeter creates a meter of total bytes in the given number of files that
+// reports the progress to report at most once per interval.
+func newMeter(o options, total uint64, files int) *meter {
+	now := o.now
+	if now == nil {
+		now = time.Now
+	}
+	start := now()
+	return &meter{
+		now:       now,
+		interval:  o.progressInterval,
+		report:    o.progress,
+		total:     total,
+		files:     files,
+		fileBytes: make(map[string]uint64),
+		start:     start,
+		last:      start,
+	}
+}
+
+// count returns a reader of the body of the file at the repository path that
+// adds the bytes read to the meter. A nil meter returns the body unchanged.
+func (m *meter) count(path string, body io.Reader) io.Reader {
+	if m == nil {
+		return body
+	}
+	m.mu.Lock()
+	// A retried file is counted from the start again.
+	m.fileBytes[path] = 0
+	m.mu.Unlock()
+	return &meterReader{m: m, path: path, r: body}
+}
+
+// add adds n downloaded bytes of the file at the repository path and reports
+// the progress if the interval has passed since the last report.
+func (m *meter) add(path string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bytes += uint64(n)
+	m.fileBytes[path] += uint64(n)
+	now := m.now()
+	if now.Sub(m.last) < m.interval {
+		return
+	}
+	m.last = now
+	m.report(m.progress(now, path))
+}
+
+// done counts the file at the repository path as downloaded and reports the
+// progress, if m is not nil.
+func (m *meter) done(path string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.filesDone++
+	m.report(m.progress(m.now(), path))
+	delete(m.fileBytes, path)
+}
+
+// finish reports the final progress.
+func (m *meter) finish() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.report(m.progress(m.now(), ""))
+}
+
+// progress returns the progress at the time now, with the file at the
+// repository path.
+func (m *meter) progress(now time.Time, path string) Progress {
+	p := Progress{
+		Bytes:     m.bytes,
+		Total:     m.total,
+		Path:      path,
+		FileBytes: m.fileBytes[path],
+		Files:     m.files,
+		FilesDone: m.filesDone,
+	}
+	elapsed := now.Sub(m.start).Seconds()
+	if elapsed <= 0 || m.bytes == 0 {
+		return p
+	}
+
+	p.BytesPerSecond = float64(m.bytes) / elapsed
+	if m.total > m.bytes {
+		p.ETA = time.Duration(float64(m.total-m.bytes) / p.BytesPerSecond * float64(time.Second))
+	}
+
+	return p
+}
+
+// meterReader represents a reader that counts the bytes it reads of the file
+// at the repository path.
+type meterReader struct {
+	m    *meter
+	path string
+	r    io.Reader
+}
+
+func (r *meterReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.m.add(r.path, n)
+	}
+	return n, err
+}
+
+// totalWriter represents the writer the downloaded content of all files is
+// written to. It is safe for concurrent use.
+type totalWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// tee returns a reader of the body that writes the bytes read to the writer.
+// A nil totalWriter returns the body unchanged.
+func (t *totalWriter) tee(body io.Reader) io.Reader {
+	if t == nil {
+		return body
+	}
+	return &totalReader{t: t, r: body}
+}
+
+// totalReader represents a reader that writes the bytes it reads to a
+// totalWriter.
+type totalReader struct {
+	t *totalWriter
+	r io.Reader
+}
+
+func (r *totalReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.t.mu.Lock()
+		// The writer only reports progress, so its errors never fail a download.
+		_, _ = r.t.w.Write(p[:n])
+		r.t.mu.Unlock()
+	}
+	return n, err
+}
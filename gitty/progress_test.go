@@ -0,0 +1,176 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeter(t *testing.T) {
+	t.Parallel()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var reports []Progress
+	o := options{
+		now:              func() time.Time { return clock },
+		progressInterval: time.Second,
+		progress:         func(p Progress) { reports = append(reports, p) },
+	}
+	m := newMeter(o, 1000, 2)
+
+	clock = clock.Add(500 * time.Millisecond)
+	m.add("a.txt", 100)
+	assert.Empty(t, reports, "want no report before the interval")
+
+	clock = clock.Add(1500 * time.Millisecond)
+	m.add("a.txt", 100)
+	require.Len(t, reports, 1)
+	assert.Equal(t, Progress{Bytes: 200, Total: 1000, BytesPerSecond: 100, ETA: 8 * time.Second, Path: "a.txt", FileBytes: 200, Files: 2}, reports[0])
+
+	m.done("a.txt")
+	require.Len(t, reports, 2)
+	assert.Equal(t, Progress{Bytes: 200, Total: 1000, BytesPerSecond: 100, ETA: 8 * time.Second, Path: "a.txt", FileBytes: 200, Files: 2, FilesDone: 1}, reports[1])
+
+	clock = clock.Add(2 * time.Second)
+	_, err := io.Copy(io.Discard, m.count("b.txt", strings.NewReader(strings.Repeat("x", 600))))
+	require.NoError(t, err)
+	require.Len(t, reports, 3)
+	assert.Equal(t, Progress{Bytes: 800, Total: 1000, BytesPerSecond: 200, ETA: time.Second, Path: "b.txt", FileBytes: 600, Files: 2, FilesDone: 1}, reports[2])
+
+	m.add("b.txt", 300)
+	m.done("b.txt")
+	m.finish()
+	require.Len(t, reports, 5)
+	assert.Equal(t, Progress{Bytes: 1100, Total: 1000, BytesPerSecond: 275, Files: 2, FilesDone: 2}, reports[4])
+}
+
+func TestMeterNoThroughput(t *testing.T) {
+	t.Parallel()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newMeter(options{now: func() time.Time { return clock }}, 1000, 1)
+	assert.Equal(t, Progress{Total: 1000, Files: 1}, m.progress(clock.Add(time.Second), ""))
+
+	var nilMeter *meter
+	r := strings.NewReader("data")
+	assert.Equal(t, io.Reader(r), nilMeter.count("data.txt", r))
+	nilMeter.done("data.txt")
+}
+
+func TestDownloadProgress(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/a.txt":     strings.Repeat("a", 10),
+		fakeBase + "/sub/b.txt": strings.Repeat("b", 20),
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	var last Progress
+	r.opts.progressInterval = time.Hour
+	r.opts.progress = func(p Progress) { last = p }
+	g := fakeNew(r)
+
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(30), last.Total)
+	assert.Equal(t, uint64(30), last.Bytes)
+	assert.Zero(t, last.ETA)
+}
+
+func TestDownloadProgressFiles(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/a.txt":       strings.Repeat("a", 10),
+		"dir/sub/b.txt":   strings.Repeat("b", 2000),
+		"dir/sub/c/d.txt": strings.Repeat("d", 33333),
+	}
+	r := serverRepository(t, contentsMux(files))
+	var events []Progress
+	// The reports are serialized, so they are collected without a lock.
+	r.opts.progress = func(p Progress) { events = append(events, p) }
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	_, err := r.downloadTo(context.Background(), t.TempDir())
+	require.NoError(t, err)
+
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, Progress{Bytes: 35343, Total: 35343, BytesPerSecond: last.BytesPerSecond, Files: 3, FilesDone: 3}, last)
+	completed := make(map[string]uint64)
+	filesDone := 0
+	for _, event := range events {
+		assert.GreaterOrEqual(t, event.FilesDone, filesDone)
+		if event.FilesDone > filesDone {
+			filesDone = event.FilesDone
+			completed[event.Path] = event.FileBytes
+		}
+	}
+	assert.Equal(t, map[string]uint64{"dir/a.txt": 10, "dir/sub/b.txt": 2000, "dir/sub/c/d.txt": 33333}, completed)
+}
+
+// countWriter represents a writer that counts the bytes written to it.
+type countWriter struct {
+	n int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestDownloadTotalProgress(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/a.txt":       strings.Repeat("a", 10),
+		fakeBase + "/sub/b.txt":   strings.Repeat("b", 2000),
+		fakeBase + "/sub/c/d.txt": strings.Repeat("d", 33333),
+	}
+	size := 0
+	for _, content := range files {
+		size += len(content)
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	w := &countWriter{}
+	r.opts.total = &totalWriter{w: w}
+	g := fakeNew(r)
+
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+	assert.Equal(t, size, w.n)
+}
+
+// errWriter represents a writer that always fails.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func TestTotalWriterError(t *testing.T) {
+	t.Parallel()
+	tw := &totalWriter{w: errWriter{}}
+	b, err := io.ReadAll(tw.tee(strings.NewReader("data")))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(b))
+
+	var nilWriter *totalWriter
+	body := strings.NewReader("data")
+	assert.Equal(t, io.Reader(body), nilWriter.tee(body))
+}
@@ -0,0 +1,110 @@
+package gitty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// Provenance represents the record of the origin of downloaded files.
+type Provenance struct {
+	// Source is the URL the files were downloaded from.
+	Source string `json:"source"`
+	// Commit is the SHA of the commit the ref resolved to.
+	Commit string `json:"commit"`
+	// Timestamp is the time the download finished.
+	Timestamp time.Time `json:"timestamp"`
+	// Files are the downloaded files in tree order.
+	Files []ProvenanceFile `json:"files"`
+}
+
+// ProvenanceFile represents a downloaded file in a provenance record.
+type ProvenanceFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// digests represents the SHA-256 digests of the saved files, keyed by
+// repository path.
+type digests struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// hash returns a reader of the body that records the digest of the file at
+// the repository path once the body is read to the end. A nil digests
+// returns the body unchanged.
+func (d *digests) hash(path string, body io.Reader) io.Reader {
+	if d == nil {
+		return body
+	}
+	return &digestReader{d: d, path: path, r: body, h: sha256.New()}
+}
+
+// get returns the digest of the file at the repository path.
+func (d *digests) get(path string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hashes[path]
+}
+
+// digestReader represents a reader that hashes the content it reads.
+type digestReader struct {
+	d    *digests
+	path string
+	r    io.Reader
+	h    hash.Hash
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF {
+		r.d.mu.Lock()
+		r.d.hashes[r.path] = hex.EncodeToString(r.h.Sum(nil))
+		r.d.mu.Unlock()
+	}
+	return n, err
+}
+
+// writeProvenance writes the provenance record of the downloaded files to the
+// provenance file.
+func (g *GitHub) writeProvenance(ctx context.Context, files []*github.RepositoryContent) error {
+	ref := g.ref()
+	if ref == "" {
+		ref = headRef
+	}
+	sha, err := g.resolveCommit(ctx)
+	if err != nil {
+		return err
+	}
+
+	p := Provenance{
+		Source:    "https://github.com/" + strings.Join([]string{g.Owner, g.Repo, "tree", ref, g.Path}, "/"),
+		Commit:    sha,
+		Timestamp: time.Now().UTC(),
+		Files:     make([]ProvenanceFile, 0, len(files)),
+	}
+	for _, file := range files {
+		p.Files = append(p.Files, ProvenanceFile{
+			Path:   file.GetPath(),
+			SHA256: g.opts.digests.get(file.GetPath()),
+		})
+	}
+
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(g.opts.provenance, b, 0o600)
+}
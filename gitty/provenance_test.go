@@ -0,0 +1,68 @@
+package gitty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProvenance(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/a.txt":     "first",
+		fakeBase + "/sub/b.txt": "second",
+	}
+	sha := "0123456789abcdef0123456789abcdef01234567"
+
+	mux := contentsMux(files)
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ref") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, sha)
+	})
+	r := serverRepository(t, mux)
+	r.opts.provenance = filepath.Join(t.TempDir(), "provenance.json")
+	g := fakeNew(r)
+
+	start := time.Now().UTC()
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(r.opts.provenance)
+	require.NoError(t, err)
+	var p Provenance
+	require.NoError(t, json.Unmarshal(b, &p))
+	assert.Equal(t, "https://github.com/owner/repo/tree/main/"+fakeBase, p.Source)
+	assert.Equal(t, sha, p.Commit)
+	assert.False(t, p.Timestamp.Before(start.Truncate(time.Second)))
+	require.Len(t, p.Files, len(files))
+	for _, file := range p.Files {
+		sum := sha256.Sum256([]byte(files[file.Path]))
+		assert.Equal(t, hex.EncodeToString(sum[:]), file.SHA256, file.Path)
+	}
+}
+
+func TestWriteProvenanceError(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockError{}}
+	err := r.writeProvenance(context.Background(), nil)
+	require.ErrorIs(t, err, errMockCommitSHA1)
+}
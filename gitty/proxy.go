@@ -0,0 +1,66 @@
+package gitty
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// cacheTransport represents an http.RoundTripper that sends GET requests to a
+// caching proxy first and falls back to the original URL on a cache miss.
+// The URL of a request is appended to the prefix of the proxy without its
+// scheme, such as https://cache.example.com/raw.githubusercontent.com/owner/repo/main/file
+// for the prefix https://cache.example.com/.
+type cacheTransport struct {
+	base   http.RoundTripper
+	prefix string
+}
+
+// Ensure cacheTransport implements the http.RoundTripper interface.
+var _ http.RoundTripper = (*cacheTransport)(nil)
+
+// RoundTrip executes a single HTTP transaction with the caching proxy and,
+// if the proxy fails or responds with 404 (Not Found) or a server error,
+// with the original request. The Authorization header is not sent to the
+// proxy. Requests other than GET are sent unchanged.
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	u, err := url.Parse(t.cacheURL(req.URL))
+	if err == nil {
+		cached := req.Clone(req.Context())
+		cached.URL = u
+		cached.Host = u.Host
+		cached.Header.Del("Authorization")
+		resp, err := t.base.RoundTrip(cached)
+		if err == nil && !cacheMiss(resp.StatusCode) {
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	// The caller's context is done, so the original URL is not requested.
+	if req.Context().Err() != nil {
+		return nil, req.Context().Err()
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// cacheURL returns the URL of u at the caching proxy.
+func (t *cacheTransport) cacheURL(u *url.URL) string {
+	s := u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		s += "?" + u.RawQuery
+	}
+	return strings.TrimSuffix(t.prefix, "/") + "/" + s
+}
+
+// cacheMiss reports whether the status code of a caching proxy response
+// means the content must be requested from the original URL.
+func cacheMiss(code int) bool {
+	return code == http.StatusNotFound || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,150 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheTransport(t *testing.T) {
+	t.Parallel()
+	var originHits atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originHits.Add(1)
+		fmt.Fprint(w, "origin "+r.URL.Path)
+	}))
+	t.Cleanup(origin.Close)
+	host := strings.TrimPrefix(origin.URL, "http://")
+
+	cache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			http.Error(w, "unexpected token", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/" + host + "/cached":
+			fmt.Fprint(w, "cached "+r.URL.RawQuery)
+		case "/" + host + "/broken":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(cache.Close)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected string
+		origin   int32
+	}{
+		{name: "hit", method: http.MethodGet, path: "/cached?ref=main", expected: "cached ref=main", origin: 0},
+		{name: "miss", method: http.MethodGet, path: "/missing", expected: "origin /missing", origin: 1},
+		{name: "server error", method: http.MethodGet, path: "/broken", expected: "origin /broken", origin: 1},
+		{name: "not get", method: http.MethodPost, path: "/cached", expected: "origin /cached", origin: 1},
+	}
+
+	// The subtests share the origin hit counter, so they run in sequence.
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			originHits.Store(0)
+			c := &http.Client{Transport: &cacheTransport{base: http.DefaultTransport, prefix: cache.URL + "/"}}
+			req, err := http.NewRequestWithContext(context.Background(), test.method, origin.URL+test.path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer token")
+
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+			assert.Equal(t, test.origin, originHits.Load())
+		})
+	}
+}
+
+func TestCacheTransportUnreachable(t *testing.T) {
+	t.Parallel()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "origin")
+	}))
+	t.Cleanup(origin.Close)
+	cache := httptest.NewServer(http.NotFoundHandler())
+	cache.Close()
+
+	c := &http.Client{Transport: &cacheTransport{base: http.DefaultTransport, prefix: cache.URL}}
+	resp, err := c.Get(origin.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "origin", string(b))
+}
+
+func TestDownloadCacheProxy(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/a.txt": "a",
+		fakeBase + "/b.txt": "b",
+	}
+	srv := httptest.NewServer(contentsMux(files))
+	t.Cleanup(srv.Close)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	var mu sync.Mutex
+	var requested []string
+	cache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		// Only a.txt is cached.
+		if r.URL.Path == "/"+host+"/raw/"+fakeBase+"/a.txt" {
+			fmt.Fprint(w, "cached a")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(cache.Close)
+
+	o := newOptions(WithCacheProxy(cache.URL + "/"))
+	o.token = ""
+	c := newClient(o)
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+	r := repository(c, o)
+
+	err = fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(fakeBase + "/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "cached a", string(b))
+	b, err = os.ReadFile(fakeBase + "/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(b))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, requested, "/"+host+"/repos/owner/repo/contents/"+fakeBase)
+	assert.Contains(t, requested, "/"+host+"/raw/"+fakeBase+"/b.txt")
+}
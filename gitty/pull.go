@@ -0,0 +1,83 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// pullFilesPerPage represents the number of changed files listed per request,
+// the maximum allowed by GitHub.
+const pullFilesPerPage = 100
+
+// downloadPull downloads the files changed by the pull request of the
+// repository, as they are at the head commit of the pull request, and saves
+// them under the base directory at their repository paths.
+func (g *GitHub) downloadPull(ctx context.Context, owner, repo string, number int, base string) (Result, error) {
+	if err := g.allowed(owner, repo); err != nil {
+		return Result{}, err
+	}
+
+	pr, _, err := g.Client.GetPullRequest(ctx, owner, repo, number)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	files, err := g.pullFiles(ctx, owner, repo, number)
+	if err != nil {
+		return Result{}, err
+	}
+
+	g.Owner = owner
+	g.Repo = repo
+	g.Ref = &github.RepositoryContentGetOptions{Ref: pr.GetHead().GetSHA()}
+	g.Path = ""
+	g.file = false
+	g.gist = nil
+	g.glob = ""
+	g.pull = files
+	defer func() {
+		g.pull = nil
+	}()
+	fmt.Printf("Downloading: %s/%s#%d\n", owner, repo, number)
+
+	return g.downloadTo(ctx, base)
+}
+
+// pullFiles returns the files changed by the pull request, listed page by
+// page. The files are not nil even if there are none, so the listing of a
+// pull request without changes is not mistaken for the whole repository.
+func (g *GitHub) pullFiles(ctx context.Context, owner, repo string, number int) ([]*github.CommitFile, error) {
+	opts := &github.ListOptions{PerPage: pullFilesPerPage}
+	files := []*github.CommitFile{}
+	for {
+		page, resp, err := g.Client.ListPullRequestFiles(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull request files: %w", err)
+		}
+		files = append(files, page...)
+		if resp == nil || resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listPull returns the files and symlinks changed by the pull request at the
+// ref. Removed files are skipped, and renamed files are listed at their new
+// paths.
+func (g *GitHub) listPull(ctx context.Context) ([]*github.RepositoryContent, error) {
+	var paths []string
+	for _, file := range g.pull {
+		if file.GetStatus() == "removed" {
+			g.warnings.add(WarnRemovedSkipped, file.GetFilename(), "Skipping removed file")
+			continue
+		}
+		paths = append(paths, file.GetFilename())
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	return g.walk(ctx, paths...)
+}
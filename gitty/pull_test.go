@@ -0,0 +1,104 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadPRFiles(t *testing.T) {
+	t.Parallel()
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	contents := contentsMux(map[string]string{
+		"added.txt":       "added",
+		"dir/changed.txt": "changed",
+		"renamed.txt":     "renamed",
+		"untouched.txt":   "untouched",
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls/7", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"number":7,"head":{"sha":%q}}`, sha)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		// The files are listed on two pages.
+		if r.URL.Query().Get("page") != "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/owner/repo/pulls/7/files?page=2>; rel="next"`, r.Host))
+			fmt.Fprint(w, `[{"filename":"added.txt","status":"added"},{"filename":"dir/changed.txt","status":"modified"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"filename":"removed.txt","status":"removed"},{"filename":"renamed.txt","previous_filename":"old.txt","status":"renamed"}]`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != sha {
+			http.Error(w, "want head sha", http.StatusBadRequest)
+			return
+		}
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	base := t.TempDir()
+
+	result, err := fakeNew(serverRepository(t, mux)).DownloadPRFiles(context.Background(), "owner", "repo", 7, base)
+	require.NoError(t, err)
+
+	assert.Equal(t, sha, result.Manifest.Ref)
+	var paths []string
+	for _, file := range result.Manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{"added.txt", "dir/changed.txt", "renamed.txt"}, paths)
+	assert.Equal(t, []Warning{{Code: WarnRemovedSkipped, Path: "removed.txt", Message: "Skipping removed file"}}, result.Warnings)
+	for name, content := range map[string]string{"added.txt": "added", "dir/changed.txt": "changed", "renamed.txt": "renamed"} {
+		b, err := os.ReadFile(filepath.Join(base, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	for _, name := range []string{"removed.txt", "old.txt", "untouched.txt"} {
+		_, err = os.Stat(filepath.Join(base, name))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+func TestDownloadPRFilesEmpty(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls/7", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"number":7,"head":{"sha":"a1b2c3d"}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/pulls/7/files", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"filename":"removed.txt","status":"removed"}]`)
+	})
+	mux.Handle("/", contentsMux(map[string]string{"untouched.txt": "untouched"}))
+	base := t.TempDir()
+
+	result, err := fakeNew(serverRepository(t, mux)).DownloadPRFiles(context.Background(), "owner", "repo", 7, base)
+	require.NoError(t, err)
+	assert.Empty(t, result.Manifest.Files)
+	_, err = os.Stat(filepath.Join(base, "untouched.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDownloadPRFilesError(t *testing.T) {
+	t.Parallel()
+	g := fakeNew(fakeRepository(&mockError{}))
+	_, err := g.DownloadPRFiles(context.Background(), "owner", "repo", 7, t.TempDir())
+	require.ErrorIs(t, err, errMockPullRequest)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls/7", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"number":7,"head":{"sha":"a1b2c3d"}}`)
+	})
+	_, err = fakeNew(serverRepository(t, mux)).DownloadPRFiles(context.Background(), "owner", "repo", 7, t.TempDir())
+	require.ErrorContains(t, err, "failed to list pull request files")
+
+	r := fakeRepository(&mockSuccess{})
+	r.opts.allowedRepos = []string{"owner/other"}
+	_, err = fakeNew(r).DownloadPRFiles(context.Background(), "owner", "repo", 7, t.TempDir())
+	require.ErrorIs(t, err, ErrRepoNotAllowed)
+}
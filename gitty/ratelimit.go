@@ -0,0 +1,130 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// rateLimitBuffer represents the time waited after the reset of a rate limit,
+// as the reset is reported in whole seconds.
+const rateLimitBuffer = time.Second
+
+// ErrRateLimited is wrapped by RateLimitError.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitError is returned for a request rejected because the rate limit is
+// exhausted. It wraps ErrRateLimited.
+type RateLimitError struct {
+	// Reset is the time the rate limit resets.
+	Reset time.Time
+}
+
+// Error returns the message of the error along with the reset time.
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, resets at %s", ErrRateLimited, e.Reset.Format(time.RFC3339))
+}
+
+// Unwrap returns ErrRateLimited.
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// rateLimitTransport represents an http.RoundTripper that fails requests
+// rejected because the rate limit is exhausted with a RateLimitError or,
+// if waiting is enabled, repeats them once the rate limit resets.
+type rateLimitTransport struct {
+	base  http.RoundTripper
+	wait  bool
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Ensure rateLimitTransport implements the http.RoundTripper interface.
+var _ http.RoundTripper = (*rateLimitTransport)(nil)
+
+// RoundTrip executes a single HTTP transaction and, if the rate limit is
+// exhausted, returns a RateLimitError or waits for the reset of the rate
+// limit and executes it again.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		reset, ok := rateLimited(resp)
+		if !ok {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if !t.wait {
+			return nil, &RateLimitError{Reset: reset}
+		}
+
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return nil, &RateLimitError{Reset: reset}
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		fmt.Println("Waiting for rate limit reset:", reset.Format(time.RFC3339))
+		if err := t.pause(req.Context(), reset.Sub(t.clock())+rateLimitBuffer); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// clock returns the current time.
+func (t *rateLimitTransport) clock() time.Time {
+	if t.now == nil {
+		return time.Now()
+	}
+	return t.now()
+}
+
+// pause waits for d or until ctx is done. The deadline of the download is
+// extended by d, as the reset may be up to an hour away.
+func (t *rateLimitTransport) pause(ctx context.Context, d time.Duration) error {
+	extendDeadline(ctx, d)
+	if t.sleep == nil {
+		return sleep(ctx, d)
+	}
+	return t.sleep(ctx, d)
+}
+
+// rateLimited returns the reset time of the rate limit if the response
+// rejects the request because the rate limit is exhausted, as reported by
+// the X-RateLimit-Remaining and X-RateLimit-Reset headers.
+func rateLimited(resp *http.Response) (time.Time, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, false
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(reset, 0), true
+}
+
+// rateLimitErr wraps the error go-github returns without a request, once it
+// knows the rate limit is exhausted, in a RateLimitError. Other errors are
+// returned unchanged.
+func rateLimitErr(err error) error {
+	var rateErr *github.RateLimitError
+	if !errors.As(err, &rateErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", &RateLimitError{Reset: rateErr.Rate.Reset.Time}, err)
+}
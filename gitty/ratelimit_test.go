@@ -0,0 +1,204 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitedHandler rejects the first n requests as rate limited until reset
+// and passes the remaining ones to h.
+func rateLimitedHandler(n int32, reset time.Time, h http.Handler) (http.Handler, *atomic.Int32) {
+	var calls atomic.Int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= n {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			http.Error(w, `{"message":"API rate limit exceeded."}`, http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}), &calls
+}
+
+func TestRateLimited(t *testing.T) {
+	t.Parallel()
+	reset := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		status    int
+		remaining string
+		reset     string
+		expected  bool
+	}{
+		{name: "forbidden", status: http.StatusForbidden, remaining: "0", reset: "1700000000", expected: true},
+		{name: "too many requests", status: http.StatusTooManyRequests, remaining: "0", reset: "1700000000", expected: true},
+		{name: "remaining", status: http.StatusForbidden, remaining: "5", reset: "1700000000", expected: false},
+		{name: "no reset", status: http.StatusForbidden, remaining: "0", expected: false},
+		{name: "ok", status: http.StatusOK, remaining: "0", reset: "1700000000", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+			resp.Header.Set("X-RateLimit-Remaining", test.remaining)
+			resp.Header.Set("X-RateLimit-Reset", test.reset)
+			got, ok := rateLimited(resp)
+			assert.Equal(t, test.expected, ok)
+			if ok {
+				assert.Equal(t, reset, got)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	t.Parallel()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	reset := clock.Add(time.Minute)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		h, calls := rateLimitedHandler(1, reset, ok)
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+		c := &http.Client{Transport: &rateLimitTransport{base: http.DefaultTransport}}
+
+		_, err := c.Get(srv.URL) //nolint:bodyclose // No response on error.
+		require.ErrorIs(t, err, ErrRateLimited)
+		var rateErr *RateLimitError
+		require.ErrorAs(t, err, &rateErr)
+		assert.Equal(t, reset.Unix(), rateErr.Reset.Unix())
+		assert.Contains(t, rateErr.Error(), "resets at")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		t.Parallel()
+		h, calls := rateLimitedHandler(1, reset, ok)
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+		var delays []time.Duration
+		c := &http.Client{Transport: &rateLimitTransport{
+			base: http.DefaultTransport,
+			wait: true,
+			now:  func() time.Time { return clock },
+			sleep: func(_ context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			},
+		}}
+
+		resp, err := c.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []time.Duration{time.Minute + rateLimitBuffer}, delays)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("wait canceled", func(t *testing.T) {
+		t.Parallel()
+		h, _ := rateLimitedHandler(1, time.Now().Add(time.Hour), ok)
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+		ctx, cancel := context.WithCancel(context.Background())
+		tr := &rateLimitTransport{
+			base: http.DefaultTransport,
+			wait: true,
+			sleep: func(context.Context, time.Duration) error {
+				cancel()
+				return context.Canceled
+			},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+
+		resp, err := tr.RoundTrip(req) //nolint:bodyclose // No response on error.
+		assert.Nil(t, resp)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestRateLimitErr(t *testing.T) {
+	t.Parallel()
+	reset := time.Unix(1700000000, 0)
+	err := rateLimitErr(fmt.Errorf("failed to download: %w", &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: reset}}}))
+	var rateErr *RateLimitError
+	require.ErrorAs(t, err, &rateErr)
+	assert.Equal(t, reset, rateErr.Reset)
+
+	assert.Equal(t, errMockContents, rateLimitErr(errMockContents))
+	assert.NoError(t, rateLimitErr(nil))
+}
+
+func TestDownloadRateLimited(t *testing.T) {
+	t.Parallel()
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	h, _ := rateLimitedHandler(1, reset, http.NotFoundHandler())
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	o := newOptions()
+	c := newClient(o)
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+
+	err = fakeNew(repository(c, o)).Download(context.Background(), "https://github.com/owner/repo/tree/main/dir")
+	var rateErr *RateLimitError
+	require.ErrorAs(t, err, &rateErr)
+	assert.Equal(t, reset, rateErr.Reset)
+	require.ErrorIs(t, err, ErrRateLimited)
+}
+
+func TestDownloadWaitOnRateLimit(t *testing.T) {
+	t.Parallel()
+	now := time.Now().Truncate(time.Second)
+	// The reset is further away than the time limit of an attempt.
+	reset := now.Add(2 * downloadLimit * time.Second)
+	h, calls := rateLimitedHandler(1, reset, contentsMux(map[string]string{"dir/file.txt": "data"}))
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	var waited time.Duration
+	tr := &rateLimitTransport{
+		base: http.DefaultTransport,
+		wait: true,
+		now:  func() time.Time { return now },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			waited = d
+			dl, ok := ctx.Value(deadlineKey{}).(*deadline)
+			require.True(t, ok)
+			dl.mu.Lock()
+			defer dl.mu.Unlock()
+			// The deadline leaves room for the wait.
+			assert.Greater(t, time.Until(dl.end), d)
+			return nil
+		},
+	}
+	c := github.NewClient(&http.Client{Transport: tr})
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+	o := newOptions(WithWaitOnRateLimit(true), WithOutputDir(t.TempDir()))
+
+	_, err = fakeNew(repository(c, o)).DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/dir")
+	require.NoError(t, err)
+	assert.Equal(t, reset.Sub(now)+rateLimitBuffer, waited)
+	assert.Greater(t, calls.Load(), int32(1))
+}
@@ -0,0 +1,170 @@
+package gitty
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+	"gopkg.in/yaml.v3"
+)
+
+// recipeName represents the name of the file that describes the actions
+// applied to a downloaded directory.
+const recipeName = ".gitty.yaml"
+
+// ErrNotValidRecipePath is returned for a recipe path outside of the
+// downloaded directory.
+var ErrNotValidRecipePath = errors.New("recipe path must be relative to the downloaded directory")
+
+// recipe represents the actions applied to a downloaded directory, such as
+// the following. The deletions are applied first, then the renames, and then
+// the variables are substituted.
+//
+//	delete:
+//	  - .github
+//	rename:
+//	  - from: cmd/template
+//	    to: cmd/{{name}}
+//	variables:
+//	  name: app
+type recipe struct {
+	// Delete is the files and directories removed.
+	Delete []string `yaml:"delete"`
+	// Rename is the files and directories moved, in order.
+	Rename []recipeRename `yaml:"rename"`
+	// Variables is the values substituted for {{name}} in the contents of
+	// text files and in rename targets, keyed by name.
+	Variables map[string]string `yaml:"variables"`
+}
+
+// recipeRename represents a file or directory moved by a recipe.
+type recipeRename struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// applyRecipe applies the recipe of the downloaded directory, if one was
+// downloaded, and removes it. The variables of the recipe are overridden by
+// the recipe variables of the options.
+func (g *GitHub) applyRecipe(files []*github.RepositoryContent) error {
+	name := path.Join(g.Path, recipeName)
+	if !slices.ContainsFunc(files, func(file *github.RepositoryContent) bool {
+		return file.GetPath() == name
+	}) {
+		return nil
+	}
+
+	file, err := localPath(g.opts, g.Path, name)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(file)
+	// A recipe saved under another name, such as gzipped, is not applied.
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var r recipe
+	if err := yaml.Unmarshal(b, &r); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", recipeName, err)
+	}
+	if err := os.Remove(file); err != nil {
+		return err
+	}
+
+	vars := make(map[string]string)
+	maps.Copy(vars, r.Variables)
+	maps.Copy(vars, g.opts.recipeVars)
+	replacer := newVarReplacer(vars)
+	root := filepath.Dir(file)
+	resolve := func(p string) (string, error) {
+		p = filepath.FromSlash(replacer.Replace(p))
+		if !filepath.IsLocal(p) {
+			return "", fmt.Errorf("%w: %s", ErrNotValidRecipePath, p)
+		}
+		return filepath.Join(root, p), nil
+	}
+
+	for _, p := range r.Delete {
+		target, err := resolve(p)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Deleting:", target)
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+
+	for _, rename := range r.Rename {
+		from, err := resolve(rename.From)
+		if err != nil {
+			return err
+		}
+		to, err := resolve(rename.To)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Renaming:", from, "->", to)
+		if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+	}
+
+	if len(vars) == 0 {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return substitute(replacer, p)
+	})
+}
+
+// newVarReplacer creates a replacer of {{name}} and {{ name }} with the
+// values of the variables.
+func newVarReplacer(vars map[string]string) *strings.Replacer {
+	var pairs []string
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v, "{{ "+k+" }}", v)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// substitute substitutes the variables in the contents of the file, if it is
+// a text file.
+func substitute(replacer *strings.Replacer, p string) error {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte(b[:min(len(b), binaryPeek)], 0) >= 0 {
+		return nil
+	}
+
+	s := replacer.Replace(string(b))
+	if s == string(b) {
+		return nil
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, []byte(s), info.Mode().Perm())
+}
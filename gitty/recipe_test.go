@@ -0,0 +1,122 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadRecipe(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/" + recipeName: `delete:
+  - .github
+  - LICENSE
+rename:
+  - from: cmd/template
+    to: cmd/{{name}}
+  - from: README.tmpl.md
+    to: README.md
+variables:
+  name: template
+  owner: someone
+`,
+		fakeBase + "/.github/workflows/ci.yml":   "ci",
+		fakeBase + "/LICENSE":                    "license",
+		fakeBase + "/README.tmpl.md":             "# {{ name }} by {{owner}}",
+		fakeBase + "/cmd/template/main.go":       "package main // {{name}}",
+		fakeBase + "/assets/logo.bin":            "\x00{{name}}",
+		fakeBase + "/docs/unchanged.md":          "no variables",
+		fakeBase + "/docs/.github/kept/file.txt": "{{unknown}}",
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.recipe = true
+	r.opts.recipeVars = map[string]string{"name": "app"}
+	g := fakeNew(r)
+
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	for path, content := range map[string]string{
+		"README.md":                  "# app by someone",
+		"cmd/app/main.go":            "package main // app",
+		"assets/logo.bin":            "\x00{{name}}",
+		"docs/unchanged.md":          "no variables",
+		"docs/.github/kept/file.txt": "{{unknown}}",
+	} {
+		b, err := os.ReadFile(filepath.Join(fakeBase, path))
+		require.NoError(t, err, path)
+		assert.Equal(t, content, string(b), path)
+	}
+	for _, path := range []string{recipeName, ".github", "LICENSE", "README.tmpl.md", "cmd/template"} {
+		_, err := os.Stat(filepath.Join(fakeBase, path))
+		require.ErrorIs(t, err, os.ErrNotExist, path)
+	}
+}
+
+func TestDownloadRecipeError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		recipe   string
+		expected error
+	}{
+		{name: "delete outside", recipe: "delete:\n  - ../outside\n", expected: ErrNotValidRecipePath},
+		{name: "rename outside", recipe: "rename:\n  - from: file.txt\n    to: /tmp/file.txt\n", expected: ErrNotValidRecipePath},
+		{name: "rename variable outside", recipe: "rename:\n  - from: file.txt\n    to: '{{dir}}/file.txt'\nvariables:\n  dir: ..\n", expected: ErrNotValidRecipePath},
+		{name: "rename missing", recipe: "rename:\n  - from: missing.txt\n    to: file.txt\n", expected: os.ErrNotExist},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			files := map[string]string{
+				fakeBase + "/" + recipeName: test.recipe,
+				fakeBase + "/file.txt":      "data",
+			}
+			r := serverRepository(t, contentsMux(files))
+			r.opts.recipe = true
+			g := fakeNew(r)
+
+			err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			require.ErrorIs(t, err, test.expected)
+			assert.FileExists(t, fakeBase+"/file.txt")
+		})
+	}
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		t.Parallel()
+		fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+		t.Cleanup(func() {
+			err := os.RemoveAll(fakeBase)
+			require.NoError(t, err)
+		})
+		files := map[string]string{
+			fakeBase + "/" + recipeName: "delete: [",
+			fakeBase + "/file.txt":      "data",
+		}
+		r := serverRepository(t, contentsMux(files))
+		r.opts.recipe = true
+		g := fakeNew(r)
+
+		err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+		require.ErrorContains(t, err, "failed to parse "+recipeName)
+	})
+}
@@ -0,0 +1,42 @@
+package gitty
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Record represents a downloaded file written as a JSON record. The content
+// is base64-encoded in JSON.
+type Record struct {
+	Path    string `json:"path"`
+	Content []byte `json:"content"`
+}
+
+// recorder writes the downloaded files instead of saving them.
+type recorder interface {
+	// write reads the body and writes it as the file of the path.
+	write(path string, body io.Reader) error
+}
+
+// Ensure recordWriter implements the recorder interface.
+var _ recorder = (*recordWriter)(nil)
+
+// recordWriter writes records as newline-delimited JSON. It is safe for
+// concurrent use.
+type recordWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// write reads the body and writes it as a record of the path.
+func (w *recordWriter) write(path string, body io.Reader) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(Record{Path: path, Content: b})
+}
@@ -0,0 +1,66 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadNDJSON(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	files := map[string]string{
+		"repo/" + fakeBase + "/a.txt":     "content a",
+		"repo/" + fakeBase + "/sub/b.bin": "\x00\x01\xff binary",
+	}
+	links := map[string]string{
+		"repo/" + fakeBase + "/link.txt": "a.txt",
+	}
+
+	var buf bytes.Buffer
+	r := serverRepository(t, linksMux(files, links))
+	r.opts = newOptions(WithNDJSON(&buf), WithIndex(true))
+	g := fakeNew(r)
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+fakeBase)
+	require.NoError(t, err)
+
+	actual := map[string]string{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		actual[record.Path] = string(record.Content)
+	}
+	require.NoError(t, scanner.Err())
+
+	expected := map[string]string{
+		fakeBase + "/a.txt":     "content a",
+		fakeBase + "/sub/b.bin": "\x00\x01\xff binary",
+		fakeBase + "/link.txt":  "content a",
+	}
+	assert.Equal(t, expected, actual)
+	_, err = os.Stat(fakeBase)
+	require.ErrorIs(t, err, os.ErrNotExist, "want no files saved")
+}
+
+func TestRecordWriter(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	w := &recordWriter{enc: json.NewEncoder(&buf)}
+
+	err := w.write("dir/file.txt", strings.NewReader("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"path":"dir/file.txt","content":"aGk="}`+"\n", buf.String())
+
+	err = w.write("dir/file.txt", errReader(0))
+	assert.Equal(t, errMockReadAll, err)
+}
@@ -0,0 +1,54 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// refSuggestions represents the number of branches listed at most in a
+// RefNotFoundError.
+const refSuggestions = 10
+
+// RefNotFoundError is returned for a branch, tag, or commit that does not
+// exist in the repository. It wraps ErrRefNotFound.
+type RefNotFoundError struct {
+	// Ref is the ref that was not found.
+	Ref string
+	// Branches are the names of some of the branches of the repository, if
+	// they could be listed.
+	Branches []string
+}
+
+// Error returns the message of the error along with the ref, a suggestion to
+// check its spelling, and the branches of the repository, if any.
+func (e *RefNotFoundError) Error() string {
+	msg := fmt.Sprintf("%s: %s, check the spelling of the branch or tag", ErrRefNotFound, e.Ref)
+	if len(e.Branches) > 0 {
+		msg += "; branches: " + strings.Join(e.Branches, ", ")
+	}
+	return msg
+}
+
+// Unwrap returns ErrRefNotFound.
+func (e *RefNotFoundError) Unwrap() error {
+	return ErrRefNotFound
+}
+
+// refNotFound returns the RefNotFoundError of the ref of the repository, with
+// the first branches of the repository. The branches are left out if they
+// cannot be listed.
+func (g *GitHub) refNotFound(ctx context.Context, owner, repo, ref string) error {
+	e := &RefNotFoundError{Ref: ref}
+	opts := &github.BranchListOptions{ListOptions: github.ListOptions{PerPage: refSuggestions}}
+	branches, _, err := g.Client.ListBranches(ctx, owner, repo, opts)
+	if err != nil {
+		return e
+	}
+	for _, branch := range branches {
+		e.Branches = append(e.Branches, branch.GetName())
+	}
+	return e
+}
@@ -0,0 +1,53 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// repoConfigName represents the path of the file at the repository root that
+// sets the default options of the downloads of the repository.
+const repoConfigName = ".gitty/config.yaml"
+
+// repoConfig represents the default options of the downloads of a
+// repository, set by its authors, such as the following.
+//
+//	exclude:
+//	  - testdata
+//	executable_extensions:
+//	  - .sh
+type repoConfig struct {
+	// Include is the default of WithInclude.
+	Include []string `yaml:"include"`
+	// Exclude is the default of WithExclude.
+	Exclude []string `yaml:"exclude"`
+	// ExecutableExtensions is the default of WithExecutableExtensions.
+	ExecutableExtensions []string `yaml:"executable_extensions"`
+}
+
+// applyRepoConfig reads the config file of the repository at the ref, if
+// there is one, and sets the options it sets that are not set locally.
+func (g *GitHub) applyRepoConfig(ctx context.Context) error {
+	b, ok, err := g.readFile(ctx, repoConfigName)
+	if err != nil || !ok {
+		return err
+	}
+
+	var c repoConfig
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", repoConfigName, err)
+	}
+	if g.opts.include == nil && c.Include != nil {
+		WithInclude(c.Include...)(&g.opts)
+	}
+	if g.opts.exclude == nil && c.Exclude != nil {
+		WithExclude(c.Exclude...)(&g.opts)
+	}
+	if g.opts.executableExtensions == nil && c.ExecutableExtensions != nil {
+		WithExecutableExtensions(c.ExecutableExtensions)(&g.opts)
+	}
+
+	return nil
+}
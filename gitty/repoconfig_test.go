@@ -0,0 +1,83 @@
+package gitty
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadRepoConfig(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		repoConfigName:          "exclude:\n  - testdata\nexecutable_extensions:\n  - SH\n",
+		"dir/main.go":           "main",
+		"dir/README.md":         "readme",
+		"dir/testdata/input.go": "input",
+	}
+	tests := []struct {
+		name     string
+		local    []Option
+		expected []string
+	}{
+		{
+			name:     "repo defaults",
+			expected: []string{"dir/README.md", "dir/main.go"},
+		},
+		{
+			name:     "local override",
+			local:    []Option{WithExclude("*.md")},
+			expected: []string{"dir/main.go", "dir/testdata/input.go"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			base := t.TempDir()
+			r := serverRepository(t, contentsMux(files))
+			r.opts = newOptions(append(test.local, WithRepoConfig(true))...)
+			exclude := r.opts.exclude
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+			_, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, savedFiles(t, base))
+			// The repo defaults do not outlive the download.
+			assert.Equal(t, exclude, r.opts.exclude)
+			assert.Nil(t, r.opts.executableExtensions)
+		})
+	}
+}
+
+func TestApplyRepoConfig(t *testing.T) {
+	t.Parallel()
+	r := serverRepository(t, contentsMux(map[string]string{
+		repoConfigName: "include: ['*.go']\nexclude: [vendor]\nexecutable_extensions: [SH, .bash]\n",
+	}))
+	r.Owner, r.Repo = "owner", "repo"
+	require.NoError(t, r.applyRepoConfig(context.Background()))
+	assert.Equal(t, []string{"*.go"}, r.opts.include)
+	assert.Equal(t, []string{"vendor"}, r.opts.exclude)
+	assert.Equal(t, []string{".sh", ".bash"}, r.opts.executableExtensions)
+
+	r = serverRepository(t, contentsMux(map[string]string{repoConfigName: "include: ['*.go']\n"}))
+	r.Owner, r.Repo = "owner", "repo"
+	r.opts.include = []string{"*.md"}
+	r.opts.executableExtensions = []string{}
+	require.NoError(t, r.applyRepoConfig(context.Background()))
+	assert.Equal(t, []string{"*.md"}, r.opts.include)
+	assert.Nil(t, r.opts.exclude)
+	assert.Empty(t, r.opts.executableExtensions)
+
+	r = serverRepository(t, contentsMux(map[string]string{"README.md": "readme"}))
+	r.Owner, r.Repo = "owner", "repo"
+	require.NoError(t, r.applyRepoConfig(context.Background()))
+	assert.Nil(t, r.opts.include)
+
+	r = serverRepository(t, contentsMux(map[string]string{repoConfigName: "include: {"}))
+	r.Owner, r.Repo = "owner", "repo"
+	err := r.applyRepoConfig(context.Background())
+	require.ErrorContains(t, err, "failed to parse "+repoConfigName)
+}
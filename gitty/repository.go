@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -21,141 +25,1109 @@ const (
 )
 
 var (
-	ErrTookTooLong    = errors.New("took more than 60 seconds to download contents")
-	ErrInvalidPathURL = errors.New("invalid url or path")
-)
-
-// Repository defines methods for interacting with GitHub.
-type Repository interface {
-	extract(url string) error
-	download(ctx context.Context) error
-	contents(ctx context.Context, wg *sync.WaitGroup, path string, errCh chan error)
-	getFile(url, path string) error
-	status(ctx context.Context) error
-	auth(ctx context.Context) error
-}
+	ErrTookTooLong     = errors.New("took more than 60 seconds to download contents")
+	ErrInvalidPathURL  = errors.New("invalid url or path")
+	ErrNotFile         = errors.New("path must point to a file")
+	ErrRefNotFound     = errors.New("branch, tag, or commit not found")
+	ErrPathNotFound    = errors.New("path not found")
+	ErrRepoNotAllowed  = errors.New("repository not allowed")
+	ErrTooFewFiles     = errors.New("too few files to download")
+	ErrNotImmutableRef = errors.New("ref must be a full commit SHA")
+	ErrFileTimeout     = errors.New("file took too long to download")
 
-// Ensure GitHub implements the Repository interface.
-var _ Repository = (*GitHub)(nil)
+	ErrRateLimitedSuggestToken = errors.New("unauthenticated rate limit nearly exhausted, set a GitHub token in GH_TOKEN to raise the limit")
+)
 
 // repository creates a GitHub repository with default values.
-func repository(c *github.Client) Repository {
-	return &GitHub{
+func repository(c *github.Client, opts options) *GitHub {
+	g := &GitHub{
 		Client: &service{
-			client: c,
+			client:  c,
+			storage: newStorageClient(opts),
 		},
 		Owner: "",
 		Repo:  "",
 		Ref:   nil,
 		Path:  "",
+		opts:  opts,
 	}
+	if opts.treeCache {
+		g.trees = newTreeCache()
+	}
+	return g
+}
+
+// treeURL returns the URL of the directory at the repository path and ref on
+// the GitHub host, as accepted by extract.
+func (g *GitHub) treeURL(owner, repo, ref, path string) string {
+	return strings.Join([]string{"https://" + webHost(g.opts), owner, repo, treeKind, ref, path}, "/")
 }
 
 // extract parses a GitHub URL and extracts the owner, repository name, reference,
-// and path from it. It sets these values in the GitHub struct.
+// and path from it. It sets these values in the GitHub struct. A path with
+// glob characters, such as src/**/*.go, selects the files it matches beneath
+// its longest literal prefix. A GitHub Gist URL sets the gist to download
+// instead.
 func (g *GitHub) extract(url string) error {
-	s, err := getGitHubRepo(url)
+	g.gist = nil
+	g.glob = ""
+	if path, ok := gistPath(url); ok {
+		return g.extractGist(path)
+	}
+
+	s, err := getHostRepo(webHost(g.opts), url)
 	if err != nil {
 		return err
 	}
 
 	sep := "/"
 	strs := strings.Split(s, sep)
+	if err := g.allowed(strs[0], strs[1]); err != nil {
+		return err
+	}
 	g.Owner = strs[0]
 	g.Repo = strs[1]
 	g.Ref = &github.RepositoryContentGetOptions{Ref: strs[3]}
 	g.Path = strings.Join(strs[4:], sep)
+	g.file = strs[2] == blobKind
+	g.glob = ""
+	// A glob pattern is matched against the files beneath its literal prefix.
+	if prefix, ok := splitGlob(g.Path); ok {
+		g.glob = g.Path
+		g.Path = prefix
+		g.file = false
+	}
 
 	return nil
 }
 
-// download downloads the contents concurrently.
-func (g *GitHub) download(ctx context.Context) error {
-	wg := &sync.WaitGroup{}
-	errCh := make(chan error, 1)
-	ctx, cancel := context.WithTimeout(ctx, downloadLimit*time.Second)
-	defer cancel()
+// allowed returns ErrRepoNotAllowed if the allowed repositories are set and
+// the repository is not one of them. Owners and names are matched
+// case-insensitively, as on GitHub.
+func (g *GitHub) allowed(owner, repo string) error {
+	if g.opts.allowedRepos == nil {
+		return nil
+	}
+	name := owner + "/" + repo
+	for _, allowed := range g.opts.allowedRepos {
+		if strings.EqualFold(allowed, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRepoNotAllowed, name)
+}
 
-	wg.Add(1)
-	go g.contents(ctx, wg, g.Path, errCh)
+// ref returns the requested branch, tag, or commit, if any.
+func (g *GitHub) ref() string {
+	if g.Ref == nil {
+		return ""
+	}
+	return g.Ref.Ref
+}
 
-	go func() {
+// download lists the contents and downloads the files concurrently. It
+// returns the manifest of the downloaded files in tree order, independent of
+// the order in which the downloads completed, along with the warnings.
+// If the operation retries are set, the download is repeated after a
+// transient network error, skipping the files downloaded before.
+func (g *GitHub) download(ctx context.Context) (Result, error) {
+	if g.opts.immutableRef && !isFullCommitSHA(g.ref()) {
+		return Result{}, fmt.Errorf("%w: %s", ErrNotImmutableRef, g.ref())
+	}
+	if g.opts.minRateLimit > 0 {
+		if err := g.checkRateLimit(ctx); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if g.opts.treeDir != "" {
+		return g.downloadTree(ctx)
+	}
+	// go-github rejects requests without sending them once it knows the rate
+	// limit is exhausted. They are sent anyway, so the rate limit transport
+	// waits for the reset within the deadline of the download.
+	if g.opts.waitOnRateLimit {
+		ctx = context.WithValue(ctx, github.BypassRateLimitCheck, true)
+	}
+
+	g.opts.runID = newRunID()
+	ignore, err := readIgnore(g.opts, g.Path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+	g.opts.ignore = ignore
+	g.completed = &completed{paths: make(map[string]bool)}
+	g.modes = make(map[string]os.FileMode)
+	g.opts.written = &written{}
+	if g.opts.provenance != "" || g.opts.checksums != "" {
+		g.opts.digests = &digests{hashes: make(map[string]string)}
+	}
+	if g.opts.detectLineEndings {
+		g.opts.lineEndings = &lineEndings{}
+	}
+	if g.opts.verify {
+		g.opts.blobs = &blobs{files: make(map[string]*github.RepositoryContent)}
+	}
+	if g.opts.overwrite == OverwriteSkip {
+		g.opts.existing = &existing{}
+	}
+	if g.opts.flatten {
+		g.opts.flat = &flattener{mode: g.opts.collision, names: make(map[string]string), taken: make(map[string]bool)}
+	}
+
+	for attempt := 0; ; attempt++ {
+		result, err := g.attempt(ctx)
+		if err == nil {
+			logger(g.opts).Info("download finished",
+				"written", result.Summary.Written,
+				"skipped", result.Summary.Skipped,
+				"bytes", result.Summary.Bytes,
+				"warnings", len(result.Warnings))
+		}
+		if err == nil || attempt >= g.opts.operationRetries || !transient(err) {
+			return result, rateLimitErr(err)
+		}
+
+		fmt.Println("Retrying download:", err)
+		logger(g.opts).Warn("retrying download", "attempt", attempt+1, "error", err)
+		if err := sleep(ctx, g.opts.backoff<<attempt); err != nil {
+			return Result{}, err
+		}
+	}
+}
+
+// downloadTo downloads the contents like download and saves them under the
+// base directory, beneath the base directory returned by the router, if any.
+// The base directory takes the place of the output directory.
+func (g *GitHub) downloadTo(ctx context.Context, base string) (Result, error) {
+	router, outputDir := g.opts.router, g.opts.outputDir
+	defer func() {
+		g.opts.router, g.opts.outputDir = router, outputDir
+	}()
+	g.opts.outputDir = ""
+	g.opts.router = func(repoPath string) string {
+		if router == nil {
+			return base
+		}
+		return filepath.Join(base, router(repoPath))
+	}
+
+	return g.download(ctx)
+}
+
+// downloadTree downloads the contents like download and saves them beneath
+// the directory of the tree directory named after the SHA of the repository
+// tree at the ref, so the same tree is always saved to the same directory.
+func (g *GitHub) downloadTree(ctx context.Context) (Result, error) {
+	if err := g.resolveLatest(ctx); err != nil {
+		return Result{}, err
+	}
+	if err := g.resolveDefault(ctx); err != nil {
+		return Result{}, err
+	}
+	tree, err := g.tree(ctx)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to resolve tree: %w", g.notFound(ctx, g.Owner, g.Repo, g.ref(), err))
+	}
+
+	dir := g.opts.treeDir
+	defer func() {
+		g.opts.treeDir = dir
+	}()
+	g.opts.treeDir = ""
+
+	return g.downloadTo(ctx, filepath.Join(dir, tree.GetSHA()))
+}
+
+// tip returns the commit SHA the branch, tag, or commit of the repository
+// points to.
+func (g *GitHub) tip(ctx context.Context, owner, repo, ref string) (string, error) {
+	if err := g.allowed(owner, repo); err != nil {
+		return "", err
+	}
+
+	sha, _, err := g.Client.GetCommitSHA1(ctx, owner, repo, ref, "")
+	if isStatus(err, http.StatusNotFound) || isStatus(err, http.StatusUnprocessableEntity) {
+		return "", fmt.Errorf("failed to resolve ref: %w", g.refNotFound(ctx, owner, repo, ref))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ref: %w", err)
+	}
+
+	return sha, nil
+}
+
+// attempt makes a single attempt to download the contents.
+func (g *GitHub) attempt(ctx context.Context) (Result, error) {
+	// The time spent waiting for the reset of a rate limit or before a retry
+	// does not count against the limit.
+	ctx, cancel := withDeadline(ctx, downloadLimit*time.Second)
+	defer cancel()
+
+	g.warnings = &warnings{log: logger(g.opts)}
+	if err := g.resolveLatest(ctx); err != nil {
+		return Result{}, err
+	}
+	if err := g.resolveDefault(ctx); err != nil {
+		return Result{}, err
+	}
+	if g.opts.repoConfig && g.gist == nil {
+		// The defaults of the repository only apply to this download.
+		opts := g.opts
 		defer func() {
-			wg.Wait()
-			close(errCh)
+			g.opts = opts
 		}()
-	}()
+		if err := g.applyRepoConfig(ctx); err != nil {
+			return Result{}, err
+		}
+	}
 
-	select {
-	case err := <-errCh:
-		if err != nil {
-			return fmt.Errorf("failed to download: %w", err)
+	var commit string
+	if g.opts.resolveCommit || (g.opts.manifest != "" && g.gist == nil) {
+		var err error
+		if commit, err = g.resolveCommit(ctx); err != nil {
+			return Result{}, err
 		}
-	case <-ctx.Done():
-		if errors.Is(ctx.Err(), context.Canceled) {
-			return context.Canceled
+	}
+
+	var files []*github.RepositoryContent
+	var err error
+	switch {
+	case g.opts.dryRun:
+		files, err = g.listDry(ctx)
+	case g.opts.coalesce && g.pull == nil && g.gist == nil:
+		files, err = g.archive(ctx)
+	default:
+		files, err = g.listAndFetch(ctx)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	sortTree(files)
+
+	if to, ok := g.opts.moves.take(g.Owner + "/" + g.Repo); ok {
+		g.warnings.add(WarnRepoMoved, to, "Repository moved from "+g.Owner+"/"+g.Repo)
+	}
+
+	if g.opts.dryRun {
+		w := &written{}
+		for _, file := range files {
+			fmt.Printf("Would download: %s (%d bytes)\n", file.GetPath(), file.GetSize())
+			w.add(relPath(g.opts, g.Path, file.GetPath(), false), int64(file.GetSize()))
+		}
+		return Result{
+			Manifest:       newManifest(g.ref(), commit, files),
+			Warnings:       g.warnings.all(),
+			ResolvedCommit: commit,
+			Summary:        newSummary(w, g.warnings.all()),
+		}, nil
+	}
+
+	// The files are collected across attempts, so the files saved by an
+	// earlier attempt are reported as well.
+	if g.opts.lineEndings != nil {
+		for _, path := range g.opts.lineEndings.all() {
+			g.warnings.add(WarnMixedLineEndings, path, "Mixed line endings")
+		}
+	}
+	if g.opts.existing != nil {
+		for _, path := range g.opts.existing.all() {
+			g.warnings.add(WarnExistingSkipped, path, "Skipping existing file")
+		}
+	}
+
+	if g.opts.checksums != "" {
+		if err := g.verifyChecksums(files); err != nil {
+			return Result{}, fmt.Errorf("failed to verify checksums: %w", err)
+		}
+	}
+
+	if g.opts.index && g.opts.records == nil && !g.single(files) {
+		if err := writeIndexes(g.opts, g.Path, files); err != nil {
+			return Result{}, fmt.Errorf("failed to write index: %w", err)
+		}
+	}
+
+	if g.opts.dependencies != nil && g.opts.records == nil {
+		if err := g.includes(ctx, files); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if g.opts.fileList != nil {
+		if err := writeFileList(g.opts.fileList, g.opts, g.Path, files); err != nil {
+			return Result{}, fmt.Errorf("failed to write file list: %w", err)
+		}
+	}
+
+	if g.opts.recipe && g.opts.records == nil && !g.single(files) {
+		if err := g.applyRecipe(files); err != nil {
+			return Result{}, fmt.Errorf("failed to apply recipe: %w", err)
+		}
+	}
+
+	if g.opts.extractArchives && g.opts.records == nil {
+		if err := g.extractArchives(files); err != nil {
+			return Result{}, fmt.Errorf("failed to extract archive: %w", err)
+		}
+	}
+
+	if g.opts.provenance != "" {
+		if err := g.writeProvenance(ctx, files); err != nil {
+			return Result{}, fmt.Errorf("failed to write provenance: %w", err)
+		}
+	}
+
+	if g.opts.xattrProvenance && g.opts.records == nil {
+		if err := g.writeXattrs(ctx, commit, files); err != nil {
+			return Result{}, fmt.Errorf("failed to write extended attributes: %w", err)
+		}
+	}
+
+	manifest := newManifest(g.ref(), commit, files)
+	if g.opts.manifest != "" {
+		if err := writeManifest(g.opts.manifest, manifest); err != nil {
+			return Result{}, fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+
+	return Result{
+		Manifest:       manifest,
+		Warnings:       g.warnings.all(),
+		ResolvedCommit: commit,
+		Summary:        newSummary(g.opts.written, g.warnings.all()),
+	}, nil
+}
+
+// single reports whether the files are the single file of a file URL.
+func (g *GitHub) single(files []*github.RepositoryContent) bool {
+	return len(files) == 1 && files[0].GetPath() == g.Path
+}
+
+// resolveLatest replaces the latest release shorthand ref with the tag of the
+// latest release of the repository.
+func (g *GitHub) resolveLatest(ctx context.Context) error {
+	if g.ref() != latestRef {
+		return nil
+	}
+
+	release, _, err := g.Client.GetLatestRelease(ctx, g.Owner, g.Repo)
+	if err != nil {
+		return fmt.Errorf("failed to resolve latest release: %w", err)
+	}
+	g.Ref = &github.RepositoryContentGetOptions{Ref: release.GetTagName()}
+
+	return nil
+}
+
+// resolveDefault replaces the ref of a bare repository URL with the default
+// branch of the repository.
+func (g *GitHub) resolveDefault(ctx context.Context) error {
+	if g.ref() != defaultRef {
+		return nil
+	}
+
+	repo, _, err := g.Client.GetRepository(ctx, g.Owner, g.Repo)
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			err = fmt.Errorf("%w: %s/%s: %w", ErrRepoNotFound, g.Owner, g.Repo, err)
+		}
+		return fmt.Errorf("failed to resolve default branch: %w", err)
+	}
+	g.Ref = &github.RepositoryContentGetOptions{Ref: repo.GetDefaultBranch()}
+
+	return nil
+}
+
+// resolveCommit returns the SHA of the commit the ref of the repository points
+// to, or of the default branch if no ref is set.
+func (g *GitHub) resolveCommit(ctx context.Context) (string, error) {
+	ref := g.ref()
+	if ref == "" {
+		ref = headRef
+	}
+	sha, _, err := g.Client.GetCommitSHA1(ctx, g.Owner, g.Repo, ref, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve commit: %w", err)
+	}
+
+	return sha, nil
+}
+
+// listAndFetch lists the contents of the GitHub path and downloads its files.
+// If a checkpoint file is set, an interrupted download is resumed from it.
+func (g *GitHub) listAndFetch(ctx context.Context) ([]*github.RepositoryContent, error) {
+	var files, links []*github.RepositoryContent
+	resumed := false
+	if g.opts.checkpoint != "" {
+		var err error
+		if files, links, resumed, err = g.resume(); err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+	}
+	if !resumed {
+		var err error
+		if files, links, err = g.listFiles(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if g.opts.checkpoint != "" {
+		var err error
+		if g.checkpoint, err = g.newCheckpoint(files, links); err != nil {
+			return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+	}
+
+	if g.opts.resume && g.opts.records == nil {
+		if err := g.skipPresent(files); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.fetch(ctx, files); err != nil {
+		return nil, err
+	}
+
+	if err := g.materialize(ctx, links, files); err != nil {
+		return nil, err
+	}
+
+	if err := g.checkpoint.remove(); err != nil {
+		return nil, fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	g.checkpoint = nil
+
+	return files, nil
+}
+
+// listDry lists the files and symlinks of the GitHub path like listFiles,
+// without downloading or saving them.
+func (g *GitHub) listDry(ctx context.Context) ([]*github.RepositoryContent, error) {
+	files, links, err := g.listFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, links...), nil
+}
+
+// listFiles lists the files and symlinks of the GitHub path to download.
+func (g *GitHub) listFiles(ctx context.Context) (files, links []*github.RepositoryContent, err error) {
+	var entries []*github.RepositoryContent
+	switch {
+	case g.pull != nil:
+		entries, err = g.listPull(ctx)
+	case g.gist != nil:
+		entries, err = g.listGist(ctx)
+	case g.file:
+		entries, err = g.listFile(ctx)
+	case g.opts.prefixMatch:
+		entries, err = g.listPrefix(ctx, g.Path)
+	default:
+		entries, err = g.list(ctx, g.Path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if files, links, err = g.filterEntries(ctx, entries); err != nil {
+		return nil, nil, err
+	}
+	if err := g.checkMinFiles(files, links); err != nil {
+		return nil, nil, err
+	}
+
+	return files, links, nil
+}
+
+// filterEntries applies the filters and checks of the options to the files
+// and symlinks listed beneath the GitHub path, and returns the ones to
+// download.
+func (g *GitHub) filterEntries(ctx context.Context, entries []*github.RepositoryContent) (files, links []*github.RepositoryContent, err error) {
+	if g.opts.exportIgnore {
+		if entries, err = g.exportIgnore(ctx, entries); err != nil {
+			return nil, nil, err
+		}
+	}
+	if g.opts.codeOwner != "" {
+		if entries, err = g.codeOwners(ctx, entries); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !g.file && (g.opts.include != nil || g.opts.exclude != nil || g.opts.ignore != nil || g.glob != "") {
+		entries = g.filterGlobs(entries)
+	}
+	if !g.file && g.opts.depthLimit {
+		entries = g.filterDepth(entries)
+	}
+	if g.opts.stripTopLevel != nil {
+		entries = stripTopLevel(g.opts.stripTopLevel, entries)
+	}
+	files, links = splitLinks(entries)
+	if g.opts.skipEmpty {
+		files = skipEmpty(g.warnings, files)
+	}
+	if g.opts.maxFileBytes > 0 {
+		if files, err = checkFileSizes(g.opts, g.warnings, files); err != nil {
+			return nil, nil, err
+		}
+	}
+	if g.opts.selection != nil {
+		if files, links, err = g.selectFiles(files, links); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := g.opts.flat.assign(files, links); err != nil {
+		return nil, nil, err
+	}
+	// The names are checked before any file is saved, so a download does not
+	// fail halfway.
+	if g.opts.windowsNames {
+		if err := g.checkNames(files, links); err != nil {
+			return nil, nil, err
+		}
+	}
+	if g.opts.caseInsensitiveFS {
+		if files, links, err = g.caseCollisions(files, links); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if g.opts.maxTotalBytes > 0 {
+		if err := checkTotalSize(g.opts, files); err != nil {
+			return nil, nil, err
+		}
+	}
+	if g.opts.checkSpace {
+		if err := checkSpace(g.opts, baseDir(g.opts, g.Path), files); err != nil {
+			return nil, nil, err
 		}
-		return ErrTookTooLong
 	}
 
+	return files, links, nil
+}
+
+// checkMinFiles returns ErrTooFewFiles if fewer files and symlinks than the
+// minimum number of files are to be downloaded.
+func (g *GitHub) checkMinFiles(files, links []*github.RepositoryContent) error {
+	if n := len(files) + len(links); n < g.opts.minFiles {
+		return fmt.Errorf("%w: %d of at least %d", ErrTooFewFiles, n, g.opts.minFiles)
+	}
 	return nil
 }
 
-// contents retrieves the contents of the GitHub directory path. It recursively
-// collects subdirectories, if any. It downloads files concurrently.
-func (g *GitHub) contents(ctx context.Context, wg *sync.WaitGroup, path string, errCh chan error) {
+// listFile returns the file or symlink of the GitHub path, without walking
+// it. It returns ErrNotFile if the GitHub path is a directory.
+func (g *GitHub) listFile(ctx context.Context) ([]*github.RepositoryContent, error) {
+	file, _, _, err := g.Client.GetContents(ctx, g.Owner, g.Repo, g.Path, g.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", g.notFound(ctx, g.Owner, g.Repo, g.ref(), err))
+	}
+	if file == nil || (file.GetType() != "file" && file.GetType() != "symlink") {
+		return nil, fmt.Errorf("failed to download: %w: %s", ErrNotFile, g.Path)
+	}
+
+	return []*github.RepositoryContent{file}, nil
+}
+
+// list returns all files and symlinks beneath the GitHub path, from the
+// cached repository tree if enabled.
+func (g *GitHub) list(ctx context.Context, path string) ([]*github.RepositoryContent, error) {
+	if g.trees != nil {
+		return g.listTree(ctx, path)
+	}
+	files, err := g.walk(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	g.treeModes(ctx, path)
+	return files, nil
+}
+
+// walk walks the GitHub paths and returns all files and symlinks beneath them.
+func (g *GitHub) walk(ctx context.Context, paths ...string) ([]*github.RepositoryContent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	errCh := make(chan error, 1)
+	filesCh := make(chan *github.RepositoryContent)
+
+	for _, path := range paths {
+		wg.Add(1)
+		go g.contents(ctx, wg, path, filesCh, errCh)
+	}
+
+	go func() {
+		wg.Wait()
+		close(filesCh)
+	}()
+
+	var files []*github.RepositoryContent
+	for {
+		select {
+		case file, ok := <-filesCh:
+			if !ok {
+				return files, nil
+			}
+			files = append(files, file)
+		case err := <-errCh:
+			if ctx.Err() != nil {
+				return nil, ctxErr(ctx)
+			}
+			return nil, fmt.Errorf("failed to download: %w", g.notFound(ctx, g.Owner, g.Repo, g.ref(), err))
+		case <-ctx.Done():
+			return nil, ctxErr(ctx)
+		}
+	}
+}
+
+// contents retrieves the contents of the GitHub directory path and sends its
+// files and symlinks to filesCh. It recursively collects subdirectories concurrently.
+func (g *GitHub) contents(ctx context.Context, wg *sync.WaitGroup, path string, filesCh chan<- *github.RepositoryContent, errCh chan error) {
 	defer wg.Done()
 
 	fileContent, directoryContent, _, err := g.Client.GetContents(ctx, g.Owner, g.Repo, path, g.Ref)
 	if err != nil {
-		errCh <- err
+		sendErr(errCh, err)
 		return
 	}
 
-	// If the URL points to a file, only the file is downloaded.
+	// If the URL points to a file, only the file is collected.
 	if len(directoryContent) == 0 && fileContent != nil {
-		if err := g.getFile(fileContent.GetDownloadURL(), fileContent.GetPath()); err != nil {
-			errCh <- err
+		if submodule(fileContent) {
+			g.warnings.add(WarnSubmoduleSkipped, fileContent.GetPath(), "Skipping submodule")
 			return
 		}
+		sendFile(ctx, filesCh, fileContent)
 		return
 	}
 
 	// Collect all subcontents of subdirectories.
 	for _, content := range directoryContent {
+		if submodule(content) {
+			g.warnings.add(WarnSubmoduleSkipped, content.GetPath(), "Skipping submodule")
+			continue
+		}
+		switch content.GetType() {
+		case "file", "symlink":
+			sendFile(ctx, filesCh, content)
+		case "dir":
+			if g.excluded(content.GetPath()) || g.ignored(content.GetPath(), true) || !g.withinDepth(content.GetPath(), true) || !g.globbed(content.GetPath(), true) {
+				continue
+			}
+			// Recursively get the files of the content.
+			wg.Add(1)
+			go g.contents(ctx, wg, content.GetPath(), filesCh, errCh)
+		}
+	}
+}
+
+// submodule reports whether the content is a submodule. The contents API
+// lists the submodules of a directory as files without a download URL, whose
+// Git URL refers to the tree of the submodule commit.
+func submodule(content *github.RepositoryContent) bool {
+	if content.GetType() == "submodule" {
+		return true
+	}
+	return content.GetType() == "file" && content.GetDownloadURL() == "" && strings.Contains(content.GetGitURL(), "/git/trees/")
+}
+
+// fetch downloads the given files concurrently. The first error cancels the
+// downloads still in progress or waiting. If the file retries are set, the
+// files failed with a transient error are instead retried one by one once
+// the others are downloaded.
+func (g *GitHub) fetch(ctx context.Context, files []*github.RepositoryContent) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	errCh := make(chan error, 1)
+
+	sem := g.opts.pool
+	if sem == nil && g.opts.concurrency > 0 {
+		sem = make(chan struct{}, g.opts.concurrency)
+	}
+
+	var pending []*github.RepositoryContent
+	for _, file := range files {
+		if !g.completed.has(file.GetPath()) {
+			pending = append(pending, file)
+		}
+	}
+	// Files with the same blob SHA as another file are linked to it once it
+	// is downloaded. Gzipped or recorded files are not saved as they are
+	// downloaded, so they are never linked.
+	var dups []duplicate
+	if g.opts.hardlinkDuplicates && !g.opts.gzip && g.opts.records == nil {
+		pending, dups = splitDuplicates(pending)
+	}
+	g.opts.blobs.add(pending)
+	cp := g.checkpoint
+	// The meter is set on a copy of the options, so files saved after the
+	// fetch, such as symlinks, are not counted.
+	o := g.opts
+	if o.progress != nil {
+		o.meter = newMeter(o, estimateSize(pending), len(pending)+len(dups))
+	}
+
+	getOne := func(ctx context.Context, file *github.RepositoryContent) error {
+		get := g.getFileWith
+		if size := int64(file.GetSize()); o.chunkSize > 0 && size > o.chunkSize {
+			get = func(ctx context.Context, o options, url, path string) error {
+				return g.getChunked(ctx, o, url, path, size)
+			}
+		}
+		logger(o).Debug("downloading file", "path", file.GetPath(), "size", file.GetSize())
+		fileCtx := ctx
+		if o.fileTimeout > 0 {
+			var cancel context.CancelFunc
+			fileCtx, cancel = context.WithTimeout(ctx, o.fileTimeout)
+			defer cancel()
+		}
+		if err := get(fileCtx, o, file.GetDownloadURL(), file.GetPath()); err != nil {
+			// Only the file timed out if the download goes on.
+			if o.fileTimeout > 0 && ctx.Err() == nil && errors.Is(fileCtx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w: %s after %s", ErrFileTimeout, file.GetPath(), o.fileTimeout)
+			}
+			return err
+		}
+		logger(o).Debug("downloaded file", "path", file.GetPath(), "size", file.GetSize())
+		return nil
+	}
+	done := func(file *github.RepositoryContent) error {
+		g.completed.add(file.GetPath())
+		o.meter.done(file.GetPath())
+		if err := cp.done(file.GetPath()); err != nil {
+			return fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		return nil
+	}
+
+	// The files failed with a transient error are retried after the others,
+	// if the file retries are set.
+	var mu sync.Mutex
+	var failed []*github.RepositoryContent
+	for _, file := range pending {
 		wg.Add(1)
-		go func(content *github.RepositoryContent) {
+		go func() {
 			defer wg.Done()
-			switch content.GetType() {
-			case "file":
-				// Download the file directly.
-				if err := g.getFile(content.GetDownloadURL(), content.GetPath()); err != nil {
-					errCh <- err
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
 					return
 				}
-			case "dir":
-				// Recursively get the files of the content.
-				wg.Add(1)
-				go g.contents(ctx, wg, content.GetPath(), errCh)
 			}
-		}(content)
+			err := getOne(ctx, file)
+			if err != nil && o.fileRetries > 0 && transient(err) && ctx.Err() == nil {
+				fmt.Println("Retrying later:", file.GetPath(), err)
+				logger(o).Warn("retrying file later", "path", file.GetPath(), "error", err)
+				mu.Lock()
+				failed = append(failed, file)
+				mu.Unlock()
+				return
+			}
+			if err != nil {
+				sendErr(errCh, err)
+				return
+			}
+			if err := done(file); err != nil {
+				sendErr(errCh, err)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed to download: %w", err)
+		}
+	case <-ctx.Done():
+		return ctxErr(ctx)
+	}
+
+	sortTree(failed)
+	for _, file := range failed {
+		if err := retryFile(ctx, o, func() error { return getOne(ctx, file) }); err != nil {
+			return fmt.Errorf("failed to download: %w", err)
+		}
+		if err := done(file); err != nil {
+			return fmt.Errorf("failed to download: %w", err)
+		}
+	}
+	for _, dup := range dups {
+		if err := g.linkFile(ctx, dup.src, dup.dst); err != nil {
+			return fmt.Errorf("failed to download: %w", err)
+		}
+		if err := done(dup.dst); err != nil {
+			return fmt.Errorf("failed to download: %w", err)
+		}
+	}
+	if o.meter != nil {
+		o.meter.finish()
+	}
+
+	return nil
+}
+
+// retryFile calls get up to the number of file retries with exponential
+// backoff, until it succeeds or fails with an error that is not transient.
+func retryFile(ctx context.Context, o options, get func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, o.backoff<<attempt); err != nil {
+			return err
+		}
+		err := get()
+		if err == nil || attempt+1 >= o.fileRetries || !transient(err) {
+			return err
+		}
+		fmt.Println("Retrying download:", err)
+		logger(o).Warn("retrying file", "attempt", attempt+1, "error", err)
 	}
 }
 
+// includes downloads the files referenced by the dependencies hook of the
+// given downloaded files, transitively. Each path is downloaded at most once,
+// which also guards against reference cycles.
+func (g *GitHub) includes(ctx context.Context, files []*github.RepositoryContent) error {
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		seen[file.GetPath()] = true
+	}
+	listed := make(map[string]bool)
+
+	for len(files) > 0 {
+		var next []*github.RepositoryContent
+		for _, file := range files {
+			deps, err := g.dependencies(file.GetPath())
+			if err != nil {
+				return fmt.Errorf("failed to resolve dependencies: %w", err)
+			}
+
+			for _, dep := range deps {
+				dep = filepath.ToSlash(filepath.Clean(dep))
+				if seen[dep] || listed[dep] {
+					continue
+				}
+				listed[dep] = true
+
+				entries, err := g.list(ctx, dep)
+				if err != nil {
+					return err
+				}
+				found, _ := splitLinks(entries)
+				for _, f := range found {
+					if !seen[f.GetPath()] {
+						seen[f.GetPath()] = true
+						next = append(next, f)
+					}
+				}
+			}
+		}
+
+		if err := g.fetch(ctx, next); err != nil {
+			return err
+		}
+		files = next
+	}
+
+	return nil
+}
+
+// dependencies reads the saved file at the repository path and returns the
+// repository paths it references.
+func (g *GitHub) dependencies(path string) ([]string, error) {
+	p, err := localPath(g.opts, g.Path, path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.opts.dependencies(path, content)
+}
+
+// notFound distinguishes a missing repository, ref, or path when err is a
+// not found response of a contents request. It returns ErrRepoNotFound if the
+// repository does not exist, ErrRefNotFound if ref does not resolve to a
+// commit, as a RefNotFoundError, ErrPathNotFound otherwise. Other errors are returned unchanged.
+func (g *GitHub) notFound(ctx context.Context, owner, repo, ref string, err error) error {
+	if !isStatus(err, http.StatusNotFound) {
+		return err
+	}
+
+	// The ref of a missing repository does not resolve either, so the
+	// repository is only looked up then.
+	if ref != "" {
+		_, _, errRef := g.Client.GetCommitSHA1(ctx, owner, repo, ref, "")
+		if isStatus(errRef, http.StatusNotFound) {
+			if _, _, errRepo := g.Client.GetRepository(ctx, owner, repo); isStatus(errRepo, http.StatusNotFound) {
+				return fmt.Errorf("%w: %s/%s: %w", ErrRepoNotFound, owner, repo, errRepo)
+			}
+		}
+		if isStatus(errRef, http.StatusNotFound) || isStatus(errRef, http.StatusUnprocessableEntity) {
+			return g.refNotFound(ctx, owner, repo, ref)
+		}
+	}
+
+	return fmt.Errorf("%w: %w", ErrPathNotFound, err)
+}
+
+// isStatus reports whether err is a GitHub error response or an HTTPError
+// with the status code.
+func isStatus(err error, code int) bool {
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.Response != nil && errResp.Response.StatusCode == code
+	}
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == code
+}
+
+// sendFile sends the file to filesCh unless ctx is done.
+func sendFile(ctx context.Context, filesCh chan<- *github.RepositoryContent, file *github.RepositoryContent) {
+	select {
+	case filesCh <- file:
+	case <-ctx.Done():
+	}
+}
+
+// sendErr sends err to errCh unless an error is already pending.
+func sendErr(errCh chan<- error, err error) {
+	select {
+	case errCh <- err:
+	default:
+	}
+}
+
+// ctxErr returns the error reported when ctx is done.
+func ctxErr(ctx context.Context) error {
+	if errors.Is(context.Cause(ctx), context.Canceled) {
+		return context.Canceled
+	}
+	return ErrTookTooLong
+}
+
 // getFile retrieves a file from the given URL and saves it.
-func (g *GitHub) getFile(url, path string) error {
+func (g *GitHub) getFile(ctx context.Context, url, path string) error {
+	return g.getFileWith(ctx, g.opts, url, path)
+}
+
+// getFileWith retrieves a file like getFile and saves it with the given options.
+func (g *GitHub) getFileWith(ctx context.Context, o options, url, path string) error {
 	if url == "" || path == "" {
 		return ErrInvalidPathURL
 	}
 	fmt.Println("Downloading:", path)
+	// Gzipped or recorded files are not saved at their local paths, so their
+	// ETags are not cached.
+	if o.cacheDir != "" && !o.gzip && o.records == nil {
+		return g.getFileConditional(ctx, o, url, path)
+	}
 
-	resp, err := g.Client.Get(url)
+	resp, err := g.Client.Get(ctx, url)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	return saveFile(g.Path, path, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(resp)
+	}
+
+	return g.saveBody(ctx, o, path, resp.Body)
+}
+
+// fetchFile retrieves the content of a single file at the given ref
+// without saving it.
+func (g *GitHub) fetchFile(ctx context.Context, owner, repo, ref, path string) ([]byte, error) {
+	b, _, err := g.fetchTyped(ctx, owner, repo, ref, path)
+	return b, err
+}
+
+// fetchWithType returns the content and content type of the file at the
+// GitHub path.
+func (g *GitHub) fetchWithType(ctx context.Context) ([]byte, string, error) {
+	return g.fetchTyped(ctx, g.Owner, g.Repo, g.ref(), g.Path)
+}
+
+// fetchTyped returns the content of the file at the given path and ref along
+// with its content type. If the server omits the content type, it is detected
+// from the content.
+func (g *GitHub) fetchTyped(ctx context.Context, owner, repo, ref, path string) ([]byte, string, error) {
+	if err := g.allowed(owner, repo); err != nil {
+		return nil, "", err
+	}
+
+	opts := &github.RepositoryContentGetOptions{Ref: ref}
+	fileContent, _, _, err := g.Client.GetContents(ctx, owner, repo, path, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch file: %w", g.notFound(ctx, owner, repo, ref, err))
+	}
+	if fileContent == nil || fileContent.GetType() != "file" {
+		return nil, "", ErrNotFile
+	}
+
+	resp, err := g.Client.Get(ctx, fileContent.GetDownloadURL())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch file: %w", newHTTPError(resp))
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch file: %w", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(b)
+	}
+
+	return b, contentType, nil
+}
+
+// streamFile copies the content of the file at the GitHub path to w as it is
+// downloaded, without saving it. It returns ErrNotFile if the GitHub path is
+// a directory or a symlink.
+func (g *GitHub) streamFile(ctx context.Context, w io.Writer) error {
+	fileContent, _, _, err := g.Client.GetContents(ctx, g.Owner, g.Repo, g.Path, g.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to get file: %w", g.notFound(ctx, g.Owner, g.Repo, g.ref(), err))
+	}
+	if fileContent == nil || fileContent.GetType() != "file" {
+		return fmt.Errorf("%w: %s", ErrNotFile, g.Path)
+	}
+
+	resp, err := g.Client.Get(ctx, fileContent.GetDownloadURL())
+	if err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get file: %w", newHTTPError(resp))
+	}
+	if _, err := io.Copy(w, &ctxReader{ctx: ctx, r: resp.Body}); err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+
+	return nil
 }
 
 // status reports the status of the client, the remaining hourly
@@ -181,6 +1153,23 @@ func (g *GitHub) status(ctx context.Context) error {
 	return nil
 }
 
+// checkRateLimit returns ErrRateLimitedSuggestToken if the client is not
+// authenticated and fewer requests than the minimum remain in the rate limit.
+// This function does not reduce the rate limit.
+func (g *GitHub) checkRateLimit(ctx context.Context) error {
+	rate, _, err := g.Client.RateLimit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	if rate.Core.Limit > baseRateLimit || rate.Core.Remaining >= g.opts.minRateLimit {
+		return nil
+	}
+
+	reset := time.Until(rate.Core.Reset.Time).Minutes()
+	return fmt.Errorf("%w: %d requests remaining, reset in %.0f mins", ErrRateLimitedSuggestToken, rate.Core.Remaining, reset)
+}
+
 // auth reports the authenticated username, if applicable.
 // This function reduces the rate limit for each request.
 func (g *GitHub) auth(ctx context.Context) error {
@@ -3,12 +3,19 @@ package gitty
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -30,13 +37,28 @@ type mockSuccess struct{}
 type mockError struct{}
 
 type mockClient interface {
-	Get(url string) (resp *http.Response, err error)
+	Get(ctx context.Context, url string) (resp *http.Response, err error)
 	GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (fileContent *github.RepositoryContent, directoryContent []*github.RepositoryContent, resp *github.Response, err error)
 	RateLimit(ctx context.Context) (*github.RateLimits, *github.Response, error)
 	GetUser(ctx context.Context, user string) (*github.User, *github.Response, error)
+	GetArchiveLink(ctx context.Context, owner, repo string, archiveformat github.ArchiveFormat, opts *github.RepositoryContentGetOptions, maxRedirects int) (*url.URL, *github.Response, error)
+	GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error)
+	GetLatestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, *github.Response, error)
+	GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*github.Tree, *github.Response, error)
+	ListCommits(ctx context.Context, owner, repo string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error)
+	GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error)
+	ListPullRequestFiles(ctx context.Context, owner, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error)
+	GetRange(ctx context.Context, url string, offset, length int64) (resp *http.Response, err error)
+	GetIfNoneMatch(ctx context.Context, url, etag string) (resp *http.Response, err error)
+	ListBranches(ctx context.Context, owner, repo string, opts *github.BranchListOptions) ([]*github.Branch, *github.Response, error)
+	GetGist(ctx context.Context, id string) (*github.Gist, *github.Response, error)
+	GetGistRevision(ctx context.Context, id, sha string) (*github.Gist, *github.Response, error)
+	PostLFSBatch(ctx context.Context, url string, body io.Reader) (resp *http.Response, err error)
+	GetWithHeader(ctx context.Context, url string, header map[string]string) (resp *http.Response, err error)
 }
 
-func fakeRepository(c mockClient) Repository {
+func fakeRepository(c mockClient) *GitHub {
 	return &GitHub{
 		Client: c,
 		Owner:  "",
@@ -46,7 +68,178 @@ func fakeRepository(c mockClient) Repository {
 	}
 }
 
-func (m *mockSuccess) Get(_ string) (resp *http.Response, err error) {
+// serverRepository creates a GitHub repository whose client talks to an
+// httptest server serving the given handler.
+func serverRepository(t *testing.T, h http.Handler) *GitHub {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c := github.NewClient(nil)
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+
+	return repository(c, options{})
+}
+
+// commitMux serves a file and a directory at a single commit SHA.
+func commitMux(sha string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != sha {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.PathValue("path") {
+		case "dir/file.txt":
+			fmt.Fprintf(w, `{"type":"file","path":"dir/file.txt","download_url":"http://%s/raw/%s/dir/file.txt"}`, r.Host, sha)
+		case "dir":
+			fmt.Fprintf(w, `[{"type":"file","path":"dir/file.txt","download_url":"http://%s/raw/%s/dir/file.txt"}]`, r.Host, sha)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("GET /raw/{sha}/dir/file.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "content at %s", r.PathValue("sha"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"default_branch":"main"}`)
+	})
+	return mux
+}
+
+// contentsMux serves the contents API and raw downloads of the given files,
+// keyed by repository path.
+func contentsMux(files map[string]string) *http.ServeMux {
+	return linksMux(files, nil)
+}
+
+// linksMux serves the contents API and raw downloads of the given files and
+// symlinks to their targets, keyed by repository path.
+func linksMux(files, links map[string]string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		p := r.PathValue("path")
+		raw := "http://" + r.Host + "/raw/"
+		if r.TLS != nil {
+			raw = "https://" + r.Host + "/raw/"
+		}
+		if content, ok := files[p]; ok {
+			_ = json.NewEncoder(w).Encode(fileContent(raw, p, content))
+			return
+		}
+
+		prefix := p + "/"
+		if p == "" {
+			prefix = ""
+		}
+		dirs := map[string]bool{}
+		entries := []*github.RepositoryContent{}
+		add := func(fp string, entry *github.RepositoryContent) {
+			rest, ok := strings.CutPrefix(fp, prefix)
+			if !ok {
+				return
+			}
+			if dir, _, found := strings.Cut(rest, "/"); found {
+				if !dirs[dir] {
+					dirs[dir] = true
+					entries = append(entries, &github.RepositoryContent{Type: ptr("dir"), Path: ptr(prefix + dir)})
+				}
+				return
+			}
+			entries = append(entries, entry)
+		}
+		for fp, content := range files {
+			add(fp, fileContent(raw, fp, content))
+		}
+		for fp := range links {
+			add(fp, &github.RepositoryContent{Type: ptr("symlink"), Path: ptr(fp), DownloadURL: ptr(raw + fp)})
+		}
+		if len(entries) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].GetPath() < entries[j].GetPath()
+		})
+		_ = json.NewEncoder(w).Encode(entries)
+	})
+	mux.HandleFunc("GET /raw/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.PathValue("path")]
+		if !ok {
+			content, ok = links[r.PathValue("path")]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	})
+	return mux
+}
+
+// fileContent creates the contents API entry of a file served under raw.
+func fileContent(raw, path, content string) *github.RepositoryContent {
+	return &github.RepositoryContent{
+		Type:        ptr("file"),
+		Path:        ptr(path),
+		Size:        ptr(len(content)),
+		DownloadURL: ptr(raw + path),
+	}
+}
+
+func (m *mockSuccess) Get(_ context.Context, _ string) (resp *http.Response, err error) {
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte("test data"))),
+	}
+	return
+}
+
+func (m *mockError) Get(_ context.Context, _ string) (resp *http.Response, err error) {
+	return &http.Response{}, errMockGet
+}
+
+func (m *mockSuccess) GetRange(_ context.Context, _ string, _, _ int64) (resp *http.Response, err error) {
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte("test data"))),
+	}
+	return
+}
+
+func (m *mockError) GetRange(_ context.Context, _ string, _, _ int64) (resp *http.Response, err error) {
+	return &http.Response{}, errMockGet
+}
+
+func (m *mockSuccess) GetIfNoneMatch(_ context.Context, _, _ string) (resp *http.Response, err error) {
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte("test data"))),
+	}
+	return
+}
+
+func (m *mockError) GetIfNoneMatch(_ context.Context, _, _ string) (resp *http.Response, err error) {
+	return &http.Response{}, errMockGet
+}
+
+var errMockLFS = errors.New("mock lfs error")
+
+func (m *mockSuccess) PostLFSBatch(_ context.Context, _ string, _ io.Reader) (resp *http.Response, err error) {
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"objects":[]}`))),
+	}
+	return
+}
+
+func (m *mockError) PostLFSBatch(_ context.Context, _ string, _ io.Reader) (resp *http.Response, err error) {
+	return &http.Response{}, errMockLFS
+}
+
+func (m *mockSuccess) GetWithHeader(_ context.Context, _ string, _ map[string]string) (resp *http.Response, err error) {
 	resp = &http.Response{
 		StatusCode: http.StatusOK,
 		Body:       io.NopCloser(bytes.NewReader([]byte("test data"))),
@@ -54,7 +247,7 @@ func (m *mockSuccess) Get(_ string) (resp *http.Response, err error) {
 	return
 }
 
-func (m *mockError) Get(_ string) (resp *http.Response, err error) {
+func (m *mockError) GetWithHeader(_ context.Context, _ string, _ map[string]string) (resp *http.Response, err error) {
 	return &http.Response{}, errMockGet
 }
 
@@ -160,18 +353,129 @@ func (m *mockError) GetUser(_ context.Context, _ string) (*github.User, *github.
 	return nil, nil, errMockGetUser
 }
 
+var errMockArchiveLink = errors.New("mock archive link error")
+
+func (m *mockSuccess) GetArchiveLink(_ context.Context, _, _ string, _ github.ArchiveFormat, _ *github.RepositoryContentGetOptions, _ int) (*url.URL, *github.Response, error) {
+	return &url.URL{Scheme: "https", Host: "codeload.github.com", Path: "/owner/repo/legacy.tar.gz/main"}, nil, nil
+}
+
+func (m *mockError) GetArchiveLink(_ context.Context, _, _ string, _ github.ArchiveFormat, _ *github.RepositoryContentGetOptions, _ int) (*url.URL, *github.Response, error) {
+	return nil, nil, errMockArchiveLink
+}
+
+var errMockCommitSHA1 = errors.New("mock commit sha1 error")
+
+func (m *mockSuccess) GetCommitSHA1(_ context.Context, _, _, _, _ string) (string, *github.Response, error) {
+	return "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678", nil, nil
+}
+
+func (m *mockError) GetCommitSHA1(_ context.Context, _, _, _, _ string) (string, *github.Response, error) {
+	return "", nil, errMockCommitSHA1
+}
+
+var errMockLatestRelease = errors.New("mock latest release error")
+
+func (m *mockSuccess) GetLatestRelease(_ context.Context, _, _ string) (*github.RepositoryRelease, *github.Response, error) {
+	return &github.RepositoryRelease{TagName: ptr("v1.2.3")}, nil, nil
+}
+
+func (m *mockError) GetLatestRelease(_ context.Context, _, _ string) (*github.RepositoryRelease, *github.Response, error) {
+	return nil, nil, errMockLatestRelease
+}
+
+var errMockRepository = errors.New("mock repository error")
+
+func (m *mockSuccess) GetRepository(_ context.Context, _, _ string) (*github.Repository, *github.Response, error) {
+	return &github.Repository{DefaultBranch: ptr("main")}, nil, nil
+}
+
+func (m *mockError) GetRepository(_ context.Context, _, _ string) (*github.Repository, *github.Response, error) {
+	return nil, nil, errMockRepository
+}
+
+var errMockTree = errors.New("mock tree error")
+
+func (m *mockSuccess) GetTree(_ context.Context, _, _, _ string, _ bool) (*github.Tree, *github.Response, error) {
+	return &github.Tree{Entries: []*github.TreeEntry{{Type: ptr("blob"), Path: ptr("file.txt")}}}, nil, nil
+}
+
+func (m *mockError) GetTree(_ context.Context, _, _, _ string, _ bool) (*github.Tree, *github.Response, error) {
+	return nil, nil, errMockTree
+}
+
+var errMockListCommits = errors.New("mock list commits error")
+
+func (m *mockSuccess) ListCommits(_ context.Context, _, _ string, _ *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return []*github.RepositoryCommit{{SHA: ptr("a1b2c3d4e5f60718293a4b5c6d7e8f9012345678")}}, nil, nil
+}
+
+func (m *mockError) ListCommits(_ context.Context, _, _ string, _ *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return nil, nil, errMockListCommits
+}
+
+var errMockPullRequest = errors.New("mock pull request error")
+
+func (m *mockSuccess) GetPullRequest(_ context.Context, _, _ string, number int) (*github.PullRequest, *github.Response, error) {
+	return &github.PullRequest{Number: ptr(number), Head: &github.PullRequestBranch{SHA: ptr("a1b2c3d4e5f60718293a4b5c6d7e8f9012345678")}}, nil, nil
+}
+
+func (m *mockError) GetPullRequest(_ context.Context, _, _ string, _ int) (*github.PullRequest, *github.Response, error) {
+	return nil, nil, errMockPullRequest
+}
+
+var errMockPullRequestFiles = errors.New("mock pull request files error")
+
+func (m *mockSuccess) ListPullRequestFiles(_ context.Context, _, _ string, _ int, _ *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	return []*github.CommitFile{{Filename: ptr("file.txt"), Status: ptr("modified")}}, nil, nil
+}
+
+func (m *mockError) ListPullRequestFiles(_ context.Context, _, _ string, _ int, _ *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	return nil, nil, errMockPullRequestFiles
+}
+
+var errMockListBranches = errors.New("mock list branches error")
+
+func (m *mockSuccess) ListBranches(_ context.Context, _, _ string, _ *github.BranchListOptions) ([]*github.Branch, *github.Response, error) {
+	return []*github.Branch{{Name: ptr("main")}}, nil, nil
+}
+
+func (m *mockError) ListBranches(_ context.Context, _, _ string, _ *github.BranchListOptions) ([]*github.Branch, *github.Response, error) {
+	return nil, nil, errMockListBranches
+}
+
+var errMockGist = errors.New("mock gist error")
+
+func (m *mockSuccess) GetGist(_ context.Context, id string) (*github.Gist, *github.Response, error) {
+	return &github.Gist{ID: ptr(id)}, nil, nil
+}
+
+func (m *mockError) GetGist(_ context.Context, _ string) (*github.Gist, *github.Response, error) {
+	return nil, nil, errMockGist
+}
+
+func (m *mockSuccess) GetGistRevision(_ context.Context, id, _ string) (*github.Gist, *github.Response, error) {
+	return &github.Gist{ID: ptr(id)}, nil, nil
+}
+
+func (m *mockError) GetGistRevision(_ context.Context, _, _ string) (*github.Gist, *github.Response, error) {
+	return nil, nil, errMockGist
+}
+
 func TestRepository(t *testing.T) {
 	t.Parallel()
 	c := github.NewClient(nil)
-	actual := repository(c)
+	opts := options{checkSpace: true, minFreeSpace: 1}
+	actual := repository(c, opts)
 	expected := &GitHub{
 		Client: &service{
-			client: c,
+			client:  c,
+			storage: &http.Client{Timeout: defaultTimeout},
 		},
 		Owner: "",
 		Repo:  "",
 		Ref:   nil,
 		Path:  "",
+		opts:  opts,
 	}
 	assert.Equal(t, expected, actual)
 }
@@ -217,6 +521,18 @@ func TestExtract(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		{
+			name: "valid blob url",
+			url:  "https://github.com/owner/repo/blob/branch/directory/file.go",
+			expected: &GitHub{
+				Owner: "owner",
+				Repo:  "repo",
+				Ref:   &github.RepositoryContentGetOptions{Ref: "branch"},
+				Path:  "directory/file.go",
+				file:  true,
+			},
+			expectedErr: nil,
+		},
 		{
 			name: "invalid https url",
 			url:  "https://gitlab.com/owner/repo/tree/branch/directory",
@@ -244,14 +560,14 @@ func TestExtract(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			r, ok := fakeRepository(&mockSuccess{}).(*GitHub)
-			assert.True(t, ok)
+			r := fakeRepository(&mockSuccess{})
 			err := r.extract(test.url)
 			assert.Equal(t, test.expectedErr, err)
 			assert.Equal(t, test.expected.Owner, r.Owner)
 			assert.Equal(t, test.expected.Repo, r.Repo)
 			assert.Equal(t, test.expected.Ref, r.Ref)
 			assert.Equal(t, test.expected.Path, r.Path)
+			assert.Equal(t, test.expected.file, r.file)
 		})
 	}
 }
@@ -267,7 +583,7 @@ func TestGetFile(t *testing.T) {
 	})
 	tests := []struct {
 		name     string
-		repo     Repository
+		repo     *GitHub
 		url      string
 		path     string
 		expected error
@@ -298,7 +614,7 @@ func TestGetFile(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			err := test.repo.getFile(test.url, test.path)
+			err := test.repo.getFile(context.Background(), test.url, test.path)
 			assert.Equal(t, test.expected, err)
 		})
 	}
@@ -330,7 +646,7 @@ func TestDownloadContents(t *testing.T) {
 	}
 	tests := []struct {
 		name     string
-		repo     Repository
+		repo     *GitHub
 		path     string
 		ctx      context.Context
 		expected error
@@ -360,12 +676,27 @@ func TestDownloadContents(t *testing.T) {
 			ctx:      ctxCancel(),
 			expected: context.Canceled,
 		},
+		{
+			name: "error insufficient space",
+			repo: &GitHub{
+				Client: &mockSuccess{},
+				opts: options{
+					checkSpace:   true,
+					minFreeSpace: 1,
+					freeSpace: func(_ string) (uint64, error) {
+						return 0, nil
+					},
+				},
+			},
+			ctx:      ctxfakePath(),
+			expected: fmt.Errorf("%w: need %d bytes, %d bytes available", ErrInsufficientSpace, 1, 0),
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			err := test.repo.download(test.ctx)
+			_, err := test.repo.download(test.ctx)
 			assert.Equal(t, test.expected, err)
 		})
 	}
@@ -377,47 +708,37 @@ func TestContents(t *testing.T) {
 	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
 	fakeFirstPath := fmt.Sprintf("%s/%s_%d.txt", fakeBase, gofakeit.LoremIpsumWord(), gofakeit.Int())
 	fakeSecondPath := fmt.Sprintf("%s/%s_%d.txt", fakeBase, gofakeit.LoremIpsumWord(), gofakeit.Int())
-	t.Cleanup(func() {
-		err := os.RemoveAll(fakeBase)
-		require.NoError(t, err)
-	})
 	ctxfakePath := func() context.Context {
 		return context.WithValue(context.Background(), pathKey, contentsData(fakeFirstPath, fakeSecondPath))
 	}
 
 	tests := []struct {
-		name     string
-		repo     Repository
-		ctx      context.Context
-		path     string
-		expected error
+		name        string
+		repo        *GitHub
+		ctx         context.Context
+		path        string
+		expected    []string
+		expectedErr error
 	}{
 		{
 			name:     "successfully get contents with directories",
 			repo:     fakeRepository(&mockSuccess{}),
 			ctx:      ctxfakePath(),
 			path:     "directory",
-			expected: nil,
+			expected: []string{fakeFirstPath, fakeFirstPath, fakeSecondPath, fakeSecondPath},
 		},
 		{
 			name:     "successfully get contents file only",
 			repo:     fakeRepository(&mockSuccess{}),
 			ctx:      ctxfakePath(),
 			path:     testFileOnly,
-			expected: nil,
-		},
-		{
-			name:     "error contents",
-			repo:     fakeRepository(&mockError{}),
-			ctx:      context.Background(),
-			expected: errMockContents,
+			expected: []string{fakeFirstPath},
 		},
 		{
-			name:     "error downloading file",
-			path:     testContentFail,
-			repo:     fakeRepository(&mockError{}),
-			ctx:      context.Background(),
-			expected: ErrInvalidPathURL,
+			name:        "error contents",
+			repo:        fakeRepository(&mockError{}),
+			ctx:         context.Background(),
+			expectedErr: errMockContents,
 		},
 	}
 
@@ -426,17 +747,232 @@ func TestContents(t *testing.T) {
 			t.Parallel()
 			wg := &sync.WaitGroup{}
 			errCh := make(chan error, 1)
+			filesCh := make(chan *github.RepositoryContent)
 
 			wg.Add(1)
-			go test.repo.contents(test.ctx, wg, test.path, errCh)
+			go test.repo.contents(test.ctx, wg, test.path, filesCh, errCh)
 			go func() {
-				defer func() {
-					wg.Wait()
-					close(errCh)
-				}()
+				wg.Wait()
+				close(filesCh)
+				close(errCh)
 			}()
 
-			assert.Equal(t, test.expected, <-errCh)
+			var actual []string
+			for file := range filesCh {
+				actual = append(actual, file.GetPath())
+			}
+			assert.ElementsMatch(t, test.expected, actual)
+			assert.Equal(t, test.expectedErr, <-errCh)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+	r := fakeRepository(&mockSuccess{})
+
+	files, err := r.list(context.Background(), "directory")
+	require.NoError(t, err)
+	// The mock returns two files at the root and the same two files in "dir".
+	assert.Len(t, files, 4)
+}
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	fakePath := fmt.Sprintf("%s/%s_%d.txt", fakeBase, gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	ctxCancel := func() context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+
+	tests := []struct {
+		name     string
+		repo     *GitHub
+		ctx      context.Context
+		files    []*github.RepositoryContent
+		expected error
+	}{
+		{
+			name: "successfully fetch files",
+			repo: fakeRepository(&mockSuccess{}),
+			ctx:  context.Background(),
+			files: []*github.RepositoryContent{
+				{Path: ptr(fakePath), DownloadURL: ptr(gofakeit.URL())},
+			},
+			expected: nil,
+		},
+		{
+			name:     "error fetch file",
+			repo:     fakeRepository(&mockSuccess{}),
+			ctx:      context.Background(),
+			files:    testDownloadFailData(),
+			expected: fmt.Errorf("failed to download: %w", ErrInvalidPathURL),
+		},
+		{
+			name:     "error ctx cancel",
+			repo:     fakeRepository(&mockSuccess{}),
+			ctx:      ctxCancel(),
+			files:    nil,
+			expected: context.Canceled,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			err := test.repo.fetch(test.ctx, test.files)
+			assert.Equal(t, test.expected, err)
+		})
+	}
+}
+
+func TestFetchErrorCancels(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	var started, canceled atomic.Int32
+	r := serverRepository(t, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		// The other downloads only complete once canceled.
+		started.Add(1)
+		<-r.Context().Done()
+		canceled.Add(1)
+	}))
+	r.Path = fakeBase
+	r.opts.concurrency = 4
+
+	files := []*github.RepositoryContent{{Path: ptr(fakeBase + "/bad.txt")}}
+	for i := range 8 {
+		path := fmt.Sprintf("%s/file%d.txt", fakeBase, i)
+		files = append(files, &github.RepositoryContent{Path: ptr(path), DownloadURL: ptr(r.Client.(*service).client.BaseURL.String() + path)})
+	}
+	err := r.fetch(context.Background(), files)
+	require.ErrorIs(t, err, ErrInvalidPathURL)
+
+	assert.Eventually(t, func() bool {
+		return canceled.Load() == started.Load()
+	}, time.Second, 10*time.Millisecond)
+	assert.LessOrEqual(t, started.Load(), int32(4))
+}
+
+func TestFetchRetryFailedFiles(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{}
+	for i := range 6 {
+		files[fmt.Sprintf("%s/file%d.txt", fakeBase, i)] = fmt.Sprintf("content %d", i)
+	}
+	flaky := map[string]int{fakeBase + "/file1.txt": 1, fakeBase + "/file4.txt": 2}
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	var order []string
+	contents := contentsMux(files)
+	r := serverRepository(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path, ok := strings.CutPrefix(req.URL.Path, "/raw/")
+		if !ok {
+			contents.ServeHTTP(w, req)
+			return
+		}
+		mu.Lock()
+		requests[path]++
+		n := requests[path]
+		order = append(order, path)
+		mu.Unlock()
+		// A body shorter than its length fails with io.ErrUnexpectedEOF.
+		if n <= flaky[path] {
+			w.Header().Set("Content-Length", "100")
+			fmt.Fprint(w, "partial")
+			return
+		}
+		contents.ServeHTTP(w, req)
+	}))
+	r.opts.fileRetries = 2
+	r.opts.backoff = time.Millisecond
+	g := fakeNew(r)
+
+	require.NoError(t, g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase))
+
+	for path, content := range files {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+		assert.Equal(t, flaky[path]+1, requests[path], path)
+	}
+	// The failed files are retried after all the others were requested.
+	assert.Equal(t, []string{fakeBase + "/file1.txt", fakeBase + "/file4.txt", fakeBase + "/file4.txt"}, order[len(files):])
+
+	// A file failing more often than retried fails the download.
+	require.NoError(t, os.RemoveAll(fakeBase))
+	mu.Lock()
+	requests = map[string]int{}
+	order = nil
+	mu.Unlock()
+	r.opts.fileRetries = 1
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDownloadPerFileTimeout(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{"dir/fast.txt": "fast", "dir/slow.txt": "slow"}
+	tests := []struct {
+		name     string
+		hangs    int32
+		retries  int
+		expected error
+	}{
+		{name: "timeout", hangs: 1, expected: ErrFileTimeout},
+		{name: "retried", hangs: 1, retries: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var hung atomic.Int32
+			contents := contentsMux(files)
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /raw/dir/slow.txt", func(w http.ResponseWriter, r *http.Request) {
+				if hung.Add(1) > test.hangs {
+					contents.ServeHTTP(w, r)
+					return
+				}
+				// The file stalls after part of its content until the
+				// request is canceled.
+				fmt.Fprint(w, "sl")
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			})
+			mux.Handle("/", contents)
+			r := serverRepository(t, mux)
+			r.opts.fileTimeout = 50 * time.Millisecond
+			r.opts.fileRetries = test.retries
+			r.opts.backoff = time.Millisecond
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+			base := t.TempDir()
+
+			_, err := r.downloadTo(context.Background(), base)
+			if test.expected != nil {
+				require.ErrorIs(t, err, test.expected)
+				require.NotErrorIs(t, err, context.DeadlineExceeded)
+				require.NotErrorIs(t, err, ErrTookTooLong)
+				assert.NotContains(t, savedFiles(t, base), "dir/slow.txt")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/fast.txt", "dir/slow.txt"}, savedFiles(t, base))
 		})
 	}
 }
@@ -447,7 +983,7 @@ func TestClientStatus(t *testing.T) {
 	// This test also checks print outputs.
 	tests := []struct {
 		name        string
-		repo        Repository
+		repo        *GitHub
 		auth        bool
 		expected    string
 		expectedErr error
@@ -506,7 +1042,7 @@ func TestClientAuth(t *testing.T) {
 
 	tests := []struct {
 		name     string
-		repo     Repository
+		repo     *GitHub
 		expected error
 	}{
 		{
@@ -529,3 +1065,939 @@ func TestClientAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchFile(t *testing.T) {
+	t.Parallel()
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	r := serverRepository(t, commitMux(sha))
+
+	tests := []struct {
+		name        string
+		ref         string
+		path        string
+		expected    []byte
+		expectedErr error
+	}{
+		{
+			name:     "file at commit",
+			ref:      sha,
+			path:     "dir/file.txt",
+			expected: []byte("content at " + sha),
+		},
+		{
+			name:        "directory at commit",
+			ref:         sha,
+			path:        "dir",
+			expectedErr: ErrNotFile,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := r.fetchFile(context.Background(), "owner", "repo", test.ref, test.path)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, b)
+		})
+	}
+
+	t.Run("unknown commit", func(t *testing.T) {
+		t.Parallel()
+		_, err := r.fetchFile(context.Background(), "owner", "repo", "1234567", "dir/file.txt")
+		require.ErrorIs(t, err, ErrRefNotFound)
+	})
+}
+
+func TestIncludes(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	config, shared, other := fakeBase+"_config", fakeBase+"_shared", fakeBase+"_other"
+	t.Cleanup(func() {
+		for _, dir := range []string{config, shared, other} {
+			err := os.RemoveAll(dir)
+			require.NoError(t, err)
+		}
+	})
+
+	// a.conf includes the shared directory, whose b.conf includes c.conf,
+	// which includes a.conf again to form a cycle.
+	files := map[string]string{
+		"repo/" + config + "/a.conf":   "include repo/" + shared,
+		"repo/" + shared + "/b.conf":   "include repo/" + other + "/c.conf",
+		"repo/" + other + "/c.conf":    "include repo/" + config + "/a.conf",
+		"repo/" + other + "/unused.md": "not referenced",
+	}
+	var mu sync.Mutex
+	calls := map[string]int{}
+	deps := func(path string, content []byte) ([]string, error) {
+		mu.Lock()
+		calls[path]++
+		mu.Unlock()
+		dep, ok := strings.CutPrefix(string(content), "include ")
+		if !ok {
+			return nil, nil
+		}
+		return []string{dep}, nil
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.dependencies = deps
+	r.Owner, r.Repo = "owner", "repo"
+	r.Path = "repo/" + config
+
+	_, err := r.download(context.Background())
+	require.NoError(t, err)
+
+	for _, p := range []string{config + "/a.conf", shared + "/b.conf", other + "/c.conf"} {
+		_, err := os.Stat(p)
+		require.NoError(t, err)
+	}
+	_, err = os.Stat(other + "/unused.md")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	assert.Equal(t, map[string]int{
+		"repo/" + config + "/a.conf": 1,
+		"repo/" + shared + "/b.conf": 1,
+		"repo/" + other + "/c.conf":  1,
+	}, calls)
+}
+
+func TestIncludesError(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	err := saveFileMode(context.Background(), options{}, fakeBase, fakeBase+"/file.txt", 0o600, strings.NewReader("test data"))
+	require.NoError(t, err)
+
+	errMockDeps := errors.New("mock dependencies error")
+	r := &GitHub{
+		Client: &mockSuccess{},
+		Path:   fakeBase,
+		opts: options{
+			dependencies: func(_ string, _ []byte) ([]string, error) {
+				return nil, errMockDeps
+			},
+		},
+	}
+
+	err = r.includes(context.Background(), []*github.RepositoryContent{{Path: ptr(fakeBase + "/file.txt")}})
+	assert.Equal(t, fmt.Errorf("failed to resolve dependencies: %w", errMockDeps), err)
+
+	err = r.includes(context.Background(), []*github.RepositoryContent{{Path: ptr(fakeBase + "/missing.txt")}})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// notFoundMux serves not found responses for all contents and resolves only
+// the main branch to a commit.
+func notFoundMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", http.NotFound)
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ref") != "main" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message":"No commit found for SHA: `+r.PathValue("ref")+`"}`)
+			return
+		}
+		fmt.Fprint(w, "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678")
+	})
+	mux.HandleFunc("GET /repos/owner/repo/branches", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"name":"main"},{"name":"dev"}]`)
+	})
+	return mux
+}
+
+func TestNotFound(t *testing.T) {
+	t.Parallel()
+	r := serverRepository(t, notFoundMux())
+
+	tests := []struct {
+		name     string
+		url      string
+		expected error
+		other    error
+	}{
+		{
+			name:     "missing ref",
+			url:      "https://github.com/owner/repo/tree/mian/dir",
+			expected: ErrRefNotFound,
+			other:    ErrPathNotFound,
+		},
+		{
+			name:     "missing path",
+			url:      "https://github.com/owner/repo/tree/main/missing",
+			expected: ErrPathNotFound,
+			other:    ErrRefNotFound,
+		},
+		{
+			name:     "missing repo",
+			url:      "https://github.com/owner/missing/tree/main/dir",
+			expected: ErrRepoNotFound,
+			other:    ErrPathNotFound,
+		},
+		{
+			name:     "missing repo at default branch",
+			url:      "https://github.com/owner/missing",
+			expected: ErrRepoNotFound,
+			other:    ErrRefNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: r.Client}
+			err := g.extract(test.url)
+			require.NoError(t, err)
+
+			_, err = g.download(context.Background())
+			require.ErrorIs(t, err, test.expected)
+			assert.NotErrorIs(t, err, test.other)
+		})
+	}
+
+	t.Run("ref suggestion", func(t *testing.T) {
+		t.Parallel()
+		g := &GitHub{Client: r.Client}
+		require.NoError(t, g.extract("https://github.com/owner/repo/tree/mian/dir"))
+
+		_, err := g.download(context.Background())
+		var refErr *RefNotFoundError
+		require.ErrorAs(t, err, &refErr)
+		assert.Equal(t, "mian", refErr.Ref)
+		assert.Equal(t, []string{"main", "dev"}, refErr.Branches)
+		require.ErrorContains(t, err, "mian, check the spelling of the branch or tag; branches: main, dev")
+	})
+
+	t.Run("ref suggestion without branches", func(t *testing.T) {
+		t.Parallel()
+		g := &GitHub{Client: &mockError{}}
+		err := g.refNotFound(context.Background(), "owner", "repo", "mian")
+		require.ErrorIs(t, err, ErrRefNotFound)
+		assert.Equal(t, ErrRefNotFound.Error()+": mian, check the spelling of the branch or tag", err.Error())
+	})
+
+	t.Run("other errors unchanged", func(t *testing.T) {
+		t.Parallel()
+		g := &GitHub{Client: &mockError{}}
+		assert.Equal(t, errMockContents, g.notFound(context.Background(), "owner", "repo", "main", errMockContents))
+	})
+}
+
+func TestResolveLatest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		repo        *GitHub
+		ref         string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "latest release",
+			repo:     &GitHub{Client: &mockSuccess{}},
+			ref:      latestRef,
+			expected: "v1.2.3",
+		},
+		{
+			name:     "other ref",
+			repo:     &GitHub{Client: &mockError{}},
+			ref:      "main",
+			expected: "main",
+		},
+		{
+			name:        "error latest release",
+			repo:        &GitHub{Client: &mockError{}},
+			ref:         latestRef,
+			expected:    latestRef,
+			expectedErr: fmt.Errorf("failed to resolve latest release: %w", errMockLatestRelease),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			test.repo.Ref = &github.RepositoryContentGetOptions{Ref: test.ref}
+			err := test.repo.resolveLatest(context.Background())
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, test.repo.ref())
+		})
+	}
+}
+
+func TestResolveDefault(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockSuccess{}, Ref: &github.RepositoryContentGetOptions{Ref: defaultRef}}
+	require.NoError(t, r.resolveDefault(context.Background()))
+	assert.Equal(t, "main", r.ref())
+
+	r = &GitHub{Client: &mockError{}, Ref: &github.RepositoryContentGetOptions{Ref: "dev"}}
+	require.NoError(t, r.resolveDefault(context.Background()))
+	assert.Equal(t, "dev", r.ref())
+
+	r = &GitHub{Client: &mockError{}, Ref: &github.RepositoryContentGetOptions{Ref: defaultRef}}
+	err := r.resolveDefault(context.Background())
+	require.ErrorIs(t, err, errMockRepository)
+	assert.Equal(t, defaultRef, r.ref())
+}
+
+func TestDownloadDefaultBranch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		branch string
+		url    string
+	}{
+		{branch: "main", url: "https://github.com/owner/repo"},
+		{branch: "master", url: "https://github.com/owner/repo"},
+		{branch: "main", url: "git@github.com:owner/repo.git"},
+		{branch: "main", url: "ssh://git@github.com/owner/repo.git"},
+	}
+	for _, test := range tests {
+		branch := test.branch
+		t.Run(branch+" "+test.url, func(t *testing.T) {
+			t.Parallel()
+			base := t.TempDir()
+			contents := contentsMux(map[string]string{"README.md": "readme", "src/main.go": "package main"})
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /repos/owner/repo", func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprintf(w, `{"default_branch":%q}`, branch)
+			})
+			mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("ref") != branch {
+					http.NotFound(w, r)
+					return
+				}
+				contents.ServeHTTP(w, r)
+			})
+			mux.Handle("/", contents)
+			r := serverRepository(t, mux)
+
+			require.NoError(t, r.extract(test.url))
+			result, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+			assert.Equal(t, branch, result.Manifest.Ref)
+			for path, content := range map[string]string{"README.md": "readme", "src/main.go": "package main"} {
+				b, err := os.ReadFile(filepath.Join(base, path))
+				require.NoError(t, err)
+				assert.Equal(t, content, string(b))
+			}
+		})
+	}
+}
+
+func TestDownloadRef(t *testing.T) {
+	t.Parallel()
+	for _, ref := range []string{"v1.2.3", "a1b2c3d"} {
+		t.Run(ref, func(t *testing.T) {
+			t.Parallel()
+			base := t.TempDir()
+			contents := contentsMux(map[string]string{"dir/file.txt": "data"})
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("ref") != ref {
+					http.NotFound(w, r)
+					return
+				}
+				contents.ServeHTTP(w, r)
+			})
+			mux.Handle("/", contents)
+			r := serverRepository(t, mux)
+
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/"+ref+"/dir"))
+			assert.Equal(t, ref, r.ref())
+			result, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+			assert.Equal(t, ref, result.Manifest.Ref)
+			b, err := os.ReadFile(filepath.Join(base, "dir/file.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "data", string(b))
+		})
+	}
+}
+
+func TestDownloadImmutableRef(t *testing.T) {
+	t.Parallel()
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	var requests atomic.Int32
+	contents := contentsMux(map[string]string{"dir/file.txt": "data"})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		contents.ServeHTTP(w, r)
+	})
+
+	for _, ref := range []string{"main", "v1.2.3", "a1b2c3d", "@latest"} {
+		r := serverRepository(t, h)
+		r.opts.immutableRef = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/"+ref+"/dir"))
+		_, err := r.downloadTo(context.Background(), t.TempDir())
+		require.ErrorIs(t, err, ErrNotImmutableRef, ref)
+		assert.Contains(t, err.Error(), ref)
+	}
+	assert.Zero(t, requests.Load(), "want no requests for a mutable ref")
+
+	r := serverRepository(t, h)
+	r.opts.immutableRef = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/"+sha+"/dir"))
+	base := t.TempDir()
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join(base, "dir/file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(b))
+}
+
+func TestDownloadLatestRelease(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+
+	contents := contentsMux(map[string]string{fakeBase + "/file.txt": "release data"})
+	var refs []string
+	var mu sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"tag_name":"v2.0.0"}`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/contents/") {
+			mu.Lock()
+			refs = append(refs, r.URL.Query().Get("ref"))
+			mu.Unlock()
+		}
+		contents.ServeHTTP(w, r)
+	})
+
+	g := fakeNew(serverRepository(t, mux))
+	err := g.Download(context.Background(), "github.com/owner/repo@latest/"+fakeBase)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(fakeBase + "/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "release data", string(b))
+	assert.Equal(t, []string{"v2.0.0"}, refs)
+}
+
+func TestDownloadConcurrentSameBase(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{}
+	for i := range 10 {
+		files[fmt.Sprintf("%s/file_%d.txt", fakeBase, i)] = strings.Repeat(fmt.Sprint(i), 1<<14)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		g := fakeNew(serverRepository(t, contentsMux(files)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	for path, content := range files {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	entries, err := os.ReadDir(fakeBase)
+	require.NoError(t, err)
+	assert.Len(t, entries, len(files))
+}
+
+func TestDownloadRouter(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	docs, code := fakeBase+"_docs", fakeBase+"_code"
+	t.Cleanup(func() {
+		for _, dir := range []string{fakeBase, docs, code} {
+			err := os.RemoveAll(dir)
+			require.NoError(t, err)
+		}
+	})
+	files := map[string]string{
+		fakeBase + "/README.md":      "readme",
+		fakeBase + "/guide/intro.md": "intro",
+		fakeBase + "/main.go":        "package main",
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.router = func(repoPath string) string {
+		if strings.HasSuffix(repoPath, ".md") {
+			return docs
+		}
+		return code
+	}
+	g := fakeNew(r)
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	for path, content := range map[string]string{
+		docs + "/" + fakeBase + "/README.md":      "readme",
+		docs + "/" + fakeBase + "/guide/intro.md": "intro",
+		code + "/" + fakeBase + "/main.go":        "package main",
+	} {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	_, err = os.Stat(fakeBase)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDownloadOutputDir(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	files := map[string]string{
+		"docs/" + fakeBase + "/README.md":      "readme",
+		"docs/" + fakeBase + "/guide/intro.md": "intro",
+	}
+	out := t.TempDir()
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.outputDir = out
+	err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/docs/"+fakeBase)
+	require.NoError(t, err)
+
+	for path, content := range map[string]string{
+		filepath.Join(out, fakeBase, "README.md"):         "readme",
+		filepath.Join(out, fakeBase, "guide", "intro.md"): "intro",
+	} {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	_, err = os.Stat(fakeBase)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDownloadResolveCommit(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	branch := "1111111111111111111111111111111111111111"
+	tag := "2222222222222222222222222222222222222222"
+	sha := "3333333333333333333333333333333333333333"
+	commits := map[string]string{"main": branch, "v1.0.0": tag, sha: sha}
+	mux := contentsMux(map[string]string{fakeBase + "/file.txt": "data"})
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		commit, ok := commits[r.PathValue("ref")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, commit)
+	})
+	r := serverRepository(t, mux)
+	r.opts.resolveCommit = true
+	g := fakeNew(r)
+
+	for ref, commit := range commits {
+		result, err := g.DownloadResult(context.Background(), "https://github.com/owner/repo/tree/"+ref+"/"+fakeBase)
+		require.NoError(t, err)
+		assert.Equal(t, commit, result.ResolvedCommit, ref)
+	}
+
+	r.opts.resolveCommit = false
+	result, err := g.DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+	assert.Empty(t, result.ResolvedCommit)
+
+	r = &GitHub{Client: &mockError{}, opts: options{resolveCommit: true}}
+	_, err = r.attempt(context.Background())
+	require.ErrorIs(t, err, errMockCommitSHA1)
+}
+
+func TestDownloadTreeDir(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	var trees atomic.Int32
+	mux := contentsMux(map[string]string{fakeBase + "/file.txt": "data"})
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		trees.Add(1)
+		fmt.Fprintf(w, `{"sha":"tree-%s"}`, r.PathValue("sha"))
+	})
+	r := serverRepository(t, mux)
+	r.opts.treeDir = base
+	g := fakeNew(r)
+
+	for _, ref := range []string{"main", "main", "v2"} {
+		err := g.Download(context.Background(), "https://github.com/owner/repo/tree/"+ref+"/"+fakeBase)
+		require.NoError(t, err)
+	}
+	// Each download resolves the tree and fetches it again for the file modes.
+	assert.Equal(t, int32(6), trees.Load())
+	entries, err := os.ReadDir(base)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, sha := range []string{"tree-main", "tree-v2"} {
+		b, err := os.ReadFile(filepath.Join(base, sha, fakeBase, "file.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(b))
+	}
+	assert.Equal(t, base, r.opts.treeDir)
+	_, err = os.Stat(fakeBase)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	r = &GitHub{Client: &mockError{}, opts: options{treeDir: base}}
+	_, err = r.download(context.Background())
+	require.ErrorIs(t, err, errMockTree)
+}
+
+func TestDownloadDryRun(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	contents := linksMux(map[string]string{"dir/a.txt": "aaa", "dir/sub/b.txt": "bb"}, map[string]string{"dir/link": "a.txt"})
+	var raw atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /raw/", func(w http.ResponseWriter, r *http.Request) {
+		raw.Add(1)
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	r := serverRepository(t, mux)
+	r.opts.dryRun = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	expected := []ManifestEntry{
+		{Path: "dir/a.txt", Size: 3},
+		{Path: "dir/link"},
+		{Path: "dir/sub/b.txt", Size: 2},
+	}
+	assert.Equal(t, expected, result.Manifest.Files)
+	assert.Zero(t, raw.Load(), "file content requested")
+	entries, err := os.ReadDir(base)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloadSkipEmptyFiles(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		skipEmpty bool
+		requests  int32
+	}{
+		{name: "write empty files", skipEmpty: false, requests: 2},
+		{name: "skip empty files", skipEmpty: true, requests: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			contents := contentsMux(map[string]string{
+				fakeBase + "/file.txt":       "data",
+				fakeBase + "/empty/.gitkeep": "",
+			})
+			var requests atomic.Int32
+			mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/raw/") {
+					requests.Add(1)
+				}
+				contents.ServeHTTP(w, r)
+			})
+
+			r := serverRepository(t, mux)
+			r.opts.skipEmpty = test.skipEmpty
+			g := fakeNew(r)
+			err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			require.NoError(t, err)
+
+			b, err := os.ReadFile(fakeBase + "/file.txt")
+			require.NoError(t, err)
+			assert.Equal(t, "data", string(b))
+			_, err = os.Stat(fakeBase + "/empty/.gitkeep")
+			if test.skipEmpty {
+				require.ErrorIs(t, err, os.ErrNotExist)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.requests, requests.Load())
+		})
+	}
+}
+
+func TestFetchSkipsCompleted(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockError{}, completed: &completed{paths: map[string]bool{"dir/a.txt": true}}}
+	files := []*github.RepositoryContent{{Path: ptr("dir/a.txt"), DownloadURL: ptr("https://example.com/a.txt")}}
+
+	err := r.fetch(context.Background(), files)
+	require.NoError(t, err, "completed files are not downloaded again")
+
+	files = append(files, &github.RepositoryContent{Path: ptr("dir/b.txt"), DownloadURL: ptr("https://example.com/b.txt")})
+	err = r.fetch(context.Background(), files)
+	require.ErrorIs(t, err, errMockGet)
+}
+
+func TestFetchConcurrency(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{}
+	for i := range 20 {
+		files[fmt.Sprintf("%s/file_%d.txt", fakeBase, i)] = fmt.Sprint(i)
+	}
+	contents := contentsMux(files)
+	var active, peak atomic.Int32
+	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/raw/") {
+			n := active.Add(1)
+			defer active.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		contents.ServeHTTP(w, r)
+	})
+
+	r := serverRepository(t, mux)
+	r.opts.concurrency = 3
+	g := fakeNew(r)
+	err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	for path, content := range files {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+}
+
+func TestDownloadMinRateLimit(t *testing.T) {
+	t.Parallel()
+	rateMux := func(limit, remaining int, contents *atomic.Int32) http.Handler {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /rate_limit", func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprintf(w, `{"resources":{"core":{"limit":%d,"remaining":%d,"reset":%d}}}`, limit, remaining, time.Now().Add(time.Hour).Unix())
+		})
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			contents.Add(1)
+			http.NotFound(w, r)
+		})
+		return mux
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		remaining int
+		expected  error
+	}{
+		{name: "unauthenticated low remaining", limit: 60, remaining: 2, expected: ErrRateLimitedSuggestToken},
+		{name: "unauthenticated enough remaining", limit: 60, remaining: 40, expected: ErrPathNotFound},
+		{name: "authenticated low remaining", limit: 5000, remaining: 2, expected: ErrPathNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var contents atomic.Int32
+			r := serverRepository(t, rateMux(test.limit, test.remaining, &contents))
+			r.opts.minRateLimit = 10
+			r.Owner, r.Repo, r.Path = "owner", "repo", "missing"
+
+			_, err := r.download(context.Background())
+			require.ErrorIs(t, err, test.expected)
+			if test.expected == ErrRateLimitedSuggestToken {
+				assert.Contains(t, err.Error(), "2 requests remaining")
+				assert.Zero(t, contents.Load(), "want no requests after the rate limit check")
+			}
+		})
+	}
+}
+
+func TestCheckRateLimitError(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockError{}, opts: newOptions(WithMinRateLimit(10))}
+	_, err := r.download(context.Background())
+	require.ErrorIs(t, err, errMockRateLimit)
+}
+
+func TestExtractAllowedRepos(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr error
+	}{
+		{name: "approved repo", url: "https://github.com/owner/repo/tree/main/dir"},
+		{name: "approved repo different case", url: "https://github.com/Owner/Repo/tree/main/dir"},
+		{name: "unapproved repo", url: "https://github.com/owner/other/tree/main/dir", expectedErr: ErrRepoNotAllowed},
+		{name: "unapproved owner", url: "https://github.com/other/repo/tree/main/dir", expectedErr: ErrRepoNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r := &GitHub{Client: &mockError{}, opts: newOptions(WithAllowedRepos([]string{"owner/repo"}))}
+			err := r.extract(test.url)
+			require.ErrorIs(t, err, test.expectedErr)
+			if test.expectedErr != nil {
+				assert.Empty(t, r.Owner)
+			}
+		})
+	}
+}
+
+func TestFetchFileAllowedRepos(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockError{}, opts: newOptions(WithAllowedRepos([]string{"owner/repo"}))}
+	_, err := r.fetchFile(context.Background(), "owner", "other", "main", "file.txt")
+	require.ErrorIs(t, err, ErrRepoNotAllowed)
+	_, err = r.fetchFile(context.Background(), "owner", "repo", "main", "file.txt")
+	require.ErrorIs(t, err, errMockContents)
+}
+
+func TestListFilesStripTopLevel(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		".github/workflows/ci.yml":     "ci",
+		"LICENSE":                      "license",
+		"LICENSE.md":                   "license",
+		"README.md":                    "readme",
+		"src/.github/workflows/ci.yml": "nested ci",
+		"src/LICENSE":                  "nested license",
+	}
+	r := serverRepository(t, contentsMux(files))
+	r.Owner, r.Repo = "owner", "repo"
+	r.opts.stripTopLevel = []string{".github", "LICENSE"}
+
+	listed, _, err := r.listFiles(context.Background())
+	require.NoError(t, err)
+	var paths []string
+	for _, file := range listed {
+		paths = append(paths, file.GetPath())
+	}
+	assert.ElementsMatch(t, []string{"LICENSE.md", "README.md", "src/.github/workflows/ci.yml", "src/LICENSE"}, paths)
+}
+
+func TestListFilesMinFiles(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"README.md":   "readme",
+		"src/main.go": "package main",
+	}
+	tests := []struct {
+		name     string
+		minFiles int
+		err      error
+	}{
+		{name: "below", minFiles: 3, err: ErrTooFewFiles},
+		{name: "equal", minFiles: 2},
+		{name: "above", minFiles: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r := serverRepository(t, contentsMux(files))
+			r.Owner, r.Repo = "owner", "repo"
+			r.opts.minFiles = test.minFiles
+
+			listed, _, err := r.listFiles(context.Background())
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				assert.Nil(t, listed)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, listed, 2)
+		})
+	}
+}
+
+func TestDownloadCancelMidWalk(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/a.txt":     "a",
+		fakeBase + "/sub/b.txt": "b",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	mux := contentsMux(files)
+	g := fakeNew(serverRepository(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The download is canceled once the walk reaches the subdirectory.
+		if r.URL.Path == "/repos/owner/repo/contents/"+fakeBase+"/sub" {
+			cancel()
+		}
+		mux.ServeHTTP(w, r)
+	})))
+
+	err := g.Download(ctx, "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = os.Stat(filepath.Join(fakeBase, "a.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDownloadBlob(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	fakeFile := fakeBase + ".go"
+	t.Cleanup(func() {
+		for _, p := range []string{fakeBase, fakeFile} {
+			err := os.RemoveAll(p)
+			require.NoError(t, err)
+		}
+	})
+	files := map[string]string{
+		fakeBase + "/dir/" + fakeFile: "package main",
+		fakeBase + "/dir/other.go":    "package other",
+	}
+	g := fakeNew(serverRepository(t, contentsMux(files)))
+
+	err := g.Download(context.Background(), "https://github.com/owner/repo/blob/main/"+fakeBase+"/dir/"+fakeFile)
+	require.NoError(t, err)
+	b, err := os.ReadFile(fakeFile)
+	require.NoError(t, err)
+	assert.Equal(t, "package main", string(b))
+
+	err = g.Download(context.Background(), "https://github.com/owner/repo/blob/main/"+fakeBase+"/dir")
+	require.ErrorIs(t, err, ErrNotFile)
+	err = g.Download(context.Background(), "https://github.com/owner/repo/blob/main/"+fakeBase+"/missing.go")
+	require.Error(t, err)
+	_, err = os.Stat(fakeBase)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,134 @@
+package gitty
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// WarningCode represents the kind of a non-fatal condition of a download.
+type WarningCode string
+
+const (
+	// WarnSymlinkSkipped reports a symlink whose target is not a downloaded file.
+	WarnSymlinkSkipped WarningCode = "symlink_skipped"
+	// WarnSymlinkCycle reports a followed directory symlink that leads back
+	// into a directory followed before.
+	WarnSymlinkCycle WarningCode = "symlink_cycle"
+	// WarnMixedLineEndings reports a text file that mixes CRLF and LF line
+	// endings.
+	WarnMixedLineEndings WarningCode = "mixed_line_endings"
+	// WarnEmptySkipped reports a zero-byte file that was skipped.
+	WarnEmptySkipped WarningCode = "empty_skipped"
+	// WarnRepoMoved reports a repository that was renamed or transferred and
+	// whose new location was followed.
+	WarnRepoMoved WarningCode = "repo_moved"
+	// WarnTreeTruncated reports a repository tree too large to be listed at
+	// once, so the contents were walked instead.
+	WarnTreeTruncated WarningCode = "tree_truncated"
+	// WarnRemovedSkipped reports a file removed by a pull request, which was
+	// skipped.
+	WarnRemovedSkipped WarningCode = "removed_skipped"
+	// WarnExistingSkipped reports a file that already existed and was left
+	// untouched.
+	WarnExistingSkipped WarningCode = "existing_skipped"
+	// WarnOversizedSkipped reports a file larger than the maximum file size,
+	// which was skipped.
+	WarnOversizedSkipped WarningCode = "oversized_skipped"
+	// WarnPresentSkipped reports a file already saved with its listed size,
+	// which was not downloaded again.
+	WarnPresentSkipped WarningCode = "present_skipped"
+	// WarnUnchangedSkipped reports a file whose content still has the ETag
+	// cached for it, which was not downloaded again.
+	WarnUnchangedSkipped WarningCode = "unchanged_skipped"
+	// WarnCaseCollisionSkipped reports a file whose local path differs only
+	// in case from that of another file, which was skipped.
+	WarnCaseCollisionSkipped WarningCode = "case_collision_skipped"
+	// WarnSubmoduleSkipped reports a submodule, whose content is in another
+	// repository, which was skipped.
+	WarnSubmoduleSkipped WarningCode = "submodule_skipped"
+	// WarnLFSPointer reports a Git LFS pointer file saved in place of its
+	// content, as LFS was not enabled.
+	WarnLFSPointer WarningCode = "lfs_pointer"
+)
+
+// Warning represents a non-fatal condition that occurred during a download.
+type Warning struct {
+	Code    WarningCode `json:"code"`
+	Path    string      `json:"path"`
+	Message string      `json:"message"`
+}
+
+// Result represents the outcome of a download.
+type Result struct {
+	Manifest Manifest  `json:"manifest"`
+	Warnings []Warning `json:"warnings,omitempty"`
+	// ResolvedCommit is the SHA of the downloaded commit, if it was resolved.
+	ResolvedCommit string `json:"resolved_commit,omitempty"`
+	// Summary counts the written and skipped files. In a dry run, it counts
+	// the files that would be written instead.
+	Summary Summary `json:"summary"`
+}
+
+// warnings collects the warnings of a download. It is safe for concurrent use.
+type warnings struct {
+	mu   sync.Mutex
+	list []Warning
+	// log receives the warnings as log events, if set.
+	log *slog.Logger
+}
+
+// add prints the warning and records it, if w is not nil.
+func (w *warnings) add(code WarningCode, path, message string) {
+	fmt.Println(message+":", path)
+	if w == nil {
+		return
+	}
+	if w.log != nil {
+		w.log.Warn(message, "code", code, "path", path)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.list = append(w.list, Warning{Code: code, Path: path, Message: message})
+}
+
+// all returns the recorded warnings.
+func (w *warnings) all() []Warning {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.list
+}
+
+// completed records the repository paths of saved files. It is safe for
+// concurrent use.
+type completed struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+// add records the repository path, if c is not nil.
+func (c *completed) add(path string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths[path] = true
+}
+
+// has reports whether the repository path is recorded.
+func (c *completed) has(path string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paths[path]
+}
@@ -0,0 +1,76 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarnings(t *testing.T) {
+	t.Parallel()
+	var nilWarnings *warnings
+	nilWarnings.add(WarnEmptySkipped, "file.txt", "Skipping empty file")
+	assert.Nil(t, nilWarnings.all())
+
+	w := &warnings{}
+	w.add(WarnEmptySkipped, "file.txt", "Skipping empty file")
+	w.add(WarnSymlinkSkipped, "link", "Skipping symlink")
+	expected := []Warning{
+		{Code: WarnEmptySkipped, Path: "file.txt", Message: "Skipping empty file"},
+		{Code: WarnSymlinkSkipped, Path: "link", Message: "Skipping symlink"},
+	}
+	assert.Equal(t, expected, w.all())
+}
+
+func TestDownloadResultWarnings(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		"repo/" + fakeBase + "/file.txt": "data",
+		"repo/" + fakeBase + "/.gitkeep": "",
+	}
+	links := map[string]string{
+		"repo/" + fakeBase + "/escape": "../../outside.txt",
+	}
+	contents := linksMux(files, links)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"sha":"main","truncated":true,"tree":[]}`)
+	})
+	mux.Handle("/", contents)
+
+	r := treeRepository(t, mux)
+	r.opts.skipEmpty = true
+	g := fakeNew(r)
+	result, err := g.DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+fakeBase)
+	require.NoError(t, err)
+
+	sort.Slice(result.Warnings, func(i, j int) bool {
+		return result.Warnings[i].Code < result.Warnings[j].Code
+	})
+	expected := []Warning{
+		{Code: WarnEmptySkipped, Path: "repo/" + fakeBase + "/.gitkeep", Message: "Skipping empty file"},
+		{Code: WarnSymlinkSkipped, Path: "repo/" + fakeBase + "/escape", Message: "Skipping symlink"},
+		{Code: WarnTreeTruncated, Path: "repo/" + fakeBase, Message: "Walking truncated tree"},
+	}
+	assert.Equal(t, expected, result.Warnings)
+	require.Len(t, result.Manifest.Files, 1)
+	assert.Equal(t, "repo/"+fakeBase+"/file.txt", result.Manifest.Files[0].Path)
+
+	// The warnings are collected again for each download.
+	r.opts.skipEmpty = false
+	result, err = g.DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+fakeBase)
+	require.NoError(t, err)
+	assert.Len(t, result.Warnings, 2)
+}
@@ -0,0 +1,65 @@
+package gitty
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// skipPresent marks the files already saved at their local paths, as judged
+// by present, as completed, so their content is not downloaded again.
+func (g *GitHub) skipPresent(files []*github.RepositoryContent) error {
+	for _, file := range files {
+		if g.completed.has(file.GetPath()) {
+			continue
+		}
+		ok, err := g.present(file)
+		if err != nil {
+			return err
+		}
+		if ok {
+			g.completed.add(file.GetPath())
+			g.warnings.add(WarnPresentSkipped, file.GetPath(), "Skipping file already present")
+		}
+	}
+	return nil
+}
+
+// present reports whether the file is saved at its local path with its listed
+// size and, if verification is enabled and the file is listed with a SHA,
+// with content that hashes to its blob SHA.
+func (g *GitHub) present(file *github.RepositoryContent) (bool, error) {
+	p, err := localPath(g.opts, g.Path, file.GetPath())
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() != int64(file.GetSize()) {
+		return false, nil
+	}
+	if !g.opts.verify || file.GetSHA() == "" {
+		return true, nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	b := &blobs{files: map[string]*github.RepositoryContent{file.GetPath(): file}}
+	_, err = io.Copy(io.Discard, b.verify(file.GetPath(), f))
+	var mismatch *BlobMismatchError
+	if errors.As(err, &mismatch) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,104 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadResume(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/a.txt":     "aaaa",
+		"dir/b.txt":     "bbbb",
+		"dir/sub/c.txt": "cccc",
+		"dir/sub/d.txt": "dddd",
+	}
+	var mu sync.Mutex
+	var fetched []string
+	contents := contentsMux(files)
+	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := strings.CutPrefix(r.URL.Path, "/raw/"); ok {
+			mu.Lock()
+			fetched = append(fetched, path)
+			mu.Unlock()
+		}
+		contents.ServeHTTP(w, r)
+	})
+	base := t.TempDir()
+	// The first half of the files were saved before, and d.txt was truncated.
+	for name, content := range map[string]string{"a.txt": "aaaa", "sub/c.txt": "cccc", "sub/d.txt": "dd"} {
+		p := filepath.Join(base, "dir", name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o700))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+	r := serverRepository(t, mux)
+	r.opts.resume = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	sort.Strings(fetched)
+	assert.Equal(t, []string{"dir/b.txt", "dir/sub/d.txt"}, fetched)
+	assert.Equal(t, []Warning{
+		{Code: WarnPresentSkipped, Path: "dir/a.txt", Message: "Skipping file already present"},
+		{Code: WarnPresentSkipped, Path: "dir/sub/c.txt", Message: "Skipping file already present"},
+	}, result.Warnings)
+	assert.Equal(t, 2, result.Summary.Skipped)
+	for path, content := range files {
+		b, err := os.ReadFile(filepath.Join(base, filepath.FromSlash(path)))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+}
+
+func TestDownloadResumeVerify(t *testing.T) {
+	t.Parallel()
+	content := "package main"
+	tests := []struct {
+		name    string
+		verify  bool
+		fetched bool
+	}{
+		{name: "size only", verify: false, fetched: false},
+		{name: "blob sha", verify: true, fetched: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fetched := false
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprintf(w, `{"sha":"main","tree":[{"type":"blob","mode":"100644","path":"dir/main.go","size":%d,"sha":%q}]}`, len(content), blobSHA(content))
+			})
+			mux.HandleFunc("GET /raw/owner/repo/main/dir/main.go", func(w http.ResponseWriter, _ *http.Request) {
+				fetched = true
+				fmt.Fprint(w, content)
+			})
+			base := t.TempDir()
+			// The saved file has the listed size but other content.
+			p := filepath.Join(base, "dir", "main.go")
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o700))
+			require.NoError(t, os.WriteFile(p, []byte("package mian"), 0o600))
+			r := treeRepository(t, mux)
+			r.opts.resume = true
+			r.opts.verify = test.verify
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+			_, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+			assert.Equal(t, test.fetched, fetched)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultBackoff represents the delay before the first retry. The delay is
+	// doubled for each following retry.
+	defaultBackoff = 500 * time.Millisecond
+	// defaultAbuseBackoff represents the delay before the first retry of a
+	// request rejected by the abuse detection of GitHub. The delay is doubled
+	// for each following retry.
+	defaultAbuseBackoff = 30 * time.Second
+)
+
+// retryTransport represents an http.RoundTripper that retries failed requests.
+type retryTransport struct {
+	base         http.RoundTripper
+	sleep        func(ctx context.Context, d time.Duration) error
+	retries      int
+	backoff      time.Duration
+	abuseBackoff time.Duration
+}
+
+// Ensure retryTransport implements the http.RoundTripper interface.
+var _ http.RoundTripper = (*retryTransport)(nil)
+
+// RoundTrip executes a single HTTP transaction and retries it with
+// exponential backoff and jitter for as long as it fails with a retryable
+// error or, if it is safe to repeat, with a server error or 429 (Too Many
+// Requests) response. Other responses, such as 401 (Unauthorized) and 404
+// (Not Found), are returned as they are.
+//
+// Requests rejected by the abuse detection of GitHub are retried separately,
+// with the longer abuse backoff or the delay the response asks for.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempt, abuses := 0, 0
+	for {
+		resp, err := t.base.RoundTrip(req)
+		var d time.Duration
+		switch {
+		case err == nil && abuses < t.retries && abused(resp):
+			d = max(t.abuseBackoff<<abuses, retryAfter(resp))
+			abuses++
+		case err == nil && attempt < t.retries && idempotent(req) && retryableStatus(resp.StatusCode):
+			d = max(jitter(t.backoff<<attempt), retryAfter(resp))
+			attempt++
+		case err == nil || attempt >= t.retries || !retryable(req, err):
+			return resp, err
+		default:
+			d = jitter(t.backoff << attempt)
+			attempt++
+		}
+
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, errBody := req.GetBody()
+			if errBody != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		if err := t.wait(req.Context(), d); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait pauses before the next retry for d or until ctx is done. The deadline
+// of the download is extended by d, so the backoff, which grows to minutes
+// for the abuse detection, does not count against the download.
+func (t *retryTransport) wait(ctx context.Context, d time.Duration) error {
+	extendDeadline(ctx, d)
+	if t.sleep == nil {
+		return sleep(ctx, d)
+	}
+	return t.sleep(ctx, d)
+}
+
+// transient reports whether the download failed with a network error or a
+// server error status that may not occur again when the download is
+// repeated. Canceled and timed out downloads are not transient.
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTookTooLong) {
+		return false
+	}
+
+	var opErr *net.OpError
+	var netErr net.Error
+	var httpErr *HTTPError
+	return errors.As(err, &opErr) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, ErrFileTimeout) ||
+		(errors.As(err, &httpErr) && retryableStatus(httpErr.StatusCode))
+}
+
+// retryable reports whether the request failed with a transient error that
+// happened before any response was received, such as a DNS resolution error,
+// or, if the request is safe to repeat, with any transient network error,
+// such as a reset connection.
+func retryable(req *http.Request, err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) || (idempotent(req) && transient(err))
+}
+
+// idempotent reports whether the request can be repeated without side effects.
+func idempotent(req *http.Request) bool {
+	return req.Method == "" || req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// retryableStatus reports whether a response with the status code may
+// succeed when the request is repeated.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// jitter returns d increased by a random duration of up to half of d, so
+// clients that failed at once do not retry at once.
+func jitter(d time.Duration) time.Duration {
+	if d < 2 {
+		return d
+	}
+	return d + rand.N(d/2)
+}
+
+// abused reports whether the response rejects the request because GitHub
+// detected an abuse, which asks clients to slow down. The body is kept
+// readable for the caller.
+func abused(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+
+	msg := strings.ToLower(string(b))
+	return strings.Contains(msg, "abuse detection") || strings.Contains(msg, "secondary rate limit")
+}
+
+// retryAfter returns the delay requested by the Retry-After header of the
+// response, if any.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,539 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errMockTransport = errors.New("mock transport error")
+
+// flakyTransport fails the first n requests with err and sends the remaining
+// ones with the default transport.
+type flakyTransport struct {
+	n     int32
+	err   error
+	calls atomic.Int32
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.calls.Add(1) <= f.n {
+		return nil, f.err
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// dnsError returns the error the resolver reports for a failed lookup.
+func dnsError() error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "server misbehaving", Name: "api.github.com", IsTemporary: true}}
+}
+
+func TestRetryTransport(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name          string
+		failures      int32
+		err           error
+		retries       int
+		expectedCalls int32
+		expectedErr   bool
+	}{
+		{
+			name:          "recover from dns error",
+			failures:      1,
+			err:           dnsError(),
+			retries:       2,
+			expectedCalls: 2,
+		},
+		{
+			name:          "retries exhausted",
+			failures:      3,
+			err:           dnsError(),
+			retries:       2,
+			expectedCalls: 3,
+			expectedErr:   true,
+		},
+		{
+			name:          "no retries",
+			failures:      1,
+			err:           dnsError(),
+			retries:       0,
+			expectedCalls: 1,
+			expectedErr:   true,
+		},
+		{
+			name:          "not retryable error",
+			failures:      1,
+			err:           errMockTransport,
+			retries:       2,
+			expectedCalls: 1,
+			expectedErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			base := &flakyTransport{n: test.failures, err: test.err}
+			c := &http.Client{Transport: &retryTransport{base: base, retries: test.retries, backoff: time.Millisecond}}
+
+			resp, err := c.Get(srv.URL)
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				resp.Body.Close()
+			}
+			assert.Equal(t, test.expectedCalls, base.calls.Load())
+		})
+	}
+}
+
+func TestRetryTransportCancel(t *testing.T) {
+	t.Parallel()
+	base := &flakyTransport{n: 1, err: dnsError()}
+	tr := &retryTransport{base: base, retries: 1, backoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	resp, err := tr.RoundTrip(req) //nolint:bodyclose // No response on error.
+	assert.Nil(t, resp)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+	get := &http.Request{Method: http.MethodGet}
+	post := &http.Request{Method: http.MethodPost}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	assert.True(t, retryable(get, dnsError()))
+	assert.True(t, retryable(post, dnsError()))
+	assert.True(t, retryable(get, &url.Error{Op: "Get", URL: "https://api.github.com", Err: dnsError()}))
+	assert.True(t, retryable(get, reset))
+	assert.False(t, retryable(post, reset))
+	assert.False(t, retryable(get, context.Canceled))
+	assert.False(t, retryable(get, errMockTransport))
+}
+
+// statusTransport answers the requests with the status codes in turn and the
+// remaining ones with 200 OK.
+type statusTransport struct {
+	codes []int
+	calls atomic.Int32
+}
+
+func (s *statusTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	n := int(s.calls.Add(1))
+	if n <= len(s.codes) {
+		return &http.Response{StatusCode: s.codes[n-1], Header: http.Header{}, Body: http.NoBody}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestRetryTransportStatus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		method         string
+		codes          []int
+		retries        int
+		expectedStatus int
+		expectedCalls  int32
+	}{
+		{name: "bad gateway", method: http.MethodGet, codes: []int{http.StatusBadGateway, http.StatusServiceUnavailable}, retries: 2, expectedStatus: http.StatusOK, expectedCalls: 3},
+		{name: "too many requests", method: http.MethodGet, codes: []int{http.StatusTooManyRequests}, retries: 1, expectedStatus: http.StatusOK, expectedCalls: 2},
+		{name: "retries exhausted", method: http.MethodGet, codes: []int{http.StatusBadGateway, http.StatusBadGateway}, retries: 1, expectedStatus: http.StatusBadGateway, expectedCalls: 2},
+		{name: "not found", method: http.MethodGet, codes: []int{http.StatusNotFound}, retries: 2, expectedStatus: http.StatusNotFound, expectedCalls: 1},
+		{name: "unauthorized", method: http.MethodGet, codes: []int{http.StatusUnauthorized}, retries: 2, expectedStatus: http.StatusUnauthorized, expectedCalls: 1},
+		{name: "not idempotent", method: http.MethodPost, codes: []int{http.StatusBadGateway}, retries: 2, expectedStatus: http.StatusBadGateway, expectedCalls: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			base := &statusTransport{codes: test.codes}
+			var delays []time.Duration
+			tr := &retryTransport{
+				base: base,
+				sleep: func(_ context.Context, d time.Duration) error {
+					delays = append(delays, d)
+					return nil
+				},
+				retries: test.retries,
+				backoff: time.Second,
+			}
+			req, err := http.NewRequest(test.method, "http://example.com", nil)
+			require.NoError(t, err)
+
+			resp, err := tr.RoundTrip(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			assert.Equal(t, test.expectedCalls, base.calls.Load())
+			for i, d := range delays {
+				// The delay doubles for each retry, with up to half of it added.
+				assert.GreaterOrEqual(t, d, time.Second<<i)
+				assert.Less(t, d, 3*time.Second<<i/2)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, time.Duration(0), jitter(0))
+	for range 100 {
+		d := jitter(time.Second)
+		assert.GreaterOrEqual(t, d, time.Second)
+		assert.Less(t, d, 3*time.Second/2)
+	}
+}
+
+func TestDownloadRetryServerError(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	contents := contentsMux(map[string]string{
+		fakeBase + "/file.txt": "test data",
+	})
+	var failures atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The file download fails twice before it succeeds.
+		if strings.HasPrefix(r.URL.Path, "/raw/") && failures.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		contents.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	o := newOptions(WithRetries(2))
+	o.backoff = time.Millisecond
+	c := newClient(o)
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+
+	g := fakeNew(repository(c, o))
+	err = g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(fakeBase + "/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "test data", string(b))
+	assert.Equal(t, int32(3), failures.Load())
+}
+
+func TestDownloadRetryDNS(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	srv := httptest.NewServer(contentsMux(map[string]string{
+		fakeBase + "/file.txt": "test data",
+	}))
+	t.Cleanup(srv.Close)
+
+	base := &flakyTransport{n: 1, err: dnsError()}
+	o := newOptions(WithRetries(1))
+	o.transport = base
+	o.backoff = time.Millisecond
+	c := newClient(o)
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = u
+
+	g := fakeNew(repository(c, o))
+	err = g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(fakeBase + "/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "test data", string(b))
+	// One failed and one retried contents request, the tree request for the
+	// file modes, then the file download.
+	assert.Equal(t, int32(4), base.calls.Load())
+}
+
+// abuseTransport rejects the first n requests with an abuse detection response
+// and answers the remaining ones with 200 OK.
+type abuseTransport struct {
+	n     int32
+	calls atomic.Int32
+}
+
+func (a *abuseTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	if a.calls.Add(1) <= a.n {
+		return &http.Response{
+			StatusCode: http.StatusForbidden,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(abuseBody)),
+		}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+const abuseBody = `{"message":"You have triggered an abuse detection mechanism. Please wait a few minutes before you try again.","documentation_url":"https://docs.github.com/rest/overview/resources-in-the-rest-api#abuse-rate-limits"}`
+
+func TestRetryTransportAbuse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		abuses         int32
+		retries        int
+		expectedStatus int
+		expectedDelays []time.Duration
+	}{
+		{
+			name:           "escalating abuse backoff",
+			abuses:         2,
+			retries:        2,
+			expectedStatus: http.StatusOK,
+			expectedDelays: []time.Duration{time.Minute, 2 * time.Minute},
+		},
+		{
+			name:           "retries exhausted",
+			abuses:         3,
+			retries:        2,
+			expectedStatus: http.StatusForbidden,
+			expectedDelays: []time.Duration{time.Minute, 2 * time.Minute},
+		},
+		{
+			name:           "no retries",
+			abuses:         1,
+			retries:        0,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var delays []time.Duration
+			tr := &retryTransport{
+				base: &abuseTransport{n: test.abuses},
+				sleep: func(_ context.Context, d time.Duration) error {
+					delays = append(delays, d)
+					return nil
+				},
+				retries:      test.retries,
+				backoff:      time.Millisecond,
+				abuseBackoff: time.Minute,
+			}
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			require.NoError(t, err)
+
+			resp, err := tr.RoundTrip(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			assert.Equal(t, test.expectedDelays, delays)
+			if test.expectedStatus == http.StatusForbidden {
+				b, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, abuseBody, string(b))
+			}
+		})
+	}
+}
+
+func TestRetryTransportAbuseDeadline(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := withDeadline(context.Background(), downloadLimit*time.Second)
+	defer cancel()
+	dl, ok := ctx.Value(deadlineKey{}).(*deadline)
+	require.True(t, ok)
+	dl.mu.Lock()
+	end := dl.end
+	dl.mu.Unlock()
+
+	var delays []time.Duration
+	tr := &retryTransport{
+		base: &abuseTransport{n: 2},
+		sleep: func(_ context.Context, d time.Duration) error {
+			delays = append(delays, d)
+			return nil
+		},
+		retries:      2,
+		backoff:      defaultBackoff,
+		abuseBackoff: defaultAbuseBackoff,
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	resp, err := tr.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// The default backoff exceeds the time limit of a download, which is
+	// extended by each wait.
+	assert.Equal(t, []time.Duration{defaultAbuseBackoff, 2 * defaultAbuseBackoff}, delays)
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	assert.Equal(t, end.Add(3*defaultAbuseBackoff), dl.end)
+}
+
+func TestAbused(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected bool
+	}{
+		{name: "abuse detection", status: http.StatusForbidden, body: abuseBody, expected: true},
+		{name: "secondary rate limit", status: http.StatusForbidden, body: `{"message":"You have exceeded a secondary rate limit."}`, expected: true},
+		{name: "rate limit", status: http.StatusForbidden, body: `{"message":"API rate limit exceeded."}`, expected: false},
+		{name: "not forbidden", status: http.StatusOK, body: abuseBody, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			resp := &http.Response{StatusCode: test.status, Body: io.NopCloser(strings.NewReader(test.body))}
+			assert.Equal(t, test.expected, abused(resp))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+	resp := &http.Response{Header: http.Header{}}
+	assert.Equal(t, time.Duration(0), retryAfter(resp))
+	resp.Header.Set("Retry-After", "90")
+	assert.Equal(t, 90*time.Second, retryAfter(resp))
+	resp.Header.Set("Retry-After", "soon")
+	assert.Equal(t, time.Duration(0), retryAfter(resp))
+}
+
+func TestTransient(t *testing.T) {
+	t.Parallel()
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "connection reset", err: fmt.Errorf("failed to download: %w", &url.Error{Op: "Get", URL: "https://api.github.com", Err: reset}), expected: true},
+		{name: "dns error", err: dnsError(), expected: true},
+		{name: "unexpected eof", err: fmt.Errorf("failed to download: %w", io.ErrUnexpectedEOF), expected: true},
+		{name: "took too long", err: ErrTookTooLong, expected: false},
+		{name: "canceled", err: &url.Error{Op: "Get", URL: "https://api.github.com", Err: context.Canceled}, expected: false},
+		{name: "not found", err: ErrPathNotFound, expected: false},
+		{name: "server error", err: fmt.Errorf("dir/file.txt: %w", &HTTPError{StatusCode: http.StatusBadGateway}), expected: true},
+		{name: "client error", err: &HTTPError{StatusCode: http.StatusNotFound}, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, transient(test.err))
+		})
+	}
+}
+
+// failingTransport fails the first request to the path with a reset
+// connection and sends the other requests with the default transport.
+type failingTransport struct {
+	path   string
+	failed atomic.Bool
+	calls  sync.Map
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n, _ := f.calls.LoadOrStore(req.URL.Path, new(atomic.Int32))
+	n.(*atomic.Int32).Add(1)
+	if req.URL.Path == f.path && f.failed.CompareAndSwap(false, true) {
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (f *failingTransport) count(path string) int32 {
+	n, ok := f.calls.Load(path)
+	if !ok {
+		return 0
+	}
+	return n.(*atomic.Int32).Load()
+}
+
+func TestDownloadRetryOperation(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	srv := httptest.NewServer(contentsMux(map[string]string{
+		fakeBase + "/a.txt": "a",
+		fakeBase + "/b.txt": "b",
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name    string
+		retries int
+		err     bool
+	}{
+		{name: "retry after transient error", retries: 1},
+		{name: "no operation retries", retries: 0, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base := &failingTransport{path: "/raw/" + fakeBase + "/b.txt"}
+			o := newOptions(WithRetryOperation(test.retries))
+			o.transport = base
+			o.backoff = time.Millisecond
+			c := newClient(o)
+			u, err := url.Parse(srv.URL + "/")
+			require.NoError(t, err)
+			c.BaseURL = u
+
+			g := fakeNew(repository(c, o))
+			err = g.Download(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+			if test.err {
+				require.Error(t, err)
+				assert.True(t, transient(err))
+				return
+			}
+			require.NoError(t, err)
+
+			for _, name := range []string{"a.txt", "b.txt"} {
+				b, err := os.ReadFile(fakeBase + "/" + name)
+				require.NoError(t, err)
+				assert.Equal(t, strings.TrimSuffix(name, ".txt"), string(b))
+			}
+			assert.Equal(t, int32(2), base.count("/repos/owner/repo/contents/"+fakeBase), "want the whole download repeated")
+			assert.Equal(t, int32(2), base.count("/raw/"+fakeBase+"/b.txt"))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package gitty
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// TreeEntry represents a listed file or symlink offered for selection.
+type TreeEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Size int    `json:"size"`
+	SHA  string `json:"sha"`
+}
+
+// selectFiles offers the listed files and symlinks to the selection hook and
+// keeps only the selected ones. An error of the hook cancels the download.
+func (g *GitHub) selectFiles(files, links []*github.RepositoryContent) ([]*github.RepositoryContent, []*github.RepositoryContent, error) {
+	entries := make([]TreeEntry, 0, len(files)+len(links))
+	for _, entry := range append(append([]*github.RepositoryContent{}, files...), links...) {
+		entries = append(entries, TreeEntry{
+			Path: entry.GetPath(),
+			Type: entry.GetType(),
+			Size: entry.GetSize(),
+			SHA:  entry.GetSHA(),
+		})
+	}
+
+	paths, err := g.opts.selection(entries)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to select files: %w", err)
+	}
+	selected := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		selected[p] = true
+	}
+
+	keep := func(entries []*github.RepositoryContent) []*github.RepositoryContent {
+		var kept []*github.RepositoryContent
+		for _, entry := range entries {
+			if selected[entry.GetPath()] {
+				kept = append(kept, entry)
+			}
+		}
+		return kept
+	}
+
+	return keep(files), keep(links), nil
+}
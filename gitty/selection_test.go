@@ -0,0 +1,83 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadSelect(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		fakeBase + "/a.txt":     "a",
+		fakeBase + "/b.txt":     "b",
+		fakeBase + "/sub/c.txt": "c",
+	}
+
+	var offered []TreeEntry
+	r := serverRepository(t, contentsMux(files))
+	r.opts.selection = func(entries []TreeEntry) ([]string, error) {
+		offered = entries
+		return []string{fakeBase + "/a.txt", fakeBase + "/sub/c.txt"}, nil
+	}
+	manifest, err := fakeNew(r).DownloadManifest(context.Background(), "https://github.com/owner/repo/tree/main/"+fakeBase)
+	require.NoError(t, err)
+
+	assert.Len(t, offered, len(files))
+	require.Len(t, manifest.Files, 2)
+	assert.Equal(t, fakeBase+"/a.txt", manifest.Files[0].Path)
+	assert.Equal(t, fakeBase+"/sub/c.txt", manifest.Files[1].Path)
+	_, err = os.Stat(fakeBase + "/b.txt")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDownloadSelectCanceled(t *testing.T) {
+	t.Parallel()
+	errCanceled := errors.New("selection canceled")
+	r := serverRepository(t, contentsMux(map[string]string{"dir/a.txt": "a"}))
+	r.opts.selection = func([]TreeEntry) ([]string, error) {
+		return nil, errCanceled
+	}
+	err := fakeNew(r).Download(context.Background(), "https://github.com/owner/repo/tree/main/dir")
+	require.ErrorIs(t, err, errCanceled)
+	_, err = os.Stat("dir")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDownloadSelectCoalesce(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/a.txt":     "a",
+		"dir/b.txt":     "b",
+		"dir/sub/c.txt": "c",
+	}
+	var offered []TreeEntry
+	r := archiveRepository(t, files, nil)
+	r.opts.selection = func(entries []TreeEntry) ([]string, error) {
+		offered = entries
+		return []string{"dir/sub/c.txt"}, nil
+	}
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	base := t.TempDir()
+
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	// The files listed from the tree are offered, and only the selected one
+	// is extracted from the archive.
+	assert.Len(t, offered, len(files))
+	assert.Equal(t, []string{"dir/sub/c.txt"}, savedFiles(t, base))
+	require.Len(t, result.Manifest.Files, 1)
+	assert.Equal(t, "dir/sub/c.txt", result.Manifest.Files[0].Path)
+}
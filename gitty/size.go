@@ -0,0 +1,56 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// ErrSizeLimitExceeded is returned when the contents are larger than the
+// sizes allowed by WithMaxTotalBytes or WithMaxFileBytes.
+var ErrSizeLimitExceeded = errors.New("size limit exceeded")
+
+// oversized reports whether a file of the listed size is larger than the
+// maximum file size, if one is set.
+func oversized(o options, size int64) bool {
+	return o.maxFileBytes > 0 && size > 0 && uint64(size) > o.maxFileBytes
+}
+
+// checkFileSizes returns the files that are not larger than the maximum file
+// size, judged by their listed size. Larger files are skipped if enabled, or
+// fail the download with ErrSizeLimitExceeded otherwise.
+func checkFileSizes(o options, w *warnings, files []*github.RepositoryContent) ([]*github.RepositoryContent, error) {
+	var kept []*github.RepositoryContent
+	for _, file := range files {
+		if oversized(o, int64(file.GetSize())) {
+			if !o.skipOversized {
+				return nil, fileSizeErr(file.GetPath(), int64(file.GetSize()), o.maxFileBytes)
+			}
+			w.add(WarnOversizedSkipped, file.GetPath(), "Skipping oversized file")
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, nil
+}
+
+// checkTotalSize reports whether the total listed size of the files is within
+// the maximum total size, so nothing is downloaded if it is exceeded.
+func checkTotalSize(o options, files []*github.RepositoryContent) error {
+	if total := estimateSize(files); total > o.maxTotalBytes {
+		return totalSizeErr(total, o.maxTotalBytes)
+	}
+	return nil
+}
+
+// fileSizeErr returns the error of a file larger than the maximum file size.
+func fileSizeErr(path string, size int64, limit uint64) error {
+	return fmt.Errorf("%w: %s is %d bytes, at most %d allowed", ErrSizeLimitExceeded, path, size, limit)
+}
+
+// totalSizeErr returns the error of contents larger than the maximum total
+// size.
+func totalSizeErr(total, limit uint64) error {
+	return fmt.Errorf("%w: %d bytes in total, at most %d allowed", ErrSizeLimitExceeded, total, limit)
+}
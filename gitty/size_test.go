@@ -0,0 +1,158 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sizeMux serves the files of contentsMux and counts the raw downloads.
+func sizeMux(files map[string]string) (http.Handler, *atomic.Int32) {
+	var raw atomic.Int32
+	contents := contentsMux(files)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/raw/") {
+			raw.Add(1)
+		}
+		contents.ServeHTTP(w, r)
+	}), &raw
+}
+
+func TestCheckFileSizes(t *testing.T) {
+	t.Parallel()
+	files := []*github.RepositoryContent{
+		{Path: ptr("small.txt"), Size: ptr(4)},
+		{Path: ptr("large.bin"), Size: ptr(10)},
+	}
+
+	_, err := checkFileSizes(options{maxFileBytes: 5}, nil, files)
+	require.ErrorIs(t, err, ErrSizeLimitExceeded)
+	require.ErrorContains(t, err, "large.bin is 10 bytes")
+
+	w := &warnings{}
+	kept, err := checkFileSizes(options{maxFileBytes: 5, skipOversized: true}, w, files)
+	require.NoError(t, err)
+	assert.Equal(t, files[:1], kept)
+	assert.Equal(t, []Warning{{Code: WarnOversizedSkipped, Path: "large.bin", Message: "Skipping oversized file"}}, w.all())
+
+	kept, err = checkFileSizes(options{maxFileBytes: 10}, nil, files)
+	require.NoError(t, err)
+	assert.Equal(t, files, kept)
+}
+
+func TestDownloadMaxTotalBytes(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/a.txt": "aaaa",
+		"dir/b.txt": "bbbb",
+	}
+	tests := []struct {
+		name  string
+		limit uint64
+		err   error
+	}{
+		{name: "exceeded", limit: 7, err: ErrSizeLimitExceeded},
+		{name: "within", limit: 8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			mux, raw := sizeMux(files)
+			r := serverRepository(t, mux)
+			r.opts.maxTotalBytes = test.limit
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+			base := t.TempDir()
+
+			_, err := r.downloadTo(context.Background(), base)
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				assert.Zero(t, raw.Load(), "content downloaded")
+				assert.Empty(t, savedFiles(t, base))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/a.txt", "dir/b.txt"}, savedFiles(t, base))
+		})
+	}
+}
+
+func TestDownloadMaxFileBytes(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"dir/small.txt": "small",
+		"dir/large.bin": "large content",
+	}
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		mux, raw := sizeMux(files)
+		r := serverRepository(t, mux)
+		r.opts.maxFileBytes = 5
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+		_, err := r.downloadTo(context.Background(), t.TempDir())
+		require.ErrorIs(t, err, ErrSizeLimitExceeded)
+		assert.Zero(t, raw.Load(), "content downloaded")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		t.Parallel()
+		mux, _ := sizeMux(files)
+		r := serverRepository(t, mux)
+		r.opts.maxFileBytes = 5
+		r.opts.skipOversized = true
+		require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+		base := t.TempDir()
+
+		result, err := r.downloadTo(context.Background(), base)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dir/small.txt"}, savedFiles(t, base))
+		assert.Equal(t, 1, result.Summary.Skipped)
+	})
+}
+
+func TestArchiveSizeLimits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		opts     options
+		expected []string
+		err      error
+	}{
+		{name: "file limit", opts: options{maxFileBytes: 4}, err: ErrSizeLimitExceeded},
+		{name: "skip oversized", opts: options{maxFileBytes: 4, skipOversized: true}, expected: []string{"dir/small.txt"}},
+		{name: "total limit", opts: options{maxTotalBytes: 8}, err: ErrSizeLimitExceeded},
+		{name: "within", opts: options{maxTotalBytes: 9, maxFileBytes: 5}, expected: []string{"dir/large.txt", "dir/small.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r := archiveRepository(t, map[string]string{
+				"dir/small.txt": "data",
+				"dir/large.txt": "large",
+			}, nil)
+			r.opts.maxFileBytes = test.opts.maxFileBytes
+			r.opts.maxTotalBytes = test.opts.maxTotalBytes
+			r.opts.skipOversized = test.opts.skipOversized
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+			base := t.TempDir()
+
+			_, err := r.downloadTo(context.Background(), base)
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				assert.Empty(t, savedFiles(t, base))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, savedFiles(t, base))
+		})
+	}
+}
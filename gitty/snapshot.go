@@ -0,0 +1,191 @@
+package gitty
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// ArchiveFormat represents the format of a repository archive.
+type ArchiveFormat string
+
+const (
+	// ArchiveTarGz represents a gzipped tarball.
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	// ArchiveZip represents a zip archive.
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+var (
+	ErrNotValidArchiveFormat = errors.New("archive format must be tar.gz or zip")
+	ErrNotRepoRoot           = errors.New("archive url must point at the repository root")
+)
+
+// streamArchive copies the archive of the repository at the ref in the given
+// format to w as it is downloaded. If stripping the archive prefix is enabled,
+// the top-level directory of the entries, named after the owner, repository
+// and commit, is removed first.
+func (g *GitHub) streamArchive(ctx context.Context, format ArchiveFormat, w io.Writer) error {
+	var archiveFormat github.ArchiveFormat
+	switch format {
+	case ArchiveTarGz:
+		archiveFormat = github.Tarball
+	case ArchiveZip:
+		archiveFormat = github.Zipball
+	default:
+		return fmt.Errorf("%w: %s", ErrNotValidArchiveFormat, format)
+	}
+	if g.Path != "" {
+		return fmt.Errorf("%w: %s", ErrNotRepoRoot, g.Path)
+	}
+	if err := g.resolveLatest(ctx); err != nil {
+		return err
+	}
+	// The archive of no ref is the archive of the default branch.
+	ref := g.Ref
+	if g.ref() == defaultRef {
+		ref = nil
+	}
+
+	link, _, err := g.Client.GetArchiveLink(ctx, g.Owner, g.Repo, archiveFormat, ref, 1)
+	if err != nil {
+		return fmt.Errorf("failed to get archive: %w", g.notFound(ctx, g.Owner, g.Repo, g.ref(), err))
+	}
+
+	resp, err := g.Client.Get(ctx, link.String())
+	if err != nil {
+		return fmt.Errorf("failed to get archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get archive: %w", newHTTPError(resp))
+	}
+
+	body := &ctxReader{ctx: ctx, r: resp.Body}
+	switch {
+	case !g.opts.stripArchivePrefix:
+		_, err = io.Copy(w, body)
+	case format == ArchiveZip:
+		err = stripZip(w, body)
+	default:
+		err = stripTarball(w, body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get archive: %w", err)
+	}
+
+	return nil
+}
+
+// stripPrefix returns the name of the archive entry without its top-level
+// directory. It reports false for the top-level directory itself and for
+// entries outside of it, such as the global header of a tarball.
+func stripPrefix(name string) (string, bool) {
+	_, rest, ok := strings.Cut(name, "/")
+	return rest, ok && rest != ""
+}
+
+// stripTarball copies the gzipped tarball r to w with the top-level directory
+// stripped from its entries.
+func stripTarball(w io.Writer, r io.Reader) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// The global header holds the commit SHA, so it is kept as is.
+		if hdr.Typeflag != tar.TypeXGlobalHeader {
+			name, ok := stripPrefix(hdr.Name)
+			if !ok {
+				continue
+			}
+			hdr.Name = name
+			if hdr.Typeflag == tar.TypeLink {
+				hdr.Linkname, _ = stripPrefix(hdr.Linkname)
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// stripZip copies the zip archive r to w with the top-level directory
+// stripped from its entries. The directory of a zip archive is at its end, so
+// the archive is buffered in a temporary file first. The entries are copied
+// without being decompressed.
+func stripZip(w io.Writer, r io.Reader) error {
+	f, err := os.CreateTemp("", tempPrefix+"*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := zw.SetComment(zr.Comment); err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		name, ok := stripPrefix(file.Name)
+		if !ok {
+			continue
+		}
+		hdr := file.FileHeader
+		hdr.Name = name
+
+		raw, err := file.OpenRaw()
+		if err != nil {
+			return err
+		}
+		fw, err := zw.CreateRaw(&hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(fw, raw); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,209 @@
+package gitty
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipball creates a repository zip archive of the given files, keyed by
+// repository path, under the top-level directory of an archive.
+func zipball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	require.NoError(t, zw.SetComment("abc1234"))
+
+	_, err := zw.Create("owner-repo-abc1234/")
+	require.NoError(t, err)
+	for path, content := range files {
+		w, err := zw.Create("owner-repo-abc1234/" + path)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// tarEntries returns the contents of the regular files of the gzipped tarball
+// keyed by name, along with the names of all entries.
+func tarEntries(t *testing.T, b []byte) (map[string]string, []string) {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, names
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		if hdr.Typeflag == tar.TypeReg {
+			content, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[hdr.Name] = string(content)
+		}
+	}
+}
+
+// zipEntries returns the contents of the files of the zip archive keyed by
+// name, along with the names of all entries.
+func zipEntries(t *testing.T, b []byte) (map[string]string, []string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	files := map[string]string{}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[file.Name] = string(content)
+	}
+	return files, names
+}
+
+// archiveMux serves the tarball and zipball of the repository at the main
+// branch and at the default branch.
+func archiveMux(tarGz, zipped []byte) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, ref := range []string{"", "/main"} {
+		mux.HandleFunc("GET /repos/owner/repo/tarball"+ref, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://"+r.Host+"/codeload/archive.tar.gz", http.StatusFound)
+		})
+		mux.HandleFunc("GET /repos/owner/repo/zipball"+ref, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://"+r.Host+"/codeload/archive.zip", http.StatusFound)
+		})
+	}
+	mux.HandleFunc("GET /codeload/archive.tar.gz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(tarGz)
+	})
+	mux.HandleFunc("GET /codeload/archive.zip", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(zipped)
+	})
+	return mux
+}
+
+func TestGetArchive(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{"README.md": "readme", "src/main.go": "package main"}
+	tarGz := tarball(t, files, nil)
+	zipped := zipball(t, files)
+
+	tests := []struct {
+		name     string
+		url      string
+		format   ArchiveFormat
+		strip    bool
+		entries  func(t *testing.T, b []byte) (map[string]string, []string)
+		expected []string
+	}{
+		{
+			name:     "tarball",
+			url:      "https://github.com/owner/repo/tree/main/",
+			format:   ArchiveTarGz,
+			entries:  tarEntries,
+			expected: []string{"pax_global_header", "owner-repo-abc1234/", "owner-repo-abc1234/README.md", "owner-repo-abc1234/src/main.go"},
+		},
+		{
+			name:     "tarball stripped",
+			url:      "https://github.com/owner/repo",
+			format:   ArchiveTarGz,
+			strip:    true,
+			entries:  tarEntries,
+			expected: []string{"pax_global_header", "README.md", "src/main.go"},
+		},
+		{
+			name:     "zip",
+			url:      "https://github.com/owner/repo",
+			format:   ArchiveZip,
+			entries:  zipEntries,
+			expected: []string{"owner-repo-abc1234/", "owner-repo-abc1234/README.md", "owner-repo-abc1234/src/main.go"},
+		},
+		{
+			name:     "zip stripped",
+			url:      "https://github.com/owner/repo/tree/main/",
+			format:   ArchiveZip,
+			strip:    true,
+			entries:  zipEntries,
+			expected: []string{"README.md", "src/main.go"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r := serverRepository(t, archiveMux(tarGz, zipped))
+			r.opts.stripArchivePrefix = test.strip
+			var buf bytes.Buffer
+
+			err := fakeNew(r).GetArchive(context.Background(), test.url, test.format, &buf)
+			require.NoError(t, err)
+
+			got, names := test.entries(t, buf.Bytes())
+			assert.ElementsMatch(t, test.expected, names)
+			for path, content := range files {
+				if !test.strip {
+					path = "owner-repo-abc1234/" + path
+				}
+				assert.Equal(t, content, got[path], path)
+			}
+		})
+	}
+}
+
+func TestGetArchiveRaw(t *testing.T) {
+	t.Parallel()
+	tarGz := tarball(t, map[string]string{"README.md": "readme"}, nil)
+	r := serverRepository(t, archiveMux(tarGz, nil))
+	var buf bytes.Buffer
+
+	err := fakeNew(r).GetArchive(context.Background(), "https://github.com/owner/repo", ArchiveTarGz, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, tarGz, buf.Bytes(), "want the archive as served")
+}
+
+func TestGetArchiveError(t *testing.T) {
+	t.Parallel()
+	g := fakeNew(serverRepository(t, archiveMux([]byte("not gzip"), []byte("not zip"))))
+	var buf bytes.Buffer
+
+	err := g.GetArchive(context.Background(), "https://github.com/owner/repo", "tar.xz", &buf)
+	require.ErrorIs(t, err, ErrNotValidArchiveFormat)
+
+	err = g.GetArchive(context.Background(), "https://github.com/owner/repo/tree/main/dir", ArchiveTarGz, &buf)
+	require.ErrorIs(t, err, ErrNotRepoRoot)
+
+	err = g.GetArchive(context.Background(), "https://example.com/owner/repo", ArchiveTarGz, &buf)
+	require.ErrorIs(t, err, ErrNotValidURL)
+
+	err = fakeNew(fakeRepository(&mockError{})).GetArchive(context.Background(), "https://github.com/owner/repo", ArchiveTarGz, &buf)
+	require.Error(t, err)
+
+	r := serverRepository(t, archiveMux([]byte("not gzip"), []byte("not zip")))
+	r.opts.stripArchivePrefix = true
+	for _, format := range []ArchiveFormat{ArchiveTarGz, ArchiveZip} {
+		err = fakeNew(r).GetArchive(context.Background(), "https://github.com/owner/repo", format, &buf)
+		require.ErrorContains(t, err, "failed to get archive")
+	}
+}
@@ -0,0 +1,84 @@
+package gitty
+
+import (
+	"io"
+	"slices"
+	"sync"
+)
+
+// Summary represents the counts of a download.
+type Summary struct {
+	// Written is the number of files written.
+	Written int `json:"written"`
+	// Skipped is the number of files skipped, such as empty or existing
+	// files, as reported by the warnings.
+	Skipped int `json:"skipped"`
+	// Bytes is the number of bytes written, before compression.
+	Bytes int64 `json:"bytes"`
+	// Paths is the paths of the written files relative to the directory they
+	// are saved under, sorted. Unlike the repository paths of the manifest,
+	// they reflect routing and flattening, and are the paths checked by
+	// WithVerifyChecksums.
+	Paths []string `json:"paths"`
+}
+
+// skippedCodes represents the warnings that report a skipped file.
+var skippedCodes = []WarningCode{WarnSymlinkSkipped, WarnEmptySkipped, WarnRemovedSkipped, WarnExistingSkipped, WarnOversizedSkipped, WarnPresentSkipped, WarnUnchangedSkipped, WarnCaseCollisionSkipped, WarnSubmoduleSkipped}
+
+// newSummary creates the summary of the written files and the warnings.
+func newSummary(w *written, list []Warning) Summary {
+	s := w.summary()
+	for _, warning := range list {
+		if slices.Contains(skippedCodes, warning.Code) {
+			s.Skipped++
+		}
+	}
+	return s
+}
+
+// written collects the paths and sizes of the written files. It is safe for
+// concurrent use.
+type written struct {
+	mu    sync.Mutex
+	paths []string
+	bytes int64
+}
+
+// add records the file of the relative path and size, if w is not nil.
+func (w *written) add(path string, n int64) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paths = append(w.paths, path)
+	w.bytes += n
+}
+
+// summary returns the summary of the recorded files.
+func (w *written) summary() Summary {
+	if w == nil {
+		return Summary{Paths: []string{}}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Summary{
+		Written: len(w.paths),
+		Bytes:   w.bytes,
+		Paths:   slices.Sorted(slices.Values(w.paths)),
+	}
+}
+
+// countReader represents a reader that counts the bytes it reads.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
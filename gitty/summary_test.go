@@ -0,0 +1,64 @@
+package gitty
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadSummary(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	existing := filepath.Join(base, "dir", "b.txt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(existing), 0o700))
+	require.NoError(t, os.WriteFile(existing, []byte("local"), 0o600))
+	files := map[string]string{
+		"dir/a.txt":       "aaaa",
+		"dir/b.txt":       "bbbbbb",
+		"dir/empty.txt":   "",
+		"dir/sub/c.txt":   "cc",
+		"dir/sub/d/e.txt": "eeeee",
+	}
+
+	r := serverRepository(t, contentsMux(files))
+	r.opts.overwrite = OverwriteSkip
+	r.opts.skipEmpty = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	expected := Summary{
+		Written: 3,
+		Skipped: 2,
+		Bytes:   11,
+		Paths:   []string{"dir/a.txt", "dir/sub/c.txt", "dir/sub/d/e.txt"},
+	}
+	assert.Equal(t, expected, result.Summary)
+
+	r.opts.dryRun = true
+	result, err = r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+	expected = Summary{
+		Written: 4,
+		Skipped: 1,
+		Bytes:   17,
+		Paths:   []string{"dir/a.txt", "dir/b.txt", "dir/sub/c.txt", "dir/sub/d/e.txt"},
+	}
+	assert.Equal(t, expected, result.Summary)
+}
+
+func TestWrittenSummary(t *testing.T) {
+	t.Parallel()
+	var w *written
+	w.add("a.txt", 1)
+	assert.Equal(t, Summary{Paths: []string{}}, w.summary())
+
+	w = &written{}
+	w.add("b.txt", 2)
+	w.add("a.txt", 3)
+	assert.Equal(t, Summary{Written: 2, Bytes: 5, Paths: []string{"a.txt", "b.txt"}}, w.summary())
+}
@@ -0,0 +1,281 @@
+package gitty
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// maxLinkSize represents the maximum number of bytes read as a symlink target.
+const maxLinkSize = 4096
+
+// splitLinks separates the symlinks from the regular files.
+func splitLinks(entries []*github.RepositoryContent) (files, links []*github.RepositoryContent) {
+	for _, entry := range entries {
+		if entry.GetType() == "symlink" {
+			links = append(links, entry)
+			continue
+		}
+		files = append(files, entry)
+	}
+	return files, links
+}
+
+// materialize saves each symlink whose relative target lies beneath the
+// GitHub path as a symlink. If following symlinks is enabled, or the target
+// lies outside of it, the symlink is saved as a regular file with the content
+// of its target instead. Only relative targets that resolve to one of the
+// downloaded files are followed, the other symlinks are skipped. If following
+// directory symlinks is enabled, the other targets within the repository are
+// downloaded at the symlink path instead.
+func (g *GitHub) materialize(ctx context.Context, links, files []*github.RepositoryContent) error {
+	downloaded := make(map[string]*github.RepositoryContent, len(files))
+	for _, file := range files {
+		downloaded[file.GetPath()] = file
+	}
+
+	for _, link := range links {
+		target, err := g.linkTarget(ctx, link)
+		if err != nil {
+			return fmt.Errorf("failed to resolve symlink: %w", err)
+		}
+
+		resolved, ok := resolveLink(link.GetPath(), target)
+		// Symlinks whose targets lie outside the GitHub path would lead out of
+		// the base directory once saved, so they are never created.
+		if ok && !g.opts.followLinks && g.opts.records == nil && underPath(g.Path, resolved) {
+			if err := g.symlink(ctx, link, resolved, downloaded[resolved]); err != nil {
+				return fmt.Errorf("failed to create symlink: %w", err)
+			}
+			continue
+		}
+		if ok && downloaded[resolved] == nil && g.opts.followDirLinks {
+			if err := g.linkDir(ctx, link.GetPath(), link.GetPath(), resolved, nil); err != nil {
+				return fmt.Errorf("failed to resolve symlink: %w", err)
+			}
+			continue
+		}
+		if !ok || downloaded[resolved] == nil {
+			g.warnings.add(WarnSymlinkSkipped, link.GetPath(), "Skipping symlink")
+			continue
+		}
+
+		if err := g.copyFile(ctx, downloaded[resolved], link.GetPath()); err != nil {
+			return fmt.Errorf("failed to resolve symlink: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// linkDir downloads the contents of the repository directory dir, the target
+// of the symlink at the repository path src, at the repository path dst. The
+// contents are filtered and downloaded at their path beneath dst like the
+// listed files, so the filters and limits of the options apply to them as
+// they are saved. The symlinks within dir are followed in turn. chain holds
+// the directories followed before, so a symlink leading back into one of
+// them, or to an ancestor of itself or of dst, is reported as a cycle and
+// skipped.
+func (g *GitHub) linkDir(ctx context.Context, src, dst, dir string, chain []string) error {
+	if underPath(dir, src) || underPath(dir, dst) || slices.Contains(chain, dir) {
+		g.warnings.add(WarnSymlinkCycle, dst, "Skipping symlink cycle")
+		return nil
+	}
+
+	entries, err := g.list(ctx, dir)
+	if errors.Is(err, ErrPathNotFound) {
+		g.warnings.add(WarnSymlinkSkipped, dst, "Skipping symlink")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The symlinks are resolved from their path within dir.
+	origins := make(map[string]string, len(entries))
+	moved := make([]*github.RepositoryContent, 0, len(entries))
+	for _, entry := range entries {
+		p := dst + strings.TrimPrefix(entry.GetPath(), dir)
+		origins[p] = entry.GetPath()
+		if mode, ok := g.modes[entry.GetPath()]; ok {
+			g.modes[p] = mode
+		}
+		e := *entry
+		e.Path = github.Ptr(p)
+		moved = append(moved, &e)
+	}
+
+	files, links, err := g.filterEntries(ctx, moved)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		if err := g.fetch(ctx, files); err != nil {
+			return err
+		}
+	}
+
+	chain = append(chain, dir)
+	for _, link := range links {
+		target, err := g.linkTarget(ctx, link)
+		if err != nil {
+			return err
+		}
+		resolved, ok := resolveLink(origins[link.GetPath()], target)
+		if !ok {
+			g.warnings.add(WarnSymlinkSkipped, link.GetPath(), "Skipping symlink")
+			continue
+		}
+		if err := g.linkDir(ctx, origins[link.GetPath()], link.GetPath(), resolved, chain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkTarget returns the target of the symlink. The target is downloaded if
+// it is not known from the listing.
+func (g *GitHub) linkTarget(ctx context.Context, link *github.RepositoryContent) (string, error) {
+	if link.GetTarget() != "" {
+		return link.GetTarget(), nil
+	}
+
+	resp, err := g.Client.Get(ctx, link.GetDownloadURL())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %w", link.GetPath(), newHTTPError(resp))
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxLinkSize))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// copyFile saves the downloaded file src again at the repository path dst.
+// When writing records, the file is not saved, so it is downloaded again. A
+// file saved gzipped is copied from its content, which is compressed again as
+// it is saved.
+func (g *GitHub) copyFile(ctx context.Context, src *github.RepositoryContent, dst string) error {
+	if g.opts.records != nil {
+		return g.getFile(ctx, src.GetDownloadURL(), dst)
+	}
+
+	p, err := localPath(g.opts, g.Path, src.GetPath())
+	if err != nil {
+		return err
+	}
+	mode := g.fileMode(src.GetPath())
+
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) && g.opts.gzip {
+		f, err = os.Open(p + gzipExt)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return saveFileMode(ctx, g.opts, g.Path, dst, mode, zr)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return saveFileMode(ctx, g.opts, g.Path, dst, mode, f)
+}
+
+// symlink saves the symlink link as a symlink to the repository path resolved,
+// relative to its own directory. If symlinks are not supported, such as on
+// Windows without the privilege to create them, the downloaded file src the
+// target resolves to is copied instead, and the symlink is skipped if there
+// is none.
+func (g *GitHub) symlink(ctx context.Context, link *github.RepositoryContent, resolved string, src *github.RepositoryContent) error {
+	p, err := localPath(g.opts, g.Path, link.GetPath())
+	if err != nil {
+		return err
+	}
+	to, err := localPath(g.opts, g.Path, resolved)
+	if err != nil {
+		return err
+	}
+	// A symlink to a file saved gzipped links to the gzipped file and is
+	// named like it.
+	gz := g.opts.gzip && gzipped(to)
+	if gz {
+		p += gzipExt
+		to += gzipExt
+	}
+	target, err := filepath.Rel(filepath.Dir(p), to)
+	if err != nil {
+		return err
+	}
+	if keep, err := keepExisting(g.opts, link.GetPath(), p); err != nil || keep {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	symlink := g.opts.symlink
+	if symlink == nil {
+		symlink = os.Symlink
+	}
+	// The symlink is created under a temporary name first, so an existing
+	// file is replaced at once, as when saving.
+	tmp := filepath.Join(filepath.Dir(p), tempPrefix+g.opts.runID+"-"+filepath.Base(p))
+	if err := symlink(target, tmp); err != nil {
+		if src == nil {
+			g.warnings.add(WarnSymlinkSkipped, link.GetPath(), "Skipping symlink")
+			return nil
+		}
+		fmt.Println("Copying:", p)
+		return g.copyFile(ctx, src, link.GetPath())
+	}
+	defer os.Remove(tmp)
+
+	fmt.Println("Linking:", p)
+	if err := os.Rename(tmp, p); err != nil {
+		return err
+	}
+
+	g.opts.written.add(relPath(g.opts, g.Path, link.GetPath(), gz), 0)
+	return nil
+}
+
+// resolveLink resolves the target of the symlink at the repository path link.
+// It reports false if the target is absolute or lies outside the repository.
+func resolveLink(link, target string) (string, bool) {
+	if target == "" || path.IsAbs(target) {
+		return "", false
+	}
+
+	resolved := path.Join(path.Dir(link), target)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+
+	return resolved, true
+}
@@ -0,0 +1,298 @@
+package gitty
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitLinks(t *testing.T) {
+	t.Parallel()
+	file := &github.RepositoryContent{Type: ptr("file"), Path: ptr("file.txt")}
+	link := &github.RepositoryContent{Type: ptr("symlink"), Path: ptr("link.txt")}
+
+	files, links := splitLinks([]*github.RepositoryContent{file, link})
+	assert.Equal(t, []*github.RepositoryContent{file}, files)
+	assert.Equal(t, []*github.RepositoryContent{link}, links)
+}
+
+func TestResolveLink(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		link     string
+		target   string
+		expected string
+		ok       bool
+	}{
+		{name: "sibling", link: "dir/link", target: "file.txt", expected: "dir/file.txt", ok: true},
+		{name: "parent", link: "dir/sub/link", target: "../file.txt", expected: "dir/file.txt", ok: true},
+		{name: "current", link: "dir/link", target: "./sub/file.txt", expected: "dir/sub/file.txt", ok: true},
+		{name: "outside repository", link: "dir/link", target: "../../etc/passwd", ok: false},
+		{name: "absolute", link: "dir/link", target: "/etc/passwd", ok: false},
+		{name: "empty", link: "dir/link", target: "", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			resolved, ok := resolveLink(test.link, test.target)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.expected, resolved)
+		})
+	}
+}
+
+func TestMaterialize(t *testing.T) {
+	t.Parallel()
+	errSymlink := errors.New("symlinks unsupported")
+	tests := []struct {
+		name    string
+		follow  bool
+		symlink func(oldname, newname string) error
+		linked  bool
+	}{
+		{name: "symlink", linked: runtime.GOOS != "windows"},
+		{name: "follow", follow: true},
+		{name: "symlinks unsupported", symlink: func(string, string) error { return errSymlink }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			files := map[string]string{
+				"repo/" + fakeBase + "/a.txt":     "content a",
+				"repo/" + fakeBase + "/sub/b.txt": "content b",
+				"repo/outside.txt":                "not downloaded",
+			}
+			links := map[string]string{
+				"repo/" + fakeBase + "/link_a.txt":     "a.txt",
+				"repo/" + fakeBase + "/sub/link_a.txt": "../a.txt",
+				"repo/" + fakeBase + "/link_b.txt":     "sub/b.txt",
+				"repo/" + fakeBase + "/outside.txt":    "../outside.txt",
+				"repo/" + fakeBase + "/escape.txt":     "../../../etc/passwd",
+				"repo/" + fakeBase + "/absolute.txt":   "/etc/passwd",
+			}
+
+			r := serverRepository(t, linksMux(files, links))
+			r.opts.followLinks = test.follow
+			r.opts.symlink = test.symlink
+			g := fakeNew(r)
+			err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+fakeBase)
+			require.NoError(t, err)
+
+			for path, expected := range map[string]string{
+				"/link_a.txt":     "content a",
+				"/sub/link_a.txt": "content a",
+				"/link_b.txt":     "content b",
+			} {
+				b, err := os.ReadFile(fakeBase + path)
+				require.NoError(t, err)
+				assert.Equal(t, expected, string(b))
+
+				info, err := os.Lstat(fakeBase + path)
+				require.NoError(t, err)
+				assert.Equal(t, test.linked, info.Mode()&os.ModeSymlink != 0, path)
+				if test.linked {
+					target, err := os.Readlink(fakeBase + path)
+					require.NoError(t, err)
+					assert.Equal(t, filepath.FromSlash(links["repo/"+fakeBase+path]), target)
+				}
+			}
+			for _, path := range []string{"/outside.txt", "/escape.txt", "/absolute.txt"} {
+				_, err := os.Lstat(fakeBase + path)
+				require.ErrorIs(t, err, os.ErrNotExist)
+			}
+		})
+	}
+}
+
+func TestMaterializeGzip(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		follow bool
+		linked bool
+	}{
+		{name: "symlink", linked: runtime.GOOS != "windows"},
+		{name: "follow", follow: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			files := map[string]string{"dir/a.txt": "content a", "dir/b.bin": "\x00binary"}
+			links := map[string]string{"dir/link_a.txt": "a.txt", "dir/link_b.bin": "b.bin"}
+			r := serverRepository(t, linksMux(files, links))
+			r.opts.followLinks = test.follow
+			r.opts.gzip = true
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+			base := t.TempDir()
+
+			result, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+			expected := []string{"dir/a.txt.gz", "dir/b.bin", "dir/link_a.txt.gz", "dir/link_b.bin"}
+			assert.Equal(t, expected, savedFiles(t, base))
+			assert.ElementsMatch(t, expected, result.Summary.Paths)
+			if test.linked {
+				target, err := os.Readlink(filepath.Join(base, "dir", "link_a.txt.gz"))
+				require.NoError(t, err)
+				assert.Equal(t, "a.txt.gz", target)
+			}
+
+			f, err := os.Open(filepath.Join(base, "dir", "link_a.txt.gz"))
+			require.NoError(t, err)
+			defer f.Close()
+			zr, err := gzip.NewReader(f)
+			require.NoError(t, err)
+			b, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			assert.Equal(t, "content a", string(b))
+		})
+	}
+}
+
+func TestSymlinkDangling(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	errSymlink := errors.New("symlinks unsupported")
+	r := &GitHub{Path: "dir", opts: options{symlink: func(string, string) error { return errSymlink }, written: &written{}}, warnings: &warnings{}}
+	link := &github.RepositoryContent{Path: ptr("dir/link")}
+	r.opts.router = func(string) string { return base }
+
+	err := r.symlink(context.Background(), link, "dir/missing", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Warning{{Code: WarnSymlinkSkipped, Path: "dir/link", Message: "Skipping symlink"}}, r.warnings.all())
+	_, err = os.Lstat(filepath.Join(base, "dir", "link"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMaterializeError(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /raw/link", http.NotFound)
+	r := serverRepository(t, mux)
+	srvURL := r.Client.(*service).client.BaseURL.String()
+
+	tests := []struct {
+		name  string
+		repo  *GitHub
+		links []*github.RepositoryContent
+		files []*github.RepositoryContent
+	}{
+		{
+			name:  "error get target",
+			repo:  &GitHub{Client: &mockError{}},
+			links: []*github.RepositoryContent{{Path: ptr("link"), DownloadURL: ptr("https://example.com/link")}},
+		},
+		{
+			name:  "error target status",
+			repo:  r,
+			links: []*github.RepositoryContent{{Path: ptr("link"), DownloadURL: ptr(srvURL + "raw/link")}},
+		},
+		{
+			name:  "error missing target file",
+			repo:  &GitHub{Client: &mockError{}, Path: "missing_dir", opts: options{followLinks: true}},
+			links: []*github.RepositoryContent{{Path: ptr("missing_dir/link"), Target: ptr("file.txt")}},
+			files: []*github.RepositoryContent{{Path: ptr("missing_dir/file.txt")}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			err := test.repo.materialize(context.Background(), test.links, test.files)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMaterializeDirSymlinks(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeBase)
+		require.NoError(t, err)
+	})
+	files := map[string]string{
+		"repo/" + fakeBase + "/a.txt":  "content a",
+		"repo/common/c.txt":            "content c",
+		"repo/common/sub/d.txt":        "content d",
+		"repo/shared/nested/e.txt":     "content e",
+		"repo/" + fakeBase + "x/f.txt": "similar prefix",
+	}
+	links := map[string]string{
+		"repo/" + fakeBase + "/common": "../common",
+		"repo/" + fakeBase + "/loop":   ".",
+		"repo/common/nested":           "../shared/nested",
+		"repo/common/back":             "../" + fakeBase,
+	}
+
+	r := serverRepository(t, linksMux(files, links))
+	r.opts.followDirLinks = true
+	r.opts.followLinks = true
+	g := fakeNew(r)
+	result, err := g.DownloadResult(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+fakeBase)
+	require.NoError(t, err)
+
+	for path, expected := range map[string]string{
+		"/a.txt":               "content a",
+		"/common/c.txt":        "content c",
+		"/common/sub/d.txt":    "content d",
+		"/common/nested/e.txt": "content e",
+	} {
+		b, err := os.ReadFile(fakeBase + path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(b))
+	}
+	for _, path := range []string{"/loop", "/common/back"} {
+		_, err := os.Stat(fakeBase + path)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+	assert.ElementsMatch(t, []Warning{
+		{Code: WarnSymlinkCycle, Path: "repo/" + fakeBase + "/loop", Message: "Skipping symlink cycle"},
+		{Code: WarnSymlinkCycle, Path: "repo/" + fakeBase + "/common/back", Message: "Skipping symlink cycle"},
+	}, result.Warnings)
+}
+
+func TestMaterializeDirSymlinksFilters(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"repo/dir/a.txt":       "content a",
+		"repo/common/c.txt":    "content c",
+		"repo/common/skip.log": "excluded",
+		"repo/common/big.txt":  "larger than the limit",
+	}
+	links := map[string]string{"repo/dir/common": "../common"}
+
+	r := serverRepository(t, linksMux(files, links))
+	r.opts.followDirLinks = true
+	r.opts.exclude = []string{"*.log"}
+	r.opts.maxFileBytes = uint64(len("content c"))
+	r.opts.skipOversized = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/repo/dir"))
+	base := t.TempDir()
+
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dir/a.txt", "dir/common/c.txt"}, savedFiles(t, base))
+	assert.Equal(t, []Warning{{Code: WarnOversizedSkipped, Path: "repo/dir/common/big.txt", Message: "Skipping oversized file"}}, result.Warnings)
+}
@@ -0,0 +1,83 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// throttle represents a token bucket that limits the rate of requests. Each
+// request takes a token; when no token is left, the request waits for the
+// next one. The bucket holds a single token, so requests are evenly spaced.
+type throttle struct {
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	last   time.Time
+	rate   float64
+	tokens float64
+	mu     sync.Mutex
+}
+
+// newThrottle creates a throttle allowing rate requests per second.
+func newThrottle(rate float64) *throttle {
+	return &throttle{
+		now:    time.Now,
+		sleep:  sleep,
+		last:   time.Now(),
+		rate:   rate,
+		tokens: 1,
+	}
+}
+
+// wait blocks until the request may be sent or ctx is done.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := t.now()
+	t.tokens = min(1, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+	// Reserve a token, possibly ahead of time, so that waiting requests are
+	// served in turn.
+	t.tokens--
+	var d time.Duration
+	if t.tokens < 0 {
+		d = time.Duration(-t.tokens / t.rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+
+	if d == 0 {
+		return nil
+	}
+	return t.sleep(ctx, d)
+}
+
+// sleep pauses for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttleTransport represents an http.RoundTripper that limits the rate of
+// requests.
+type throttleTransport struct {
+	base     http.RoundTripper
+	throttle *throttle
+}
+
+// Ensure throttleTransport implements the http.RoundTripper interface.
+var _ http.RoundTripper = (*throttleTransport)(nil)
+
+// RoundTrip waits for the throttle and executes a single HTTP transaction.
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.throttle.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
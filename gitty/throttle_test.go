@@ -0,0 +1,134 @@
+package gitty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock represents a clock that only advances while sleeping.
+type fakeClock struct {
+	now time.Time
+	mu  sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// fakeThrottle creates a throttle driven by the fake clock.
+func fakeThrottle(c *fakeClock, rate float64) *throttle {
+	th := newThrottle(rate)
+	th.now = c.Now
+	th.sleep = c.Sleep
+	th.last = c.Now()
+	return th
+}
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		rate     float64
+		requests int
+		spacing  time.Duration
+	}{
+		{name: "two requests per second", rate: 2, requests: 5, spacing: 500 * time.Millisecond},
+		{name: "ten requests per second", rate: 10, requests: 5, spacing: 100 * time.Millisecond},
+		{name: "one request every two seconds", rate: 0.5, requests: 3, spacing: 2 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c := &fakeClock{now: time.Unix(0, 0)}
+			th := fakeThrottle(c, test.rate)
+
+			var sent []time.Time
+			for range test.requests {
+				err := th.wait(context.Background())
+				require.NoError(t, err)
+				sent = append(sent, c.Now())
+			}
+
+			// The first request is sent immediately.
+			assert.Equal(t, time.Unix(0, 0), sent[0])
+			for i := 1; i < len(sent); i++ {
+				assert.Equal(t, test.spacing, sent[i].Sub(sent[i-1]))
+			}
+		})
+	}
+}
+
+func TestThrottleIdle(t *testing.T) {
+	t.Parallel()
+	c := &fakeClock{now: time.Unix(0, 0)}
+	th := fakeThrottle(c, 1)
+
+	err := th.wait(context.Background())
+	require.NoError(t, err)
+
+	// Idle time does not accumulate more than a single token.
+	_ = c.Sleep(context.Background(), 10*time.Second)
+	start := c.Now()
+	for range 2 {
+		err := th.wait(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, time.Second, c.Now().Sub(start))
+}
+
+func TestSleep(t *testing.T) {
+	t.Parallel()
+	err := sleep(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = sleep(ctx, time.Hour)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestThrottleTransport(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := &fakeClock{now: time.Unix(0, 0)}
+	client := &http.Client{
+		Transport: &throttleTransport{base: http.DefaultTransport, throttle: fakeThrottle(c, 4)},
+	}
+	for range 3 {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, 500*time.Millisecond, c.Now().Sub(time.Unix(0, 0)))
+
+	th := newThrottle(1)
+	th.tokens = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := (&throttleTransport{base: http.DefaultTransport, throttle: th}).RoundTrip(req) //nolint:bodyclose // No response on error.
+	assert.Nil(t, resp)
+	assert.Equal(t, context.Canceled, err)
+}
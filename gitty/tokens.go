@@ -0,0 +1,87 @@
+package gitty
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenTransport represents an http.RoundTripper that authenticates requests
+// with several tokens in turn. A token whose rate limit is exhausted is
+// skipped until its rate limit resets.
+type tokenTransport struct {
+	base   http.RoundTripper
+	now    func() time.Time
+	mu     sync.Mutex
+	tokens []string
+	// resets is the time until which each token is exhausted.
+	resets []time.Time
+	next   int
+}
+
+// Ensure tokenTransport implements the http.RoundTripper interface.
+var _ http.RoundTripper = (*tokenTransport)(nil)
+
+// newTokenTransport creates a tokenTransport of the tokens.
+func newTokenTransport(base http.RoundTripper, tokens []string) *tokenTransport {
+	return &tokenTransport{
+		base:   base,
+		now:    time.Now,
+		tokens: tokens,
+		resets: make([]time.Time, len(tokens)),
+	}
+}
+
+// RoundTrip executes a single HTTP transaction with a copy of the request
+// authenticated with the next token and records the rate limit of the token
+// reported by the response.
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := t.pick()
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.tokens[i])
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.observe(i, resp)
+	}
+	return resp, err
+}
+
+// pick returns the index of the next token whose rate limit is not exhausted.
+// If all are exhausted, the token whose rate limit resets first is returned.
+func (t *tokenTransport) pick() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	first := -1
+	for k := range len(t.tokens) {
+		i := (t.next + k) % len(t.tokens)
+		if !t.resets[i].After(now) {
+			first = i
+			break
+		}
+		if first < 0 || t.resets[i].Before(t.resets[first]) {
+			first = i
+		}
+	}
+	t.next = (first + 1) % len(t.tokens)
+	return first
+}
+
+// observe records the token of index i as exhausted until its rate limit
+// resets, if the response reports no remaining requests.
+func (t *tokenTransport) observe(i int, resp *http.Response) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resets[i] = time.Unix(reset, 0)
+}
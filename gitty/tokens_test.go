@@ -0,0 +1,98 @@
+package gitty
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenServer records the tokens of the requests it serves and reports the
+// rate limit of the tokens in exhausted as exhausted until reset.
+func tokenServer(t *testing.T, exhausted map[string]bool, reset time.Time) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		mu.Lock()
+		seen = append(seen, token)
+		remaining := "10"
+		if exhausted[token] {
+			remaining = "0"
+		}
+		mu.Unlock()
+		w.Header().Set("X-RateLimit-Remaining", remaining)
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return seen
+	}
+}
+
+func TestTokenTransport(t *testing.T) {
+	t.Parallel()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	reset := clock.Add(time.Hour)
+	srv, seen := tokenServer(t, map[string]bool{"b": true}, reset)
+	tt := newTokenTransport(http.DefaultTransport, []string{"a", "b", "c"})
+	tt.now = func() time.Time { return clock }
+	c := &http.Client{Transport: tt}
+	get := func() {
+		resp, err := c.Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// The tokens rotate, and b is skipped once it is reported exhausted.
+	for range 5 {
+		get()
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a", "c"}, seen())
+
+	// b is used again once its rate limit resets.
+	clock = reset
+	get()
+	get()
+	assert.Equal(t, []string{"a", "b", "c", "a", "c", "a", "b"}, seen())
+}
+
+func TestTokenTransportExhausted(t *testing.T) {
+	t.Parallel()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt := newTokenTransport(http.DefaultTransport, []string{"a", "b", "c"})
+	tt.now = func() time.Time { return clock }
+	tt.resets = []time.Time{clock.Add(3 * time.Hour), clock.Add(time.Hour), clock.Add(2 * time.Hour)}
+
+	// All tokens are exhausted, so the token that resets first is used.
+	assert.Equal(t, 1, tt.pick())
+	assert.Equal(t, 1, tt.pick())
+
+	// An unparsable reset keeps the token usable.
+	tt.resets[0] = time.Time{}
+	resp := &http.Response{Header: http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"soon"}}}
+	tt.observe(0, resp)
+	assert.Equal(t, 0, tt.pick())
+}
+
+func TestNewClientTokens(t *testing.T) {
+	t.Parallel()
+	srv, seen := tokenServer(t, nil, time.Now())
+	c := newClient(options{transport: http.DefaultTransport, token: "single", tokens: []string{"a", "b"}})
+	for range 3 {
+		resp, err := c.Client().Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, []string{"a", "b", "a"}, seen())
+}
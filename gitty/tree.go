@@ -0,0 +1,239 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v70/github"
+)
+
+const (
+	// defaultRawURL represents the base URL of raw file downloads.
+	defaultRawURL = "https://raw.githubusercontent.com/"
+	// headRef represents the ref of the default branch.
+	headRef = "HEAD"
+	// symlinkMode represents the file mode of a symlink in a tree.
+	symlinkMode = "120000"
+)
+
+// treeCache represents the recursive trees of repositories, keyed by owner,
+// repository and ref.
+type treeCache struct {
+	mu    sync.Mutex
+	trees map[string]*github.Tree
+}
+
+// newTreeCache creates an empty tree cache.
+func newTreeCache() *treeCache {
+	return &treeCache{
+		trees: make(map[string]*github.Tree),
+	}
+}
+
+// tree returns the recursive tree of the repository at the ref. If the tree
+// cache is enabled, the tree is fetched once and served from the cache
+// afterwards.
+func (g *GitHub) tree(ctx context.Context) (*github.Tree, error) {
+	ref := g.ref()
+	if ref == "" {
+		ref = headRef
+	}
+	if g.trees == nil {
+		return g.getTree(ctx, ref)
+	}
+	key := strings.Join([]string{g.Owner, g.Repo, ref}, "/")
+
+	g.trees.mu.Lock()
+	defer g.trees.mu.Unlock()
+
+	if tree, ok := g.trees.trees[key]; ok {
+		return tree, nil
+	}
+
+	tree, err := g.getTree(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	g.trees.trees[key] = tree
+
+	return tree, nil
+}
+
+// getTree fetches the recursive tree of the repository at the ref.
+func (g *GitHub) getTree(ctx context.Context, ref string) (*github.Tree, error) {
+	tree, _, err := g.Client.GetTree(ctx, g.Owner, g.Repo, ref, true)
+	if err != nil {
+		return nil, err
+	}
+	logger(g.opts).Info("fetched tree",
+		"owner", g.Owner,
+		"repo", g.Repo,
+		"ref", ref,
+		"entries", len(tree.Entries),
+		"truncated", tree.GetTruncated())
+
+	return tree, nil
+}
+
+// listTree returns all files and symlinks beneath the GitHub path from the
+// cached repository tree. If the tree is truncated, the GitHub path is walked
+// instead.
+func (g *GitHub) listTree(ctx context.Context, path string) ([]*github.RepositoryContent, error) {
+	tree, err := g.tree(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", g.notFound(ctx, g.Owner, g.Repo, g.ref(), err))
+	}
+	if tree.GetTruncated() {
+		g.warnings.add(WarnTreeTruncated, path, "Walking truncated tree")
+		return g.walk(ctx, path)
+	}
+
+	files := g.treeFiles(tree, func(p string) bool {
+		return underPath(path, p)
+	})
+	// A directory without files, such as one with only submodules, is
+	// downloaded without any file.
+	if len(files) == 0 && !treeDir(tree, path) {
+		return nil, fmt.Errorf("failed to download: %w: %s", ErrPathNotFound, path)
+	}
+
+	return files, nil
+}
+
+// listPrefix returns all files and symlinks whose repository path starts with
+// the prefix, such as both config/ and config.yaml for config. If the tree is
+// truncated, the parent directory of the prefix is walked instead.
+func (g *GitHub) listPrefix(ctx context.Context, prefix string) ([]*github.RepositoryContent, error) {
+	match := func(p string) bool {
+		return strings.HasPrefix(p, prefix)
+	}
+
+	tree, err := g.tree(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", g.notFound(ctx, g.Owner, g.Repo, g.ref(), err))
+	}
+
+	var files []*github.RepositoryContent
+	if tree.GetTruncated() {
+		parent := path.Dir(prefix)
+		if parent == "." {
+			parent = ""
+		}
+		g.warnings.add(WarnTreeTruncated, parent, "Walking truncated tree")
+		entries, err := g.walk(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if match(entry.GetPath()) {
+				files = append(files, entry)
+			}
+		}
+	} else {
+		files = g.treeFiles(tree, match)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("failed to download: %w: %s", ErrPathNotFound, prefix)
+	}
+
+	return files, nil
+}
+
+// treeFiles returns the files and symlinks of the tree whose repository path
+// matches.
+func (g *GitHub) treeFiles(tree *github.Tree, match func(path string) bool) []*github.RepositoryContent {
+	var files []*github.RepositoryContent
+	for _, entry := range tree.Entries {
+		// Trees and submodules have no content to download.
+		if entry.GetType() != "blob" || !match(entry.GetPath()) {
+			if entry.GetType() == "commit" && match(entry.GetPath()) {
+				g.warnings.add(WarnSubmoduleSkipped, entry.GetPath(), "Skipping submodule")
+			}
+			continue
+		}
+
+		typ := "file"
+		if entry.GetMode() == symlinkMode {
+			typ = "symlink"
+		} else {
+			g.addMode(entry)
+		}
+		files = append(files, &github.RepositoryContent{
+			Type:        github.Ptr(typ),
+			Path:        entry.Path,
+			Size:        entry.Size,
+			SHA:         entry.SHA,
+			DownloadURL: github.Ptr(g.rawURL(entry.GetPath())),
+		})
+	}
+
+	return files
+}
+
+// treeDir reports whether the repository path is the root or a directory or
+// submodule of the tree.
+func treeDir(tree *github.Tree, path string) bool {
+	if path == "" {
+		return true
+	}
+	for _, entry := range tree.Entries {
+		if entry.GetPath() == path && (entry.GetType() == "tree" || entry.GetType() == "commit") {
+			return true
+		}
+	}
+	return false
+}
+
+// rawURL returns the raw download URL of the file at the repository path.
+func (g *GitHub) rawURL(path string) string {
+	ref := g.ref()
+	if ref == "" {
+		ref = headRef
+	}
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	return g.opts.rawURL + strings.Join([]string{g.Owner, g.Repo, ref, strings.Join(segments, "/")}, "/")
+}
+
+// addMode records the file mode of the blob entry of the tree.
+func (g *GitHub) addMode(entry *github.TreeEntry) {
+	if mode, err := strconv.ParseUint(entry.GetMode(), 8, 32); err == nil && g.modes != nil {
+		g.modes[entry.GetPath()] = os.FileMode(mode).Perm()
+	}
+}
+
+// treeModes records the file modes of the files beneath the repository path
+// from the repository tree, as the contents API lists no file modes. If the
+// tree cannot be fetched, the files are saved non-executable.
+func (g *GitHub) treeModes(ctx context.Context, path string) {
+	tree, err := g.tree(ctx)
+	if err != nil {
+		logger(g.opts).Warn("failed to fetch file modes", "path", path, "error", err)
+		return
+	}
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" && entry.GetMode() != symlinkMode && underPath(path, entry.GetPath()) {
+			g.addMode(entry)
+		}
+	}
+}
+
+// fileMode returns the file mode of the file at the repository path, as
+// listed in the repository tree, such as 0o755 for 100755. Files without a
+// mode in the tree are saved non-executable.
+func (g *GitHub) fileMode(path string) os.FileMode {
+	if mode, ok := g.modes[path]; ok {
+		return mode
+	}
+	return 0o600
+}
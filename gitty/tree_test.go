@@ -0,0 +1,446 @@
+package gitty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// treeMux serves the recursive tree and raw downloads of the given files,
+// keyed by repository path, and counts the tree requests.
+func treeMux(files map[string]string, links map[string]string, requests *atomic.Int32) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Get("recursive") == "" {
+			http.Error(w, "want recursive tree", http.StatusBadRequest)
+			return
+		}
+		dirs := map[string]bool{}
+		entries := []*github.TreeEntry{{Type: ptr("commit"), Mode: ptr("160000"), Path: ptr("submodule")}}
+		add := func(path, mode string, size int) {
+			for dir := path; strings.Contains(dir, "/"); {
+				dir = dir[:strings.LastIndex(dir, "/")]
+				if !dirs[dir] {
+					dirs[dir] = true
+					entries = append(entries, &github.TreeEntry{Type: ptr("tree"), Mode: ptr("040000"), Path: ptr(dir)})
+				}
+			}
+			entries = append(entries, &github.TreeEntry{Type: ptr("blob"), Mode: ptr(mode), Path: ptr(path), Size: ptr(size), SHA: ptr("sha-" + path)})
+		}
+		for path, content := range files {
+			add(path, "100644", len(content))
+		}
+		for path, target := range links {
+			add(path, symlinkMode, len(target))
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].GetPath() < entries[j].GetPath()
+		})
+		_ = json.NewEncoder(w).Encode(&github.Tree{SHA: ptr(r.PathValue("sha")), Entries: entries})
+	})
+	mux.HandleFunc("GET /raw/owner/repo/main/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.PathValue("path")]
+		if !ok {
+			content, ok = links[r.PathValue("path")]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unexpected request "+r.URL.Path, http.StatusInternalServerError)
+	})
+	return mux
+}
+
+// treeRepository creates a GitHub repository that lists the contents from the
+// cached trees served by the handler.
+func treeRepository(t *testing.T, handler http.Handler) *GitHub {
+	t.Helper()
+	r := serverRepository(t, handler)
+	r.trees = newTreeCache()
+	r.opts.rawURL = r.Client.(*service).client.BaseURL.String() + "raw/"
+	return r
+}
+
+func TestListTreeCache(t *testing.T) {
+	t.Parallel()
+	docs := fmt.Sprintf("docs_%d", gofakeit.Int())
+	src := fmt.Sprintf("src_%d", gofakeit.Int())
+	t.Cleanup(func() {
+		for _, dir := range []string{docs, src} {
+			err := os.RemoveAll(dir)
+			require.NoError(t, err)
+		}
+	})
+	files := map[string]string{
+		"repo/" + docs + "/guide.md":   "guide",
+		"repo/" + docs + "/sub/api.md": "api",
+		"repo/" + src + "/main.go":     "package main",
+		"other/file.txt":               "not requested",
+	}
+	links := map[string]string{
+		"repo/" + docs + "/link.md": "guide.md",
+	}
+
+	var requests atomic.Int32
+	g := fakeNew(treeRepository(t, treeMux(files, links, &requests)))
+	for _, dir := range []string{docs, src} {
+		err := g.Download(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+dir)
+		require.NoError(t, err)
+	}
+
+	for path, content := range map[string]string{
+		docs + "/guide.md":   "guide",
+		docs + "/sub/api.md": "api",
+		docs + "/link.md":    "guide",
+		src + "/main.go":     "package main",
+	} {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	assert.Equal(t, int32(1), requests.Load(), "want the tree fetched once")
+}
+
+func TestListTree(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	r := treeRepository(t, treeMux(map[string]string{"dir/a.txt": "a", "dir/sub/b.txt": "b"}, nil, &requests))
+	r.Owner, r.Repo, r.Ref = "owner", "repo", &github.RepositoryContentGetOptions{Ref: "main"}
+
+	files, err := r.listTree(context.Background(), "dir")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "dir/a.txt", files[0].GetPath())
+	assert.Equal(t, "file", files[0].GetType())
+	assert.Equal(t, 1, files[0].GetSize())
+	assert.Equal(t, "sha-dir/a.txt", files[0].GetSHA())
+	assert.True(t, strings.HasSuffix(files[0].GetDownloadURL(), "/raw/owner/repo/main/dir/a.txt"))
+
+	files, err = r.listTree(context.Background(), "dir/sub/b.txt")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "dir/sub/b.txt", files[0].GetPath())
+
+	_, err = r.listTree(context.Background(), "missing")
+	require.ErrorIs(t, err, ErrPathNotFound)
+	r.warnings = &warnings{}
+	files, err = r.listTree(context.Background(), "submodule")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Equal(t, []Warning{{Code: WarnSubmoduleSkipped, Path: "submodule", Message: "Skipping submodule"}}, r.warnings.all())
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestListTreeTruncated(t *testing.T) {
+	t.Parallel()
+	contents := contentsMux(map[string]string{"dir/a.txt": "a"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"sha":"main","truncated":true,"tree":[]}`)
+	})
+	mux.Handle("/", contents)
+	r := treeRepository(t, mux)
+	r.Owner, r.Repo = "owner", "repo"
+
+	files, err := r.listTree(context.Background(), "dir")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "dir/a.txt", files[0].GetPath())
+}
+
+func TestDownloadTreeTruncated(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	files := map[string]string{
+		"dir/a.txt":         "a",
+		"dir/sub/b.txt":     "b",
+		"dir/sub/c.txt":     "c",
+		"dir/sub/deep/d.go": "d",
+		"dir/other/e.md":    "e",
+	}
+	contents := contentsMux(files)
+	var listed sync.Map
+	mux := http.NewServeMux()
+	// The truncated tree silently omits most of the entries.
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"sha":"main","truncated":true,"tree":[{"path":"dir/a.txt","mode":"100644","type":"blob","size":1}]}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		listed.Store(r.PathValue("path"), true)
+		contents.ServeHTTP(w, r)
+	})
+	mux.Handle("/", contents)
+	r := treeRepository(t, mux)
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+
+	result, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	var paths []string
+	for _, file := range result.Manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{"dir/a.txt", "dir/other/e.md", "dir/sub/b.txt", "dir/sub/c.txt", "dir/sub/deep/d.go"}, paths)
+	assert.Equal(t, []Warning{{Code: WarnTreeTruncated, Path: "dir", Message: "Walking truncated tree"}}, result.Warnings)
+	for path, content := range files {
+		b, err := os.ReadFile(filepath.Join(base, path))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(b))
+	}
+	// Each directory is listed on its own.
+	for _, dir := range []string{"dir", "dir/sub", "dir/sub/deep", "dir/other"} {
+		_, ok := listed.Load(dir)
+		assert.True(t, ok, dir)
+	}
+}
+
+func TestListTreeError(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockError{}, trees: newTreeCache()}
+	_, err := r.listTree(context.Background(), "dir")
+	require.ErrorIs(t, err, errMockTree)
+}
+
+func TestListPrefix(t *testing.T) {
+	t.Parallel()
+	config := fmt.Sprintf("config_%d", gofakeit.Int())
+	t.Cleanup(func() {
+		for _, path := range []string{config, config + ".yaml"} {
+			err := os.RemoveAll(path)
+			require.NoError(t, err)
+		}
+	})
+	files := map[string]string{
+		"repo/" + config + "/app.yaml": "app",
+		"repo/" + config + ".yaml":     "root",
+		"repo/other.yaml":              "not requested",
+	}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected []string
+	}{
+		{name: "disabled", expected: []string{"repo/" + config + "/app.yaml"}},
+		{name: "enabled", enabled: true, expected: []string{"repo/" + config + "/app.yaml", "repo/" + config + ".yaml"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			r := treeRepository(t, treeMux(files, nil, &requests))
+			r.opts.prefixMatch = test.enabled
+			g := fakeNew(r)
+
+			manifest, err := g.DownloadManifest(context.Background(), "https://github.com/owner/repo/tree/main/repo/"+config)
+			require.NoError(t, err)
+
+			var paths []string
+			for _, entry := range manifest.Files {
+				paths = append(paths, entry.Path)
+				b, err := os.ReadFile(strings.TrimPrefix(entry.Path, "repo/"))
+				require.NoError(t, err)
+				assert.Equal(t, files[entry.Path], string(b))
+			}
+			assert.ElementsMatch(t, test.expected, paths)
+			for _, path := range []string{config, config + ".yaml"} {
+				err := os.RemoveAll(path)
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestListPrefixTruncated(t *testing.T) {
+	t.Parallel()
+	contents := contentsMux(map[string]string{"dir/app/a.yaml": "a", "dir/app.yaml": "b", "dir/other.yaml": "c"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"sha":"main","truncated":true,"tree":[]}`)
+	})
+	mux.Handle("/", contents)
+	r := serverRepository(t, mux)
+	r.Owner, r.Repo = "owner", "repo"
+
+	files, err := r.listPrefix(context.Background(), "dir/app")
+	require.NoError(t, err)
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.GetPath())
+	}
+	assert.ElementsMatch(t, []string{"dir/app/a.yaml", "dir/app.yaml"}, paths)
+
+	_, err = r.listPrefix(context.Background(), "dir/missing")
+	require.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestListPrefixError(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockError{}}
+	_, err := r.listPrefix(context.Background(), "dir")
+	require.ErrorIs(t, err, errMockTree)
+}
+
+func TestRawURL(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Owner: "owner", Repo: "repo", opts: newOptions()}
+	assert.Equal(t, "https://raw.githubusercontent.com/owner/repo/HEAD/dir/a%20b.txt", r.rawURL("dir/a b.txt"))
+
+	r.Ref = &github.RepositoryContentGetOptions{Ref: "v1.0.0"}
+	assert.Equal(t, "https://raw.githubusercontent.com/owner/repo/v1.0.0/a.txt", r.rawURL("a.txt"))
+}
+
+func TestDownloadTreeModes(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits are not supported on windows")
+	}
+	files := map[string]string{"dir/run.sh": "#!/bin/sh", "dir/notes.txt": "notes"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"sha":"main","tree":[`+
+			`{"type":"blob","mode":"100755","path":"dir/run.sh","size":9},`+
+			`{"type":"blob","mode":"100644","path":"dir/notes.txt","size":5}]}`)
+	})
+	mux.Handle("GET /raw/owner/repo/main/", http.StripPrefix("/raw/owner/repo/main/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, files[r.URL.Path])
+	})))
+	r := treeRepository(t, mux)
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	base := t.TempDir()
+
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	for path, exec := range map[string]bool{"dir/run.sh": true, "dir/notes.txt": false} {
+		info, err := os.Stat(filepath.Join(base, path))
+		require.NoError(t, err)
+		assert.Equal(t, exec, info.Mode()&0o111 != 0, path)
+	}
+}
+
+func TestDownloadContentsModes(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits are not supported on windows")
+	}
+	mux := contentsMux(map[string]string{"dir/run.sh": "#!/bin/sh", "dir/notes.txt": "notes"})
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"sha":"main","tree":[`+
+			`{"type":"blob","mode":"100755","path":"dir/run.sh","size":9},`+
+			`{"type":"blob","mode":"100644","path":"dir/notes.txt","size":5}]}`)
+	})
+	// The files are listed from the contents API, without a tree cache.
+	r := serverRepository(t, mux)
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+	base := t.TempDir()
+
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	for path, exec := range map[string]bool{"dir/run.sh": true, "dir/notes.txt": false} {
+		info, err := os.Stat(filepath.Join(base, path))
+		require.NoError(t, err)
+		assert.Equal(t, exec, info.Mode()&0o111 != 0, path)
+	}
+}
+
+// submoduleMux serves dir/a.txt next to the submodule dir/lib, and the
+// directory vendor whose only child is the submodule vendor/lib, from both
+// the contents API and the recursive tree.
+func submoduleMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	submodule := func(r *http.Request, path string) *github.RepositoryContent {
+		return &github.RepositoryContent{
+			Type:   ptr("file"),
+			Path:   ptr(path),
+			GitURL: ptr("http://" + r.Host + "/repos/other/lib/git/trees/a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"),
+		}
+	}
+	mux.HandleFunc("GET /repos/owner/repo/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		var entries []*github.RepositoryContent
+		switch r.PathValue("path") {
+		case "dir":
+			entries = []*github.RepositoryContent{fileContent("http://"+r.Host+"/raw/owner/repo/main/", "dir/a.txt", "a"), submodule(r, "dir/lib")}
+		case "vendor":
+			entries = []*github.RepositoryContent{submodule(r, "vendor/lib")}
+		case "vendor/lib":
+			_ = json.NewEncoder(w).Encode(&github.RepositoryContent{Type: ptr("submodule"), Path: ptr("vendor/lib")})
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(entries)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.Tree{Entries: []*github.TreeEntry{
+			{Type: ptr("tree"), Mode: ptr("040000"), Path: ptr("dir")},
+			{Type: ptr("blob"), Mode: ptr("100644"), Path: ptr("dir/a.txt"), Size: ptr(1)},
+			{Type: ptr("commit"), Mode: ptr("160000"), Path: ptr("dir/lib")},
+			{Type: ptr("tree"), Mode: ptr("040000"), Path: ptr("vendor")},
+			{Type: ptr("commit"), Mode: ptr("160000"), Path: ptr("vendor/lib")},
+		}})
+	})
+	mux.HandleFunc("GET /raw/owner/repo/main/dir/a.txt", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "a")
+	})
+	return mux
+}
+
+func TestDownloadSubmodules(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		path     string
+		tree     bool
+		expected []string
+		skipped  string
+	}{
+		{name: "directory", path: "dir", expected: []string{"dir/a.txt"}, skipped: "dir/lib"},
+		{name: "only submodule", path: "vendor", skipped: "vendor/lib"},
+		{name: "submodule", path: "vendor/lib", skipped: "vendor/lib"},
+		{name: "tree directory", path: "dir", tree: true, expected: []string{"dir/a.txt"}, skipped: "dir/lib"},
+		{name: "tree only submodule", path: "vendor", tree: true, skipped: "vendor/lib"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var r *GitHub
+			if test.tree {
+				r = treeRepository(t, submoduleMux())
+			} else {
+				r = serverRepository(t, submoduleMux())
+			}
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/"+test.path))
+			base := t.TempDir()
+
+			result, err := r.downloadTo(context.Background(), base)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, savedFiles(t, base))
+			assert.Equal(t, []Warning{{Code: WarnSubmoduleSkipped, Path: test.skipped, Message: "Skipping submodule"}}, result.Warnings)
+			assert.Equal(t, 1, result.Summary.Skipped)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package gitty
+
+import (
+	"crypto/sha1" //nolint:gosec // Git blob SHAs are SHA-1 digests.
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// BlobMismatchError is returned for a downloaded file whose content does not
+// hash to the Git blob SHA listed for it, such as a truncated or corrupted
+// download. The file is not saved. It wraps ErrChecksumMismatch.
+type BlobMismatchError struct {
+	// Path is the repository path of the file.
+	Path string
+	// Expected is the blob SHA listed for the file.
+	Expected string
+	// Actual is the blob SHA of the downloaded content. It is empty if the
+	// size of the content differs from the listed size, as the blob SHA
+	// hashes the size before the content.
+	Actual string
+}
+
+// Error returns the message of the error along with the path and both SHAs.
+func (e *BlobMismatchError) Error() string {
+	if e.Actual == "" {
+		return fmt.Sprintf("%s: %s: size differs from blob %s", ErrChecksumMismatch, e.Path, e.Expected)
+	}
+	return fmt.Sprintf("%s: %s: blob %s, want %s", ErrChecksumMismatch, e.Path, e.Actual, e.Expected)
+}
+
+// Unwrap returns ErrChecksumMismatch.
+func (e *BlobMismatchError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// blobs collects the files to download whose content is verified against
+// their blob SHAs, keyed by repository path. It is safe for concurrent use.
+type blobs struct {
+	mu    sync.Mutex
+	files map[string]*github.RepositoryContent
+}
+
+// add records the files to verify, if b is not nil. Files listed without a
+// SHA are not verified.
+func (b *blobs) add(files []*github.RepositoryContent) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, file := range files {
+		if file.GetSHA() != "" {
+			b.files[file.GetPath()] = file
+		}
+	}
+}
+
+// verify returns a reader of the body that fails with a BlobMismatchError
+// once the body is read to the end, if the content of the file at the
+// repository path does not hash to its blob SHA. A nil blobs, or a file not
+// recorded, returns the body unchanged.
+func (b *blobs) verify(path string, body io.Reader) io.Reader {
+	if b == nil {
+		return body
+	}
+	b.mu.Lock()
+	file, ok := b.files[path]
+	b.mu.Unlock()
+	if !ok {
+		return body
+	}
+
+	// The blob SHA hashes a header with the size of the content first, so
+	// the listed size is hashed and the size read is compared to it.
+	h := sha1.New() //nolint:gosec // Git blob SHAs are SHA-1 digests.
+	fmt.Fprintf(h, "blob %d\x00", file.GetSize())
+	return &blobReader{path: path, sha: file.GetSHA(), size: int64(file.GetSize()), r: body, h: h}
+}
+
+// blobReader represents a reader that verifies the blob SHA of the content it
+// reads.
+type blobReader struct {
+	path string
+	sha  string
+	size int64
+	n    int64
+	r    io.Reader
+	h    hash.Hash
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+	if err != io.EOF {
+		return n, err
+	}
+	if r.n != r.size {
+		return n, &BlobMismatchError{Path: r.path, Expected: r.sha}
+	}
+	if sum := hex.EncodeToString(r.h.Sum(nil)); sum != r.sha {
+		return n, &BlobMismatchError{Path: r.path, Expected: r.sha, Actual: sum}
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package gitty
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // Git blob SHAs are SHA-1 digests.
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blobSHA returns the Git blob SHA of the content.
+func blobSHA(content string) string {
+	h := sha1.New() //nolint:gosec // Git blob SHAs are SHA-1 digests.
+	fmt.Fprintf(h, "blob %d\x00%s", len(content), content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestBlobsVerifyEmpty(t *testing.T) {
+	t.Parallel()
+	// The SHA of the empty blob, as printed by git hash-object /dev/null.
+	empty := &github.RepositoryContent{Path: ptr("empty.txt"), SHA: ptr("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"), Size: ptr(0)}
+	b := &blobs{files: make(map[string]*github.RepositoryContent)}
+	b.add([]*github.RepositoryContent{empty})
+
+	_, err := io.ReadAll(b.verify("empty.txt", strings.NewReader("")))
+	require.NoError(t, err)
+	assert.Equal(t, empty.GetSHA(), blobSHA(""))
+}
+
+func TestDownloadVerify(t *testing.T) {
+	t.Parallel()
+	content := "package main"
+	tests := []struct {
+		name    string
+		body    string
+		verify  bool
+		invalid bool
+		actual  string
+	}{
+		{name: "valid", body: content, verify: true},
+		{name: "corrupted", body: "package mian", verify: true, invalid: true, actual: blobSHA("package mian")},
+		{name: "truncated", body: "package", verify: true, invalid: true},
+		{name: "disabled", body: "package mian"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /repos/owner/repo/git/trees/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprintf(w, `{"sha":"main","tree":[{"type":"blob","mode":"100644","path":"dir/main.go","size":%d,"sha":%q}]}`, len(content), blobSHA(content))
+			})
+			mux.HandleFunc("GET /raw/owner/repo/main/dir/main.go", func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, test.body)
+			})
+			r := treeRepository(t, mux)
+			r.opts.verify = test.verify
+			require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/dir"))
+			base := t.TempDir()
+
+			_, err := r.downloadTo(context.Background(), base)
+			if !test.invalid {
+				require.NoError(t, err)
+				b, err := os.ReadFile(filepath.Join(base, "dir", "main.go"))
+				require.NoError(t, err)
+				assert.Equal(t, test.body, string(b))
+				return
+			}
+
+			require.ErrorIs(t, err, ErrChecksumMismatch)
+			var mismatch *BlobMismatchError
+			require.ErrorAs(t, err, &mismatch)
+			assert.Equal(t, "dir/main.go", mismatch.Path)
+			assert.Equal(t, blobSHA(content), mismatch.Expected)
+			assert.Equal(t, test.actual, mismatch.Actual)
+			assert.Contains(t, mismatch.Error(), mismatch.Expected)
+			_, err = os.Stat(filepath.Join(base, "dir", "main.go"))
+			require.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+}
+
+func TestBlobsVerify(t *testing.T) {
+	t.Parallel()
+	var b *blobs
+	body := strings.NewReader("content")
+	assert.Equal(t, body, b.verify("file.txt", body))
+	b.add([]*github.RepositoryContent{{Path: ptr("file.txt"), SHA: ptr("sha")}})
+
+	b = &blobs{files: make(map[string]*github.RepositoryContent)}
+	b.add([]*github.RepositoryContent{{Path: ptr("unlisted.txt")}})
+	assert.Equal(t, body, b.verify("unlisted.txt", body))
+}
@@ -0,0 +1,66 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+const (
+	// xattrURL represents the extended attribute the source URL of a
+	// downloaded file is written to.
+	xattrURL = "user.gitty.url"
+	// xattrCommit represents the extended attribute the commit SHA of a
+	// downloaded file is written to.
+	xattrCommit = "user.gitty.commit"
+)
+
+// errXattrUnsupported is returned by setXattr if the filesystem or the os does
+// not support extended attributes.
+var errXattrUnsupported = errors.New("extended attributes are unsupported")
+
+// writeXattrs tags the downloaded files with extended attributes recording
+// their source URL and the commit SHA the ref resolved to. If the commit is
+// empty, it is resolved. Nothing is written if the filesystem does not support
+// extended attributes.
+func (g *GitHub) writeXattrs(ctx context.Context, commit string, files []*github.RepositoryContent) error {
+	if commit == "" {
+		var err error
+		if commit, err = g.resolveCommit(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		// Symlinks cannot hold user extended attributes.
+		if file.GetType() == "symlink" {
+			continue
+		}
+		p, err := localPath(g.opts, g.Path, file.GetPath())
+		if err != nil {
+			return err
+		}
+
+		source := "https://" + webHost(g.opts) + "/" + strings.Join([]string{g.Owner, g.Repo, blobKind, commit, file.GetPath()}, "/")
+		err = setXattr(p, xattrURL, source)
+		// A file saved under another name, such as renamed by a recipe, is
+		// not tagged.
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if errors.Is(err, errXattrUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := setXattr(p, xattrCommit, commit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
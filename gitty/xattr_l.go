@@ -0,0 +1,22 @@
+//go:build linux
+
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// setXattr sets the extended attribute of the file at the path to value.
+func setXattr(path, name, value string) error {
+	err := syscall.Setxattr(path, name, []byte(value), 0)
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP) {
+		return fmt.Errorf("%w: %w", errXattrUnsupported, err)
+	}
+	if err != nil {
+		return &fs.PathError{Op: "setxattr", Path: path, Err: err}
+	}
+	return nil
+}
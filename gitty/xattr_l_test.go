@@ -0,0 +1,59 @@
+//go:build linux
+
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getXattr returns the extended attribute of the file at the path.
+func getXattr(t *testing.T, path, name string) string {
+	t.Helper()
+	buf := make([]byte, 256)
+	n, err := syscall.Getxattr(path, name, buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestDownloadXattrProvenance(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	if err := setXattr(base, xattrCommit, "probe"); errors.Is(err, errXattrUnsupported) {
+		t.Skip("extended attributes are unsupported:", err)
+	}
+	sha := "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	dir := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	mux := contentsMux(map[string]string{dir + "/a.txt": "a", dir + "/sub/b.txt": "b"})
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, sha)
+	})
+	r := serverRepository(t, mux)
+	r.opts.xattrProvenance = true
+	require.NoError(t, r.extract("https://github.com/owner/repo/tree/main/"+dir))
+
+	_, err := r.downloadTo(context.Background(), base)
+	require.NoError(t, err)
+
+	for _, path := range []string{dir + "/a.txt", dir + "/sub/b.txt"} {
+		p := filepath.Join(base, path)
+		assert.Equal(t, "https://github.com/owner/repo/blob/"+sha+"/"+path, getXattr(t, p, xattrURL))
+		assert.Equal(t, sha, getXattr(t, p, xattrCommit))
+	}
+}
+
+func TestSetXattr(t *testing.T) {
+	t.Parallel()
+	err := setXattr(filepath.Join(t.TempDir(), "missing.txt"), xattrURL, "url")
+	require.ErrorIs(t, err, syscall.ENOENT)
+	assert.Contains(t, err.Error(), "missing.txt")
+}
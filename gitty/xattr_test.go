@@ -0,0 +1,21 @@
+package gitty
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteXattrs(t *testing.T) {
+	t.Parallel()
+	r := &GitHub{Client: &mockError{}, opts: options{}}
+	err := r.writeXattrs(context.Background(), "", nil)
+	require.ErrorIs(t, err, errMockCommitSHA1)
+
+	// Missing files are skipped.
+	r.Path = "dir"
+	files := []*github.RepositoryContent{{Type: github.Ptr("file"), Path: github.Ptr("dir/missing.txt")}}
+	require.NoError(t, r.writeXattrs(context.Background(), "a1b2c3d", files))
+}
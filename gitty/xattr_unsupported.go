@@ -0,0 +1,13 @@
+//go:build !linux
+
+package gitty
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// setXattr returns errXattrUnsupported for unsupported os.
+func setXattr(_, _, _ string) error {
+	return fmt.Errorf("%w on %v", errXattrUnsupported, runtime.GOOS)
+}